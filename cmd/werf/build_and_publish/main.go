@@ -92,6 +92,14 @@ If one or more IMAGE_NAME parameters specified, werf will build images stages an
 
 	common.SetupIntrospectStage(&CommonCmdData, cmd)
 
+	common.SetupParallelOptions(&CommonCmdData, cmd)
+	common.SetupDockerfileBuilderOptions(&CommonCmdData, cmd)
+
+	common.SetupSignImages(&CommonCmdData, cmd)
+	common.SetupProvenancePath(&CommonCmdData, cmd)
+	common.SetupPushProvenance(&CommonCmdData, cmd)
+	common.SetupPolicyMode(&CommonCmdData, cmd)
+
 	cmd.Flags().BoolVarP(&CmdData.IntrospectAfterError, "introspect-error", "", false, "Introspect failed stage in the state, right after running failed assembly instruction")
 	cmd.Flags().BoolVarP(&CmdData.IntrospectBeforeError, "introspect-before-error", "", false, "Introspect failed stage in the clean state, before running all assembly instructions of the stage")
 
@@ -185,23 +193,49 @@ func runBuildAndPublish(imagesToProcess []string) error {
 		return err
 	}
 
+	parallelOptions, err := common.GetParallelOptions(&CommonCmdData, projectDir, introspectOptions)
+	if err != nil {
+		return err
+	}
+
+	dockerfileBuilderOptions, err := common.GetDockerfileBuilderOptions(&CommonCmdData)
+	if err != nil {
+		return err
+	}
+
+	policyOptions, err := common.GetPolicyOptions(&CommonCmdData)
+	if err != nil {
+		return err
+	}
+
+	signingKey, err := common.GetSigningKey(&CommonCmdData, projectDir)
+	if err != nil {
+		return err
+	}
+
 	opts := build.BuildAndPublishOptions{
 		BuildStagesOptions: build.BuildStagesOptions{
 			ImageBuildOptions: image.BuildOptions{
 				IntrospectAfterError:  CmdData.IntrospectAfterError,
 				IntrospectBeforeError: CmdData.IntrospectBeforeError,
 			},
-			IntrospectOptions: introspectOptions,
+			IntrospectOptions:        introspectOptions,
+			ParallelOptions:          parallelOptions,
+			DockerfileBuilderOptions: dockerfileBuilderOptions,
+			PolicyOptions:            policyOptions,
 		},
 		PublishImagesOptions: build.PublishImagesOptions{
-			TagOptions: tagOpts,
+			TagOptions:        tagOpts,
+			ProvenanceOptions: common.GetProvenanceOptions(&CommonCmdData),
+			SigningKey:        signingKey,
+			PushProvenance:    *CommonCmdData.PushProvenance,
 		},
 	}
 
-	c := build.NewConveyor(werfConfig, imagesToProcess, projectDir, projectTmpDir, ssh_agent.SSHAuthSock)
+	c := build.NewConveyor(werfConfig, imagesToProcess, projectDir, projectTmpDir, ssh_agent.SSHAuthSock, stagesRepo, build.ConveyorOptions{})
 	defer c.Terminate()
 
-	if err = c.BuildAndPublish(stagesRepo, imagesRepoManager, opts); err != nil {
+	if err = c.BuildAndPublish(imagesRepoManager, opts); err != nil {
 		return err
 	}
 
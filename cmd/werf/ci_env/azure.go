@@ -0,0 +1,61 @@
+package ci_env
+
+import (
+	"fmt"
+	"strings"
+)
+
+type azureProvider struct {
+	getenv getenvFunc
+}
+
+func (p *azureProvider) ImagesRepo() string {
+	return ""
+}
+
+func (p *azureProvider) DockerLogin() (string, string, string, bool) {
+	return "", "", "", false
+}
+
+func (p *azureProvider) GitTag() string {
+	tag, _ := gitRef(p.getenv("BUILD_SOURCEBRANCH"))
+	return tag
+}
+
+func (p *azureProvider) GitBranch() string {
+	// pull request builds are running for the merge ref
+	if sourceBranch := p.getenv("SYSTEM_PULLREQUEST_SOURCEBRANCH"); sourceBranch != "" {
+		return strings.TrimPrefix(sourceBranch, "refs/heads/")
+	}
+
+	_, branch := gitRef(p.getenv("BUILD_SOURCEBRANCH"))
+	return branch
+}
+
+func (p *azureProvider) GitCommit() string {
+	return p.getenv("BUILD_SOURCEVERSION")
+}
+
+func (p *azureProvider) Env() string {
+	return p.getenv("ENVIRONMENT_NAME")
+}
+
+func (p *azureProvider) Annotations() []ciAnnotation {
+	var buildUrl string
+	collectionUri := p.getenv("SYSTEM_TEAMFOUNDATIONCOLLECTIONURI")
+	teamProject := p.getenv("SYSTEM_TEAMPROJECT")
+	buildId := p.getenv("BUILD_BUILDID")
+	if collectionUri != "" && teamProject != "" && buildId != "" {
+		buildUrl = fmt.Sprintf("%s/%s/_build/results?buildId=%s", strings.TrimSuffix(collectionUri, "/"), teamProject, buildId)
+	}
+
+	return []ciAnnotation{
+		projectGitAnnotation(p.getenv("BUILD_REPOSITORY_URI")),
+		ciCommitAnnotation(p.GitCommit()),
+		{Name: "AZURE_PIPELINES_BUILD_URL", Key: "azure.ci.werf.io/build-url", Value: buildUrl},
+	}
+}
+
+func (p *azureProvider) LogColorMode() string {
+	return "on"
+}
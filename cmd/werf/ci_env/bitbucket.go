@@ -0,0 +1,54 @@
+package ci_env
+
+import "fmt"
+
+type bitbucketProvider struct {
+	getenv getenvFunc
+}
+
+func (p *bitbucketProvider) ImagesRepo() string {
+	return ""
+}
+
+func (p *bitbucketProvider) DockerLogin() (string, string, string, bool) {
+	return "", "", "", false
+}
+
+func (p *bitbucketProvider) GitTag() string {
+	return p.getenv("BITBUCKET_TAG")
+}
+
+func (p *bitbucketProvider) GitBranch() string {
+	if p.GitTag() != "" {
+		return ""
+	}
+
+	return p.getenv("BITBUCKET_BRANCH")
+}
+
+func (p *bitbucketProvider) GitCommit() string {
+	return p.getenv("BITBUCKET_COMMIT")
+}
+
+func (p *bitbucketProvider) Env() string {
+	return p.getenv("BITBUCKET_DEPLOYMENT_ENVIRONMENT")
+}
+
+func (p *bitbucketProvider) Annotations() []ciAnnotation {
+	var pipelineUrl string
+	repoFullName := p.getenv("BITBUCKET_REPO_FULL_NAME")
+	buildNumber := p.getenv("BITBUCKET_BUILD_NUMBER")
+	if repoFullName != "" && buildNumber != "" {
+		pipelineUrl = fmt.Sprintf("https://bitbucket.org/%s/addon/pipelines/home#!/results/%s", repoFullName, buildNumber)
+	}
+
+	return []ciAnnotation{
+		projectGitAnnotation(p.getenv("BITBUCKET_GIT_HTTP_ORIGIN")),
+		ciCommitAnnotation(p.GitCommit()),
+		{Name: "BITBUCKET_PIPELINE_URL", Key: "bitbucket.ci.werf.io/pipeline-url", Value: pipelineUrl},
+	}
+}
+
+func (p *bitbucketProvider) LogColorMode() string {
+	return "on"
+}
@@ -5,10 +5,10 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/flant/shluz"
 
-	"github.com/Masterminds/semver"
 	"github.com/ghodss/yaml"
 	"github.com/spf13/cobra"
 
@@ -34,9 +34,15 @@ func NewCmd() *cobra.Command {
 		Short:                 "Generate werf environment variables for specified CI system",
 		Long: `Generate werf environment variables for specified CI system.
 
-Currently supported only GitLab CI`,
+Supported CI systems: ` + strings.Join(ciProvidersNames(), ", ") + `.
+
+For GitHub Actions werf logs into ghcr.io when $GITHUB_TOKEN is passed to the job environment.
+For other CI systems except GitLab CI images repo should be specified explicitly with $WERF_IMAGES_REPO`,
 		Example: `  # Load generated werf environment variables on GitLab job runner
-  $ source <(werf ci-env gitlab --tagging-strategy tag-or-branch)`,
+  $ source <(werf ci-env gitlab --tagging-strategy tag-or-branch)
+
+  # Load generated werf environment variables in GitHub Actions job
+  $ source <(werf ci-env github --tagging-strategy tag-or-branch)`,
 		RunE: runCIEnv,
 	}
 
@@ -74,21 +80,21 @@ func runCIEnv(cmd *cobra.Command, args []string) error {
 
 	ciSystem := args[0]
 
-	switch ciSystem {
-	case "gitlab":
-		err := generateGitlabEnvs()
-		if err != nil {
-			fmt.Println()
-			printError(err.Error())
-		}
-		return err
-	default:
+	newProvider, ok := ciProviders[ciSystem]
+	if !ok {
 		common.PrintHelp(cmd)
 		return fmt.Errorf("provided ci system '%s' not supported", ciSystem)
 	}
+
+	err := generateEnvs(newProvider(os.Getenv))
+	if err != nil {
+		fmt.Println()
+		printError(err.Error())
+	}
+	return err
 }
 
-func generateGitlabEnvs() error {
+func generateEnvs(provider ciProvider) error {
 	dockerConfigPath := *CommonCmdData.DockerConfig
 	if *CommonCmdData.DockerConfig == "" {
 		dockerConfigPath = filepath.Join(os.Getenv("HOME"), ".docker")
@@ -110,41 +116,21 @@ func generateGitlabEnvs() error {
 		return err
 	}
 
-	ciRegistryImage := os.Getenv("CI_REGISTRY_IMAGE")
-	ciJobToken := os.Getenv("CI_JOB_TOKEN")
-
-	var imagesUsername, imagesPassword string
-	var doLogin bool
-	if ciRegistryImage != "" && ciJobToken != "" {
-		imagesUsername = "gitlab-ci-token"
-		imagesPassword = ciJobToken
-		doLogin = true
-	}
-
-	if doLogin {
-		err := docker.Login(imagesUsername, imagesPassword, ciRegistryImage)
+	if registry, username, password, ok := provider.DockerLogin(); ok {
+		err := docker.Login(username, password, registry)
 		if err != nil {
-			return fmt.Errorf("unable to login into docker repo %s: %s", ciRegistryImage, err)
+			return fmt.Errorf("unable to login into docker repo %s: %s", registry, err)
 		}
 	}
 
-	var ciGitTag, ciGitBranch string
-
-	if os.Getenv("CI_BUILD_TAG") != "" {
-		ciGitTag = os.Getenv("CI_BUILD_TAG")
-	} else if os.Getenv("CI_COMMIT_TAG") != "" {
-		ciGitTag = os.Getenv("CI_COMMIT_TAG")
-	} else if os.Getenv("CI_BUILD_REF_NAME") != "" {
-		ciGitBranch = os.Getenv("CI_BUILD_REF_NAME")
-	} else if os.Getenv("CI_COMMIT_REF_NAME") != "" {
-		ciGitBranch = os.Getenv("CI_COMMIT_REF_NAME")
-	}
+	ciGitTag := provider.GitTag()
+	ciGitBranch := provider.GitBranch()
 
 	printHeader("DOCKER CONFIG", false)
 	printExportCommand("DOCKER_CONFIG", dockerConfig, true)
 
 	printHeader("IMAGES REPO", true)
-	printExportCommand("WERF_IMAGES_REPO", ciRegistryImage, false)
+	printExportCommand("WERF_IMAGES_REPO", provider.ImagesRepo(), false)
 
 	printHeader("TAGGING", true)
 	if ciGitTag != "" {
@@ -155,35 +141,11 @@ func generateGitlabEnvs() error {
 	}
 
 	printHeader("DEPLOY", true)
-	printExportCommand("WERF_ENV", os.Getenv("CI_ENVIRONMENT_SLUG"), false)
-
-	var projectGit string
-	ciProjectUrlEnv := os.Getenv("CI_PROJECT_URL")
-	if ciProjectUrlEnv != "" {
-		projectGit = fmt.Sprintf("project.werf.io/git=%s", ciProjectUrlEnv)
-	}
-	printExportCommand("WERF_ADD_ANNOTATION_PROJECT_GIT", projectGit, false)
-
-	var ciCommit string
-	ciCommitShaEnv := os.Getenv("CI_COMMIT_SHA")
-	if ciCommitShaEnv != "" {
-		ciCommit = fmt.Sprintf("ci.werf.io/commit=%s", ciCommitShaEnv)
-	}
-	printExportCommand("WERF_ADD_ANNOTATION_CI_COMMIT", ciCommit, false)
+	printExportCommand("WERF_ENV", provider.Env(), false)
 
-	var gitlabCIPipelineUrl string
-	ciPipelineIdEnv := os.Getenv("CI_PIPELINE_ID")
-	if ciProjectUrlEnv != "" && ciPipelineIdEnv != "" {
-		gitlabCIPipelineUrl = fmt.Sprintf("gitlab.ci.werf.io/pipeline-url=%s/pipelines/%s", ciProjectUrlEnv, ciPipelineIdEnv)
+	for _, annotation := range provider.Annotations() {
+		printExportCommand(fmt.Sprintf("WERF_ADD_ANNOTATION_%s", annotation.Name), annotation.String(), false)
 	}
-	printExportCommand("WERF_ADD_ANNOTATION_GITLAB_CI_PIPELINE_URL", gitlabCIPipelineUrl, false)
-
-	var gitlabCiJobUrl string
-	ciJobIdEnv := os.Getenv("CI_JOB_ID")
-	if ciProjectUrlEnv != "" && os.Getenv("CI_JOB_ID") != "" {
-		gitlabCiJobUrl = fmt.Sprintf("gitlab.ci.werf.io/job-url=%s/-/jobs/%s", ciProjectUrlEnv, ciJobIdEnv)
-	}
-	printExportCommand("WERF_ADD_ANNOTATION_GITLAB_CI_JOB_URL", gitlabCiJobUrl, false)
 
 	cleanupConfig, err := getCleanupConfig()
 	if err != nil {
@@ -197,28 +159,13 @@ func generateGitlabEnvs() error {
 	printExportCommand("WERF_GIT_COMMIT_STRATEGY_EXPIRY_DAYS", fmt.Sprintf("%d", cleanupConfig.GitCommitStrategyExpiryDays), false)
 
 	printHeader("OTHER", true)
-
-	werfLogColorMode := "on"
-	ciServerVersion := os.Getenv("CI_SERVER_VERSION")
-	if ciServerVersion != "" {
-		currentVersion, err := semver.NewVersion(ciServerVersion)
-		if err == nil {
-			colorWorkTillVersion, _ := semver.NewVersion("12.1.3")
-			colorWorkSinceVersion, _ := semver.NewVersion("12.2.0")
-
-			if currentVersion.GreaterThan(colorWorkTillVersion) && currentVersion.LessThan(colorWorkSinceVersion) {
-				werfLogColorMode = "off"
-			}
-		}
-	}
-
-	printExportCommand("WERF_LOG_COLOR_MODE", werfLogColorMode, false)
+	printExportCommand("WERF_LOG_COLOR_MODE", provider.LogColorMode(), false)
 	printExportCommand("WERF_LOG_PROJECT_DIR", "1", false)
 	printExportCommand("WERF_ENABLE_PROCESS_EXTERMINATOR", "1", false)
 	printExportCommand("WERF_LOG_TERMINAL_WIDTH", "95", false)
 
 	if ciGitTag == "" && ciGitBranch == "" {
-		return fmt.Errorf("none of enviroment variables $WERF_TAG_GIT_TAG=$CI_COMMIT_TAG or $WERF_TAG_GIT_BRANCH=$CI_COMMIT_REF_NAME for '%s' strategy are detected", CmdData.TaggingStrategy)
+		return fmt.Errorf("none of git tag or git branch for '%s' strategy are detected by environment variables of the CI system", CmdData.TaggingStrategy)
 	}
 
 	return nil
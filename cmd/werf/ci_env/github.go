@@ -0,0 +1,77 @@
+package ci_env
+
+import (
+	"fmt"
+	"strings"
+)
+
+const githubContainerRegistry = "ghcr.io"
+
+type githubProvider struct {
+	getenv getenvFunc
+}
+
+func (p *githubProvider) ImagesRepo() string {
+	repository := p.getenv("GITHUB_REPOSITORY")
+	if repository == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%s/%s", githubContainerRegistry, strings.ToLower(repository))
+}
+
+// DockerLogin uses GITHUB_TOKEN which should be passed to the job environment explicitly
+func (p *githubProvider) DockerLogin() (string, string, string, bool) {
+	actor := p.getenv("GITHUB_ACTOR")
+	token := p.getenv("GITHUB_TOKEN")
+	if actor == "" || token == "" {
+		return "", "", "", false
+	}
+
+	return githubContainerRegistry, actor, token, true
+}
+
+func (p *githubProvider) GitTag() string {
+	tag, _ := gitRef(p.getenv("GITHUB_REF"))
+	return tag
+}
+
+func (p *githubProvider) GitBranch() string {
+	// pull request workflows are running for the merge ref
+	if headRef := p.getenv("GITHUB_HEAD_REF"); headRef != "" {
+		return headRef
+	}
+
+	_, branch := gitRef(p.getenv("GITHUB_REF"))
+	return branch
+}
+
+func (p *githubProvider) GitCommit() string {
+	return p.getenv("GITHUB_SHA")
+}
+
+func (p *githubProvider) Env() string {
+	return ""
+}
+
+func (p *githubProvider) Annotations() []ciAnnotation {
+	var projectUrl, workflowRunUrl string
+	if repository := p.getenv("GITHUB_REPOSITORY"); repository != "" {
+		serverUrl := firstNonEmpty(p.getenv("GITHUB_SERVER_URL"), "https://github.com")
+		projectUrl = fmt.Sprintf("%s/%s", serverUrl, repository)
+
+		if runId := p.getenv("GITHUB_RUN_ID"); runId != "" {
+			workflowRunUrl = fmt.Sprintf("%s/actions/runs/%s", projectUrl, runId)
+		}
+	}
+
+	return []ciAnnotation{
+		projectGitAnnotation(projectUrl),
+		ciCommitAnnotation(p.GitCommit()),
+		{Name: "GITHUB_ACTIONS_WORKFLOW_RUN_URL", Key: "github.ci.werf.io/workflow-run-url", Value: workflowRunUrl},
+	}
+}
+
+func (p *githubProvider) LogColorMode() string {
+	return "on"
+}
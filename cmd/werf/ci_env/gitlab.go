@@ -0,0 +1,81 @@
+package ci_env
+
+import (
+	"fmt"
+
+	"github.com/Masterminds/semver"
+)
+
+type gitlabProvider struct {
+	getenv getenvFunc
+}
+
+func (p *gitlabProvider) ImagesRepo() string {
+	return p.getenv("CI_REGISTRY_IMAGE")
+}
+
+func (p *gitlabProvider) DockerLogin() (string, string, string, bool) {
+	ciRegistryImage := p.getenv("CI_REGISTRY_IMAGE")
+	ciJobToken := p.getenv("CI_JOB_TOKEN")
+	if ciRegistryImage == "" || ciJobToken == "" {
+		return "", "", "", false
+	}
+
+	return ciRegistryImage, "gitlab-ci-token", ciJobToken, true
+}
+
+func (p *gitlabProvider) GitTag() string {
+	return firstNonEmpty(p.getenv("CI_BUILD_TAG"), p.getenv("CI_COMMIT_TAG"))
+}
+
+func (p *gitlabProvider) GitBranch() string {
+	if p.GitTag() != "" {
+		return ""
+	}
+
+	return firstNonEmpty(p.getenv("CI_BUILD_REF_NAME"), p.getenv("CI_COMMIT_REF_NAME"))
+}
+
+func (p *gitlabProvider) GitCommit() string {
+	return p.getenv("CI_COMMIT_SHA")
+}
+
+func (p *gitlabProvider) Env() string {
+	return p.getenv("CI_ENVIRONMENT_SLUG")
+}
+
+func (p *gitlabProvider) Annotations() []ciAnnotation {
+	ciProjectUrl := p.getenv("CI_PROJECT_URL")
+
+	var pipelineUrl, jobUrl string
+	if ciProjectUrl != "" && p.getenv("CI_PIPELINE_ID") != "" {
+		pipelineUrl = fmt.Sprintf("%s/pipelines/%s", ciProjectUrl, p.getenv("CI_PIPELINE_ID"))
+	}
+	if ciProjectUrl != "" && p.getenv("CI_JOB_ID") != "" {
+		jobUrl = fmt.Sprintf("%s/-/jobs/%s", ciProjectUrl, p.getenv("CI_JOB_ID"))
+	}
+
+	return []ciAnnotation{
+		projectGitAnnotation(ciProjectUrl),
+		ciCommitAnnotation(p.GitCommit()),
+		{Name: "GITLAB_CI_PIPELINE_URL", Key: "gitlab.ci.werf.io/pipeline-url", Value: pipelineUrl},
+		{Name: "GITLAB_CI_JOB_URL", Key: "gitlab.ci.werf.io/job-url", Value: jobUrl},
+	}
+}
+
+func (p *gitlabProvider) LogColorMode() string {
+	ciServerVersion := p.getenv("CI_SERVER_VERSION")
+	if ciServerVersion != "" {
+		currentVersion, err := semver.NewVersion(ciServerVersion)
+		if err == nil {
+			colorWorkTillVersion, _ := semver.NewVersion("12.1.3")
+			colorWorkSinceVersion, _ := semver.NewVersion("12.2.0")
+
+			if currentVersion.GreaterThan(colorWorkTillVersion) && currentVersion.LessThan(colorWorkSinceVersion) {
+				return "off"
+			}
+		}
+	}
+
+	return "on"
+}
@@ -0,0 +1,53 @@
+package ci_env
+
+import "strings"
+
+type jenkinsProvider struct {
+	getenv getenvFunc
+}
+
+func (p *jenkinsProvider) ImagesRepo() string {
+	return ""
+}
+
+func (p *jenkinsProvider) DockerLogin() (string, string, string, bool) {
+	return "", "", "", false
+}
+
+func (p *jenkinsProvider) GitTag() string {
+	return p.getenv("TAG_NAME")
+}
+
+func (p *jenkinsProvider) GitBranch() string {
+	if p.GitTag() != "" {
+		return ""
+	}
+
+	// BRANCH_NAME is set by multibranch pipelines, GIT_BRANCH by git plugin and contains remote name
+	if branch := p.getenv("BRANCH_NAME"); branch != "" {
+		return branch
+	}
+
+	return strings.TrimPrefix(p.getenv("GIT_BRANCH"), "origin/")
+}
+
+func (p *jenkinsProvider) GitCommit() string {
+	return p.getenv("GIT_COMMIT")
+}
+
+func (p *jenkinsProvider) Env() string {
+	return ""
+}
+
+func (p *jenkinsProvider) Annotations() []ciAnnotation {
+	return []ciAnnotation{
+		projectGitAnnotation(p.getenv("GIT_URL")),
+		ciCommitAnnotation(p.GitCommit()),
+		{Name: "JENKINS_BUILD_URL", Key: "jenkins.ci.werf.io/build-url", Value: p.getenv("BUILD_URL")},
+	}
+}
+
+// LogColorMode is off because Jenkins console does not support colors without additional plugins
+func (p *jenkinsProvider) LogColorMode() string {
+	return "off"
+}
@@ -0,0 +1,89 @@
+package ci_env
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ciProvider gathers werf params from environment variables of the CI system job
+type ciProvider interface {
+	// ImagesRepo is a docker repo provided by the CI system for the project images
+	ImagesRepo() string
+	// DockerLogin returns credentials to login into ImagesRepo, ok is false when the CI system does not provide such credentials
+	DockerLogin() (registry, username, password string, ok bool)
+
+	GitTag() string
+	GitBranch() string
+	GitCommit() string
+
+	Env() string
+	// Annotations are extra auto annotations with info about the CI job, WERF_ADD_ANNOTATION_ prefix is prepended to the name of each one
+	Annotations() []ciAnnotation
+	LogColorMode() string
+}
+
+type ciAnnotation struct {
+	Name  string
+	Key   string
+	Value string
+}
+
+func (a ciAnnotation) String() string {
+	if a.Value == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%s=%s", a.Key, a.Value)
+}
+
+type getenvFunc func(string) string
+
+var ciProviders = map[string]func(getenv getenvFunc) ciProvider{
+	"gitlab":    func(getenv getenvFunc) ciProvider { return &gitlabProvider{getenv: getenv} },
+	"github":    func(getenv getenvFunc) ciProvider { return &githubProvider{getenv: getenv} },
+	"travis":    func(getenv getenvFunc) ciProvider { return &travisProvider{getenv: getenv} },
+	"jenkins":   func(getenv getenvFunc) ciProvider { return &jenkinsProvider{getenv: getenv} },
+	"bitbucket": func(getenv getenvFunc) ciProvider { return &bitbucketProvider{getenv: getenv} },
+	"azure":     func(getenv getenvFunc) ciProvider { return &azureProvider{getenv: getenv} },
+}
+
+func ciProvidersNames() []string {
+	var names []string
+	for name := range ciProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func projectGitAnnotation(url string) ciAnnotation {
+	return ciAnnotation{Name: "PROJECT_GIT", Key: "project.werf.io/git", Value: url}
+}
+
+func ciCommitAnnotation(commit string) ciAnnotation {
+	return ciAnnotation{Name: "CI_COMMIT", Key: "ci.werf.io/commit", Value: commit}
+}
+
+// gitRef splits full git ref into a git tag or a git branch
+func gitRef(ref string) (tag, branch string) {
+	switch {
+	case strings.HasPrefix(ref, "refs/tags/"):
+		return strings.TrimPrefix(ref, "refs/tags/"), ""
+	case strings.HasPrefix(ref, "refs/heads/"):
+		return "", strings.TrimPrefix(ref, "refs/heads/")
+	default:
+		return "", ""
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
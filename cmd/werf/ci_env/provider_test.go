@@ -0,0 +1,314 @@
+package ci_env
+
+import (
+	"reflect"
+	"testing"
+)
+
+type providerExpectation struct {
+	imagesRepo   string
+	login        []string
+	gitTag       string
+	gitBranch    string
+	gitCommit    string
+	env          string
+	annotations  map[string]string
+	logColorMode string
+}
+
+func fakeGetenv(envs map[string]string) getenvFunc {
+	return func(key string) string {
+		return envs[key]
+	}
+}
+
+func checkProvider(t *testing.T, provider ciProvider, expected providerExpectation) {
+	if imagesRepo := provider.ImagesRepo(); imagesRepo != expected.imagesRepo {
+		t.Errorf("expected images repo %q, got %q", expected.imagesRepo, imagesRepo)
+	}
+
+	registry, username, password, ok := provider.DockerLogin()
+	if expected.login == nil {
+		if ok {
+			t.Errorf("expected no docker login, got %s@%s", username, registry)
+		}
+	} else if login := []string{registry, username, password}; !ok || !reflect.DeepEqual(login, expected.login) {
+		t.Errorf("expected docker login %v, got %v (ok=%t)", expected.login, login, ok)
+	}
+
+	if gitTag := provider.GitTag(); gitTag != expected.gitTag {
+		t.Errorf("expected git tag %q, got %q", expected.gitTag, gitTag)
+	}
+
+	if gitBranch := provider.GitBranch(); gitBranch != expected.gitBranch {
+		t.Errorf("expected git branch %q, got %q", expected.gitBranch, gitBranch)
+	}
+
+	if gitCommit := provider.GitCommit(); gitCommit != expected.gitCommit {
+		t.Errorf("expected git commit %q, got %q", expected.gitCommit, gitCommit)
+	}
+
+	if env := provider.Env(); env != expected.env {
+		t.Errorf("expected env %q, got %q", expected.env, env)
+	}
+
+	annotations := map[string]string{}
+	for _, annotation := range provider.Annotations() {
+		if value := annotation.String(); value != "" {
+			annotations[annotation.Name] = value
+		}
+	}
+
+	if !reflect.DeepEqual(annotations, expected.annotations) {
+		t.Errorf("expected annotations %v, got %v", expected.annotations, annotations)
+	}
+
+	if logColorMode := provider.LogColorMode(); logColorMode != expected.logColorMode {
+		t.Errorf("expected log color mode %q, got %q", expected.logColorMode, logColorMode)
+	}
+}
+
+func TestCIProviders(t *testing.T) {
+	tests := []struct {
+		name     string
+		ciSystem string
+		envs     map[string]string
+		expected providerExpectation
+	}{
+		{
+			name:     "gitlab branch",
+			ciSystem: "gitlab",
+			envs: map[string]string{
+				"CI_REGISTRY_IMAGE":   "registry.example.com/group/project",
+				"CI_JOB_TOKEN":        "token",
+				"CI_COMMIT_REF_NAME":  "feature/x",
+				"CI_COMMIT_SHA":       "abc",
+				"CI_ENVIRONMENT_SLUG": "review-x",
+				"CI_PROJECT_URL":      "https://gitlab.example.com/group/project",
+				"CI_PIPELINE_ID":      "10",
+				"CI_JOB_ID":           "20",
+				"CI_SERVER_VERSION":   "12.1.5",
+			},
+			expected: providerExpectation{
+				imagesRepo: "registry.example.com/group/project",
+				login:      []string{"registry.example.com/group/project", "gitlab-ci-token", "token"},
+				gitBranch:  "feature/x",
+				gitCommit:  "abc",
+				env:        "review-x",
+				annotations: map[string]string{
+					"PROJECT_GIT":            "project.werf.io/git=https://gitlab.example.com/group/project",
+					"CI_COMMIT":              "ci.werf.io/commit=abc",
+					"GITLAB_CI_PIPELINE_URL": "gitlab.ci.werf.io/pipeline-url=https://gitlab.example.com/group/project/pipelines/10",
+					"GITLAB_CI_JOB_URL":      "gitlab.ci.werf.io/job-url=https://gitlab.example.com/group/project/-/jobs/20",
+				},
+				logColorMode: "off",
+			},
+		},
+		{
+			name:     "gitlab tag",
+			ciSystem: "gitlab",
+			envs: map[string]string{
+				"CI_COMMIT_TAG":      "v1.0.0",
+				"CI_COMMIT_REF_NAME": "v1.0.0",
+			},
+			expected: providerExpectation{
+				gitTag:       "v1.0.0",
+				annotations:  map[string]string{},
+				logColorMode: "on",
+			},
+		},
+		{
+			name:     "github branch",
+			ciSystem: "github",
+			envs: map[string]string{
+				"GITHUB_REPOSITORY": "Owner/Repo",
+				"GITHUB_ACTOR":      "user",
+				"GITHUB_TOKEN":      "token",
+				"GITHUB_REF":        "refs/heads/master",
+				"GITHUB_SHA":        "abc",
+				"GITHUB_RUN_ID":     "42",
+			},
+			expected: providerExpectation{
+				imagesRepo: "ghcr.io/owner/repo",
+				login:      []string{"ghcr.io", "user", "token"},
+				gitBranch:  "master",
+				gitCommit:  "abc",
+				annotations: map[string]string{
+					"PROJECT_GIT":                     "project.werf.io/git=https://github.com/Owner/Repo",
+					"CI_COMMIT":                       "ci.werf.io/commit=abc",
+					"GITHUB_ACTIONS_WORKFLOW_RUN_URL": "github.ci.werf.io/workflow-run-url=https://github.com/Owner/Repo/actions/runs/42",
+				},
+				logColorMode: "on",
+			},
+		},
+		{
+			name:     "github tag",
+			ciSystem: "github",
+			envs: map[string]string{
+				"GITHUB_REPOSITORY": "owner/repo",
+				"GITHUB_REF":        "refs/tags/v1.0.0",
+			},
+			expected: providerExpectation{
+				imagesRepo: "ghcr.io/owner/repo",
+				gitTag:     "v1.0.0",
+				annotations: map[string]string{
+					"PROJECT_GIT": "project.werf.io/git=https://github.com/owner/repo",
+				},
+				logColorMode: "on",
+			},
+		},
+		{
+			name:     "github pull request",
+			ciSystem: "github",
+			envs: map[string]string{
+				"GITHUB_REF":      "refs/pull/1/merge",
+				"GITHUB_HEAD_REF": "feature",
+			},
+			expected: providerExpectation{
+				gitBranch:    "feature",
+				annotations:  map[string]string{},
+				logColorMode: "on",
+			},
+		},
+		{
+			name:     "travis pull request",
+			ciSystem: "travis",
+			envs: map[string]string{
+				"TRAVIS_BRANCH":              "master",
+				"TRAVIS_PULL_REQUEST_BRANCH": "feature",
+				"TRAVIS_COMMIT":              "abc",
+				"TRAVIS_REPO_SLUG":           "owner/repo",
+				"TRAVIS_BUILD_WEB_URL":       "https://travis-ci.com/owner/repo/builds/1",
+				"TRAVIS_JOB_WEB_URL":         "https://travis-ci.com/owner/repo/jobs/2",
+			},
+			expected: providerExpectation{
+				gitBranch: "feature",
+				gitCommit: "abc",
+				annotations: map[string]string{
+					"PROJECT_GIT":         "project.werf.io/git=https://github.com/owner/repo",
+					"CI_COMMIT":           "ci.werf.io/commit=abc",
+					"TRAVIS_CI_BUILD_URL": "travis.ci.werf.io/build-url=https://travis-ci.com/owner/repo/builds/1",
+					"TRAVIS_CI_JOB_URL":   "travis.ci.werf.io/job-url=https://travis-ci.com/owner/repo/jobs/2",
+				},
+				logColorMode: "on",
+			},
+		},
+		{
+			name:     "travis tag",
+			ciSystem: "travis",
+			envs: map[string]string{
+				"TRAVIS_TAG":    "v1.0.0",
+				"TRAVIS_BRANCH": "v1.0.0",
+			},
+			expected: providerExpectation{
+				gitTag:       "v1.0.0",
+				annotations:  map[string]string{},
+				logColorMode: "on",
+			},
+		},
+		{
+			name:     "jenkins git plugin branch",
+			ciSystem: "jenkins",
+			envs: map[string]string{
+				"GIT_BRANCH": "origin/master",
+				"GIT_COMMIT": "abc",
+				"GIT_URL":    "https://git.example.com/repo.git",
+				"BUILD_URL":  "https://jenkins.example.com/job/repo/1/",
+			},
+			expected: providerExpectation{
+				gitBranch: "master",
+				gitCommit: "abc",
+				annotations: map[string]string{
+					"PROJECT_GIT":       "project.werf.io/git=https://git.example.com/repo.git",
+					"CI_COMMIT":         "ci.werf.io/commit=abc",
+					"JENKINS_BUILD_URL": "jenkins.ci.werf.io/build-url=https://jenkins.example.com/job/repo/1/",
+				},
+				logColorMode: "off",
+			},
+		},
+		{
+			name:     "jenkins multibranch tag",
+			ciSystem: "jenkins",
+			envs: map[string]string{
+				"TAG_NAME":    "v1.0.0",
+				"BRANCH_NAME": "v1.0.0",
+			},
+			expected: providerExpectation{
+				gitTag:       "v1.0.0",
+				annotations:  map[string]string{},
+				logColorMode: "off",
+			},
+		},
+		{
+			name:     "bitbucket branch",
+			ciSystem: "bitbucket",
+			envs: map[string]string{
+				"BITBUCKET_BRANCH":                 "master",
+				"BITBUCKET_COMMIT":                 "abc",
+				"BITBUCKET_DEPLOYMENT_ENVIRONMENT": "production",
+				"BITBUCKET_GIT_HTTP_ORIGIN":        "http://bitbucket.org/owner/repo",
+				"BITBUCKET_REPO_FULL_NAME":         "owner/repo",
+				"BITBUCKET_BUILD_NUMBER":           "7",
+			},
+			expected: providerExpectation{
+				gitBranch: "master",
+				gitCommit: "abc",
+				env:       "production",
+				annotations: map[string]string{
+					"PROJECT_GIT":            "project.werf.io/git=http://bitbucket.org/owner/repo",
+					"CI_COMMIT":              "ci.werf.io/commit=abc",
+					"BITBUCKET_PIPELINE_URL": "bitbucket.ci.werf.io/pipeline-url=https://bitbucket.org/owner/repo/addon/pipelines/home#!/results/7",
+				},
+				logColorMode: "on",
+			},
+		},
+		{
+			name:     "azure tag",
+			ciSystem: "azure",
+			envs: map[string]string{
+				"BUILD_SOURCEBRANCH":                 "refs/tags/v1.0.0",
+				"BUILD_SOURCEVERSION":                "abc",
+				"BUILD_REPOSITORY_URI":               "https://dev.azure.com/org/project/_git/repo",
+				"SYSTEM_TEAMFOUNDATIONCOLLECTIONURI": "https://dev.azure.com/org/",
+				"SYSTEM_TEAMPROJECT":                 "project",
+				"BUILD_BUILDID":                      "5",
+				"ENVIRONMENT_NAME":                   "staging",
+			},
+			expected: providerExpectation{
+				gitTag:    "v1.0.0",
+				gitCommit: "abc",
+				env:       "staging",
+				annotations: map[string]string{
+					"PROJECT_GIT":               "project.werf.io/git=https://dev.azure.com/org/project/_git/repo",
+					"CI_COMMIT":                 "ci.werf.io/commit=abc",
+					"AZURE_PIPELINES_BUILD_URL": "azure.ci.werf.io/build-url=https://dev.azure.com/org/project/_build/results?buildId=5",
+				},
+				logColorMode: "on",
+			},
+		},
+		{
+			name:     "azure pull request",
+			ciSystem: "azure",
+			envs: map[string]string{
+				"BUILD_SOURCEBRANCH":              "refs/pull/1/merge",
+				"SYSTEM_PULLREQUEST_SOURCEBRANCH": "refs/heads/feature",
+			},
+			expected: providerExpectation{
+				gitBranch:    "feature",
+				annotations:  map[string]string{},
+				logColorMode: "on",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newProvider, ok := ciProviders[test.ciSystem]
+			if !ok {
+				t.Fatalf("ci system %s is not supported", test.ciSystem)
+			}
+
+			checkProvider(t, newProvider(fakeGetenv(test.envs)), test.expected)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package ci_env
+
+import "fmt"
+
+type travisProvider struct {
+	getenv getenvFunc
+}
+
+func (p *travisProvider) ImagesRepo() string {
+	return ""
+}
+
+func (p *travisProvider) DockerLogin() (string, string, string, bool) {
+	return "", "", "", false
+}
+
+func (p *travisProvider) GitTag() string {
+	return p.getenv("TRAVIS_TAG")
+}
+
+func (p *travisProvider) GitBranch() string {
+	if p.GitTag() != "" {
+		return ""
+	}
+
+	// TRAVIS_BRANCH is the target branch for pull request builds
+	return firstNonEmpty(p.getenv("TRAVIS_PULL_REQUEST_BRANCH"), p.getenv("TRAVIS_BRANCH"))
+}
+
+func (p *travisProvider) GitCommit() string {
+	return p.getenv("TRAVIS_COMMIT")
+}
+
+func (p *travisProvider) Env() string {
+	return ""
+}
+
+func (p *travisProvider) Annotations() []ciAnnotation {
+	var projectUrl string
+	if repoSlug := p.getenv("TRAVIS_REPO_SLUG"); repoSlug != "" {
+		projectUrl = fmt.Sprintf("https://github.com/%s", repoSlug)
+	}
+
+	return []ciAnnotation{
+		projectGitAnnotation(projectUrl),
+		ciCommitAnnotation(p.GitCommit()),
+		{Name: "TRAVIS_CI_BUILD_URL", Key: "travis.ci.werf.io/build-url", Value: p.getenv("TRAVIS_BUILD_WEB_URL")},
+		{Name: "TRAVIS_CI_JOB_URL", Key: "travis.ci.werf.io/job-url", Value: p.getenv("TRAVIS_JOB_WEB_URL")},
+	}
+}
+
+func (p *travisProvider) LogColorMode() string {
+	return "on"
+}
@@ -56,12 +56,16 @@ It is safe to run this command periodically (daily is enough) by automated clean
 	common.SetupDockerConfig(&CommonCmdData, cmd, "Command needs granted permissions to read, pull and delete images from the specified stages storage and images repo")
 	common.SetupInsecureRegistry(&CommonCmdData, cmd)
 	common.SetupSkipTlsVerifyRegistry(&CommonCmdData, cmd)
+	common.SetupRepoImplementation(&CommonCmdData, cmd)
 	common.SetupImagesCleanupPolicies(&CommonCmdData, cmd)
 
 	common.SetupKubeConfig(&CommonCmdData, cmd)
 	common.SetupKubeContext(&CommonCmdData, cmd)
+	common.SetupHelmReleaseStorageNamespace(&CommonCmdData, cmd)
+	common.SetupLockBackend(&CommonCmdData, cmd)
 
 	common.SetupDryRun(&CommonCmdData, cmd)
+	common.SetupReportOptions(&CommonCmdData, cmd)
 
 	common.SetupLogOptions(&CommonCmdData, cmd)
 	common.SetupLogProjectDir(&CommonCmdData, cmd)
@@ -80,7 +84,7 @@ func runCleanup() error {
 		return err
 	}
 
-	if err := docker_registry.Init(docker_registry.Options{InsecureRegistry: *CommonCmdData.InsecureRegistry, SkipTlsVerifyRegistry: *CommonCmdData.SkipTlsVerifyRegistry}); err != nil {
+	if err := docker_registry.Init(docker_registry.Options{InsecureRegistry: *CommonCmdData.InsecureRegistry, SkipTlsVerifyRegistry: *CommonCmdData.SkipTlsVerifyRegistry, Implementation: *CommonCmdData.RepoImplementation, QuayToken: *CommonCmdData.RepoQuayToken}); err != nil {
 		return err
 	}
 
@@ -96,6 +100,10 @@ func runCleanup() error {
 		return fmt.Errorf("cannot init kubedog: %s", err)
 	}
 
+	if err := common.InitLockManager(&CommonCmdData); err != nil {
+		return err
+	}
+
 	projectDir, err := common.GetProjectDir(&CommonCmdData)
 	if err != nil {
 		return fmt.Errorf("getting project dir failed: %s", err)
@@ -156,7 +164,7 @@ func runCleanup() error {
 		}
 	}
 
-	policies, err := common.GetImagesCleanupPolicies(&CommonCmdData)
+	policies, err := common.GetImagesCleanupPolicies(&CommonCmdData, werfConfig)
 	if err != nil {
 		return err
 	}
@@ -166,11 +174,17 @@ func runCleanup() error {
 		return fmt.Errorf("unable to get Kubernetes clusters connections: %s", err)
 	}
 
+	report, err := common.NewCleanupReport(&CommonCmdData)
+	if err != nil {
+		return err
+	}
+
 	imagesCleanupOptions := cleaning.ImagesCleanupOptions{
 		CommonRepoOptions: cleaning.CommonRepoOptions{
 			ImagesRepoManager: imagesRepoManager,
 			ImagesNames:       imagesNames,
 			DryRun:            *CommonCmdData.DryRun,
+			Report:            report,
 		},
 		LocalGit:                  localGitRepo,
 		KubernetesContextsClients: kubernetesContextsClients,
@@ -184,6 +198,7 @@ func runCleanup() error {
 		StagesStorage:     stagesRepo,
 		ImagesNames:       imagesNames,
 		DryRun:            *CommonCmdData.DryRun,
+		Report:            report,
 	}
 
 	cleanupOptions := cleaning.CleanupOptions{
@@ -192,9 +207,7 @@ func runCleanup() error {
 	}
 
 	logboek.LogOptionalLn()
-	if err := cleaning.Cleanup(cleanupOptions); err != nil {
-		return err
-	}
+	cleanupErr := cleaning.Cleanup(cleanupOptions)
 
-	return nil
+	return common.WriteCleanupReport(&CommonCmdData, report, cleanupErr)
 }
@@ -20,7 +20,9 @@ import (
 	cleanup "github.com/flant/werf/pkg/cleaning"
 	"github.com/flant/werf/pkg/config"
 	"github.com/flant/werf/pkg/deploy/helm"
+	"github.com/flant/werf/pkg/docker_registry"
 	"github.com/flant/werf/pkg/logging"
+	"github.com/flant/werf/pkg/tag_strategy"
 	"github.com/flant/werf/pkg/util"
 	"github.com/flant/werf/pkg/werf"
 )
@@ -36,6 +38,8 @@ type CmdData struct {
 	TagGitTag    *string
 	TagGitCommit *string
 
+	TagByStagesSignature *bool
+
 	Environment                      *string
 	Release                          *string
 	Namespace                        *string
@@ -62,8 +66,13 @@ type CmdData struct {
 	DockerConfig          *string
 	InsecureRegistry      *bool
 	SkipTlsVerifyRegistry *bool
+	RepoImplementation    *string
+	RepoQuayToken         *string
 	DryRun                *bool
 
+	ReportPath   *string
+	ReportFormat *string
+
 	GitTagStrategyLimit         *int64
 	GitTagStrategyExpiryDays    *int64
 	GitCommitStrategyLimit      *int64
@@ -73,6 +82,24 @@ type CmdData struct {
 
 	StagesToIntrospect *[]string
 
+	Dev *bool
+
+	Parallel           *bool
+	ParallelTasksLimit *int64
+
+	DockerfileBuilder *string
+	BuildKitAddr      *string
+
+	SignImages   *bool
+	VerifyImages *bool
+
+	ProvenancePath *string
+	PushProvenance *bool
+
+	PolicyMode *string
+
+	LockBackend *string
+
 	LogPretty        *bool
 	LogColorMode     *string
 	LogProjectDir    *bool
@@ -139,11 +166,13 @@ func SetupTag(cmdData *CmdData, cmd *cobra.Command) {
 	cmdData.TagGitBranch = new(string)
 	cmdData.TagGitTag = new(string)
 	cmdData.TagGitCommit = new(string)
+	cmdData.TagByStagesSignature = new(bool)
 
 	cmd.Flags().StringArrayVarP(cmdData.TagCustom, "tag-custom", "", tagCustom, "Use custom tagging strategy and tag by the specified arbitrary tags.\nOption can be used multiple times to produce multiple images with the specified tags.\nAlso can be specified in $WERF_TAG_CUSTOM* (e.g. $WERF_TAG_CUSTOM_TAG1=tag1, $WERF_TAG_CUSTOM_TAG2=tag2)")
 	cmd.Flags().StringVarP(cmdData.TagGitBranch, "tag-git-branch", "", os.Getenv("WERF_TAG_GIT_BRANCH"), "Use git-branch tagging strategy and tag by the specified git branch (option can be enabled by specifying git branch in the $WERF_TAG_GIT_BRANCH)")
 	cmd.Flags().StringVarP(cmdData.TagGitTag, "tag-git-tag", "", os.Getenv("WERF_TAG_GIT_TAG"), "Use git-tag tagging strategy and tag by the specified git tag (option can be enabled by specifying git tag in the $WERF_TAG_GIT_TAG)")
 	cmd.Flags().StringVarP(cmdData.TagGitCommit, "tag-git-commit", "", os.Getenv("WERF_TAG_GIT_COMMIT"), "Use git-commit tagging strategy and tag by the specified git commit hash (option can be enabled by specifying git commit hash in the $WERF_TAG_GIT_COMMIT)")
+	cmd.Flags().BoolVarP(cmdData.TagByStagesSignature, "tag-by-stages-signature", "", GetBoolEnvironment("WERF_TAG_BY_STAGES_SIGNATURE"), "Use stages-signature tagging strategy and tag each image by the signature of its last stage (default $WERF_TAG_BY_STAGES_SIGNATURE)")
 }
 
 func SetupEnvironment(cmdData *CmdData, cmd *cobra.Command) {
@@ -218,7 +247,7 @@ func SetupHelmReleaseStorageType(cmdData *CmdData, cmd *cobra.Command) {
 
 func SetupStagesStorage(cmdData *CmdData, cmd *cobra.Command) {
 	cmdData.StagesStorage = new(string)
-	cmd.Flags().StringVarP(cmdData.StagesStorage, "stages-storage", "s", os.Getenv("WERF_STAGES_STORAGE"), "Docker Repo to store stages or :local for non-distributed build (only :local is supported for now; default $WERF_STAGES_STORAGE environment).\nMore info about stages: https://werf.io/documentation/reference/stages_and_images.html")
+	cmd.Flags().StringVarP(cmdData.StagesStorage, "stages-storage", "s", os.Getenv("WERF_STAGES_STORAGE"), "Docker Repo to store stages or :local for non-distributed build (default $WERF_STAGES_STORAGE environment).\nMore info about stages: https://werf.io/documentation/reference/stages_and_images.html")
 }
 
 func SetupStatusProgressPeriod(cmdData *CmdData, cmd *cobra.Command) {
@@ -316,6 +345,16 @@ func SetupInsecureRegistry(cmdData *CmdData, cmd *cobra.Command) {
 	cmd.Flags().BoolVarP(cmdData.InsecureRegistry, "insecure-registry", "", GetBoolEnvironment("WERF_INSECURE_REGISTRY"), "Use plain HTTP requests when accessing a registry (default $WERF_INSECURE_REGISTRY)")
 }
 
+func SetupRepoImplementation(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.RepoImplementation = new(string)
+	cmd.Flags().StringVarP(cmdData.RepoImplementation, "repo-implementation", "", os.Getenv("WERF_REPO_IMPLEMENTATION"), fmt.Sprintf(`Choose repository implementation to use the registry API specific for deletion of tags.
+The following implementations are supported: %s.
+By default the implementation is detected automatically by the registry address and API (default $WERF_REPO_IMPLEMENTATION)`, strings.Join(docker_registry.Implementations, ", ")))
+
+	cmdData.RepoQuayToken = new(string)
+	cmd.Flags().StringVarP(cmdData.RepoQuayToken, "repo-quay-token", "", os.Getenv("WERF_REPO_QUAY_TOKEN"), "Quay OAuth access token to delete tags with Quay API (default $WERF_REPO_QUAY_TOKEN)")
+}
+
 func SetupSkipTlsVerifyRegistry(cmdData *CmdData, cmd *cobra.Command) {
 	cmdData.SkipTlsVerifyRegistry = new(bool)
 	cmd.Flags().BoolVarP(cmdData.SkipTlsVerifyRegistry, "skip-tls-verify-registry", "", GetBoolEnvironment("WERF_SKIP_TLS_VERIFY_REGISTRY"), "Skip TLS certificate validation when accessing a registry (default $WERF_SKIP_TLS_VERIFY_REGISTRY)")
@@ -332,6 +371,19 @@ func SetupDryRun(cmdData *CmdData, cmd *cobra.Command) {
 	cmd.Flags().BoolVarP(cmdData.DryRun, "dry-run", "", false, "Indicate what the command would do without actually doing that")
 }
 
+func SetupReportOptions(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.ReportPath = new(string)
+	cmdData.ReportFormat = new(string)
+
+	reportFormatDefaultValue := string(cleanup.ReportFormatJson)
+	if os.Getenv("WERF_REPORT_FORMAT") != "" {
+		reportFormatDefaultValue = os.Getenv("WERF_REPORT_FORMAT")
+	}
+
+	cmd.Flags().StringVarP(cmdData.ReportPath, "report-path", "", os.Getenv("WERF_REPORT_PATH"), "Write cleanup report with decision and reason for every considered image, stage and container to the specified file (default $WERF_REPORT_PATH)")
+	cmd.Flags().StringVarP(cmdData.ReportFormat, "report-format", "", reportFormatDefaultValue, "Cleanup report format, only json is supported (default $WERF_REPORT_FORMAT or json)")
+}
+
 func SetupDockerConfig(cmdData *CmdData, cmd *cobra.Command, extraDesc string) {
 	defaultValue := os.Getenv("WERF_DOCKER_CONFIG")
 	if defaultValue == "" {
@@ -443,6 +495,13 @@ func allStagesNames() []string {
 	return stageNames
 }
 
+func SetupDev(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.Dev = new(bool)
+	cmd.Flags().BoolVarP(cmdData.Dev, "dev", "", GetBoolEnvironment("WERF_DEV"), `Enable development mode (default $WERF_DEV).
+Uncommitted tracked and untracked but not ignored changes of the local git repo are added to the build.
+Stages built in development mode are stored separately, are not pushed into stages storage and are removed by host cleanup`)
+}
+
 func SetupThreeWayMergeMode(cmdData *CmdData, cmd *cobra.Command) {
 	cmdData.ThreeWayMergeMode = new(string)
 
@@ -551,7 +610,20 @@ func GetGitCommitStrategyExpiryDays(cmdData *CmdData) (int64, error) {
 	return *cmdData.GitCommitStrategyExpiryDays, nil
 }
 
-func GetImagesCleanupPolicies(cmdData *CmdData) (cleanup.ImagesCleanupPolicies, error) {
+func GetImagesCleanupPolicies(cmdData *CmdData, werfConfig *config.WerfConfig) (cleanup.ImagesCleanupPolicies, error) {
+	if len(werfConfig.Meta.Cleanup.KeepPolicies) != 0 {
+		ignoredOptions, err := getSpecifiedGitStrategyPoliciesOptions(cmdData)
+		if err != nil {
+			return cleanup.ImagesCleanupPolicies{}, err
+		}
+
+		if len(ignoredOptions) != 0 {
+			logboek.LogErrorF("WARNING: %s ignored: cleanup keep policies from werf.yaml are used\n", strings.Join(ignoredOptions, ", "))
+		}
+
+		return getImagesCleanupPoliciesByWerfConfig(werfConfig), nil
+	}
+
 	tagLimit, err := GetGitTagStrategyLimit(cmdData)
 	if err != nil {
 		return cleanup.ImagesCleanupPolicies{}, err
@@ -594,13 +666,121 @@ func GetImagesCleanupPolicies(cmdData *CmdData) (cleanup.ImagesCleanupPolicies,
 	return res, nil
 }
 
+// getSpecifiedGitStrategyPoliciesOptions returns cleanup policies options which are set by flags or environment variables
+func getSpecifiedGitStrategyPoliciesOptions(cmdData *CmdData) ([]string, error) {
+	var res []string
+	for _, option := range []struct {
+		name   string
+		envVar string
+		value  *int64
+	}{
+		{"--git-tag-strategy-limit", "WERF_GIT_TAG_STRATEGY_LIMIT", cmdData.GitTagStrategyLimit},
+		{"--git-tag-strategy-expiry-days", "WERF_GIT_TAG_STRATEGY_EXPIRY_DAYS", cmdData.GitTagStrategyExpiryDays},
+		{"--git-commit-strategy-limit", "WERF_GIT_COMMIT_STRATEGY_LIMIT", cmdData.GitCommitStrategyLimit},
+		{"--git-commit-strategy-expiry-days", "WERF_GIT_COMMIT_STRATEGY_EXPIRY_DAYS", cmdData.GitCommitStrategyExpiryDays},
+	} {
+		envValue, err := getInt64EnvVar(option.envVar)
+		if err != nil {
+			return nil, err
+		}
+
+		if envValue != nil {
+			res = append(res, fmt.Sprintf("$%s", option.envVar))
+		} else if option.value != nil && *option.value != -1 {
+			res = append(res, option.name)
+		}
+	}
+
+	return res, nil
+}
+
+func getImagesCleanupPoliciesByWerfConfig(werfConfig *config.WerfConfig) cleanup.ImagesCleanupPolicies {
+	res := cleanup.ImagesCleanupPolicies{}
+
+	for _, keepPolicy := range werfConfig.Meta.Cleanup.KeepPolicies {
+		policy := cleanup.ImagesCleanupKeepPolicy{Regexp: keepPolicy.Regexp}
+
+		switch keepPolicy.References {
+		case config.CleanupReferencesTag:
+			policy.TagStrategy = tag_strategy.GitTag
+		case config.CleanupReferencesBranch:
+			policy.TagStrategy = tag_strategy.GitBranch
+		case config.CleanupReferencesCommit:
+			policy.TagStrategy = tag_strategy.GitCommit
+		}
+
+		if keepPolicy.Limit != nil {
+			policy.HasLimit = true
+			policy.Limit = *keepPolicy.Limit
+		}
+
+		if keepPolicy.ExpiryDays != nil {
+			policy.HasExpiryPeriod = true
+			policy.ExpiryPeriod = time.Hour * 24 * time.Duration(*keepPolicy.ExpiryDays)
+		}
+
+		res.KeepPolicies = append(res.KeepPolicies, policy)
+	}
+
+	return res
+}
+
+func NewCleanupReport(cmdData *CmdData) (*cleanup.Report, error) {
+	if *cmdData.ReportPath == "" {
+		return nil, nil
+	}
+
+	switch cleanup.ReportFormat(*cmdData.ReportFormat) {
+	case cleanup.ReportFormatJson:
+	default:
+		return nil, fmt.Errorf("bad --report-format '%s': only json format is supported", *cmdData.ReportFormat)
+	}
+
+	return cleanup.NewReport(*cmdData.DryRun), nil
+}
+
+// WriteCleanupReport writes the report marked as incomplete if the cleanup has failed and returns the cleanup error
+func WriteCleanupReport(cmdData *CmdData, report *cleanup.Report, cleanupErr error) error {
+	if report == nil {
+		return cleanupErr
+	}
+
+	if cleanupErr != nil {
+		report.MarkIncomplete(cleanupErr)
+	}
+
+	if err := report.WriteFile(*cmdData.ReportPath, cleanup.ReportFormat(*cmdData.ReportFormat)); err != nil {
+		if cleanupErr != nil {
+			logboek.LogErrorF("WARNING: %s\n", err)
+			return cleanupErr
+		}
+
+		return err
+	}
+
+	if cleanupErr != nil {
+		logboek.LogF("Incomplete cleanup report written to %s\n", *cmdData.ReportPath)
+	} else {
+		logboek.LogF("Cleanup report written to %s\n", *cmdData.ReportPath)
+	}
+
+	return cleanupErr
+}
+
 func GetStagesRepo(cmdData *CmdData) (string, error) {
-	if *cmdData.StagesStorage == "" {
-		return "", fmt.Errorf("--stages-storage :local param required")
-	} else if *cmdData.StagesStorage != ":local" {
-		return "", fmt.Errorf("only --stages-storage :local is supported for now, got '%s'", *cmdData.StagesStorage)
+	stagesStorageOption := *cmdData.StagesStorage
+
+	if stagesStorageOption == "" {
+		return "", fmt.Errorf("--stages-storage :local or --stages-storage REPO param required")
+	} else if stagesStorageOption == build.LocalStagesStorage {
+		return stagesStorageOption, nil
+	}
+
+	if _, err := name.NewRepository(stagesStorageOption, name.WeakValidation); err != nil {
+		return "", fmt.Errorf("bad --stages-storage '%s': %s.\nThe registry domain defaults to Docker Hub. Do not forget to specify project repository name, REGISTRY_DOMAIN/REPOSITORY_NAME, if you do not use Docker Hub", stagesStorageOption, err)
 	}
-	return *cmdData.StagesStorage, nil
+
+	return stagesStorageOption, nil
 }
 
 func GetImagesRepo(projectName string, cmdData *CmdData) (string, error) {
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/flant/werf/pkg/build"
+)
+
+func SetupDockerfileBuilderOptions(cmdData *CmdData, cmd *cobra.Command) {
+	SetupDockerfileBuilder(cmdData, cmd)
+	SetupBuildKitAddr(cmdData, cmd)
+}
+
+func SetupDockerfileBuilder(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.DockerfileBuilder = new(string)
+
+	defaultValue := os.Getenv("WERF_DOCKERFILE_BUILDER")
+	if defaultValue == "" {
+		defaultValue = build.DefaultDockerfileBuilder
+	}
+
+	cmd.Flags().StringVarP(cmdData.DockerfileBuilder, "dockerfile-builder", "", defaultValue, fmt.Sprintf(`Backend to build images from Dockerfile: %s (default $WERF_DOCKERFILE_BUILDER or %s).
+%s builder supports RUN --mount, build secrets, ssh agent forwarding and build cache in stages storage`, strings.Join(build.DockerfileBuilders, " or "), build.DefaultDockerfileBuilder, build.BuildKitDockerfileBuilder))
+}
+
+func SetupBuildKitAddr(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.BuildKitAddr = new(string)
+	cmd.Flags().StringVarP(cmdData.BuildKitAddr, "buildkit-addr", "", os.Getenv("WERF_BUILDKIT_ADDR"), `Address of the buildkitd to use with buildkit Dockerfile builder, e.g. unix:///run/buildkit/buildkitd.sock or tcp://127.0.0.1:1234.
+BuildKit embedded into the docker daemon is used by default (default $WERF_BUILDKIT_ADDR)`)
+}
+
+func GetDockerfileBuilderOptions(cmdData *CmdData) (build.DockerfileBuilderOptions, error) {
+	opts := build.DockerfileBuilderOptions{
+		DockerfileBuilder: *cmdData.DockerfileBuilder,
+		BuildKitAddr:      *cmdData.BuildKitAddr,
+	}
+
+	switch opts.DockerfileBuilder {
+	case build.DockerDockerfileBuilder:
+		if opts.BuildKitAddr != "" {
+			return opts, fmt.Errorf("--buildkit-addr parameter can be used only with --dockerfile-builder=%s", build.BuildKitDockerfileBuilder)
+		}
+	case build.BuildKitDockerfileBuilder:
+	default:
+		return opts, fmt.Errorf("bad --dockerfile-builder '%s': only %s supported", opts.DockerfileBuilder, strings.Join(build.DockerfileBuilders, " or "))
+	}
+
+	return opts, nil
+}
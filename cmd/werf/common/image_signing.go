@@ -0,0 +1,38 @@
+package common
+
+import (
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/flant/werf/pkg/image_signing"
+)
+
+func SetupSignImages(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.SignImages = new(bool)
+	cmd.Flags().BoolVarP(cmdData.SignImages, "sign-images", "", GetBoolEnvironment("WERF_SIGN_IMAGES"), `Sign published images and push signatures into images repo next to the images (default $WERF_SIGN_IMAGES).
+The signing key is taken from $WERF_SIGNING_KEY, .werf_signing_key file in the project directory or ~/.werf/global_signing_key`)
+}
+
+func SetupVerifyImages(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.VerifyImages = new(bool)
+	cmd.Flags().BoolVarP(cmdData.VerifyImages, "verify-images", "", GetBoolEnvironment("WERF_VERIFY_IMAGES"), `Refuse to deploy images which signatures are missing or not valid (default $WERF_VERIFY_IMAGES).
+The public key is taken from $WERF_SIGNING_PUBLIC_KEY, .werf_signing_public_key file in the project directory or ~/.werf/global_signing_public_key, otherwise it is derived from the signing key`)
+}
+
+// GetSigningKey returns nil if images signing is disabled
+func GetSigningKey(cmdData *CmdData, projectDir string) (ed25519.PrivateKey, error) {
+	if !*cmdData.SignImages {
+		return nil, nil
+	}
+
+	return image_signing.GetSigningKey(projectDir)
+}
+
+// GetVerificationKey returns nil if images verification is disabled
+func GetVerificationKey(cmdData *CmdData, projectDir string) (ed25519.PublicKey, error) {
+	if !*cmdData.VerifyImages {
+		return nil, nil
+	}
+
+	return image_signing.GetVerificationKey(projectDir)
+}
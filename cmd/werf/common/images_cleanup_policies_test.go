@@ -0,0 +1,94 @@
+package common
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/flant/werf/pkg/config"
+	"github.com/flant/werf/pkg/tag_strategy"
+)
+
+func TestGetImagesCleanupPolicies_ByWerfConfig(t *testing.T) {
+	limit, expiryDays := int64(10), int64(30)
+
+	werfConfig := &config.WerfConfig{Meta: &config.Meta{Cleanup: config.Cleanup{KeepPolicies: []*config.CleanupKeepPolicy{
+		{References: config.CleanupReferencesTag, Limit: &limit},
+		{References: config.CleanupReferencesBranch, Regexp: regexp.MustCompile("^(?:release-.*)$"), ExpiryDays: &expiryDays},
+		{References: config.CleanupReferencesCommit, Limit: &limit, ExpiryDays: &expiryDays},
+	}}}}
+
+	policies, err := GetImagesCleanupPolicies(&CmdData{}, werfConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(policies.KeepPolicies) != 3 {
+		t.Fatalf("expected 3 keep policies, got %d", len(policies.KeepPolicies))
+	}
+
+	expectedStrategies := []tag_strategy.TagStrategy{tag_strategy.GitTag, tag_strategy.GitBranch, tag_strategy.GitCommit}
+	for ind, policy := range policies.KeepPolicies {
+		if policy.TagStrategy != expectedStrategies[ind] {
+			t.Errorf("policy %d: expected strategy %s, got %s", ind, expectedStrategies[ind], policy.TagStrategy)
+		}
+	}
+
+	if p := policies.KeepPolicies[0]; !p.HasLimit || p.Limit != 10 || p.HasExpiryPeriod {
+		t.Errorf("unexpected tag policy: %+v", p)
+	}
+
+	if p := policies.KeepPolicies[1]; p.HasLimit || !p.HasExpiryPeriod || p.ExpiryPeriod != 30*24*time.Hour || p.Regexp == nil {
+		t.Errorf("unexpected branch policy: %+v", p)
+	}
+
+	if policies.GitTagStrategyHasLimit || policies.GitCommitStrategyHasLimit {
+		t.Errorf("cli policies should not be used with werf.yaml keep policies: %+v", policies)
+	}
+}
+
+func TestGetSpecifiedGitStrategyPoliciesOptions(t *testing.T) {
+	int64P := func(i int64) *int64 { return &i }
+
+	cmdData := &CmdData{
+		GitTagStrategyLimit:         int64P(-1),
+		GitTagStrategyExpiryDays:    int64P(-1),
+		GitCommitStrategyLimit:      int64P(-1),
+		GitCommitStrategyExpiryDays: int64P(-1),
+	}
+
+	options, err := getSpecifiedGitStrategyPoliciesOptions(cmdData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(options) != 0 {
+		t.Errorf("expected no specified options by default, got %v", options)
+	}
+
+	*cmdData.GitTagStrategyLimit = 10
+
+	prevEnvValue, hasPrevEnvValue := os.LookupEnv("WERF_GIT_COMMIT_STRATEGY_EXPIRY_DAYS")
+	if err := os.Setenv("WERF_GIT_COMMIT_STRATEGY_EXPIRY_DAYS", "30"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if hasPrevEnvValue {
+			_ = os.Setenv("WERF_GIT_COMMIT_STRATEGY_EXPIRY_DAYS", prevEnvValue)
+		} else {
+			_ = os.Unsetenv("WERF_GIT_COMMIT_STRATEGY_EXPIRY_DAYS")
+		}
+	}()
+
+	options, err = getSpecifiedGitStrategyPoliciesOptions(cmdData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"--git-tag-strategy-limit", "$WERF_GIT_COMMIT_STRATEGY_EXPIRY_DAYS"}
+	if !reflect.DeepEqual(options, expected) {
+		t.Errorf("expected specified options %v, got %v", expected, options)
+	}
+}
@@ -0,0 +1,52 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/flant/kubedog/pkg/kube"
+	"github.com/flant/logboek"
+
+	"github.com/flant/werf/pkg/lock_manager"
+)
+
+func SetupLockBackend(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.LockBackend = new(string)
+
+	defaultValue := os.Getenv("WERF_LOCK_BACKEND")
+	if defaultValue == "" {
+		defaultValue = lock_manager.LocalBackend
+	}
+
+	cmd.Flags().StringVarP(cmdData.LockBackend, "lock-backend", "", defaultValue, fmt.Sprintf(`Backend to lock releases and repositories: %s (default $WERF_LOCK_BACKEND or %s).
+%s backend works only for werf processes on the same host, %s backend stores locks in the helm release storage namespace of the cluster and works for processes on different hosts`, strings.Join(lock_manager.Backends, " or "), lock_manager.LocalBackend, lock_manager.LocalBackend, lock_manager.KubernetesBackend))
+}
+
+// InitLockManager should be called after shluz initialization
+func InitLockManager(cmdData *CmdData) error {
+	switch *cmdData.LockBackend {
+	case lock_manager.LocalBackend:
+		lock_manager.Init(lock_manager.NewLocalLockManager())
+	case lock_manager.KubernetesBackend:
+		if cmdData.WithoutKube != nil && *cmdData.WithoutKube {
+			logboek.LogErrorF("WARNING: --lock-backend=%s ignored with --without-kube: %s locks are used\n", lock_manager.KubernetesBackend, lock_manager.LocalBackend)
+			lock_manager.Init(lock_manager.NewLocalLockManager())
+			return nil
+		}
+
+		if kube.Kubernetes == nil {
+			if err := kube.Init(kube.InitOptions{KubeContext: *cmdData.KubeContext, KubeConfig: *cmdData.KubeConfig}); err != nil {
+				return fmt.Errorf("cannot initialize kube: %s", err)
+			}
+		}
+
+		lock_manager.Init(lock_manager.NewKubernetesLockManager(kube.Kubernetes, *cmdData.HelmReleaseStorageNamespace))
+	default:
+		return fmt.Errorf("bad --lock-backend '%s': only %s supported", *cmdData.LockBackend, strings.Join(lock_manager.Backends, " or "))
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/flant/werf/pkg/lock_manager"
+)
+
+func TestInitLockManager_WithoutKube(t *testing.T) {
+	defer lock_manager.Init(lock_manager.NewLocalLockManager())
+
+	lockBackend := lock_manager.KubernetesBackend
+	withoutKube := true
+	cmdData := &CmdData{LockBackend: &lockBackend, WithoutKube: &withoutKube}
+
+	if err := InitLockManager(cmdData); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if lock_manager.IsDistributed() {
+		t.Errorf("expected local lock manager with --without-kube")
+	}
+}
@@ -0,0 +1,97 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+
+	"github.com/flant/werf/pkg/build"
+)
+
+func SetupParallelOptions(cmdData *CmdData, cmd *cobra.Command) {
+	SetupParallel(cmdData, cmd)
+	SetupParallelTasksLimit(cmdData, cmd)
+}
+
+func SetupParallel(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.Parallel = new(bool)
+	cmd.Flags().BoolVarP(cmdData.Parallel, "parallel", "p", GetBoolEnvironment("WERF_PARALLEL"), `Build independent images in parallel (default $WERF_PARALLEL).
+Output of each image build is collected and printed as a separate block when the image is built`)
+}
+
+func SetupParallelTasksLimit(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.ParallelTasksLimit = new(int64)
+
+	defaultValueP, err := getInt64EnvVar("WERF_PARALLEL_TASKS_LIMIT")
+	if err != nil {
+		TerminateWithError(fmt.Sprintf("bad WERF_PARALLEL_TASKS_LIMIT value: %s", err), 1)
+	}
+
+	defaultValue := int64(build.DefaultParallelTasksLimit)
+	if defaultValueP != nil {
+		defaultValue = *defaultValueP
+	}
+
+	cmd.Flags().Int64VarP(cmdData.ParallelTasksLimit, "parallel-tasks-limit", "", defaultValue, fmt.Sprintf("Parallel tasks limit (default $WERF_PARALLEL_TASKS_LIMIT or %d)", build.DefaultParallelTasksLimit))
+}
+
+func GetParallelOptions(cmdData *CmdData, projectDir string, introspectOptions build.IntrospectOptions) (build.ParallelOptions, error) {
+	parallelOptions := build.ParallelOptions{}
+
+	if !*cmdData.Parallel {
+		return parallelOptions, nil
+	}
+
+	if *cmdData.ParallelTasksLimit <= 0 {
+		return parallelOptions, fmt.Errorf("--parallel-tasks-limit parameter (%d) should be positive", *cmdData.ParallelTasksLimit)
+	}
+
+	if len(introspectOptions.Targets) != 0 {
+		return parallelOptions, fmt.Errorf("--introspect-stage parameter cannot be used with --parallel")
+	}
+
+	werfPath, err := os.Executable()
+	if err != nil {
+		return parallelOptions, fmt.Errorf("unable to get werf executable path: %s", err)
+	}
+
+	parallelOptions.Parallel = true
+	parallelOptions.ParallelTasksLimit = *cmdData.ParallelTasksLimit
+	parallelOptions.ImageBuildTaskFunc = func(imageName string) (*exec.Cmd, error) {
+		args := []string{
+			"stages", "build", imageName,
+			"--dir", projectDir,
+			"--stages-storage", *cmdData.StagesStorage,
+			"--docker-config", *cmdData.DockerConfig,
+			fmt.Sprintf("--insecure-registry=%t", *cmdData.InsecureRegistry),
+			fmt.Sprintf("--skip-tls-verify-registry=%t", *cmdData.SkipTlsVerifyRegistry),
+			"--tmp-dir", *cmdData.TmpDir,
+			"--home-dir", *cmdData.HomeDir,
+			fmt.Sprintf("--log-pretty=%t", *cmdData.LogPretty),
+			"--parallel=false",
+			"--provenance-path=",
+		}
+
+		if cmdData.Dev != nil && *cmdData.Dev {
+			args = append(args, "--dev")
+		}
+
+		if cmdData.PolicyMode != nil {
+			args = append(args, "--policy-mode", *cmdData.PolicyMode)
+		}
+
+		if cmdData.DockerfileBuilder != nil {
+			args = append(args, "--dockerfile-builder", *cmdData.DockerfileBuilder, "--buildkit-addr", *cmdData.BuildKitAddr)
+		}
+
+		for _, sshKey := range *cmdData.SSHKeys {
+			args = append(args, "--ssh-key", sshKey)
+		}
+
+		return exec.Command(werfPath, args...), nil
+	}
+
+	return parallelOptions, nil
+}
@@ -0,0 +1,56 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/flant/werf/pkg/build"
+)
+
+func TestGetParallelOptions_ImageBuildTaskArgs(t *testing.T) {
+	stringP := func(s string) *string { return &s }
+	boolP := func(b bool) *bool { return &b }
+	int64P := func(i int64) *int64 { return &i }
+
+	cmdData := &CmdData{
+		Parallel:              boolP(true),
+		ParallelTasksLimit:    int64P(2),
+		StagesStorage:         stringP(":local"),
+		DockerConfig:          stringP("/docker-config"),
+		InsecureRegistry:      boolP(false),
+		SkipTlsVerifyRegistry: boolP(false),
+		TmpDir:                stringP("/tmp-dir"),
+		HomeDir:               stringP("/home-dir"),
+		LogPretty:             boolP(true),
+		SSHKeys:               &[]string{},
+		PolicyMode:            stringP(string(build.PolicyModeWarn)),
+	}
+
+	parallelOptions, err := GetParallelOptions(cmdData, "/project", build.IntrospectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd, err := parallelOptions.ImageBuildTaskFunc("backend")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := strings.Join(cmd.Args[1:], " ")
+	for _, expected := range []string{"stages build backend", "--dir /project", "--parallel=false", "--policy-mode warn"} {
+		if !strings.Contains(args, expected) {
+			t.Errorf("expected %q in image build task args: %s", expected, args)
+		}
+	}
+
+	cmdData.PolicyMode = nil
+
+	cmd, err = parallelOptions.ImageBuildTaskFunc("backend")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if args := strings.Join(cmd.Args[1:], " "); strings.Contains(args, "--policy-mode") {
+		t.Errorf("unexpected --policy-mode in image build task args of command without policies: %s", args)
+	}
+}
@@ -0,0 +1,30 @@
+package common
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/flant/werf/pkg/build"
+)
+
+func SetupPolicyMode(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.PolicyMode = new(string)
+
+	defaultValue := os.Getenv("WERF_POLICY_MODE")
+	if defaultValue == "" {
+		defaultValue = string(build.PolicyModeFail)
+	}
+
+	cmd.Flags().StringVarP(cmdData.PolicyMode, "policy-mode", "", defaultValue, `How to handle violations of images policies from werf.yaml: fail or warn (default $WERF_POLICY_MODE or fail)`)
+}
+
+func GetPolicyOptions(cmdData *CmdData) (build.PolicyOptions, error) {
+	switch mode := build.PolicyMode(*cmdData.PolicyMode); mode {
+	case build.PolicyModeFail, build.PolicyModeWarn:
+		return build.PolicyOptions{PolicyMode: mode}, nil
+	default:
+		return build.PolicyOptions{}, fmt.Errorf("bad --policy-mode '%s': only %s or %s supported", *cmdData.PolicyMode, build.PolicyModeFail, build.PolicyModeWarn)
+	}
+}
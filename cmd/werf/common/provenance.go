@@ -0,0 +1,23 @@
+package common
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/flant/werf/pkg/build"
+)
+
+func SetupProvenancePath(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.ProvenancePath = new(string)
+	cmd.Flags().StringVarP(cmdData.ProvenancePath, "provenance-path", "", os.Getenv("WERF_PROVENANCE_PATH"), `Write provenance of the images into the specified file: stages chain with signatures, base image, git commits and imports used by each image (default $WERF_PROVENANCE_PATH)`)
+}
+
+func SetupPushProvenance(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.PushProvenance = new(bool)
+	cmd.Flags().BoolVarP(cmdData.PushProvenance, "push-provenance", "", GetBoolEnvironment("WERF_PUSH_PROVENANCE"), `Push provenance of each published image into images repo next to the image (default $WERF_PUSH_PROVENANCE)`)
+}
+
+func GetProvenanceOptions(cmdData *CmdData) build.ProvenanceOptions {
+	return build.ProvenanceOptions{ProvenancePath: *cmdData.ProvenancePath}
+}
@@ -0,0 +1,44 @@
+package common
+
+import (
+	"github.com/flant/werf/pkg/config"
+	"github.com/flant/werf/pkg/stages_info"
+)
+
+func GetStagesInfoOptions(cmdData *CmdData, werfConfig *config.WerfConfig) (stages_info.Options, error) {
+	opts := stages_info.Options{ProjectName: werfConfig.Meta.Project}
+
+	stagesRepo, err := GetStagesRepo(cmdData)
+	if err != nil {
+		return opts, err
+	}
+	opts.StagesStorage = stagesRepo
+
+	imagesRepo, err := GetOptionalImagesRepo(werfConfig.Meta.Project, cmdData)
+	if err != nil {
+		return opts, err
+	}
+
+	if imagesRepo != "" {
+		imagesRepoMode, err := GetImagesRepoMode(cmdData)
+		if err != nil {
+			return opts, err
+		}
+
+		imagesRepoManager, err := GetImagesRepoManager(imagesRepo, imagesRepoMode)
+		if err != nil {
+			return opts, err
+		}
+		opts.ImagesRepoManager = imagesRepoManager
+
+		for _, image := range werfConfig.StapelImages {
+			opts.ImagesNames = append(opts.ImagesNames, image.Name)
+		}
+
+		for _, image := range werfConfig.ImagesFromDockerfile {
+			opts.ImagesNames = append(opts.ImagesNames, image.Name)
+		}
+	}
+
+	return opts, nil
+}
@@ -27,6 +27,9 @@ func GetDeployTag(cmdData *CmdData, opts TagOptionsGetterOptions) (string, tag_s
 	if *cmdData.TagGitCommit != "" {
 		optionsCount++
 	}
+	if *cmdData.TagByStagesSignature {
+		optionsCount++
+	}
 
 	if optionsCount > 1 {
 		return "", "", fmt.Errorf("exactly one tag should be specified for deploy")
@@ -45,6 +48,9 @@ func GetDeployTag(cmdData *CmdData, opts TagOptionsGetterOptions) (string, tag_s
 		return tagOpts.TagsByGitTag[0], tag_strategy.GitTag, nil
 	} else if len(tagOpts.TagsByGitCommit) > 0 {
 		return tagOpts.TagsByGitCommit[0], tag_strategy.GitCommit, nil
+	} else if tagOpts.TagByStagesSignature {
+		// tag of each image is its stages signature which is calculated by the conveyor
+		return "", tag_strategy.StagesSignature, nil
 	}
 
 	if !opts.Optional {
@@ -99,8 +105,13 @@ func GetTagOptions(cmdData *CmdData, opts TagOptionsGetterOptions) (build.TagOpt
 		emptyTags = false
 	}
 
+	if *cmdData.TagByStagesSignature {
+		res.TagByStagesSignature = true
+		emptyTags = false
+	}
+
 	if emptyTags && !opts.Optional {
-		return build.TagOptions{}, fmt.Errorf("at least one tag should be specified with --tag-custom|--tag-git-tag|--tag-git-branch|--tag-git-commit options")
+		return build.TagOptions{}, fmt.Errorf("at least one tag should be specified with --tag-custom|--tag-git-tag|--tag-git-branch|--tag-git-commit|--tag-by-stages-signature options")
 	}
 
 	return res, nil
@@ -2,6 +2,7 @@ package deploy
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"time"
 
@@ -24,8 +25,14 @@ import (
 	"github.com/flant/werf/pkg/werf"
 )
 
+// DiffHasChangesExitCode is returned by deploy with --diff option when the release has changes
+const DiffHasChangesExitCode = 2
+
 var CmdData struct {
-	Timeout int
+	Timeout      int
+	AutoRollback bool
+	Diff         bool
+	DiffLive     bool
 }
 
 var CommonCmdData common.CmdData
@@ -42,6 +49,10 @@ Deploy needs the same parameters as push to construct image names: repo and tags
 
 Helm chart directory .helm should exists and contain valid Helm chart.
 
+With --auto-rollback (or --atomic) option failed release upgrade is rolled back to the latest successfully deployed revision and failed release installation is deleted.
+
+With --diff option command only shows changes of the release resources and does not change anything in the cluster. Secret data values are masked.
+
 Environment is a required param for the deploy by default, because it is needed to construct Helm Release name and Kubernetes Namespace. Either --env or $WERF_ENV should be specified for command.
 
 Read more info about Helm chart structure, Helm Release name, Kubernetes Namespace and how to change it: https://werf.io/documentation/reference/deploy_process/deploy_into_kubernetes.html`),
@@ -49,7 +60,10 @@ Read more info about Helm chart structure, Helm Release name, Kubernetes Namespa
   $ werf deploy --stages-storage :local --env dev --images-repo registry.mydomain.com/myproject --tag-git-tag mytag
 
   # Deploy project using specified helm release name and namespace using images from registry.mydomain.com/myproject tagged with docker tag 'myversion'
-  $ werf deploy --stages-storage :local --release myrelease --namespace myns --images-repo registry.mydomain.com/myproject --tag-custom myversion`,
+  $ werf deploy --stages-storage :local --release myrelease --namespace myns --images-repo registry.mydomain.com/myproject --tag-custom myversion
+
+  # Show changes which deploy into 'production' environment would bring compared with the objects in the cluster
+  $ werf deploy --stages-storage :local --env production --images-repo registry.mydomain.com/myproject --tag-git-tag mytag --diff --diff-live`,
 		DisableFlagsInUseLine: true,
 		Annotations: map[string]string{
 			common.CmdEnvAnno: common.EnvsDescription(common.WerfSecretKey),
@@ -61,9 +75,15 @@ Read more info about Helm chart structure, Helm Release name, Kubernetes Namespa
 			}
 			common.LogVersion()
 
-			return common.LogRunningTime(func() error {
+			err := common.LogRunningTime(func() error {
 				return runDeploy()
 			})
+
+			if err == deploy.ErrDiffHasChanges {
+				os.Exit(DiffHasChangesExitCode)
+			}
+
+			return err
 		},
 	}
 
@@ -83,6 +103,7 @@ Read more info about Helm chart structure, Helm Release name, Kubernetes Namespa
 	common.SetupKubeContext(&CommonCmdData, cmd)
 	common.SetupHelmReleaseStorageNamespace(&CommonCmdData, cmd)
 	common.SetupHelmReleaseStorageType(&CommonCmdData, cmd)
+	common.SetupLockBackend(&CommonCmdData, cmd)
 	common.SetupStatusProgressPeriod(&CommonCmdData, cmd)
 	common.SetupHooksStatusProgressPeriod(&CommonCmdData, cmd)
 	common.SetupReleasesHistoryMax(&CommonCmdData, cmd)
@@ -93,6 +114,7 @@ Read more info about Helm chart structure, Helm Release name, Kubernetes Namespa
 	common.SetupDockerConfig(&CommonCmdData, cmd, "Command needs granted permissions to read and pull images from the specified stages storage and images repo")
 	common.SetupInsecureRegistry(&CommonCmdData, cmd)
 	common.SetupSkipTlsVerifyRegistry(&CommonCmdData, cmd)
+	common.SetupVerifyImages(&CommonCmdData, cmd)
 
 	common.SetupLogOptions(&CommonCmdData, cmd)
 	common.SetupLogProjectDir(&CommonCmdData, cmd)
@@ -106,6 +128,10 @@ Read more info about Helm chart structure, Helm Release name, Kubernetes Namespa
 	common.SetupThreeWayMergeMode(&CommonCmdData, cmd)
 
 	cmd.Flags().IntVarP(&CmdData.Timeout, "timeout", "t", 0, "Resources tracking timeout in seconds")
+	cmd.Flags().BoolVarP(&CmdData.AutoRollback, "auto-rollback", "", common.GetBoolEnvironment("WERF_AUTO_ROLLBACK"), "Roll back failed release upgrade to the latest successfully deployed revision and delete failed release installation (default $WERF_AUTO_ROLLBACK)")
+	cmd.Flags().BoolVarP(&CmdData.AutoRollback, "atomic", "", common.GetBoolEnvironment("WERF_AUTO_ROLLBACK"), "Same as --auto-rollback")
+	cmd.Flags().BoolVarP(&CmdData.Diff, "diff", "", common.GetBoolEnvironment("WERF_DIFF"), fmt.Sprintf("Only show changes of the release resources compared with the latest deployed release revision without changing anything in the cluster, exit with code %d if there are changes (default $WERF_DIFF)", DiffHasChangesExitCode))
+	cmd.Flags().BoolVarP(&CmdData.DiffLive, "diff-live", "", common.GetBoolEnvironment("WERF_DIFF_LIVE"), "Same as --diff, but compare with the objects in the cluster instead of the latest deployed release revision (default $WERF_DIFF_LIVE)")
 
 	return cmd
 }
@@ -164,6 +190,10 @@ func runDeploy() error {
 		return fmt.Errorf("cannot init kubedog: %s", err)
 	}
 
+	if err := common.InitLockManager(&CommonCmdData); err != nil {
+		return err
+	}
+
 	projectDir, err := common.GetProjectDir(&CommonCmdData)
 	if err != nil {
 		return fmt.Errorf("getting project dir failed: %s", err)
@@ -185,9 +215,11 @@ func runDeploy() error {
 	var imagesRepoManager *common.ImagesRepoManager
 	var tag string
 	var tagStrategy tag_strategy.TagStrategy
+	var imagesTags map[string]string
 	if len(werfConfig.StapelImages) != 0 || len(werfConfig.ImagesFromDockerfile) != 0 {
+		stagesRepo := build.LocalStagesStorage
 		if len(werfConfig.StapelImages) != 0 {
-			_, err = common.GetStagesRepo(&CommonCmdData)
+			stagesRepo, err = common.GetStagesRepo(&CommonCmdData)
 			if err != nil {
 				return err
 			}
@@ -223,18 +255,33 @@ func runDeploy() error {
 			}
 		}()
 
-		c := build.NewConveyor(werfConfig, []string{}, projectDir, projectTmpDir, ssh_agent.SSHAuthSock)
+		c := build.NewConveyor(werfConfig, []string{}, projectDir, projectTmpDir, ssh_agent.SSHAuthSock, stagesRepo, build.ConveyorOptions{})
 		defer c.Terminate()
 
 		if err = c.ShouldBeBuilt(); err != nil {
 			return err
 		}
+
+		if tagStrategy == tag_strategy.StagesSignature {
+			imagesTags = map[string]string{}
+			for _, image := range werfConfig.StapelImages {
+				imagesTags[image.Name] = c.GetImageLatestStageSignature(image.Name)
+			}
+			for _, image := range werfConfig.ImagesFromDockerfile {
+				imagesTags[image.Name] = c.GetImageLatestStageSignature(image.Name)
+			}
+		}
 	}
 
 	if imagesRepoManager == nil {
 		imagesRepoManager = &common.ImagesRepoManager{}
 	}
 
+	verificationKey, err := common.GetVerificationKey(&CommonCmdData, projectDir)
+	if err != nil {
+		return err
+	}
+
 	release, err := common.GetHelmRelease(*CommonCmdData.Release, *CommonCmdData.Environment, werfConfig)
 	if err != nil {
 		return err
@@ -266,5 +313,10 @@ func runDeploy() error {
 		UserExtraLabels:      userExtraLabels,
 		IgnoreSecretKey:      *CommonCmdData.IgnoreSecretKey,
 		ThreeWayMergeMode:    threeWayMergeMode,
+		AutoRollback:         CmdData.AutoRollback,
+		VerificationKey:      verificationKey,
+		ImagesTags:           imagesTags,
+		Diff:                 CmdData.Diff || CmdData.DiffLive,
+		DiffOptions:          helm.DiffOptions{Live: CmdData.DiffLive},
 	})
 }
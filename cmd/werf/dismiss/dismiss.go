@@ -65,6 +65,7 @@ Read more info about Helm Release name, Kubernetes Namespace and how to change i
 	common.SetupKubeContext(&CommonCmdData, cmd)
 	common.SetupHelmReleaseStorageNamespace(&CommonCmdData, cmd)
 	common.SetupHelmReleaseStorageType(&CommonCmdData, cmd)
+	common.SetupLockBackend(&CommonCmdData, cmd)
 	common.SetupReleasesHistoryMax(&CommonCmdData, cmd)
 
 	common.SetupDockerConfig(&CommonCmdData, cmd, "")
@@ -131,6 +132,10 @@ func runDismiss() error {
 		return fmt.Errorf("cannot init kubedog: %s", err)
 	}
 
+	if err := common.InitLockManager(&CommonCmdData); err != nil {
+		return err
+	}
+
 	release, err := common.GetHelmRelease(*CommonCmdData.Release, *CommonCmdData.Environment, werfConfig)
 	if err != nil {
 		return err
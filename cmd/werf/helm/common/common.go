@@ -42,7 +42,11 @@ func GetTagOrStub(commonCmdData *common.CmdData) (string, tag_strategy.TagStrate
 	}
 
 	if tag == "" {
-		tag, tagStrategy = "TAG", tag_strategy.Custom
+		if tagStrategy == tag_strategy.StagesSignature {
+			tag = "STAGES_SIGNATURE"
+		} else {
+			tag, tagStrategy = "TAG", tag_strategy.Custom
+		}
 	}
 
 	return tag, tagStrategy, nil
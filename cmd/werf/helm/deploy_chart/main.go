@@ -69,6 +69,7 @@ func NewCmd() *cobra.Command {
 	common.SetupKubeContext(&CommonCmdData, cmd)
 	common.SetupHelmReleaseStorageNamespace(&CommonCmdData, cmd)
 	common.SetupHelmReleaseStorageType(&CommonCmdData, cmd)
+	common.SetupLockBackend(&CommonCmdData, cmd)
 	common.SetupStatusProgressPeriod(&CommonCmdData, cmd)
 	common.SetupHooksStatusProgressPeriod(&CommonCmdData, cmd)
 
@@ -146,6 +147,10 @@ func runDeployChart(chartDirOrChartReference string, releaseName string) error {
 		return fmt.Errorf("cannot init kubedog: %s", err)
 	}
 
+	if err := common.InitLockManager(&CommonCmdData); err != nil {
+		return err
+	}
+
 	namespace := CmdData.Namespace
 	if namespace == "" {
 		namespace = kube.DefaultNamespace
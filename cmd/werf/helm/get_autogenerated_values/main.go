@@ -140,7 +140,7 @@ func runGetServiceValues() error {
 		}
 	}()
 
-	images := deploy.GetImagesInfoGetters(werfConfig.StapelImages, werfConfig.ImagesFromDockerfile, imagesRepoManager, tag, withoutRepo)
+	images := deploy.GetImagesInfoGetters(werfConfig.StapelImages, werfConfig.ImagesFromDockerfile, imagesRepoManager, tag, nil, withoutRepo)
 
 	serviceValues, err := deploy.GetServiceValues(werfConfig.Meta.Project, imagesRepoManager, namespace, tag, tagStrategy, images, deploy.ServiceValuesOptions{Env: environment})
 	if err != nil {
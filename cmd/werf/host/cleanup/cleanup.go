@@ -2,8 +2,11 @@ package cleanup
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/docker/go-units"
 	"github.com/flant/shluz"
 
 	"github.com/spf13/cobra"
@@ -17,7 +20,10 @@ import (
 	"github.com/flant/werf/pkg/werf"
 )
 
-var CmdData struct{}
+var CmdData struct {
+	CacheMountsMaxSize string
+	CacheMountsMaxAge  string
+}
 
 var CommonCmdData common.CmdData
 
@@ -33,6 +39,7 @@ The data include:
 * Local cache:
   * Remote git clones cache.
   * Git worktree cache.
+* Cache mounts docker volumes (mount from: cache) which exceed size limit or have not been used for a long time.
 
 It is safe to run this command periodically by automated cleanup job in parallel with other werf commands such as build, deploy, stages and images cleanup.`),
 		DisableFlagsInUseLine: true,
@@ -58,11 +65,33 @@ It is safe to run this command periodically by automated cleanup job in parallel
 	common.SetupLogOptions(&CommonCmdData, cmd)
 
 	common.SetupDryRun(&CommonCmdData, cmd)
+	common.SetupReportOptions(&CommonCmdData, cmd)
+
+	cmd.Flags().StringVarP(&CmdData.CacheMountsMaxSize, "cache-mounts-max-size", "", getEnvOrDefault("WERF_CACHE_MOUNTS_MAX_SIZE", "10GiB"), "Remove cache mounts volumes which exceed the size, 0 disables the limit (default $WERF_CACHE_MOUNTS_MAX_SIZE or 10GiB)")
+	cmd.Flags().StringVarP(&CmdData.CacheMountsMaxAge, "cache-mounts-max-age", "", getEnvOrDefault("WERF_CACHE_MOUNTS_MAX_AGE", "168h"), "Remove cache mounts volumes which have not been used for the duration, 0 disables the limit (default $WERF_CACHE_MOUNTS_MAX_AGE or 168h)")
 
 	return cmd
 }
 
+func getEnvOrDefault(envName, defaultValue string) string {
+	if v := os.Getenv(envName); v != "" {
+		return v
+	}
+
+	return defaultValue
+}
+
 func runGC() error {
+	cacheMountsMaxSize, err := units.RAMInBytes(CmdData.CacheMountsMaxSize)
+	if err != nil {
+		return fmt.Errorf("bad --cache-mounts-max-size '%s': %s", CmdData.CacheMountsMaxSize, err)
+	}
+
+	cacheMountsMaxAge, err := time.ParseDuration(CmdData.CacheMountsMaxAge)
+	if err != nil {
+		return fmt.Errorf("bad --cache-mounts-max-age '%s': %s", CmdData.CacheMountsMaxAge, err)
+	}
+
 	if err := werf.Init(*CommonCmdData.TmpDir, *CommonCmdData.HomeDir); err != nil {
 		return fmt.Errorf("initialization error: %s", err)
 	}
@@ -83,11 +112,19 @@ func runGC() error {
 		return err
 	}
 
-	logboek.LogOptionalLn()
-	hostCleanupOptions := cleaning.HostCleanupOptions{DryRun: *CommonCmdData.DryRun}
-	if err := cleaning.HostCleanup(hostCleanupOptions); err != nil {
+	report, err := common.NewCleanupReport(&CommonCmdData)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	logboek.LogOptionalLn()
+	hostCleanupOptions := cleaning.HostCleanupOptions{
+		DryRun:             *CommonCmdData.DryRun,
+		Report:             report,
+		CacheMountsMaxSize: cacheMountsMaxSize,
+		CacheMountsMaxAge:  cacheMountsMaxAge,
+	}
+	cleanupErr := cleaning.HostCleanup(hostCleanupOptions)
+
+	return common.WriteCleanupReport(&CommonCmdData, report, cleanupErr)
 }
@@ -49,15 +49,19 @@ func NewCmd() *cobra.Command {
 	common.SetupDockerConfig(&CommonCmdData, cmd, "Command needs granted permissions to delete images from the specified images repo")
 	common.SetupInsecureRegistry(&CommonCmdData, cmd)
 	common.SetupSkipTlsVerifyRegistry(&CommonCmdData, cmd)
+	common.SetupRepoImplementation(&CommonCmdData, cmd)
 	common.SetupImagesCleanupPolicies(&CommonCmdData, cmd)
 
 	common.SetupKubeConfig(&CommonCmdData, cmd)
 	common.SetupKubeContext(&CommonCmdData, cmd)
+	common.SetupHelmReleaseStorageNamespace(&CommonCmdData, cmd)
+	common.SetupLockBackend(&CommonCmdData, cmd)
 
 	common.SetupLogOptions(&CommonCmdData, cmd)
 	common.SetupLogProjectDir(&CommonCmdData, cmd)
 
 	common.SetupDryRun(&CommonCmdData, cmd)
+	common.SetupReportOptions(&CommonCmdData, cmd)
 
 	common.SetupWithoutKube(&CommonCmdData, cmd)
 
@@ -73,7 +77,7 @@ func runCleanup() error {
 		return err
 	}
 
-	if err := docker_registry.Init(docker_registry.Options{InsecureRegistry: *CommonCmdData.InsecureRegistry, SkipTlsVerifyRegistry: *CommonCmdData.SkipTlsVerifyRegistry}); err != nil {
+	if err := docker_registry.Init(docker_registry.Options{InsecureRegistry: *CommonCmdData.InsecureRegistry, SkipTlsVerifyRegistry: *CommonCmdData.SkipTlsVerifyRegistry, Implementation: *CommonCmdData.RepoImplementation, QuayToken: *CommonCmdData.RepoQuayToken}); err != nil {
 		return err
 	}
 
@@ -89,6 +93,10 @@ func runCleanup() error {
 		return fmt.Errorf("cannot init kubedog: %s", err)
 	}
 
+	if err := common.InitLockManager(&CommonCmdData); err != nil {
+		return err
+	}
+
 	projectDir, err := common.GetProjectDir(&CommonCmdData)
 	if err != nil {
 		return fmt.Errorf("getting project dir failed: %s", err)
@@ -144,7 +152,7 @@ func runCleanup() error {
 		}
 	}
 
-	policies, err := common.GetImagesCleanupPolicies(&CommonCmdData)
+	policies, err := common.GetImagesCleanupPolicies(&CommonCmdData, werfConfig)
 	if err != nil {
 		return err
 	}
@@ -154,11 +162,17 @@ func runCleanup() error {
 		return fmt.Errorf("unable to get Kubernetes clusters connections: %s", err)
 	}
 
+	report, err := common.NewCleanupReport(&CommonCmdData)
+	if err != nil {
+		return err
+	}
+
 	imagesCleanupOptions := cleaning.ImagesCleanupOptions{
 		CommonRepoOptions: cleaning.CommonRepoOptions{
 			ImagesRepoManager: imagesRepoManager,
 			ImagesNames:       imagesNames,
 			DryRun:            *CommonCmdData.DryRun,
+			Report:            report,
 		},
 		LocalGit:                  localRepo,
 		KubernetesContextsClients: kubernetesContextsClients,
@@ -167,9 +181,7 @@ func runCleanup() error {
 	}
 
 	logboek.LogOptionalLn()
-	if err := cleaning.ImagesCleanup(imagesCleanupOptions); err != nil {
-		return err
-	}
+	cleanupErr := cleaning.ImagesCleanup(imagesCleanupOptions)
 
-	return nil
+	return common.WriteCleanupReport(&CommonCmdData, report, cleanupErr)
 }
@@ -0,0 +1,206 @@
+package export
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/flant/shluz"
+
+	"github.com/spf13/cobra"
+
+	"github.com/flant/logboek"
+	"github.com/flant/werf/cmd/werf/common"
+	"github.com/flant/werf/pkg/build"
+	"github.com/flant/werf/pkg/docker"
+	"github.com/flant/werf/pkg/docker_registry"
+	"github.com/flant/werf/pkg/images_archive"
+	"github.com/flant/werf/pkg/logging"
+	"github.com/flant/werf/pkg/ssh_agent"
+	"github.com/flant/werf/pkg/tag_strategy"
+	"github.com/flant/werf/pkg/tmp_manager"
+	"github.com/flant/werf/pkg/true_git"
+	"github.com/flant/werf/pkg/werf"
+)
+
+var CmdData struct {
+	Output     string
+	WithStages bool
+}
+
+var CommonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export [IMAGE_NAME...]",
+		Short: "Export published images into OCI archive",
+		Long: common.GetLongCommandDescription(`Export images published into images repo with the specified tag into a single OCI image layout tarball.
+
+The archive also contains werf-images.json manifest which maps werf images names to digests of images in the archive. Use werf images import to load the archive into images repo or local docker on the other side, werf deploy with the same tag can be used then.
+
+If one or more IMAGE_NAME parameters specified, werf will export only these images from werf.yaml.`),
+		Example: `  # Export images tagged by git tag v1.2.3 with their stages
+  $ werf images export --images-repo registry.mydomain.com/myproject --stages-storage registry.mydomain.com/myproject/stages --tag-git-tag v1.2.3 --with-stages --output images.tar`,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&CommonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+			common.LogVersion()
+
+			return common.LogRunningTime(func() error {
+				return runExport(args)
+			})
+		},
+	}
+
+	common.SetupDir(&CommonCmdData, cmd)
+	common.SetupTmpDir(&CommonCmdData, cmd)
+	common.SetupHomeDir(&CommonCmdData, cmd)
+	common.SetupSSHKey(&CommonCmdData, cmd)
+
+	common.SetupTag(&CommonCmdData, cmd)
+
+	common.SetupStagesStorage(&CommonCmdData, cmd)
+	common.SetupImagesRepo(&CommonCmdData, cmd)
+	common.SetupImagesRepoMode(&CommonCmdData, cmd)
+	common.SetupDockerConfig(&CommonCmdData, cmd, "Command needs granted permissions to read and pull images from the specified images repo and stages storage")
+	common.SetupInsecureRegistry(&CommonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&CommonCmdData, cmd)
+
+	common.SetupLogOptions(&CommonCmdData, cmd)
+	common.SetupLogProjectDir(&CommonCmdData, cmd)
+
+	cmd.Flags().StringVarP(&CmdData.Output, "output", "o", "", "Path of the archive to write (required)")
+	cmd.Flags().BoolVarP(&CmdData.WithStages, "with-stages", "", common.GetBoolEnvironment("WERF_WITH_STAGES"), "Also export stages chain of each image from the stages storage (default $WERF_WITH_STAGES)")
+
+	return cmd
+}
+
+func runExport(imagesToProcess []string) error {
+	if CmdData.Output == "" {
+		return fmt.Errorf("--output ARCHIVE_PATH param required")
+	}
+
+	if err := werf.Init(*CommonCmdData.TmpDir, *CommonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := shluz.Init(filepath.Join(werf.GetServiceDir(), "locks")); err != nil {
+		return err
+	}
+
+	if err := true_git.Init(true_git.Options{Out: logboek.GetOutStream(), Err: logboek.GetErrStream()}); err != nil {
+		return err
+	}
+
+	if err := docker_registry.Init(docker_registry.Options{InsecureRegistry: *CommonCmdData.InsecureRegistry, SkipTlsVerifyRegistry: *CommonCmdData.SkipTlsVerifyRegistry}); err != nil {
+		return err
+	}
+
+	if err := docker.Init(*CommonCmdData.DockerConfig); err != nil {
+		return err
+	}
+
+	projectDir, err := common.GetProjectDir(&CommonCmdData)
+	if err != nil {
+		return fmt.Errorf("getting project dir failed: %s", err)
+	}
+
+	common.ProcessLogProjectDir(&CommonCmdData, projectDir)
+
+	werfConfig, err := common.GetWerfConfig(projectDir)
+	if err != nil {
+		return fmt.Errorf("bad config: %s", err)
+	}
+
+	for _, imageToProcess := range imagesToProcess {
+		if !werfConfig.HasImage(imageToProcess) {
+			return fmt.Errorf("specified image %s is not defined in werf.yaml", logging.ImageLogName(imageToProcess, false))
+		}
+	}
+
+	if len(imagesToProcess) == 0 {
+		for _, image := range werfConfig.StapelImages {
+			imagesToProcess = append(imagesToProcess, image.Name)
+		}
+
+		for _, image := range werfConfig.ImagesFromDockerfile {
+			imagesToProcess = append(imagesToProcess, image.Name)
+		}
+	}
+
+	projectName := werfConfig.Meta.Project
+
+	imagesRepo, err := common.GetImagesRepo(projectName, &CommonCmdData)
+	if err != nil {
+		return err
+	}
+
+	imagesRepoMode, err := common.GetImagesRepoMode(&CommonCmdData)
+	if err != nil {
+		return err
+	}
+
+	imagesRepoManager, err := common.GetImagesRepoManager(imagesRepo, imagesRepoMode)
+	if err != nil {
+		return err
+	}
+
+	tag, tagStrategy, err := common.GetDeployTag(&CommonCmdData, common.TagOptionsGetterOptions{})
+	if err != nil {
+		return err
+	}
+
+	var stagesRepo string
+	if CmdData.WithStages || tagStrategy == tag_strategy.StagesSignature {
+		stagesRepo, err = common.GetStagesRepo(&CommonCmdData)
+		if err != nil {
+			return err
+		}
+	}
+
+	imagesTags := map[string]string{}
+	if tagStrategy == tag_strategy.StagesSignature {
+		projectTmpDir, err := tmp_manager.CreateProjectDir()
+		if err != nil {
+			return fmt.Errorf("getting project tmp dir failed: %s", err)
+		}
+		defer tmp_manager.ReleaseProjectDir(projectTmpDir)
+
+		if err := ssh_agent.Init(*CommonCmdData.SSHKeys); err != nil {
+			return fmt.Errorf("cannot initialize ssh agent: %s", err)
+		}
+		defer func() {
+			err := ssh_agent.Terminate()
+			if err != nil {
+				logboek.LogErrorF("WARNING: ssh agent termination failed: %s\n", err)
+			}
+		}()
+
+		c := build.NewConveyor(werfConfig, imagesToProcess, projectDir, projectTmpDir, ssh_agent.SSHAuthSock, stagesRepo, build.ConveyorOptions{})
+		defer c.Terminate()
+
+		if err = c.ShouldBeBuilt(); err != nil {
+			return err
+		}
+
+		for _, imageName := range imagesToProcess {
+			imagesTags[imageName] = c.GetImageLatestStageSignature(imageName)
+		}
+	} else {
+		for _, imageName := range imagesToProcess {
+			imagesTags[imageName] = tag
+		}
+	}
+
+	logboek.LogOptionalLn()
+
+	return images_archive.Export(CmdData.Output, images_archive.ExportOptions{
+		ProjectName:       projectName,
+		ImagesRepoManager: imagesRepoManager,
+		ImagesTags:        imagesTags,
+		WithStages:        CmdData.WithStages,
+		StagesStorage:     stagesRepo,
+	})
+}
@@ -0,0 +1,40 @@
+package generate_signing_key
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/flant/werf/cmd/werf/common"
+	"github.com/flant/werf/pkg/image_signing"
+)
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "generate-signing-key",
+		DisableFlagsInUseLine: true,
+		Short:                 "Generate hex signing key and public key",
+		Long: common.GetLongCommandDescription(`Generate hex ed25519 signing key and the corresponding public key.
+The signing key is used by publish commands with --sign-images option and should be saved in $WERF_SIGNING_KEY or .werf_signing_key file.
+The public key is used by deploy command with --verify-images option and should be saved in $WERF_SIGNING_PUBLIC_KEY or .werf_signing_public_key file`),
+		Example: `  # Export signing key and public key
+  $ export $(werf images generate-signing-key)`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runGenerateSigningKey()
+		},
+	}
+
+	return cmd
+}
+
+func runGenerateSigningKey() error {
+	signingKey, publicKey, err := image_signing.GenerateKey()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("WERF_SIGNING_KEY=%s\n", signingKey)
+	fmt.Printf("WERF_SIGNING_PUBLIC_KEY=%s\n", publicKey)
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package import_
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/flant/shluz"
+
+	"github.com/spf13/cobra"
+
+	"github.com/flant/werf/cmd/werf/common"
+	"github.com/flant/werf/pkg/docker"
+	"github.com/flant/werf/pkg/docker_registry"
+	"github.com/flant/werf/pkg/images_archive"
+	"github.com/flant/werf/pkg/werf"
+)
+
+var CmdData struct {
+	LocalDocker bool
+}
+
+var CommonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import ARCHIVE_PATH",
+		Short: "Import images from OCI archive into images repo",
+		Long: common.GetLongCommandDescription(`Import images from the archive created by werf images export into images repo or local docker.
+
+Images are imported as is: digests and werf labels of images are preserved, images are tagged by the same tags, so werf deploy with the same tag can be used.
+
+Stages from the archive are imported into the stages storage when --stages-storage is specified.`),
+		Example: `  # Import images into the registry available from the cluster
+  $ werf images import --images-repo registry.local/myproject --stages-storage registry.local/myproject/stages images.tar`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&CommonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+			common.LogVersion()
+
+			return common.LogRunningTime(func() error {
+				return runImport(args[0])
+			})
+		},
+	}
+
+	common.SetupTmpDir(&CommonCmdData, cmd)
+	common.SetupHomeDir(&CommonCmdData, cmd)
+
+	common.SetupStagesStorage(&CommonCmdData, cmd)
+	common.SetupImagesRepo(&CommonCmdData, cmd)
+	common.SetupImagesRepoMode(&CommonCmdData, cmd)
+	common.SetupDockerConfig(&CommonCmdData, cmd, "Command needs granted permissions to push images into the specified images repo and stages storage")
+	common.SetupInsecureRegistry(&CommonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&CommonCmdData, cmd)
+
+	common.SetupLogOptions(&CommonCmdData, cmd)
+
+	cmd.Flags().BoolVarP(&CmdData.LocalDocker, "local-docker", "", common.GetBoolEnvironment("WERF_LOCAL_DOCKER"), "Load images into the local docker daemon with IMAGES_REPO names instead of pushing into images repo (default $WERF_LOCAL_DOCKER)")
+
+	return cmd
+}
+
+func runImport(archivePath string) error {
+	if err := werf.Init(*CommonCmdData.TmpDir, *CommonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := shluz.Init(filepath.Join(werf.GetServiceDir(), "locks")); err != nil {
+		return err
+	}
+
+	if err := docker_registry.Init(docker_registry.Options{InsecureRegistry: *CommonCmdData.InsecureRegistry, SkipTlsVerifyRegistry: *CommonCmdData.SkipTlsVerifyRegistry}); err != nil {
+		return err
+	}
+
+	if err := docker.Init(*CommonCmdData.DockerConfig); err != nil {
+		return err
+	}
+
+	// project name is used only for the :minikube images repo, the project is taken from the archive manifest otherwise
+	imagesRepo, err := common.GetImagesRepo("", &CommonCmdData)
+	if err != nil {
+		return err
+	}
+
+	imagesRepoMode, err := common.GetImagesRepoMode(&CommonCmdData)
+	if err != nil {
+		return err
+	}
+
+	imagesRepoManager, err := common.GetImagesRepoManager(imagesRepo, imagesRepoMode)
+	if err != nil {
+		return err
+	}
+
+	var stagesRepo string
+	if *CommonCmdData.StagesStorage != "" {
+		stagesRepo, err = common.GetStagesRepo(&CommonCmdData)
+		if err != nil {
+			return err
+		}
+	}
+
+	return images_archive.Import(archivePath, images_archive.ImportOptions{
+		ImagesRepoManager: imagesRepoManager,
+		LocalDocker:       CmdData.LocalDocker,
+		StagesStorage:     stagesRepo,
+	})
+}
@@ -60,6 +60,10 @@ If one or more IMAGE_NAME parameters specified, werf will publish only these ima
 	common.SetupInsecureRegistry(commonCmdData, cmd)
 	common.SetupSkipTlsVerifyRegistry(commonCmdData, cmd)
 
+	common.SetupSignImages(commonCmdData, cmd)
+	common.SetupProvenancePath(commonCmdData, cmd)
+	common.SetupPushProvenance(commonCmdData, cmd)
+
 	common.SetupLogOptions(commonCmdData, cmd)
 	common.SetupLogProjectDir(commonCmdData, cmd)
 
@@ -113,7 +117,7 @@ func runImagesPublish(commonCmdData *common.CmdData, imagesToProcess []string) e
 	}
 	defer tmp_manager.ReleaseProjectDir(projectTmpDir)
 
-	_, err = common.GetStagesRepo(commonCmdData)
+	stagesRepo, err := common.GetStagesRepo(commonCmdData)
 	if err != nil {
 		return err
 	}
@@ -148,9 +152,19 @@ func runImagesPublish(commonCmdData *common.CmdData, imagesToProcess []string) e
 		}
 	}()
 
-	opts := build.PublishImagesOptions{TagOptions: tagOpts}
+	signingKey, err := common.GetSigningKey(commonCmdData, projectDir)
+	if err != nil {
+		return err
+	}
+
+	opts := build.PublishImagesOptions{
+		TagOptions:        tagOpts,
+		ProvenanceOptions: common.GetProvenanceOptions(commonCmdData),
+		SigningKey:        signingKey,
+		PushProvenance:    *commonCmdData.PushProvenance,
+	}
 
-	c := build.NewConveyor(werfConfig, imagesToProcess, projectDir, projectTmpDir, ssh_agent.SSHAuthSock)
+	c := build.NewConveyor(werfConfig, imagesToProcess, projectDir, projectTmpDir, ssh_agent.SSHAuthSock, stagesRepo, build.ConveyorOptions{})
 	defer c.Terminate()
 
 	if err = c.PublishImages(imagesRepoManager, opts); err != nil {
@@ -29,11 +29,17 @@ import (
 	"github.com/flant/werf/cmd/werf/slugify"
 
 	images_cleanup "github.com/flant/werf/cmd/werf/images/cleanup"
+	images_export "github.com/flant/werf/cmd/werf/images/export"
+	images_generate_signing_key "github.com/flant/werf/cmd/werf/images/generate_signing_key"
+	images_import "github.com/flant/werf/cmd/werf/images/import"
 	images_publish "github.com/flant/werf/cmd/werf/images/publish"
 	images_purge "github.com/flant/werf/cmd/werf/images/purge"
 
 	stages_build "github.com/flant/werf/cmd/werf/stages/build"
 	stages_cleanup "github.com/flant/werf/cmd/werf/stages/cleanup"
+	stages_explain "github.com/flant/werf/cmd/werf/stages/explain"
+	stages_inspect "github.com/flant/werf/cmd/werf/stages/inspect"
+	stages_list "github.com/flant/werf/cmd/werf/stages/list"
 	stages_purge "github.com/flant/werf/cmd/werf/stages/purge"
 
 	stage_image "github.com/flant/werf/cmd/werf/stage/image"
@@ -154,6 +160,9 @@ func imagesCmd() *cobra.Command {
 		images_publish.NewCmd(),
 		images_cleanup.NewCmd(),
 		images_purge.NewCmd(),
+		images_export.NewCmd(),
+		images_import.NewCmd(),
+		images_generate_signing_key.NewCmd(),
 	)
 
 	return cmd
@@ -168,6 +177,9 @@ func stagesCmd() *cobra.Command {
 		stages_build.NewCmd(),
 		stages_cleanup.NewCmd(),
 		stages_purge.NewCmd(),
+		stages_explain.NewCmd(),
+		stages_list.NewCmd(),
+		stages_inspect.NewCmd(),
 	)
 
 	return cmd
@@ -55,6 +55,7 @@ WARNING: Do not run this command during any other werf command is working on the
 	common.SetupDockerConfig(&CommonCmdData, cmd, "Command needs granted permissions to delete images from the specified stages storage and images repo")
 	common.SetupInsecureRegistry(&CommonCmdData, cmd)
 	common.SetupSkipTlsVerifyRegistry(&CommonCmdData, cmd)
+	common.SetupRepoImplementation(&CommonCmdData, cmd)
 
 	common.SetupLogOptions(&CommonCmdData, cmd)
 	common.SetupLogProjectDir(&CommonCmdData, cmd)
@@ -81,12 +82,12 @@ func runPurge() error {
 
 	common.ProcessLogProjectDir(&CommonCmdData, projectDir)
 
-	_, err = common.GetStagesRepo(&CommonCmdData)
+	stagesRepo, err := common.GetStagesRepo(&CommonCmdData)
 	if err != nil {
 		return err
 	}
 
-	if err := docker_registry.Init(docker_registry.Options{InsecureRegistry: *CommonCmdData.InsecureRegistry, SkipTlsVerifyRegistry: *CommonCmdData.SkipTlsVerifyRegistry}); err != nil {
+	if err := docker_registry.Init(docker_registry.Options{InsecureRegistry: *CommonCmdData.InsecureRegistry, SkipTlsVerifyRegistry: *CommonCmdData.SkipTlsVerifyRegistry, Implementation: *CommonCmdData.RepoImplementation, QuayToken: *CommonCmdData.RepoQuayToken}); err != nil {
 		return err
 	}
 
@@ -133,6 +134,7 @@ func runPurge() error {
 
 	stagesPurgeOptions := cleaning.StagesPurgeOptions{
 		ProjectName:                   projectName,
+		StagesStorage:                 stagesRepo,
 		RmContainersThatUseWerfImages: CmdData.Force,
 		DryRun:                        *CommonCmdData.DryRun,
 	}
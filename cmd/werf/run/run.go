@@ -103,6 +103,8 @@ func NewCmd() *cobra.Command {
 	common.SetupInsecureRegistry(&CommonCmdData, cmd)
 	common.SetupSkipTlsVerifyRegistry(&CommonCmdData, cmd)
 
+	common.SetupDev(&CommonCmdData, cmd)
+
 	common.SetupLogOptions(&CommonCmdData, cmd)
 	common.SetupLogProjectDir(&CommonCmdData, cmd)
 
@@ -185,7 +187,7 @@ func runRun() error {
 	}
 	defer tmp_manager.ReleaseProjectDir(projectTmpDir)
 
-	_, err = common.GetStagesRepo(&CommonCmdData)
+	stagesRepo, err := common.GetStagesRepo(&CommonCmdData)
 	if err != nil {
 		return err
 	}
@@ -209,7 +211,7 @@ func runRun() error {
 		return fmt.Errorf("image '%s' is not defined in werf.yaml", logging.ImageLogName(imageName, false))
 	}
 
-	c := build.NewConveyor(werfConfig, []string{imageName}, projectDir, projectTmpDir, ssh_agent.SSHAuthSock)
+	c := build.NewConveyor(werfConfig, []string{imageName}, projectDir, projectTmpDir, ssh_agent.SSHAuthSock, stagesRepo, build.ConveyorOptions{DevMode: *CommonCmdData.Dev})
 	defer c.Terminate()
 
 	if err = c.ShouldBeBuilt(); err != nil {
@@ -39,6 +39,9 @@ func NewCmdWithData(cmdData *CmdData, commonCmdData *common.CmdData) *cobra.Comm
   # Build stages of image 'backend' from werf.yaml
   $ werf stages build --stages-storage :local backend
 
+  # Build stages of independent images in parallel, not more than 3 images at a time
+  $ werf stages build --stages-storage :local --parallel --parallel-tasks-limit 3
+
   # Build and enable drop-in shell session in the failed assembly container in the case when an error occurred
   $ werf build --stages-storage :local --introspect-error
 
@@ -80,6 +83,12 @@ If one or more IMAGE_NAME parameters specified, werf will build only these image
 
 	common.SetupIntrospectStage(commonCmdData, cmd)
 
+	common.SetupParallelOptions(commonCmdData, cmd)
+	common.SetupDockerfileBuilderOptions(commonCmdData, cmd)
+	common.SetupProvenancePath(commonCmdData, cmd)
+	common.SetupPolicyMode(commonCmdData, cmd)
+	common.SetupDev(commonCmdData, cmd)
+
 	common.SetupLogOptions(commonCmdData, cmd)
 	common.SetupLogProjectDir(commonCmdData, cmd)
 
@@ -154,18 +163,37 @@ func runStagesBuild(cmdData *CmdData, commonCmdData *common.CmdData, imagesToPro
 		return err
 	}
 
+	parallelOptions, err := common.GetParallelOptions(commonCmdData, projectDir, introspectOptions)
+	if err != nil {
+		return err
+	}
+
+	dockerfileBuilderOptions, err := common.GetDockerfileBuilderOptions(commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	policyOptions, err := common.GetPolicyOptions(commonCmdData)
+	if err != nil {
+		return err
+	}
+
 	opts := build.BuildStagesOptions{
 		ImageBuildOptions: image.BuildOptions{
 			IntrospectAfterError:  cmdData.IntrospectAfterError,
 			IntrospectBeforeError: cmdData.IntrospectBeforeError,
 		},
-		IntrospectOptions: introspectOptions,
+		IntrospectOptions:        introspectOptions,
+		ParallelOptions:          parallelOptions,
+		DockerfileBuilderOptions: dockerfileBuilderOptions,
+		ProvenanceOptions:        common.GetProvenanceOptions(commonCmdData),
+		PolicyOptions:            policyOptions,
 	}
 
-	c := build.NewConveyor(werfConfig, imagesToProcess, projectDir, projectTmpDir, ssh_agent.SSHAuthSock)
+	c := build.NewConveyor(werfConfig, imagesToProcess, projectDir, projectTmpDir, ssh_agent.SSHAuthSock, stagesRepo, build.ConveyorOptions{DevMode: *commonCmdData.Dev})
 	defer c.Terminate()
 
-	if err = c.BuildStages(stagesRepo, opts); err != nil {
+	if err = c.BuildStages(opts); err != nil {
 		return err
 	}
 
@@ -51,11 +51,18 @@ func NewCmd() *cobra.Command {
 	common.SetupDockerConfig(&CommonCmdData, cmd, "Command needs granted permissions to read, pull and delete images from the specified stages storage, read images from the specified images repo")
 	common.SetupInsecureRegistry(&CommonCmdData, cmd)
 	common.SetupSkipTlsVerifyRegistry(&CommonCmdData, cmd)
+	common.SetupRepoImplementation(&CommonCmdData, cmd)
+
+	common.SetupKubeConfig(&CommonCmdData, cmd)
+	common.SetupKubeContext(&CommonCmdData, cmd)
+	common.SetupHelmReleaseStorageNamespace(&CommonCmdData, cmd)
+	common.SetupLockBackend(&CommonCmdData, cmd)
 
 	common.SetupLogOptions(&CommonCmdData, cmd)
 	common.SetupLogProjectDir(&CommonCmdData, cmd)
 
 	common.SetupDryRun(&CommonCmdData, cmd)
+	common.SetupReportOptions(&CommonCmdData, cmd)
 
 	return cmd
 }
@@ -69,7 +76,11 @@ func runSync() error {
 		return err
 	}
 
-	if err := docker_registry.Init(docker_registry.Options{InsecureRegistry: *CommonCmdData.InsecureRegistry, SkipTlsVerifyRegistry: *CommonCmdData.SkipTlsVerifyRegistry}); err != nil {
+	if err := common.InitLockManager(&CommonCmdData); err != nil {
+		return err
+	}
+
+	if err := docker_registry.Init(docker_registry.Options{InsecureRegistry: *CommonCmdData.InsecureRegistry, SkipTlsVerifyRegistry: *CommonCmdData.SkipTlsVerifyRegistry, Implementation: *CommonCmdData.RepoImplementation, QuayToken: *CommonCmdData.RepoQuayToken}); err != nil {
 		return err
 	}
 
@@ -122,18 +133,22 @@ func runSync() error {
 		imagesNames = append(imagesNames, image.Name)
 	}
 
+	report, err := common.NewCleanupReport(&CommonCmdData)
+	if err != nil {
+		return err
+	}
+
 	stagesCleanupOptions := cleaning.StagesCleanupOptions{
 		ProjectName:       projectName,
 		ImagesRepoManager: imagesRepoManager,
 		StagesStorage:     stagesRepo,
 		ImagesNames:       imagesNames,
 		DryRun:            *CommonCmdData.DryRun,
+		Report:            report,
 	}
 
 	logboek.LogOptionalLn()
-	if err := cleaning.StagesCleanup(stagesCleanupOptions); err != nil {
-		return err
-	}
+	cleanupErr := cleaning.StagesCleanup(stagesCleanupOptions)
 
-	return nil
+	return common.WriteCleanupReport(&CommonCmdData, report, cleanupErr)
 }
@@ -0,0 +1,139 @@
+package explain
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/flant/shluz"
+
+	"github.com/spf13/cobra"
+
+	"github.com/flant/logboek"
+	"github.com/flant/werf/cmd/werf/common"
+	"github.com/flant/werf/pkg/build"
+	"github.com/flant/werf/pkg/docker"
+	"github.com/flant/werf/pkg/docker_registry"
+	"github.com/flant/werf/pkg/logging"
+	"github.com/flant/werf/pkg/ssh_agent"
+	"github.com/flant/werf/pkg/tmp_manager"
+	"github.com/flant/werf/pkg/true_git"
+	"github.com/flant/werf/pkg/werf"
+)
+
+var CommonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "explain IMAGE_NAME [STAGE_NAME]",
+		Short: "Explain why stages signatures of the image have changed",
+		Long: common.GetLongCommandDescription(`Explain why stages signatures of the image have changed.
+
+Stage signature inputs (dependencies components, git checksums of stage dependencies paths, imports signatures, cache versions, previous stage signature) are recorded in the stage image labels when the stage is built.
+
+For each stage which is not built with current signature werf finds the closest (the most recently created) stage of the same image and stage name in the local docker and in the stages storage and shows the difference between current signature inputs and the recorded ones.`),
+		Example: `  # Explain all stages of image 'backend'
+  $ werf stages explain --stages-storage :local backend
+
+  # Explain install stage of image 'backend'
+  $ werf stages explain --stages-storage :local backend install`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&CommonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+			common.LogVersion()
+
+			var stageName string
+			if len(args) == 2 {
+				stageName = args[1]
+			}
+
+			return common.LogRunningTime(func() error {
+				return runExplain(args[0], stageName)
+			})
+		},
+	}
+
+	common.SetupDir(&CommonCmdData, cmd)
+	common.SetupTmpDir(&CommonCmdData, cmd)
+	common.SetupHomeDir(&CommonCmdData, cmd)
+	common.SetupSSHKey(&CommonCmdData, cmd)
+
+	common.SetupStagesStorage(&CommonCmdData, cmd)
+	common.SetupDockerConfig(&CommonCmdData, cmd, "Command needs granted permissions to read and pull images from the specified stages storage")
+	common.SetupInsecureRegistry(&CommonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&CommonCmdData, cmd)
+
+	common.SetupDev(&CommonCmdData, cmd)
+
+	common.SetupLogOptions(&CommonCmdData, cmd)
+	common.SetupLogProjectDir(&CommonCmdData, cmd)
+
+	return cmd
+}
+
+func runExplain(imageName, stageName string) error {
+	if err := werf.Init(*CommonCmdData.TmpDir, *CommonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := shluz.Init(filepath.Join(werf.GetServiceDir(), "locks")); err != nil {
+		return err
+	}
+
+	if err := true_git.Init(true_git.Options{Out: logboek.GetOutStream(), Err: logboek.GetErrStream()}); err != nil {
+		return err
+	}
+
+	if err := docker_registry.Init(docker_registry.Options{InsecureRegistry: *CommonCmdData.InsecureRegistry, SkipTlsVerifyRegistry: *CommonCmdData.SkipTlsVerifyRegistry}); err != nil {
+		return err
+	}
+
+	if err := docker.Init(*CommonCmdData.DockerConfig); err != nil {
+		return err
+	}
+
+	projectDir, err := common.GetProjectDir(&CommonCmdData)
+	if err != nil {
+		return fmt.Errorf("getting project dir failed: %s", err)
+	}
+
+	common.ProcessLogProjectDir(&CommonCmdData, projectDir)
+
+	werfConfig, err := common.GetWerfConfig(projectDir)
+	if err != nil {
+		return fmt.Errorf("bad config: %s", err)
+	}
+
+	if !werfConfig.HasImage(imageName) {
+		return fmt.Errorf("specified image %s is not defined in werf.yaml", logging.ImageLogName(imageName, false))
+	}
+
+	projectTmpDir, err := tmp_manager.CreateProjectDir()
+	if err != nil {
+		return fmt.Errorf("getting project tmp dir failed: %s", err)
+	}
+	defer tmp_manager.ReleaseProjectDir(projectTmpDir)
+
+	stagesRepo, err := common.GetStagesRepo(&CommonCmdData)
+	if err != nil {
+		return err
+	}
+
+	if err := ssh_agent.Init(*CommonCmdData.SSHKeys); err != nil {
+		return fmt.Errorf("cannot initialize ssh agent: %s", err)
+	}
+	defer func() {
+		err := ssh_agent.Terminate()
+		if err != nil {
+			logboek.LogErrorF("WARNING: ssh agent termination failed: %s\n", err)
+		}
+	}()
+
+	c := build.NewConveyor(werfConfig, []string{imageName}, projectDir, projectTmpDir, ssh_agent.SSHAuthSock, stagesRepo, build.ConveyorOptions{DevMode: *CommonCmdData.Dev})
+	defer c.Terminate()
+
+	return c.ExplainStages(build.ExplainStagesOptions{StageName: stageName})
+}
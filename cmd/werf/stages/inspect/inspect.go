@@ -0,0 +1,142 @@
+package inspect
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/docker/go-units"
+	"github.com/flant/shluz"
+
+	"github.com/spf13/cobra"
+
+	"github.com/flant/werf/cmd/werf/common"
+	"github.com/flant/werf/pkg/docker"
+	"github.com/flant/werf/pkg/docker_registry"
+	"github.com/flant/werf/pkg/stages_info"
+	"github.com/flant/werf/pkg/werf"
+)
+
+var CommonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "inspect SIGNATURE",
+		Short: "Print information about the stage from the stages storage",
+		Long: common.GetLongCommandDescription(`Print information about the stage from the stages storage: labels, commands that built the stage and the chain of parent stages.
+
+Commands are recorded for stages built by werf of this version or later.`),
+		Example: `  # Inspect local stage
+  $ werf stages inspect --stages-storage :local b1d6b0bba5a38bd7d8cc0e6e6d0c64b3af8b6a53a8e3bc41f4b4e0a7b2b3e3c5`,
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&CommonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			return runInspect(args[0])
+		},
+	}
+
+	common.SetupDir(&CommonCmdData, cmd)
+	common.SetupTmpDir(&CommonCmdData, cmd)
+	common.SetupHomeDir(&CommonCmdData, cmd)
+
+	common.SetupStagesStorage(&CommonCmdData, cmd)
+	common.SetupImagesRepo(&CommonCmdData, cmd)
+	common.SetupImagesRepoMode(&CommonCmdData, cmd)
+	common.SetupDockerConfig(&CommonCmdData, cmd, "Command needs granted permissions to read images from the specified stages storage and images repo")
+	common.SetupInsecureRegistry(&CommonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&CommonCmdData, cmd)
+
+	common.SetupLogOptions(&CommonCmdData, cmd)
+
+	return cmd
+}
+
+func runInspect(signature string) error {
+	if err := werf.Init(*CommonCmdData.TmpDir, *CommonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := shluz.Init(filepath.Join(werf.GetServiceDir(), "locks")); err != nil {
+		return err
+	}
+
+	if err := docker_registry.Init(docker_registry.Options{InsecureRegistry: *CommonCmdData.InsecureRegistry, SkipTlsVerifyRegistry: *CommonCmdData.SkipTlsVerifyRegistry}); err != nil {
+		return err
+	}
+
+	if err := docker.Init(*CommonCmdData.DockerConfig); err != nil {
+		return err
+	}
+
+	projectDir, err := common.GetProjectDir(&CommonCmdData)
+	if err != nil {
+		return fmt.Errorf("getting project dir failed: %s", err)
+	}
+
+	werfConfig, err := common.GetWerfConfig(projectDir)
+	if err != nil {
+		return fmt.Errorf("bad config: %s", err)
+	}
+
+	opts, err := common.GetStagesInfoOptions(&CommonCmdData, werfConfig)
+	if err != nil {
+		return err
+	}
+
+	stages, err := stages_info.ListStages(opts)
+	if err != nil {
+		return err
+	}
+
+	stage := stages_info.FindStageBySignature(stages, signature)
+	if stage == nil {
+		return fmt.Errorf("stage with signature %s is not found in the stages storage %s", signature, opts.StagesStorage)
+	}
+
+	fmt.Printf("Name:       %s\n", stage.Name)
+	fmt.Printf("ID:         %s\n", stage.ID)
+	fmt.Printf("Image:      %s\n", stage.ImageName)
+	fmt.Printf("Stage:      %s\n", stage.StageName)
+	fmt.Printf("Signature:  %s\n", stage.Signature)
+	fmt.Printf("Size:       %s\n", units.HumanSize(float64(stage.Size)))
+	fmt.Printf("Created:    %s\n", stage.Created.Format("2006-01-02T15:04:05Z07:00"))
+
+	fmt.Printf("\nGit commits:\n")
+	for _, commit := range stage.GitCommits {
+		fmt.Printf("  %s\n", commit)
+	}
+
+	if opts.ImagesRepoManager != nil {
+		fmt.Printf("\nLinked images:\n")
+		for _, linkedImage := range stage.LinkedImages {
+			fmt.Printf("  %s\n", linkedImage)
+		}
+	}
+
+	fmt.Printf("\nLabels:\n")
+	var labelKeys []string
+	for key := range stage.Labels {
+		labelKeys = append(labelKeys, key)
+	}
+	sort.Strings(labelKeys)
+	for _, key := range labelKeys {
+		fmt.Printf("  %s=%s\n", key, stage.Labels[key])
+	}
+
+	fmt.Printf("\nCommands:\n")
+	for _, command := range stage.Commands {
+		fmt.Printf("  %s\n", command)
+	}
+
+	fmt.Printf("\nParent chain:\n")
+	for _, parent := range stages_info.ParentChain(stages, stage) {
+		fmt.Printf("  %s %s %s\n", parent.Signature, parent.ImageName, parent.StageName)
+	}
+
+	return nil
+}
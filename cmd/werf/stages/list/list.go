@@ -0,0 +1,166 @@
+package list
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/flant/shluz"
+	"github.com/gosuri/uitable"
+
+	"github.com/spf13/cobra"
+
+	"github.com/flant/werf/cmd/werf/common"
+	"github.com/flant/werf/pkg/docker"
+	"github.com/flant/werf/pkg/docker_registry"
+	"github.com/flant/werf/pkg/logging"
+	"github.com/flant/werf/pkg/stages_info"
+	"github.com/flant/werf/pkg/werf"
+)
+
+const (
+	TableOutputFormat = "table"
+	JsonOutputFormat  = "json"
+)
+
+var CmdData struct {
+	OutputFormat string
+}
+
+var CommonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list [IMAGE_NAME...]",
+		Short: "List stages of the project from the stages storage",
+		Long: common.GetLongCommandDescription(`List stages of the project from the stages storage.
+
+For each stage werf prints image name, stage name, signature, size, creation time and git commits the stage has been built from. If images repo is specified, werf also prints published images linked to the stage.
+
+If one or more IMAGE_NAME parameters specified, werf will list only stages of these images from werf.yaml.`),
+		Example: `  # List local stages of all images
+  $ werf stages list --stages-storage :local
+
+  # List stages of image 'backend' in json format and find published images linked to the stages
+  $ werf stages list --stages-storage registry.mydomain.com/myproject/stages --images-repo registry.mydomain.com/myproject --format json backend`,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&CommonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			return runList(args)
+		},
+	}
+
+	common.SetupDir(&CommonCmdData, cmd)
+	common.SetupTmpDir(&CommonCmdData, cmd)
+	common.SetupHomeDir(&CommonCmdData, cmd)
+
+	common.SetupStagesStorage(&CommonCmdData, cmd)
+	common.SetupImagesRepo(&CommonCmdData, cmd)
+	common.SetupImagesRepoMode(&CommonCmdData, cmd)
+	common.SetupDockerConfig(&CommonCmdData, cmd, "Command needs granted permissions to read images from the specified stages storage and images repo")
+	common.SetupInsecureRegistry(&CommonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&CommonCmdData, cmd)
+
+	common.SetupLogOptions(&CommonCmdData, cmd)
+
+	defaultOutputFormat := os.Getenv("WERF_OUTPUT_FORMAT")
+	if defaultOutputFormat == "" {
+		defaultOutputFormat = TableOutputFormat
+	}
+	cmd.Flags().StringVarP(&CmdData.OutputFormat, "format", "", defaultOutputFormat, fmt.Sprintf("Output format: %s or %s (default $WERF_OUTPUT_FORMAT or %s)", TableOutputFormat, JsonOutputFormat, TableOutputFormat))
+
+	return cmd
+}
+
+func runList(imagesToProcess []string) error {
+	if CmdData.OutputFormat != TableOutputFormat && CmdData.OutputFormat != JsonOutputFormat {
+		return fmt.Errorf("bad --format '%s': %s or %s format is supported", CmdData.OutputFormat, TableOutputFormat, JsonOutputFormat)
+	}
+
+	if err := werf.Init(*CommonCmdData.TmpDir, *CommonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := shluz.Init(filepath.Join(werf.GetServiceDir(), "locks")); err != nil {
+		return err
+	}
+
+	if err := docker_registry.Init(docker_registry.Options{InsecureRegistry: *CommonCmdData.InsecureRegistry, SkipTlsVerifyRegistry: *CommonCmdData.SkipTlsVerifyRegistry}); err != nil {
+		return err
+	}
+
+	if err := docker.Init(*CommonCmdData.DockerConfig); err != nil {
+		return err
+	}
+
+	projectDir, err := common.GetProjectDir(&CommonCmdData)
+	if err != nil {
+		return fmt.Errorf("getting project dir failed: %s", err)
+	}
+
+	werfConfig, err := common.GetWerfConfig(projectDir)
+	if err != nil {
+		return fmt.Errorf("bad config: %s", err)
+	}
+
+	for _, imageToProcess := range imagesToProcess {
+		if !werfConfig.HasImage(imageToProcess) {
+			return fmt.Errorf("specified image %s is not defined in werf.yaml", logging.ImageLogName(imageToProcess, false))
+		}
+	}
+
+	opts, err := common.GetStagesInfoOptions(&CommonCmdData, werfConfig)
+	if err != nil {
+		return err
+	}
+
+	stages, err := stages_info.ListStages(opts)
+	if err != nil {
+		return err
+	}
+
+	stages = stages_info.FilterStagesByImageName(stages, imagesToProcess)
+
+	if CmdData.OutputFormat == JsonOutputFormat {
+		data, err := json.MarshalIndent(stages, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(data))
+		return nil
+	}
+
+	table := uitable.New()
+	table.MaxColWidth = 64
+	table.AddRow("IMAGE", "STAGE", "SIGNATURE", "SIZE", "CREATED", "GIT COMMITS", "LINKED IMAGES")
+	for _, stage := range stages {
+		var linkedImages string
+		if opts.ImagesRepoManager == nil {
+			linkedImages = "-"
+		} else {
+			linkedImages = strings.Join(stage.LinkedImages, ", ")
+		}
+
+		table.AddRow(
+			stage.ImageName,
+			stage.StageName,
+			stage.Signature,
+			units.HumanSize(float64(stage.Size)),
+			units.HumanDuration(time.Since(stage.Created))+" ago",
+			strings.Join(stage.GitCommits, ", "),
+			linkedImages,
+		)
+	}
+	fmt.Println(table)
+
+	return nil
+}
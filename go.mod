@@ -68,6 +68,7 @@ require (
 	github.com/otiai10/copy v1.0.1
 	github.com/pkg/profile v1.2.1 // indirect
 	github.com/satori/go.uuid v1.2.0
+	github.com/sergi/go-diff v1.0.0
 	github.com/sirupsen/logrus v1.4.2
 	github.com/spaolacci/murmur3 v1.1.0
 	github.com/spf13/cobra v0.0.5
@@ -11,16 +11,20 @@ import (
 	"github.com/flant/werf/pkg/build/stage"
 	"github.com/flant/werf/pkg/docker"
 	imagePkg "github.com/flant/werf/pkg/image"
-	"github.com/flant/werf/pkg/werf"
+	"github.com/flant/werf/pkg/util/secretvalues"
 )
 
-func NewBuildStagesPhase(stagesRepo string, opts BuildStagesOptions) *BuildStagesPhase {
-	return &BuildStagesPhase{StagesRepo: stagesRepo, BuildStagesOptions: opts}
+func NewBuildStagesPhase(opts BuildStagesOptions) *BuildStagesPhase {
+	return &BuildStagesPhase{BuildStagesOptions: opts}
 }
 
 type BuildStagesOptions struct {
 	ImageBuildOptions imagePkg.BuildOptions
 	IntrospectOptions
+	ParallelOptions
+	ProvenanceOptions
+	DockerfileBuilderOptions
+	PolicyOptions
 }
 
 type IntrospectOptions struct {
@@ -43,21 +47,28 @@ func (opts *IntrospectOptions) ImageStageShouldBeIntrospected(imageName, stageNa
 }
 
 type BuildStagesPhase struct {
-	StagesRepo string
 	BuildStagesOptions
 }
 
 func (p *BuildStagesPhase) Run(c *Conveyor) (err error) {
+	secretValuesToMask := c.secretValuesToMask()
+	docker.SetOutputSecretValuesToMask(secretValuesToMask)
+	defer docker.SetOutputSecretValuesToMask(nil)
+
 	logProcessOptions := logboek.LogProcessOptions{ColorizeMsgFunc: logboek.ColorizeHighlight}
-	return logboek.LogProcess("Building stages", logProcessOptions, func() error {
+	if err := logboek.LogProcess("Building stages", logProcessOptions, func() error {
 		return p.run(c)
-	})
+	}); err != nil {
+		return fmt.Errorf("%s", secretvalues.MaskSecretValuesInString(secretValuesToMask, err.Error()))
+	}
+
+	return nil
 }
 
 func (p *BuildStagesPhase) run(c *Conveyor) error {
-	/*
-	 * TODO: Build stages phase should push result into stagesRepo if non :local repo has been used
-	 */
+	if p.Parallel {
+		return p.runParallel(c)
+	}
 
 	images := c.imagesInOrder
 	for _, image := range images {
@@ -90,6 +101,10 @@ func (p *BuildStagesPhase) runImage(image *Image, c *Conveyor) error {
 
 			prevStageImageSize = img.Inspect().Size
 
+			if err := c.pushStageIntoStagesStorage(s); err != nil {
+				return err
+			}
+
 			if p.IntrospectOptions.ImageStageShouldBeIntrospected(image.GetName(), string(s.Name())) {
 				if err := introspectStage(s); err != nil {
 					return err
@@ -122,27 +137,8 @@ func (p *BuildStagesPhase) runImage(image *Image, c *Conveyor) error {
 				// TODO: isolate stapel and dockerfile builders logic
 				switch certainStage := s.(type) {
 				case *stage.DockerfileStage:
-					var buildArgs []string
-
-					for key, value := range map[string]string{
-						imagePkg.WerfDockerImageName:   img.Name(),
-						imagePkg.WerfLabel:             c.projectName(),
-						imagePkg.WerfVersionLabel:      werf.Version,
-						imagePkg.WerfCacheVersionLabel: BuildCacheVersion,
-						imagePkg.WerfImageLabel:        "false",
-					} {
-						buildArgs = append(buildArgs, fmt.Sprintf("--label=%s=%s", key, value))
-					}
-
-					buildArgs = append(buildArgs, fmt.Sprintf("--tag=%s", img.Name()))
-					buildArgs = append(buildArgs, certainStage.DockerBuildArgs()...)
-
-					if err := docker.CliBuild(buildArgs...); err != nil {
-						return fmt.Errorf("failed to build %s: %s", img.Name(), err)
-					}
-
-					if err := img.SyncDockerState(); err != nil {
-						return fmt.Errorf("failed to sync %s: %s", img.Name(), err)
+					if err := p.buildDockerfileStage(c, image, certainStage); err != nil {
+						return err
 					}
 				default:
 					if err := img.Build(p.ImageBuildOptions); err != nil {
@@ -166,6 +162,10 @@ func (p *BuildStagesPhase) runImage(image *Image, c *Conveyor) error {
 			return err
 		}
 
+		if err := c.pushStageIntoStagesStorage(s); err != nil {
+			return err
+		}
+
 		imageLockName := imagePkg.ImageLockName(img.Name())
 		if err := c.ReleaseGlobalLock(imageLockName); err != nil {
 			return fmt.Errorf("failed to unlock %s: %s", imageLockName, err)
@@ -0,0 +1,187 @@
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/fatih/color"
+
+	"github.com/flant/logboek"
+
+	"github.com/flant/werf/pkg/config"
+)
+
+const DefaultParallelTasksLimit = 5
+
+type ParallelOptions struct {
+	Parallel           bool
+	ParallelTasksLimit int64
+
+	// ImageBuildTaskFunc returns a command which builds stages of the specified image in a separate werf process
+	ImageBuildTaskFunc func(imageName string) (*exec.Cmd, error)
+}
+
+type imageBuildTaskResult struct {
+	image  *Image
+	output *bytes.Buffer
+	err    error
+}
+
+func (p *BuildStagesPhase) runParallel(c *Conveyor) error {
+	// stages of all images will be locked by the build tasks
+	if err := c.ReleaseAllGlobalLocks(); err != nil {
+		return err
+	}
+
+	for _, wave := range imagesBuildWaves(c) {
+		if err := p.runImagesBuildTasks(wave); err != nil {
+			return err
+		}
+	}
+
+	for _, image := range c.imagesInOrder {
+		for _, s := range image.GetStages() {
+			img := s.GetImage()
+			if err := img.SyncDockerState(); err != nil {
+				return fmt.Errorf("error synchronizing docker state of stage %s: %s", s.LogDetailedName(), err)
+			}
+
+			if !img.IsExists() {
+				return fmt.Errorf("stage %s has not been built by build task of %s", s.LogDetailedName(), image.LogDetailedName())
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *BuildStagesPhase) runImagesBuildTasks(images []*Image) error {
+	tasksLimit := p.ParallelTasksLimit
+	if tasksLimit <= 0 {
+		tasksLimit = DefaultParallelTasksLimit
+	}
+
+	semaphore := make(chan bool, tasksLimit)
+	results := make(chan *imageBuildTaskResult, len(images))
+
+	for _, image := range images {
+		cmd, err := p.ImageBuildTaskFunc(image.GetName())
+		if err != nil {
+			return fmt.Errorf("unable to create build task of %s: %s", image.LogDetailedName(), err)
+		}
+
+		output := bytes.NewBuffer(nil)
+		cmd.Stdout = output
+		cmd.Stderr = output
+		cmd.Env = append(os.Environ(), imageBuildTaskLogEnv()...)
+
+		go func(image *Image, cmd *exec.Cmd, output *bytes.Buffer) {
+			semaphore <- true
+			defer func() { <-semaphore }()
+
+			results <- &imageBuildTaskResult{image: image, output: output, err: cmd.Run()}
+		}(image, cmd, output)
+	}
+
+	var resultErr error
+	for range images {
+		res := <-results
+
+		if err := logboek.LogProcess(res.image.LogDetailedName(), logboek.LogProcessOptions{ColorizeMsgFunc: res.image.LogProcessColorizeFunc()}, func() error {
+			if _, err := io.Copy(logboek.GetOutStream(), res.output); err != nil {
+				return err
+			}
+
+			if res.err != nil {
+				return fmt.Errorf("build task of %s failed: %s", res.image.LogDetailedName(), res.err)
+			}
+
+			return nil
+		}); err != nil && resultErr == nil {
+			resultErr = err
+		}
+	}
+
+	return resultErr
+}
+
+func imageBuildTaskLogEnv() []string {
+	logColorMode := "on"
+	if color.NoColor {
+		logColorMode = "off"
+	}
+
+	return []string{
+		fmt.Sprintf("WERF_LOG_COLOR_MODE=%s", logColorMode),
+		fmt.Sprintf("WERF_LOG_TERMINAL_WIDTH=%d", logboek.ContentWidth()),
+	}
+}
+
+// imagesBuildWaves groups images into waves: images of the same wave do not depend on each other
+// and can be built concurrently, each wave depends only on the images of the previous waves.
+// Artifacts are not scheduled separately, they are built by the tasks of the images which use them.
+func imagesBuildWaves(c *Conveyor) [][]*Image {
+	levels := map[string]int{}
+
+	var imageLevel func(imageName string) int
+	imageLevel = func(imageName string) int {
+		if level, ok := levels[imageName]; ok {
+			return level
+		}
+
+		level := 0
+		for _, dependencyName := range imageDependencies(c.werfConfig, c.werfConfig.GetImage(imageName)) {
+			if dependencyLevel := imageLevel(dependencyName) + 1; dependencyLevel > level {
+				level = dependencyLevel
+			}
+		}
+		levels[imageName] = level
+
+		return level
+	}
+
+	var waves [][]*Image
+	for _, image := range c.imagesInOrder {
+		if image.isArtifact {
+			continue
+		}
+
+		level := imageLevel(image.GetName())
+		for len(waves) <= level {
+			waves = append(waves, nil)
+		}
+		waves[level] = append(waves[level], image)
+	}
+
+	return waves
+}
+
+// imageDependencies returns names of the images which should be built before the specified one (transitively through artifacts)
+func imageDependencies(werfConfig *config.WerfConfig, imageConfig config.ImageInterface) []string {
+	stapelImageConfig, ok := imageConfig.(config.StapelImageInterface)
+	if !ok {
+		return nil
+	}
+
+	var dependencies []string
+
+	imageBaseConfig := stapelImageConfig.ImageBaseConfig()
+	if imageBaseConfig.FromImageName != "" {
+		dependencies = append(dependencies, imageBaseConfig.FromImageName)
+	} else if imageBaseConfig.FromImageArtifactName != "" {
+		dependencies = append(dependencies, imageDependencies(werfConfig, werfConfig.GetArtifact(imageBaseConfig.FromImageArtifactName))...)
+	}
+
+	for _, imp := range imageBaseConfig.Import {
+		if imp.ImageName != "" {
+			dependencies = append(dependencies, imp.ImageName)
+		} else if imp.ArtifactName != "" {
+			dependencies = append(dependencies, imageDependencies(werfConfig, werfConfig.GetArtifact(imp.ArtifactName))...)
+		}
+	}
+
+	return dependencies
+}
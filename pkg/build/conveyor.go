@@ -3,14 +3,19 @@ package build
 import (
 	"fmt"
 	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/ed25519"
 
 	"github.com/flant/logboek"
 	"github.com/flant/werf/pkg/build/stage"
 	"github.com/flant/werf/pkg/config"
+	"github.com/flant/werf/pkg/docker"
 	"github.com/flant/werf/pkg/git_repo"
 	"github.com/flant/werf/pkg/image"
 	"github.com/flant/shluz"
 	"github.com/flant/werf/pkg/util"
+	"github.com/flant/werf/pkg/util/secretvalues"
 )
 
 type Conveyor struct {
@@ -23,6 +28,11 @@ type Conveyor struct {
 	remoteGitRepos                  map[string]*git_repo.Remote
 	imagesBySignature               map[string]image.ImageInterface
 	globalLocks                     []string
+	stagesStorageTags               []string
+	stagesStorageTagsMutex          sync.Mutex
+
+	// buildStagesOptions is set by the building conveyor to build stages of imports sources in advance
+	buildStagesOptions *BuildStagesOptions
 
 	tmpDir string
 }
@@ -34,6 +44,7 @@ type conveyorPermanentFields struct {
 	projectDir       string
 	containerWerfDir string
 	baseTmpDir       string
+	stagesStorage    string
 
 	baseImagesRepoIdsCache map[string]string
 	baseImagesRepoErrCache map[string]error
@@ -41,9 +52,16 @@ type conveyorPermanentFields struct {
 	sshAuthSock string
 
 	gitReposCaches map[string]*stage.GitRepoCache
+
+	devMode bool
 }
 
-func NewConveyor(werfConfig *config.WerfConfig, imageNamesToProcess []string, projectDir, baseTmpDir, sshAuthSock string) *Conveyor {
+type ConveyorOptions struct {
+	// DevMode builds local git mappings with uncommitted changes, such stages are signed and labeled separately
+	DevMode bool
+}
+
+func NewConveyor(werfConfig *config.WerfConfig, imageNamesToProcess []string, projectDir, baseTmpDir, sshAuthSock, stagesStorage string, opts ConveyorOptions) *Conveyor {
 	c := &Conveyor{
 		conveyorPermanentFields: &conveyorPermanentFields{
 			werfConfig:          werfConfig,
@@ -52,6 +70,7 @@ func NewConveyor(werfConfig *config.WerfConfig, imageNamesToProcess []string, pr
 			projectDir:       projectDir,
 			containerWerfDir: "/.werf",
 			baseTmpDir:       baseTmpDir,
+			stagesStorage:    stagesStorage,
 
 			sshAuthSock: sshAuthSock,
 
@@ -59,6 +78,8 @@ func NewConveyor(werfConfig *config.WerfConfig, imageNamesToProcess []string, pr
 
 			baseImagesRepoIdsCache: make(map[string]string),
 			baseImagesRepoErrCache: make(map[string]error),
+
+			devMode: opts.DevMode,
 		},
 	}
 	c.ReInitRuntimeFields()
@@ -101,6 +122,10 @@ func (c *Conveyor) ReInitRuntimeFields() {
 	c.tmpDir = filepath.Join(c.baseTmpDir, string(util.GenerateConsistentRandomString(10)))
 
 	c.globalLocks = nil
+
+	c.stagesStorageTagsMutex.Lock()
+	c.stagesStorageTags = nil
+	c.stagesStorageTagsMutex.Unlock()
 }
 
 func (c *Conveyor) AcquireGlobalLock(name string, opts shluz.LockOptions) error {
@@ -154,9 +179,9 @@ type Phase interface {
 	Run(*Conveyor) error
 }
 
-func (c *Conveyor) BuildStages(stageRepo string, opts BuildStagesOptions) error {
+func (c *Conveyor) BuildStages(opts BuildStagesOptions) error {
 restart:
-	if err := c.buildStages(stageRepo, opts); err != nil {
+	if err := c.buildStages(opts); err != nil {
 		if isConveyorShouldBeResetError(err) {
 			c.ReleaseAllGlobalLocks()
 			c.ReInitRuntimeFields()
@@ -169,7 +194,7 @@ restart:
 	return nil
 }
 
-func (c *Conveyor) buildStages(stageRepo string, opts BuildStagesOptions) error {
+func (c *Conveyor) buildStages(opts BuildStagesOptions) error {
 	var err error
 
 	var phases []Phase
@@ -177,7 +202,12 @@ func (c *Conveyor) buildStages(stageRepo string, opts BuildStagesOptions) error
 	phases = append(phases, NewSignaturesPhase(true))
 	phases = append(phases, NewRenewPhase())
 	phases = append(phases, NewPrepareStagesPhase())
-	phases = append(phases, NewBuildStagesPhase(stageRepo, opts))
+	phases = append(phases, NewBuildStagesPhase(opts))
+	phases = append(phases, NewPolicyPhase(opts.PolicyOptions))
+
+	if opts.ProvenancePath != "" {
+		phases = append(phases, NewProvenancePhase(opts.ProvenanceOptions))
+	}
 
 	lockName, err := c.lockAllImagesReadOnly()
 	if err != nil {
@@ -185,6 +215,9 @@ func (c *Conveyor) buildStages(stageRepo string, opts BuildStagesOptions) error
 	}
 	defer shluz.Unlock(lockName)
 
+	c.buildStagesOptions = &opts
+	defer func() { c.buildStagesOptions = nil }()
+
 	return c.runPhases(phases)
 }
 
@@ -193,6 +226,8 @@ type TagOptions struct {
 	TagsByGitTag    []string
 	TagsByGitBranch []string
 	TagsByGitCommit []string
+
+	TagByStagesSignature bool
 }
 
 type ImagesRepoManager interface {
@@ -204,6 +239,14 @@ type ImagesRepoManager interface {
 
 type PublishImagesOptions struct {
 	TagOptions
+
+	ProvenanceOptions
+
+	// SigningKey enables signing of published images
+	SigningKey ed25519.PrivateKey
+
+	// PushProvenance enables pushing of provenance of published images into images repo
+	PushProvenance bool
 }
 
 func (c *Conveyor) ShouldBeBuilt() error {
@@ -215,6 +258,15 @@ func (c *Conveyor) ShouldBeBuilt() error {
 	return c.runPhases(phases)
 }
 
+func (c *Conveyor) ExplainStages(opts ExplainStagesOptions) error {
+	var phases []Phase
+	phases = append(phases, NewInitializationPhase())
+	phases = append(phases, NewSignaturesPhase(false))
+	phases = append(phases, NewExplainStagesPhase(opts))
+
+	return c.runPhases(phases)
+}
+
 func (c *Conveyor) PublishImages(imagesRepoManager ImagesRepoManager, opts PublishImagesOptions) error {
 	var err error
 
@@ -224,6 +276,10 @@ func (c *Conveyor) PublishImages(imagesRepoManager ImagesRepoManager, opts Publi
 	phases = append(phases, NewShouldBeBuiltPhase())
 	phases = append(phases, NewPublishImagesPhase(imagesRepoManager, opts))
 
+	if opts.ProvenancePath != "" {
+		phases = append(phases, NewProvenancePhase(opts.ProvenanceOptions))
+	}
+
 	lockName, err := c.lockAllImagesReadOnly()
 	if err != nil {
 		return err
@@ -238,9 +294,9 @@ type BuildAndPublishOptions struct {
 	PublishImagesOptions
 }
 
-func (c *Conveyor) BuildAndPublish(stagesRepo string, imagesRepoManager ImagesRepoManager, opts BuildAndPublishOptions) error {
+func (c *Conveyor) BuildAndPublish(imagesRepoManager ImagesRepoManager, opts BuildAndPublishOptions) error {
 restart:
-	if err := c.buildAndPublish(stagesRepo, imagesRepoManager, opts); err != nil {
+	if err := c.buildAndPublish(imagesRepoManager, opts); err != nil {
 		if isConveyorShouldBeResetError(err) {
 			c.ReInitRuntimeFields()
 			goto restart
@@ -252,7 +308,7 @@ restart:
 	return nil
 }
 
-func (c *Conveyor) buildAndPublish(stagesRepo string, imagesRepoManager ImagesRepoManager, opts BuildAndPublishOptions) error {
+func (c *Conveyor) buildAndPublish(imagesRepoManager ImagesRepoManager, opts BuildAndPublishOptions) error {
 	var err error
 
 	var phases []Phase
@@ -260,15 +316,23 @@ func (c *Conveyor) buildAndPublish(stagesRepo string, imagesRepoManager ImagesRe
 	phases = append(phases, NewSignaturesPhase(true))
 	phases = append(phases, NewRenewPhase())
 	phases = append(phases, NewPrepareStagesPhase())
-	phases = append(phases, NewBuildStagesPhase(stagesRepo, opts.BuildStagesOptions))
+	phases = append(phases, NewBuildStagesPhase(opts.BuildStagesOptions))
+	phases = append(phases, NewPolicyPhase(opts.BuildStagesOptions.PolicyOptions))
 	phases = append(phases, NewPublishImagesPhase(imagesRepoManager, opts.PublishImagesOptions))
 
+	if opts.PublishImagesOptions.ProvenancePath != "" {
+		phases = append(phases, NewProvenancePhase(opts.PublishImagesOptions.ProvenanceOptions))
+	}
+
 	lockName, err := c.lockAllImagesReadOnly()
 	if err != nil {
 		return err
 	}
 	defer shluz.Unlock(lockName)
 
+	c.buildStagesOptions = &opts.BuildStagesOptions
+	defer func() { c.buildStagesOptions = nil }()
+
 	return c.runPhases(phases)
 }
 
@@ -340,6 +404,45 @@ func (c *Conveyor) GetImageLatestStageImageName(imageName string) string {
 	return c.GetImage(imageName).LatestStage().GetImage().Name()
 }
 
+func (c *Conveyor) IsImageLatestStageBuilt(imageName string) bool {
+	return c.GetImage(imageName).LatestStage().GetImage().IsExists()
+}
+
+// IsBuildingStages reports whether the conveyor runs a build command and is able to build stages in advance
+func (c *Conveyor) IsBuildingStages() bool {
+	return c.buildStagesOptions != nil
+}
+
+// BuildImageStages builds not existing stages of the image before the build phase,
+// it is used when stages signatures of other images depend on the content of the image
+func (c *Conveyor) BuildImageStages(imageName string) error {
+	image := c.GetImage(imageName)
+	if image.LatestStage().GetImage().IsExists() {
+		return nil
+	}
+
+	if c.buildStagesOptions == nil {
+		return fmt.Errorf("stages of %s should be built", image.LogDetailedName())
+	}
+
+	secretValuesToMask := c.secretValuesToMask()
+	docker.SetOutputSecretValuesToMask(secretValuesToMask)
+	defer docker.SetOutputSecretValuesToMask(nil)
+
+	logProcessOptions := logboek.LogProcessOptions{ColorizeMsgFunc: logboek.ColorizeHighlight}
+	if err := logboek.LogProcess(fmt.Sprintf("Building %s stages in advance", image.LogDetailedName()), logProcessOptions, func() error {
+		if err := NewPrepareStagesPhase().runImage(image, c); err != nil {
+			return err
+		}
+
+		return NewBuildStagesPhase(*c.buildStagesOptions).runImage(image, c)
+	}); err != nil {
+		return fmt.Errorf("%s", secretvalues.MaskSecretValuesInString(secretValuesToMask, err.Error()))
+	}
+
+	return nil
+}
+
 func (c *Conveyor) SetBuildingGitStage(imageName string, stageName stage.StageName) {
 	c.buildingGitStageNameByImageName[imageName] = stageName
 }
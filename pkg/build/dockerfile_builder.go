@@ -0,0 +1,244 @@
+package build
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/flant/logboek"
+
+	"github.com/flant/werf/pkg/build/stage"
+	"github.com/flant/werf/pkg/buildkit"
+	"github.com/flant/werf/pkg/config"
+	"github.com/flant/werf/pkg/docker"
+	imagePkg "github.com/flant/werf/pkg/image"
+	"github.com/flant/werf/pkg/slug"
+	"github.com/flant/werf/pkg/werf"
+)
+
+const (
+	DockerDockerfileBuilder   = "docker"
+	BuildKitDockerfileBuilder = "buildkit"
+
+	DefaultDockerfileBuilder = DockerDockerfileBuilder
+)
+
+var DockerfileBuilders = []string{DockerDockerfileBuilder, BuildKitDockerfileBuilder}
+
+type DockerfileBuilderOptions struct {
+	DockerfileBuilder string
+
+	// BuildKitAddr is the address of the buildkitd, BuildKit embedded into the docker daemon is used by default
+	BuildKitAddr string
+}
+
+func (p *BuildStagesPhase) buildDockerfileStage(c *Conveyor, image *Image, s *stage.DockerfileStage) error {
+	img := s.GetImage()
+
+	labels := map[string]string{
+		imagePkg.WerfDockerImageName:   img.Name(),
+		imagePkg.WerfLabel:             c.projectName(),
+		imagePkg.WerfVersionLabel:      werf.Version,
+		imagePkg.WerfCacheVersionLabel: BuildCacheVersion,
+		imagePkg.WerfImageLabel:        "false",
+	}
+
+	if c.devMode {
+		labels[imagePkg.WerfDevLabel] = "true"
+	}
+
+	signatureInputs, err := getStageSignatureInputs(c, s, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	signatureInputsLabels, err := stageSignatureInputsLabels(image.GetName(), s, signatureInputs)
+	if err != nil {
+		return fmt.Errorf("unable to prepare stage %s signature inputs labels: %s", s.Name(), err)
+	}
+
+	for key, value := range signatureInputsLabels {
+		labels[key] = value
+	}
+
+	var secrets []*config.Secret
+	if imageConfig := c.werfConfig.GetDockerfileImage(image.GetName()); imageConfig != nil {
+		secrets = imageConfig.Secrets
+	}
+
+	switch p.DockerfileBuilder {
+	case BuildKitDockerfileBuilder:
+		if p.BuildKitAddr != "" {
+			if err := p.buildDockerfileStageWithBuildKitd(c, image, s, labels, secrets); err != nil {
+				return err
+			}
+		} else if err := p.buildDockerfileStageWithDockerBuildKit(c, image, s, labels, secrets); err != nil {
+			return err
+		}
+	default:
+		if len(secrets) != 0 {
+			return fmt.Errorf("secrets are supported only by %s dockerfile builder", BuildKitDockerfileBuilder)
+		}
+
+		var buildArgs []string
+		for key, value := range labels {
+			buildArgs = append(buildArgs, fmt.Sprintf("--label=%s=%s", key, value))
+		}
+
+		buildArgs = append(buildArgs, fmt.Sprintf("--tag=%s", img.Name()))
+		buildArgs = append(buildArgs, s.DockerBuildArgs()...)
+
+		if err := docker.CliBuild(buildArgs...); err != nil {
+			return fmt.Errorf("failed to build %s: %s", img.Name(), err)
+		}
+	}
+
+	if err := img.SyncDockerState(); err != nil {
+		return fmt.Errorf("failed to sync %s: %s", img.Name(), err)
+	}
+
+	return nil
+}
+
+func (p *BuildStagesPhase) buildDockerfileStageWithDockerBuildKit(c *Conveyor, image *Image, s *stage.DockerfileStage, labels map[string]string, secrets []*config.Secret) error {
+	img := s.GetImage()
+
+	var buildArgs []string
+	for key, value := range labels {
+		buildArgs = append(buildArgs, fmt.Sprintf("--label=%s=%s", key, value))
+	}
+
+	buildArgs = append(buildArgs, fmt.Sprintf("--tag=%s", img.Name()))
+
+	secretsFiles, tmpSecretsFiles, err := secretsFiles(c, image, secrets)
+	defer removeSecretsFiles(tmpSecretsFiles)
+	if err != nil {
+		return err
+	}
+
+	for id, path := range secretsFiles {
+		buildArgs = append(buildArgs, fmt.Sprintf("--secret=id=%s,src=%s", id, path))
+	}
+
+	if c.sshAuthSock != "" {
+		buildArgs = append(buildArgs, fmt.Sprintf("--ssh=%s=%s", buildkit.DefaultSSHId, c.sshAuthSock))
+	}
+
+	var exportCacheImageName string
+	if !c.isLocalStagesStorage() {
+		cacheImageName := c.buildKitCacheImageName(image.GetName())
+
+		// the cache metadata is stored in the image itself and pushed into stages storage after build
+		if c.canPushIntoStagesStorage() {
+			buildArgs = append(buildArgs, "--build-arg=BUILDKIT_INLINE_CACHE=1")
+			exportCacheImageName = cacheImageName
+		}
+
+		if exist, err := c.isBuildKitCacheExistInStagesStorage(image.GetName()); err != nil {
+			return err
+		} else if exist {
+			buildArgs = append(buildArgs, fmt.Sprintf("--cache-from=%s", cacheImageName))
+		}
+	}
+
+	buildArgs = append(buildArgs, s.DockerBuildArgs()...)
+
+	if err := docker.CliBuildWithBuildKit(buildArgs...); err != nil {
+		return fmt.Errorf("failed to build %s: %s", img.Name(), err)
+	}
+
+	if exportCacheImageName != "" {
+		if err := img.SyncDockerState(); err != nil {
+			return fmt.Errorf("failed to sync %s: %s", img.Name(), err)
+		}
+
+		if err := logboek.LogProcess("Exporting build cache into stages storage", logboek.LogProcessOptions{}, func() error {
+			return c.GetStageImage(img.Name()).Export(exportCacheImageName)
+		}); err != nil {
+			return fmt.Errorf("unable to export build cache %s: %s", exportCacheImageName, err)
+		}
+	}
+
+	return nil
+}
+
+func (p *BuildStagesPhase) buildDockerfileStageWithBuildKitd(c *Conveyor, image *Image, s *stage.DockerfileStage, labels map[string]string, secrets []*config.Secret) error {
+	img := s.GetImage()
+
+	secretsData, err := secretsData(c, secrets)
+	if err != nil {
+		return err
+	}
+
+	opts := buildkit.BuildOptions{
+		ContextDir:     s.Context(),
+		DockerfilePath: s.DockerfilePath(),
+		Target:         s.Target(),
+		BuildArgs:      s.BuildArgs(),
+		AddHost:        s.AddHost(),
+		Labels:         labels,
+		Tag:            img.Name(),
+		Secrets:        secretsData,
+		SSHAuthSock:    c.sshAuthSock,
+	}
+
+	if c.canPushIntoStagesStorage() {
+		opts.CacheRef = c.buildKitCacheImageName(image.GetName())
+	}
+
+	if err := buildkit.Build(p.BuildKitAddr, opts); err != nil {
+		return fmt.Errorf("failed to build %s: %s", img.Name(), err)
+	}
+
+	return nil
+}
+
+func (c *Conveyor) buildKitCacheImageName(imageName string) string {
+	return fmt.Sprintf("%s:%s", c.stagesStorage, slug.DockerTag(imagePkg.BuildKitCacheTagPrefix+imageName))
+}
+
+func (c *Conveyor) isBuildKitCacheExistInStagesStorage(imageName string) (bool, error) {
+	return c.hasStagesStorageTag(slug.DockerTag(imagePkg.BuildKitCacheTagPrefix + imageName))
+}
+
+// secretsFiles returns paths of the secrets files and the temporary files, which must be removed after build.
+// Values of environment variables are written into the image tmp dir
+func secretsFiles(c *Conveyor, image *Image, secrets []*config.Secret) (map[string]string, []string, error) {
+	files := map[string]string{}
+	var tmpFiles []string
+	for _, secret := range secrets {
+		if secret.Src != "" {
+			files[secret.Id] = secretSrcPath(c, secret)
+			continue
+		}
+
+		value, ok := os.LookupEnv(secret.Env)
+		if !ok {
+			return nil, tmpFiles, fmt.Errorf("environment variable %s of secret %s is not set", secret.Env, secret.Id)
+		}
+
+		secretsDir := filepath.Join(c.GetImageTmpDir(image.GetName()), "secrets")
+		if err := os.MkdirAll(secretsDir, 0700); err != nil {
+			return nil, tmpFiles, err
+		}
+
+		path := filepath.Join(secretsDir, slug.Slug(secret.Id))
+		tmpFiles = append(tmpFiles, path)
+		if err := ioutil.WriteFile(path, []byte(value), 0600); err != nil {
+			return nil, tmpFiles, fmt.Errorf("unable to write secret %s: %s", secret.Id, err)
+		}
+
+		files[secret.Id] = path
+	}
+
+	return files, tmpFiles, nil
+}
+
+func removeSecretsFiles(paths []string) {
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logboek.LogErrorF("WARNING: unable to remove secret file %s: %s\n", path, err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flant/werf/pkg/config"
+)
+
+func TestSecretsFiles(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "werf-secrets-files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := os.Setenv("WERF_TEST_SECRET", "value"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("WERF_TEST_SECRET")
+
+	srcPath := filepath.Join(tmpDir, "src-secret")
+	if err := ioutil.WriteFile(srcPath, []byte("value"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Conveyor{tmpDir: tmpDir, conveyorPermanentFields: &conveyorPermanentFields{}}
+	secrets := []*config.Secret{
+		{Id: "src", Src: srcPath},
+		{Id: "env", Env: "WERF_TEST_SECRET"},
+	}
+
+	files, tmpFiles, err := secretsFiles(c, &Image{name: "image"}, secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files["src"] != srcPath {
+		t.Errorf("expected src secret path %s, got %s", srcPath, files["src"])
+	}
+
+	if len(tmpFiles) != 1 || tmpFiles[0] != files["env"] {
+		t.Fatalf("expected the only tmp file %s, got %v", files["env"], tmpFiles)
+	}
+
+	info, err := os.Stat(files["env"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("expected secret file mode 0600, got %o", perm)
+	}
+
+	if info, err := os.Stat(filepath.Dir(files["env"])); err != nil {
+		t.Fatal(err)
+	} else if perm := info.Mode().Perm(); perm != 0700 {
+		t.Errorf("expected secrets dir mode 0700, got %o", perm)
+	}
+
+	removeSecretsFiles(tmpFiles)
+
+	if _, err := os.Stat(files["env"]); !os.IsNotExist(err) {
+		t.Errorf("secret file %s expected to be removed, got %v", files["env"], err)
+	}
+
+	if _, err := os.Stat(srcPath); err != nil {
+		t.Errorf("user secret file %s must be kept: %s", srcPath, err)
+	}
+}
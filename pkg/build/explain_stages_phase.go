@@ -0,0 +1,221 @@
+package build
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/flant/logboek"
+	"github.com/flant/werf/pkg/build/stage"
+	"github.com/flant/werf/pkg/docker"
+	"github.com/flant/werf/pkg/docker_registry"
+	imagePkg "github.com/flant/werf/pkg/image"
+	"github.com/flant/werf/pkg/util"
+)
+
+type ExplainStagesOptions struct {
+	// StageName limits explanation to the single stage of the image
+	StageName string
+}
+
+func NewExplainStagesPhase(opts ExplainStagesOptions) *ExplainStagesPhase {
+	return &ExplainStagesPhase{ExplainStagesOptions: opts}
+}
+
+type ExplainStagesPhase struct {
+	ExplainStagesOptions
+}
+
+func (p *ExplainStagesPhase) Run(c *Conveyor) error {
+	logProcessOptions := logboek.LogProcessOptions{ColorizeMsgFunc: logboek.ColorizeHighlight}
+	return logboek.LogProcess("Explaining stages signatures", logProcessOptions, func() error {
+		return p.run(c)
+	})
+}
+
+func (p *ExplainStagesPhase) run(c *Conveyor) error {
+	for _, image := range c.imagesInOrder {
+		if len(c.imageNamesToProcess) != 0 && !util.IsStringsContainValue(c.imageNamesToProcess, image.GetName()) {
+			continue
+		}
+
+		if err := logboek.LogProcess(image.LogDetailedName(), logboek.LogProcessOptions{ColorizeMsgFunc: image.LogProcessColorizeFunc()}, func() error {
+			return p.explainImage(c, image)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *ExplainStagesPhase) explainImage(c *Conveyor, image *Image) error {
+	var prevImage, prevBuiltImage imagePkg.ImageInterface
+	var prevStage stage.Interface
+
+	if p.StageName != "" && image.GetStage(stage.StageName(p.StageName)) == nil {
+		return fmt.Errorf("stage %s is not found in image %s (the stage may be empty)", p.StageName, image.LogName())
+	}
+
+	prevImage = image.GetBaseImage()
+	for _, s := range image.GetStages() {
+		if prevImage.IsExists() {
+			prevBuiltImage = prevImage
+		}
+
+		if p.StageName == "" || string(s.Name()) == p.StageName {
+			if err := p.explainStage(c, image, s, prevStage, prevImage, prevBuiltImage); err != nil {
+				return err
+			}
+		}
+
+		prevImage = s.GetImage()
+		prevStage = s
+	}
+
+	return nil
+}
+
+func (p *ExplainStagesPhase) explainStage(c *Conveyor, image *Image, s, prevStage stage.Interface, prevImage, prevBuiltImage imagePkg.ImageInterface) error {
+	isBuilt := s.GetImage().IsExists()
+	if !isBuilt && !c.isLocalStagesStorage() {
+		exist, err := c.isStageExistInStagesStorage(s.GetSignature())
+		if err != nil {
+			return err
+		}
+
+		isBuilt = exist
+	}
+
+	if isBuilt {
+		logboek.LogF("%s: signature %s is up-to-date\n", s.Name(), s.GetSignature())
+		return nil
+	}
+
+	logboek.LogHighlightF("%s: signature %s is not built\n", s.Name(), s.GetSignature())
+
+	inputs, err := getStageSignatureInputs(c, s, prevStage, prevImage, prevBuiltImage)
+	if err != nil {
+		return err
+	}
+
+	closestStage, err := closestBuiltStage(c, image.GetName(), s.Name())
+	if err != nil {
+		return fmt.Errorf("unable to find closest built stage %s: %s", s.Name(), err)
+	}
+
+	return logboek.WithIndent(func() error {
+		if closestStage == nil {
+			logboek.LogF("There is no previously built stage %s of image %s with recorded signature inputs\n", s.Name(), image.GetName())
+			return nil
+		}
+
+		logboek.LogF("Closest built stage: %s (created %s)\n", closestStage.name, closestStage.created.Format(time.RFC3339))
+
+		changes := DiffStageSignatureInputs(closestStage.inputs, inputs)
+		if len(changes) == 0 {
+			logboek.LogF("Signature inputs are equal\n")
+			return nil
+		}
+
+		for _, change := range changes {
+			switch {
+			case change.Added:
+				logboek.LogF("+ %s: %s\n", change.Key, change.NewValue)
+			case change.Removed:
+				logboek.LogF("- %s: %s\n", change.Key, change.OldValue)
+			default:
+				logboek.LogF("~ %s: %s -> %s\n", change.Key, change.OldValue, change.NewValue)
+			}
+		}
+
+		return nil
+	})
+}
+
+type builtStage struct {
+	name    string
+	created time.Time
+	inputs  StageSignatureInputs
+}
+
+// closestBuiltStage returns the latest stage with recorded signature inputs from the local docker and the stages storage
+func closestBuiltStage(c *Conveyor, imageName string, stageName stage.StageName) (*builtStage, error) {
+	stageLabels := map[string]string{
+		imagePkg.WerfLabel:               c.projectName(),
+		imagePkg.WerfStageImageNameLabel: imageName,
+		imagePkg.WerfStageNameLabel:      string(stageName),
+	}
+
+	var stages []*builtStage
+
+	filterSet := filters.NewArgs()
+	for key, value := range stageLabels {
+		filterSet.Add("label", fmt.Sprintf("%s=%s", key, value))
+	}
+
+	images, err := docker.Images(types.ImageListOptions{Filters: filterSet})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, img := range images {
+		inputs, err := parseStageSignatureInputs(img.Labels)
+		if err != nil {
+			return nil, fmt.Errorf("image %s: %s", img.ID, err)
+		}
+
+		if inputs != nil {
+			stages = append(stages, &builtStage{name: strings.Join(img.RepoTags, ", "), created: time.Unix(img.Created, 0), inputs: inputs})
+		}
+	}
+
+	if !c.isLocalStagesStorage() {
+		repoImages, err := docker_registry.ImagesByWerfImageLabel(c.stagesStorage, "false")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, repoImage := range repoImages {
+			configFile, err := repoImage.Image.ConfigFile()
+			if err != nil {
+				return nil, err
+			}
+
+			if !isLabelsMatched(configFile.Config.Labels, stageLabels) {
+				continue
+			}
+
+			inputs, err := parseStageSignatureInputs(configFile.Config.Labels)
+			if err != nil {
+				return nil, fmt.Errorf("image %s:%s: %s", repoImage.Repository, repoImage.Tag, err)
+			}
+
+			if inputs != nil {
+				stages = append(stages, &builtStage{name: fmt.Sprintf("%s:%s", repoImage.Repository, repoImage.Tag), created: configFile.Created.Time, inputs: inputs})
+			}
+		}
+	}
+
+	if len(stages) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(stages, func(i, j int) bool { return stages[i].created.After(stages[j].created) })
+
+	return stages[0], nil
+}
+
+func isLabelsMatched(labels, expectedLabels map[string]string) bool {
+	for key, value := range expectedLabels {
+		if labels[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
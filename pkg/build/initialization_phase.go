@@ -272,6 +272,8 @@ func generateGitMappings(imageBaseConfig *config.StapelImageBase, c *Conveyor) (
 			Base:   git_repo.Base{Name: "own"},
 			Path:   c.projectDir,
 			GitDir: filepath.Join(c.projectDir, ".git"),
+
+			DevMode: c.devMode,
 		}
 	}
 
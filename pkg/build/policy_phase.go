@@ -0,0 +1,169 @@
+package build
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/go-units"
+
+	"github.com/flant/logboek"
+	"github.com/flant/werf/pkg/build/stage"
+	"github.com/flant/werf/pkg/config"
+)
+
+type PolicyMode string
+
+const (
+	PolicyModeFail PolicyMode = "fail"
+	PolicyModeWarn PolicyMode = "warn"
+)
+
+type PolicyOptions struct {
+	// PolicyMode defines whether images policy violations fail the build or only produce warnings
+	PolicyMode PolicyMode
+}
+
+func NewPolicyPhase(opts PolicyOptions) *PolicyPhase {
+	return &PolicyPhase{PolicyOptions: opts}
+}
+
+type PolicyPhase struct {
+	PolicyOptions
+}
+
+func (p *PolicyPhase) Run(c *Conveyor) error {
+	logProcessOptions := logboek.LogProcessOptions{ColorizeMsgFunc: logboek.ColorizeHighlight}
+	return logboek.LogProcess("Checking images policies", logProcessOptions, func() error {
+		return p.run(c)
+	})
+}
+
+func (p *PolicyPhase) run(c *Conveyor) error {
+	var failedImages []string
+
+	for _, image := range c.imagesInOrder {
+		if image.isArtifact {
+			continue
+		}
+
+		policy, fromReferences, err := imagePolicy(c, image)
+		if err != nil {
+			return err
+		} else if policy == nil {
+			continue
+		}
+
+		inspect := image.LatestStage().GetImage().Inspect()
+		if inspect == nil {
+			return fmt.Errorf("unable to check policy of %s: image is not built", image.LogDetailedName())
+		}
+
+		violations := checkImagePolicy(policy, inspect, fromReferences)
+		if len(violations) == 0 {
+			logboek.LogF("%s: ok\n", image.LogName())
+			continue
+		}
+
+		for _, violation := range violations {
+			if p.PolicyMode == PolicyModeWarn {
+				logboek.LogErrorF("WARNING: %s: %s\n", image.LogName(), violation)
+			} else {
+				logboek.LogErrorF("%s: %s\n", image.LogName(), violation)
+			}
+		}
+
+		failedImages = append(failedImages, image.LogName())
+	}
+
+	if len(failedImages) != 0 && p.PolicyMode != PolicyModeWarn {
+		return fmt.Errorf("policy violations detected for %s", strings.Join(failedImages, ", "))
+	}
+
+	return nil
+}
+
+func imagePolicy(c *Conveyor, image *Image) (*config.Policy, []string, error) {
+	switch imageConfig := c.werfConfig.GetImage(image.GetName()).(type) {
+	case *config.StapelImage:
+		var fromReferences []string
+		if imageConfig.From != "" {
+			fromReferences = append(fromReferences, imageConfig.From)
+		}
+
+		return imageConfig.Policy, fromReferences, nil
+	case *config.ImageFromDockerfile:
+		if imageConfig.Policy == nil {
+			return nil, nil, nil
+		}
+
+		dockerfileStage, ok := image.LatestStage().(*stage.DockerfileStage)
+		if !ok {
+			return imageConfig.Policy, nil, nil
+		}
+
+		fromReferences, err := dockerfileStage.BaseImagesReferences()
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to get base images of %s: %s", image.LogDetailedName(), err)
+		}
+
+		return imageConfig.Policy, fromReferences, nil
+	}
+
+	return nil, nil, nil
+}
+
+func checkImagePolicy(policy *config.Policy, inspect *types.ImageInspect, fromReferences []string) []string {
+	var violations []string
+
+	if policy.MaxSize != 0 && inspect.Size > policy.MaxSize {
+		violations = append(violations, fmt.Sprintf("image size %s exceeds maxSize %s", units.BytesSize(float64(inspect.Size)), units.BytesSize(float64(policy.MaxSize))))
+	}
+
+	var user string
+	var labels map[string]string
+	if inspect.Config != nil {
+		user = inspect.Config.User
+		labels = inspect.Config.Labels
+	}
+
+	if policy.ForbidRootUser && isRootUser(user) {
+		if user == "" {
+			violations = append(violations, "USER is not set, image runs as root")
+		} else {
+			violations = append(violations, fmt.Sprintf("USER %s is root", user))
+		}
+	}
+
+	for _, label := range policy.RequiredLabels {
+		if _, hasKey := labels[label]; !hasKey {
+			violations = append(violations, fmt.Sprintf("required label %s is not set", label))
+		}
+	}
+
+	if policy.ForbidLatestFrom {
+		for _, reference := range fromReferences {
+			if isLatestReference(reference) {
+				violations = append(violations, fmt.Sprintf("base image %s uses latest tag", reference))
+			}
+		}
+	}
+
+	return violations
+}
+
+func isRootUser(user string) bool {
+	name := strings.SplitN(user, ":", 2)[0]
+	return name == "" || name == "root" || name == "0"
+}
+
+// isLatestReference returns true for references with latest tag or without tag and digest
+func isLatestReference(reference string) bool {
+	if strings.Contains(reference, "@") {
+		return false
+	}
+
+	lastPart := reference[strings.LastIndex(reference, "/")+1:]
+	parts := strings.SplitN(lastPart, ":", 2)
+	return len(parts) == 1 || parts[1] == "" || parts[1] == "latest"
+}
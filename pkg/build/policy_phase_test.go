@@ -0,0 +1,47 @@
+package build
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/flant/werf/pkg/config"
+)
+
+func TestCheckImagePolicy(t *testing.T) {
+	policy := &config.Policy{
+		MaxSize:          1024,
+		ForbidRootUser:   true,
+		RequiredLabels:   []string{"maintainer"},
+		ForbidLatestFrom: true,
+	}
+
+	inspect := &types.ImageInspect{
+		Size:   2048,
+		Config: &container.Config{User: "0:0"},
+	}
+
+	violations := checkImagePolicy(policy, inspect, []string{"alpine", "registry.example.com:5000/base:3.10", "ubuntu:latest", "golang@sha256:0123"})
+	expected := []string{
+		"image size 2KiB exceeds maxSize 1KiB",
+		"USER 0:0 is root",
+		"required label maintainer is not set",
+		"base image alpine uses latest tag",
+		"base image ubuntu:latest uses latest tag",
+	}
+
+	if !reflect.DeepEqual(violations, expected) {
+		t.Errorf("unexpected violations:\n%q\nexpected:\n%q", violations, expected)
+	}
+
+	inspect = &types.ImageInspect{
+		Size:   512,
+		Config: &container.Config{User: "app", Labels: map[string]string{"maintainer": "team"}},
+	}
+
+	if violations := checkImagePolicy(policy, inspect, []string{"alpine:3.10"}); len(violations) != 0 {
+		t.Errorf("unexpected violations: %q", violations)
+	}
+}
@@ -1,9 +1,11 @@
 package build
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/flant/logboek"
+	"github.com/flant/werf/pkg/build/stage"
 	imagePkg "github.com/flant/werf/pkg/image"
 	"github.com/flant/werf/pkg/werf"
 )
@@ -35,6 +37,7 @@ func (p *PrepareStagesPhase) run(c *Conveyor) (err error) {
 
 func (p *PrepareStagesPhase) runImage(image *Image, c *Conveyor) (err error) {
 	var prevImage, prevBuiltImage imagePkg.ImageInterface
+	var prevStage stage.Interface
 
 	if !image.isDockerfileImage {
 		if err = image.PrepareBaseImage(c); err != nil {
@@ -52,6 +55,7 @@ func (p *PrepareStagesPhase) runImage(image *Image, c *Conveyor) (err error) {
 
 		if c.GetImageBySignature(s.GetSignature()) != nil || stageImage.IsExists() {
 			prevImage = stageImage
+			prevStage = s
 			continue
 		}
 
@@ -64,20 +68,48 @@ func (p *PrepareStagesPhase) runImage(image *Image, c *Conveyor) (err error) {
 			imagePkg.WerfImageLabel:        "false",
 		})
 
+		if c.devMode {
+			imageServiceCommitChangeOptions.AddLabel(map[string]string{imagePkg.WerfDevLabel: "true"})
+		}
+
+		signatureInputs, err := getStageSignatureInputs(c, s, prevStage, prevImage, prevBuiltImage)
+		if err != nil {
+			return err
+		}
+
+		signatureInputsLabels, err := stageSignatureInputsLabels(image.GetName(), s, signatureInputs)
+		if err != nil {
+			return fmt.Errorf("unable to prepare stage %s signature inputs labels: %s", s.Name(), err)
+		}
+		imageServiceCommitChangeOptions.AddLabel(signatureInputsLabels)
+
 		if c.sshAuthSock != "" {
 			imageRunOptions := stageImage.Container().RunOptions()
 			imageRunOptions.AddVolume(fmt.Sprintf("%s:/.werf/tmp/ssh-auth-sock", c.sshAuthSock))
 			imageRunOptions.AddEnv(map[string]string{"SSH_AUTH_SOCK": "/.werf/tmp/ssh-auth-sock"})
 		}
 
-		err := s.PrepareImage(c, prevBuiltImage, stageImage)
+		if err := c.addStageSecretsVolume(image.GetName(), s, stageImage); err != nil {
+			return fmt.Errorf("unable to add secrets of stage %s: %s", s.Name(), err)
+		}
+
+		err = s.PrepareImage(c, prevBuiltImage, stageImage)
 		if err != nil {
 			return fmt.Errorf("error preparing stage %s: %s", s.Name(), err)
 		}
 
+		if commands := stageImage.Container().UserRunCommands(); len(commands) != 0 {
+			commandsData, err := json.Marshal(commands)
+			if err != nil {
+				return err
+			}
+			imageServiceCommitChangeOptions.AddLabel(map[string]string{imagePkg.WerfStageCommandsLabel: string(commandsData)})
+		}
+
 		c.SetImageBySignature(s.GetSignature(), stageImage)
 
 		prevImage = stageImage
+		prevStage = s
 	}
 
 	return
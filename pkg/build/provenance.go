@@ -0,0 +1,198 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/flant/logboek"
+
+	"github.com/flant/werf/pkg/config"
+	"github.com/flant/werf/pkg/docker_registry"
+	"github.com/flant/werf/pkg/werf"
+)
+
+const ProvenanceLabel = "werf.io/provenance"
+
+type ProvenanceOptions struct {
+	// ProvenancePath enables writing of provenance documents of all processed images into the file
+	ProvenancePath string
+}
+
+type Provenance struct {
+	Project string             `json:"project"`
+	Images  []*ImageProvenance `json:"images"`
+}
+
+// ImageProvenance describes what went into the image
+type ImageProvenance struct {
+	Name        string                  `json:"name"`
+	IsArtifact  bool                    `json:"isArtifact"`
+	WerfVersion string                  `json:"werfVersion"`
+	BaseImage   *BaseImageProvenance    `json:"baseImage,omitempty"`
+	Stages      []*StageProvenance      `json:"stages"`
+	GitMappings []*GitMappingProvenance `json:"gitMappings,omitempty"`
+	Imports     []*ImportProvenance     `json:"imports,omitempty"`
+}
+
+type BaseImageProvenance struct {
+	Name        string   `json:"name,omitempty"`
+	ID          string   `json:"id,omitempty"`
+	RepoDigests []string `json:"repoDigests,omitempty"`
+	FromImage   string   `json:"fromImage,omitempty"`
+}
+
+type StageProvenance struct {
+	Name      string `json:"name"`
+	Signature string `json:"signature"`
+	Image     string `json:"image"`
+	ID        string `json:"id"`
+}
+
+type GitMappingProvenance struct {
+	Repo         string   `json:"repo"`
+	Url          string   `json:"url,omitempty"`
+	Commit       string   `json:"commit"`
+	Add          string   `json:"add"`
+	To           string   `json:"to"`
+	IncludePaths []string `json:"includePaths,omitempty"`
+	ExcludePaths []string `json:"excludePaths,omitempty"`
+}
+
+type ImportProvenance struct {
+	Image         string `json:"image,omitempty"`
+	Artifact      string `json:"artifact,omitempty"`
+	ExternalImage string `json:"externalImage,omitempty"`
+	Signature     string `json:"signature,omitempty"`
+	StageImage    string `json:"stageImage,omitempty"`
+	Add           string `json:"add"`
+	To            string `json:"to"`
+	Before        string `json:"before,omitempty"`
+	After         string `json:"after,omitempty"`
+}
+
+func NewProvenancePhase(opts ProvenanceOptions) *ProvenancePhase {
+	return &ProvenancePhase{ProvenanceOptions: opts}
+}
+
+type ProvenancePhase struct {
+	ProvenanceOptions
+}
+
+func (p *ProvenancePhase) Run(c *Conveyor) error {
+	provenance := &Provenance{Project: c.projectName()}
+	for _, image := range c.imagesInOrder {
+		provenance.Images = append(provenance.Images, imageProvenance(c, image))
+	}
+
+	data, err := json.MarshalIndent(provenance, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if err := ioutil.WriteFile(p.ProvenancePath, data, 0644); err != nil {
+		return fmt.Errorf("unable to write provenance %s: %s", p.ProvenancePath, err)
+	}
+
+	logboek.LogInfoF("Provenance has been written to %s\n", p.ProvenancePath)
+
+	return nil
+}
+
+// ProvenanceTag returns the tag of the provenance artifact of the image with the specified digest
+func ProvenanceTag(digest string) string {
+	return fmt.Sprintf("%s.provenance", strings.Replace(digest, ":", "-", 1))
+}
+
+func pushImageProvenance(c *Conveyor, image *Image, imageRepository, digest string) error {
+	data, err := json.Marshal(imageProvenance(c, image))
+	if err != nil {
+		return err
+	}
+
+	reference := strings.Join([]string{imageRepository, ProvenanceTag(digest)}, ":")
+	return docker_registry.PushArtifact(reference, map[string]string{ProvenanceLabel: string(data)})
+}
+
+func imageProvenance(c *Conveyor, image *Image) *ImageProvenance {
+	provenance := &ImageProvenance{
+		Name:        image.GetName(),
+		IsArtifact:  image.isArtifact,
+		WerfVersion: werf.Version,
+	}
+
+	if image.baseImageImageName != "" {
+		provenance.BaseImage = &BaseImageProvenance{FromImage: image.baseImageImageName}
+	} else if image.baseImage != nil {
+		provenance.BaseImage = &BaseImageProvenance{Name: image.baseImage.Name(), ID: image.baseImage.ID()}
+		if inspect := image.baseImage.Inspect(); inspect != nil {
+			provenance.BaseImage.RepoDigests = inspect.RepoDigests
+		}
+	}
+
+	for _, s := range image.GetStages() {
+		provenance.Stages = append(provenance.Stages, &StageProvenance{
+			Name:      string(s.Name()),
+			Signature: s.GetSignature(),
+			Image:     s.GetImage().Name(),
+			ID:        s.GetImage().ID(),
+		})
+	}
+
+	if len(image.GetStages()) != 0 {
+		lastStageImage := image.LatestStage().GetImage()
+		for _, gitMapping := range image.LatestStage().GetGitMappings() {
+			gitMappingProvenance := &GitMappingProvenance{
+				Repo:         gitMapping.GitRepoInterface.GetName(),
+				Commit:       gitMapping.GetGitCommitFromImageLabels(lastStageImage),
+				Add:          gitMapping.Cwd,
+				To:           gitMapping.To,
+				IncludePaths: gitMapping.IncludePaths,
+				ExcludePaths: gitMapping.ExcludePaths,
+			}
+
+			if gitMapping.RemoteGitRepo != nil {
+				gitMappingProvenance.Url = gitMapping.RemoteGitRepo.Url
+			}
+
+			provenance.GitMappings = append(provenance.GitMappings, gitMappingProvenance)
+		}
+	}
+
+	var imageConfig config.ImageInterface
+	if image.isArtifact {
+		imageConfig = c.werfConfig.GetArtifact(image.GetName())
+	} else {
+		imageConfig = c.werfConfig.GetImage(image.GetName())
+	}
+
+	if stapelImageConfig, ok := imageConfig.(config.StapelImageInterface); ok {
+		for _, imp := range stapelImageConfig.ImageBaseConfig().Import {
+			importProvenance := &ImportProvenance{
+				Image:         imp.ImageName,
+				Artifact:      imp.ArtifactName,
+				ExternalImage: imp.ExternalImage,
+				Add:           imp.Add,
+				To:            imp.To,
+				Before:        imp.Before,
+				After:         imp.After,
+			}
+
+			if imp.ExternalImage == "" {
+				importSourceName := imp.ImageName
+				if importSourceName == "" {
+					importSourceName = imp.ArtifactName
+				}
+
+				importProvenance.Signature = c.GetImageLatestStageSignature(importSourceName)
+				importProvenance.StageImage = c.GetImageLatestStageImageName(importSourceName)
+			}
+
+			provenance.Imports = append(provenance.Imports, importProvenance)
+		}
+	}
+
+	return provenance
+}
@@ -0,0 +1,86 @@
+package build
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flant/werf/pkg/config"
+)
+
+func TestProvenanceTag(t *testing.T) {
+	digest := "sha256:4f5b8d6e8a5c1e0dbd4e1c4a6c3e4b0a6ac7cbbb3e6c5d5a5ef0de3b1a7f6e2c"
+	expected := "sha256-4f5b8d6e8a5c1e0dbd4e1c4a6c3e4b0a6ac7cbbb3e6c5d5a5ef0de3b1a7f6e2c.provenance"
+
+	if tag := ProvenanceTag(digest); tag != expected {
+		t.Errorf("expected %s, got %s", expected, tag)
+	}
+}
+
+func TestProvenancePhase_Run(t *testing.T) {
+	dir, err := ioutil.TempDir("", "werf-provenance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	externalImage := "golang@sha256:4f5b8d6e8a5c1e0dbd4e1c4a6c3e4b0a6ac7cbbb3e6c5d5a5ef0de3b1a7f6e2c"
+
+	werfConfig := &config.WerfConfig{
+		Meta: &config.Meta{Project: "myproject"},
+		StapelImages: []*config.StapelImage{
+			{StapelImageBase: &config.StapelImageBase{
+				Name: "app",
+				Import: []*config.Import{{
+					ArtifactExport: &config.ArtifactExport{ExportBase: &config.ExportBase{Add: "/go/bin/tool", To: "/usr/local/bin/tool"}},
+					ExternalImage:  externalImage,
+					After:          "install",
+				}},
+			}},
+		},
+	}
+
+	c := &Conveyor{
+		conveyorPermanentFields: &conveyorPermanentFields{werfConfig: werfConfig},
+		imagesInOrder:           []*Image{{name: "app", baseImageImageName: "base"}},
+	}
+
+	path := filepath.Join(dir, "provenance.json")
+	if err := NewProvenancePhase(ProvenanceOptions{ProvenancePath: path}).Run(c); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var provenance Provenance
+	if err := json.Unmarshal(data, &provenance); err != nil {
+		t.Fatalf("bad provenance json: %s\n%s", err, data)
+	}
+
+	if provenance.Project != "myproject" || len(provenance.Images) != 1 {
+		t.Fatalf("unexpected provenance: %s", data)
+	}
+
+	imageProvenance := provenance.Images[0]
+	if imageProvenance.Name != "app" || imageProvenance.IsArtifact {
+		t.Errorf("unexpected image: %s", data)
+	}
+
+	if imageProvenance.BaseImage == nil || imageProvenance.BaseImage.FromImage != "base" {
+		t.Errorf("expected base image from image base: %s", data)
+	}
+
+	if len(imageProvenance.Imports) != 1 {
+		t.Fatalf("expected one import: %s", data)
+	}
+
+	imp := imageProvenance.Imports[0]
+	if imp.ExternalImage != externalImage || imp.Add != "/go/bin/tool" || imp.To != "/usr/local/bin/tool" || imp.After != "install" || imp.Signature != "" {
+		t.Errorf("unexpected import: %+v", imp)
+	}
+}
@@ -2,18 +2,23 @@ package build
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
 
 	"github.com/flant/werf/pkg/docker_registry"
+	"github.com/flant/werf/pkg/git_repo"
+	"github.com/flant/werf/pkg/image_signing"
 	imagePkg "github.com/flant/werf/pkg/image"
 	"github.com/flant/shluz"
 	"github.com/flant/werf/pkg/tag_strategy"
+	"github.com/flant/werf/pkg/true_git"
 	"github.com/flant/werf/pkg/util"
 
 	"github.com/flant/logboek"
 )
 
-const RepoImageStageTagFormat = "image-stage-%s"
-
 func NewPublishImagesPhase(imagesRepoManager ImagesRepoManager, opts PublishImagesOptions) *PublishImagesPhase {
 	tagsByScheme := map[tag_strategy.TagStrategy][]string{
 		tag_strategy.Custom:    opts.CustomTags,
@@ -21,13 +26,23 @@ func NewPublishImagesPhase(imagesRepoManager ImagesRepoManager, opts PublishImag
 		tag_strategy.GitTag:    opts.TagsByGitTag,
 		tag_strategy.GitCommit: opts.TagsByGitCommit,
 	}
-	return &PublishImagesPhase{TagsByScheme: tagsByScheme, ImageRepoManager: imagesRepoManager}
+	return &PublishImagesPhase{
+		TagsByScheme:         tagsByScheme,
+		TagByStagesSignature: opts.TagByStagesSignature,
+		ImageRepoManager:     imagesRepoManager,
+		SigningKey:           opts.SigningKey,
+		PushProvenance:       opts.PushProvenance,
+	}
 }
 
 type PublishImagesPhase struct {
-	WithStages       bool
-	TagsByScheme     map[tag_strategy.TagStrategy][]string
-	ImageRepoManager ImagesRepoManager
+	TagsByScheme         map[tag_strategy.TagStrategy][]string
+	TagByStagesSignature bool
+	ImageRepoManager     ImagesRepoManager
+	SigningKey           ed25519.PrivateKey
+	PushProvenance       bool
+
+	gitCommit string
 }
 
 func (p *PublishImagesPhase) Run(c *Conveyor) error {
@@ -38,7 +53,13 @@ func (p *PublishImagesPhase) Run(c *Conveyor) error {
 }
 
 func (p *PublishImagesPhase) run(c *Conveyor) error {
-	// TODO: Push stages should occur on the BuildStagesPhase
+	if p.TagByStagesSignature {
+		gitCommit, err := projectGitHeadCommit(c)
+		if err != nil {
+			return fmt.Errorf("unable to get project git head commit: %s", err)
+		}
+		p.gitCommit = gitCommit
+	}
 
 	var imagesToPublish []*Image
 	if len(c.imageNamesToProcess) == 0 {
@@ -50,25 +71,11 @@ func (p *PublishImagesPhase) run(c *Conveyor) error {
 	}
 
 	for _, image := range imagesToPublish {
-		if image.isArtifact { // FIXME: distributed stages
+		if image.isArtifact {
 			continue
 		}
 
 		if err := logboek.LogProcess(image.LogDetailedName(), logboek.LogProcessOptions{ColorizeMsgFunc: image.LogProcessColorizeFunc()}, func() error {
-			//if p.WithStages {
-			//	err := logboek.LogProcess("Pushing stages cache", logboek.LogProcessOptions{}, func() error {
-			//		if err := p.pushImageStages(c, image); err != nil {
-			//			return fmt.Errorf("unable to push image %s stages: %s", image.GetName(), err)
-			//		}
-			//
-			//		return nil
-			//	})
-			//
-			//	if err != nil {
-			//		return err
-			//	}
-			//}
-
 			if !image.isArtifact {
 				if err := p.pushImage(c, image); err != nil {
 					return fmt.Errorf("unable to push image %s: %s", image.LogName(), err)
@@ -84,66 +91,6 @@ func (p *PublishImagesPhase) run(c *Conveyor) error {
 	return nil
 }
 
-//func (p *PublishImagesPhase) pushImageStages(c *Conveyor, image *Image) error {
-//	stages := image.GetStages()
-//
-//	existingTags, err := docker_registry.Tags(p.ImagesRepo)
-//	if err != nil {
-//		return fmt.Errorf("error fetching existing stages cache list %s: %s", p.ImagesRepo, err)
-//	}
-//
-//	for _, stage := range stages {
-//		stageTagName := fmt.Sprintf(RepoImageStageTagFormat, stage.GetSignature())
-//		stageImageName := fmt.Sprintf("%s:%s", p.ImagesRepo, stageTagName)
-//
-//		if util.IsStringsContainValue(existingTags, stageTagName) {
-//			logboek.LogHighlightLn(stage.Name())
-//
-//			logboek.LogInfoF("stages-repo: %s\n", p.ImagesRepo)
-//			logboek.LogInfoF("      image: %s\n", stageImageName)
-//
-//			logboek.LogOptionalLn()
-//
-//			continue
-//		}
-//
-//		err := func() error {
-//			imageLockName := imagePkg.ImageLockName(stageImageName)
-//			if err := shluz.Lock(imageLockName, shluz.LockOptions{}); err != nil {
-//				return fmt.Errorf("failed to lock %s: %s", imageLockName, err)
-//			}
-//
-//			defer shluz.Unlock(imageLockName)
-//
-//			stageImage := c.GetStageImage(stage.GetImage().Name())
-//
-//			successInfoSectionFunc := func() {
-//				_ = logboek.WithIndent(func() error {
-//					logboek.LogInfoF("stages-repo: %s\n", p.ImagesRepo)
-//					logboek.LogInfoF("      image: %s\n", stageImageName)
-//
-//					return nil
-//				})
-//			}
-//
-//			logProcessOptions := logboek.LogProcessOptions{SuccessInfoSectionFunc: successInfoSectionFunc, ColorizeMsgFunc: logboek.ColorizeHighlight}
-//			return logboek.LogProcess(fmt.Sprintf("Publishing %s", stage.Name()), logProcessOptions, func() error {
-//				if err := stageImage.Export(stageImageName); err != nil {
-//					return fmt.Errorf("error pushing %s: %s", stageImageName, err)
-//				}
-//
-//				return nil
-//			})
-//		}()
-//
-//		if err != nil {
-//			return err
-//		}
-//	}
-//
-//	return nil
-//}
-
 func (p *PublishImagesPhase) pushImage(c *Conveyor, image *Image) error {
 	imageRepository := p.ImageRepoManager.ImageRepo(image.GetName())
 
@@ -168,8 +115,10 @@ func (p *PublishImagesPhase) pushImage(c *Conveyor, image *Image) error {
 	stages := image.GetStages()
 	lastStageImage := stages[len(stages)-1].GetImage()
 
+	tagsByScheme := p.imageTagsByScheme(image)
+
 	var nonEmptySchemeInOrder []tag_strategy.TagStrategy
-	for strategy, tags := range p.TagsByScheme {
+	for strategy, tags := range tagsByScheme {
 		if len(tags) == 0 {
 			continue
 		}
@@ -178,7 +127,7 @@ func (p *PublishImagesPhase) pushImage(c *Conveyor, image *Image) error {
 	}
 
 	for _, strategy := range nonEmptySchemeInOrder {
-		imageMetaTags := p.TagsByScheme[strategy]
+		imageMetaTags := tagsByScheme[strategy]
 
 		if len(imageMetaTags) == 0 {
 			continue
@@ -223,6 +172,16 @@ func (p *PublishImagesPhase) pushImage(c *Conveyor, image *Image) error {
 
 						logboek.LogOptionalLn()
 
+						if strategy == tag_strategy.StagesSignature && p.gitCommit != "" {
+							if err := p.recordImageGitCommit(c, imageRepository, imageName, imageTag, existingTags); err != nil {
+								return err
+							}
+						}
+
+						if err := p.publishImageAttachments(c, image, imageRepository, imageName, existingTags); err != nil {
+							return err
+						}
+
 						continue ProcessingTags
 					}
 				}
@@ -244,6 +203,13 @@ func (p *PublishImagesPhase) pushImage(c *Conveyor, image *Image) error {
 						imagePkg.WerfImageTagLabel:    imageMetaTag,
 					})
 
+					// cleanup keeps the image while the commit or any commit recorded by recordImageGitCommit is reachable from git heads
+					if strategy == tag_strategy.StagesSignature && p.gitCommit != "" {
+						pushImage.Container().ServiceCommitChangeOptions().AddLabel(map[string]string{
+							imagePkg.WerfImageGitCommitLabel: p.gitCommit,
+						})
+					}
+
 					successInfoSectionFunc := func() {
 						_ = logboek.WithIndent(func() error {
 							logboek.LogInfoF("images-repo: %s\n", imageRepository)
@@ -268,7 +234,7 @@ func (p *PublishImagesPhase) pushImage(c *Conveyor, image *Image) error {
 							return fmt.Errorf("error pushing %s: %s", imageName, err)
 						}
 
-						return nil
+						return p.publishImageAttachments(c, image, imageRepository, imageName, existingTags)
 					})
 				}()
 
@@ -287,3 +253,115 @@ func (p *PublishImagesPhase) pushImage(c *Conveyor, image *Image) error {
 
 	return nil
 }
+
+func (p *PublishImagesPhase) imageTagsByScheme(image *Image) map[tag_strategy.TagStrategy][]string {
+	tagsByScheme := map[tag_strategy.TagStrategy][]string{}
+	for strategy, tags := range p.TagsByScheme {
+		tagsByScheme[strategy] = tags
+	}
+
+	if p.TagByStagesSignature {
+		tagsByScheme[tag_strategy.StagesSignature] = []string{image.LatestStage().GetSignature()}
+	}
+
+	return tagsByScheme
+}
+
+func projectGitHeadCommit(c *Conveyor) (string, error) {
+	gitDir := filepath.Join(c.projectDir, ".git")
+	if exist, err := util.DirExists(gitDir); err != nil {
+		return "", err
+	} else if !exist {
+		return "", nil
+	}
+
+	localGitRepo := &git_repo.Local{Path: c.projectDir, GitDir: gitDir}
+	return localGitRepo.HeadCommit()
+}
+
+// GitCommitRecordTag returns the tag of the artifact which records that the up-to-date image has been published by another commit
+func GitCommitRecordTag(imageTag, commit string) string {
+	return fmt.Sprintf("%s%s", GitCommitRecordTagPrefix(imageTag), commit[:12])
+}
+
+func GitCommitRecordTagPrefix(imageTag string) string {
+	return fmt.Sprintf("%s.git-", imageTag)
+}
+
+// recordImageGitCommit records the current commit if the up-to-date image has been published by a commit which is not its ancestor
+// (e.g. by a commit from another branch with the same content), the image label keeps only the commit of the first publication
+func (p *PublishImagesPhase) recordImageGitCommit(c *Conveyor, imageRepository, imageName, imageTag string, existingTags []string) error {
+	if util.IsStringsContainValue(existingTags, GitCommitRecordTag(imageTag, p.gitCommit)) {
+		return nil
+	}
+
+	configFile, err := docker_registry.ImageConfigFile(imageName)
+	if err != nil {
+		return fmt.Errorf("unable to get image %s config: %s", imageName, err)
+	}
+
+	publicationCommits := []string{configFile.Config.Labels[imagePkg.WerfImageGitCommitLabel]}
+	for _, tag := range existingTags {
+		if !strings.HasPrefix(tag, GitCommitRecordTagPrefix(imageTag)) {
+			continue
+		}
+
+		recordReference := strings.Join([]string{imageRepository, tag}, ":")
+		recordConfigFile, err := docker_registry.ImageConfigFile(recordReference)
+		if err != nil {
+			return fmt.Errorf("unable to get image %s git commit record %s: %s", imageName, recordReference, err)
+		}
+
+		publicationCommits = append(publicationCommits, recordConfigFile.Config.Labels[imagePkg.WerfImageGitCommitLabel])
+	}
+
+	gitDir := filepath.Join(c.projectDir, ".git")
+	for _, commit := range publicationCommits {
+		if commit == "" {
+			continue
+		}
+
+		// the commit may be absent in the local repository, so the current commit is recorded in case of error
+		if isAncestor, err := true_git.IsAncestor(gitDir, commit, p.gitCommit); err == nil && isAncestor {
+			return nil
+		}
+	}
+
+	recordReference := strings.Join([]string{imageRepository, GitCommitRecordTag(imageTag, p.gitCommit)}, ":")
+	return logboek.LogProcessInline(fmt.Sprintf("Recording %s git commit %s", imageName, p.gitCommit), logboek.LogProcessInlineOptions{}, func() error {
+		return docker_registry.PushArtifact(recordReference, map[string]string{
+			imagePkg.WerfDockerImageName:     imageName,
+			imagePkg.WerfImageGitCommitLabel: p.gitCommit,
+		})
+	})
+}
+
+// publishImageAttachments pushes signature and provenance of the published image next to the image
+func (p *PublishImagesPhase) publishImageAttachments(c *Conveyor, image *Image, imageRepository, imageName string, existingTags []string) error {
+	if p.SigningKey == nil && !p.PushProvenance {
+		return nil
+	}
+
+	digest, err := docker_registry.ImageDigest(imageName)
+	if err != nil {
+		return fmt.Errorf("unable to get image %s digest: %s", imageName, err)
+	}
+
+	if p.SigningKey != nil && !util.IsStringsContainValue(existingTags, image_signing.SignatureTag(digest)) {
+		if err := logboek.LogProcessInline(fmt.Sprintf("Signing %s", imageName), logboek.LogProcessInlineOptions{}, func() error {
+			return image_signing.Sign(imageRepository, digest, p.SigningKey)
+		}); err != nil {
+			return fmt.Errorf("unable to sign image %s: %s", imageName, err)
+		}
+	}
+
+	if p.PushProvenance && !util.IsStringsContainValue(existingTags, ProvenanceTag(digest)) {
+		if err := logboek.LogProcessInline(fmt.Sprintf("Pushing %s provenance", imageName), logboek.LogProcessInlineOptions{}, func() error {
+			return pushImageProvenance(c, image, imageRepository, digest)
+		}); err != nil {
+			return fmt.Errorf("unable to push image %s provenance: %s", imageName, err)
+		}
+	}
+
+	return nil
+}
@@ -58,6 +58,10 @@ func (p *RenewPhase) run(c *Conveyor) error {
 					if err := img.Untag(); err != nil {
 						return err
 					}
+
+					if err := c.removeStageFromStagesStorage(s); err != nil {
+						return err
+					}
 				}
 
 				imageLockName := imagePkg.ImageLockName(img.Name())
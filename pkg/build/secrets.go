@@ -0,0 +1,156 @@
+package build
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/flant/werf/pkg/build/stage"
+	"github.com/flant/werf/pkg/config"
+	imagePkg "github.com/flant/werf/pkg/image"
+	"github.com/flant/werf/pkg/util"
+	"github.com/flant/werf/pkg/util/secretvalues"
+)
+
+// containerSecretsDir is mounted read-only into the build container of stapel user stage while it runs
+func (c *Conveyor) containerSecretsDir() string {
+	return path.Join(c.containerWerfDir, "secrets")
+}
+
+func (c *Conveyor) addStageSecretsVolume(imageName string, s stage.Interface, stageImage imagePkg.ImageInterface) error {
+	imageBaseConfig := c.getStapelImageBaseConfig(imageName)
+	if imageBaseConfig == nil {
+		return nil
+	}
+
+	var secrets []*config.Secret
+	for _, secret := range imageBaseConfig.Secrets {
+		if util.IsStringsContainValue(config.SecretStages, string(s.Name())) && secret.IsUsedByStage(string(s.Name())) {
+			secrets = append(secrets, secret)
+		}
+	}
+
+	if len(secrets) == 0 {
+		return nil
+	}
+
+	data, err := secretsData(c, secrets)
+	if err != nil {
+		return err
+	}
+
+	// secrets are written to the host only while the build container is running
+	secretsDir := filepath.Join(c.GetImageTmpDir(imageName), "secrets", string(s.Name()))
+	writeSecrets, removeSecrets := stageSecretsRunFuncs(secretsDir, data)
+	stageImage.Container().AddRunPrepareFuncs(writeSecrets)
+	stageImage.Container().AddRunCleanupFuncs(removeSecrets)
+	stageImage.Container().RunOptions().AddVolume(fmt.Sprintf("%s:%s:ro", secretsDir, c.containerSecretsDir()))
+
+	return nil
+}
+
+func stageSecretsRunFuncs(secretsDir string, data map[string][]byte) (func() error, func() error) {
+	writeSecrets := func() error {
+		if err := os.MkdirAll(secretsDir, 0700); err != nil {
+			return fmt.Errorf("unable to create secrets dir %s: %s", secretsDir, err)
+		}
+
+		for id, value := range data {
+			if err := ioutil.WriteFile(filepath.Join(secretsDir, id), value, 0600); err != nil {
+				return fmt.Errorf("unable to write secret %s: %s", id, err)
+			}
+		}
+
+		return nil
+	}
+
+	removeSecrets := func() error {
+		if err := os.RemoveAll(secretsDir); err != nil {
+			return fmt.Errorf("unable to remove secrets dir %s: %s", secretsDir, err)
+		}
+
+		return nil
+	}
+
+	return writeSecrets, removeSecrets
+}
+
+func (c *Conveyor) getStapelImageBaseConfig(imageName string) *config.StapelImageBase {
+	if i := c.werfConfig.GetStapelImage(imageName); i != nil {
+		return i.StapelImageBase
+	} else if i := c.werfConfig.GetArtifact(imageName); i != nil {
+		return i.StapelImageBase
+	}
+
+	return nil
+}
+
+// secretValuesToMask returns values of all available secrets of werf.yaml
+func (c *Conveyor) secretValuesToMask() []string {
+	var secrets []*config.Secret
+	for _, i := range c.werfConfig.StapelImages {
+		secrets = append(secrets, i.Secrets...)
+	}
+
+	for _, i := range c.werfConfig.Artifacts {
+		secrets = append(secrets, i.Secrets...)
+	}
+
+	for _, i := range c.werfConfig.ImagesFromDockerfile {
+		secrets = append(secrets, i.Secrets...)
+	}
+
+	values := map[string]interface{}{}
+	for _, secret := range secrets {
+		// unavailable secret is reported when the stage which uses it is built
+		data, err := secretsData(c, []*config.Secret{secret})
+		if err != nil {
+			continue
+		}
+
+		values[secret.Id] = string(data[secret.Id])
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	return secretvalues.ExtractSecretValuesFromMap(values)
+}
+
+func secretsData(c *Conveyor, secrets []*config.Secret) (map[string][]byte, error) {
+	data := map[string][]byte{}
+	for _, secret := range secrets {
+		if secret.Env != "" {
+			value, ok := os.LookupEnv(secret.Env)
+			if !ok {
+				return nil, fmt.Errorf("environment variable %s of secret %s is not set", secret.Env, secret.Id)
+			}
+
+			data[secret.Id] = []byte(value)
+			continue
+		}
+
+		value, err := ioutil.ReadFile(secretSrcPath(c, secret))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read secret %s: %s", secret.Id, err)
+		}
+
+		data[secret.Id] = value
+	}
+
+	return data, nil
+}
+
+func secretSrcPath(c *Conveyor, secret *config.Secret) string {
+	if strings.HasPrefix(secret.Src, "~") {
+		return util.ExpandPath(secret.Src)
+	} else if filepath.IsAbs(secret.Src) {
+		return secret.Src
+	}
+
+	return filepath.Join(c.projectDir, secret.Src)
+}
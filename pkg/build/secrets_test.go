@@ -0,0 +1,143 @@
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/flant/werf/pkg/build/stage"
+	"github.com/flant/werf/pkg/config"
+	imagePkg "github.com/flant/werf/pkg/image"
+)
+
+func TestConveyor_AddStageSecretsVolume(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "werf-secrets-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "key"), []byte("file-secret-value"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	oldValue, hasOldValue := os.LookupEnv("WERF_TEST_SECRET")
+	defer func() {
+		if hasOldValue {
+			os.Setenv("WERF_TEST_SECRET", oldValue)
+		} else {
+			os.Unsetenv("WERF_TEST_SECRET")
+		}
+	}()
+
+	imageBaseConfig := &config.StapelImageBase{
+		Name:  "app",
+		Shell: &config.Shell{BeforeInstall: []string{"cat /.werf/secrets/token /.werf/secrets/key"}},
+		Secrets: []*config.Secret{
+			{Id: "token", Env: "WERF_TEST_SECRET"},
+			{Id: "key", Src: "key"},
+		},
+	}
+	werfConfig := &config.WerfConfig{StapelImages: []*config.StapelImage{{StapelImageBase: imageBaseConfig}}}
+	c := NewConveyor(werfConfig, nil, tmpDir, filepath.Join(tmpDir, "tmp"), "", "", ConveyorOptions{})
+
+	s := stage.GenerateBeforeInstallStage(imageBaseConfig, &stage.NewBaseStageOptions{
+		ImageName:        "app",
+		ImageTmpDir:      c.GetImageTmpDir("app"),
+		ContainerWerfDir: c.containerWerfDir,
+	})
+
+	var dependencies []string
+	for _, value := range []string{"env-secret-value", "changed-env-secret-value"} {
+		os.Setenv("WERF_TEST_SECRET", value)
+
+		d, err := s.GetDependencies(c, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Contains(d, value) {
+			t.Errorf("secret value should not be in stage dependencies: %s", d)
+		}
+
+		dependencies = append(dependencies, d)
+	}
+
+	if dependencies[0] != dependencies[1] {
+		t.Errorf("secret value should not change stage dependencies: %s != %s", dependencies[0], dependencies[1])
+	}
+
+	stageImage := imagePkg.NewStageImage(nil, "werf-test-image")
+	if err := c.addStageSecretsVolume("app", s, stageImage); err != nil {
+		t.Fatal(err)
+	}
+
+	secretsDir := filepath.Join(c.GetImageTmpDir("app"), "secrets", string(s.Name()))
+	if _, err := os.Stat(secretsDir); !os.IsNotExist(err) {
+		t.Errorf("secrets should not be written before the container run: %v", err)
+	}
+
+	runOptions := stageImage.Container().RunOptions().(*imagePkg.StageImageContainerOptions)
+	if strings.Join(runOptions.Volume, " ") != secretsDir+":/.werf/secrets:ro" {
+		t.Errorf("unexpected volumes %v", runOptions.Volume)
+	}
+
+	// secrets are mounted as a volume, which is not committed, and must not get into the committed image config
+	for _, options := range []imagePkg.ContainerOptions{stageImage.Container().RunOptions(), stageImage.Container().CommitChangeOptions(), stageImage.Container().ServiceCommitChangeOptions()} {
+		o := options.(*imagePkg.StageImageContainerOptions)
+		for _, value := range append(mapValues(o.Env), mapValues(o.Label)...) {
+			if strings.Contains(value, "secret-value") {
+				t.Errorf("secret value in container options: %+v", o)
+			}
+		}
+	}
+
+	for _, change := range stageImage.Container().UserCommitChanges() {
+		if strings.Contains(change, "secret-value") {
+			t.Errorf("secret value in commit changes: %s", change)
+		}
+	}
+}
+
+func TestStageSecretsRunFuncs(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "werf-secrets-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	secretsDir := filepath.Join(tmpDir, "secrets", "install")
+	writeSecrets, removeSecrets := stageSecretsRunFuncs(secretsDir, map[string][]byte{"token": []byte("value")})
+
+	if err := writeSecrets(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(filepath.Join(secretsDir, "token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected secret file mode 0600, got %s", info.Mode())
+	}
+
+	if err := removeSecrets(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(secretsDir); !os.IsNotExist(err) {
+		t.Errorf("expected secrets dir to be removed after the container run: %v", err)
+	}
+}
+
+func mapValues(m map[string]string) []string {
+	var res []string
+	for _, v := range m {
+		res = append(res, v)
+	}
+
+	return res
+}
@@ -0,0 +1,121 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/flant/werf/pkg/build/stage"
+	imagePkg "github.com/flant/werf/pkg/image"
+	"github.com/flant/werf/pkg/util"
+)
+
+const (
+	signatureInputsDependenciesPrefix   = "dependencies/"
+	signatureInputsBuildCacheVersion    = "buildCacheVersion"
+	signatureInputsPrevStageSignature   = "prevStageSignature"
+	signatureInputsDevModeSignaturePart = "devMode"
+)
+
+// StageSignatureInputs are the named components the stage signature is calculated from
+type StageSignatureInputs map[string]string
+
+func getStageSignatureInputs(c *Conveyor, s, prevStage stage.Interface, prevImage, prevBuiltImage imagePkg.ImageInterface) (StageSignatureInputs, error) {
+	_, inputs, err := calculateStageSignature(c, s, prevStage, prevImage, prevBuiltImage)
+	return inputs, err
+}
+
+// calculateStageSignature returns the stage signature and the inputs it is calculated from
+func calculateStageSignature(c *Conveyor, s, prevStage stage.Interface, prevImage, prevBuiltImage imagePkg.ImageInterface) (string, StageSignatureInputs, error) {
+	stageDependencies, dependencies, err := stage.ExplainDependencies(s, c, prevImage, prevBuiltImage)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to calculate stage %s dependencies: %s", s.Name(), err)
+	}
+
+	inputs := StageSignatureInputs{}
+	for key, value := range dependencies {
+		inputs[signatureInputsDependenciesPrefix+key] = value
+	}
+
+	checksumArgs := []string{stageDependencies, BuildCacheVersion}
+	inputs[signatureInputsBuildCacheVersion] = BuildCacheVersion
+
+	if prevStage != nil {
+		checksumArgs = append(checksumArgs, prevStage.GetSignature())
+		inputs[signatureInputsPrevStageSignature] = prevStage.GetSignature()
+	}
+
+	if c.devMode {
+		checksumArgs = append(checksumArgs, DevModeSignaturePart)
+		inputs[signatureInputsDevModeSignaturePart] = DevModeSignaturePart
+	}
+
+	return util.Sha256Hash(checksumArgs...), inputs, nil
+}
+
+func stageSignatureInputsLabels(imageName string, s stage.Interface, inputs StageSignatureInputs) (map[string]string, error) {
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		imagePkg.WerfStageNameLabel:            string(s.Name()),
+		imagePkg.WerfStageImageNameLabel:       imageName,
+		imagePkg.WerfStageSignatureInputsLabel: string(data),
+	}, nil
+}
+
+// StageParentSignature returns the previous stage signature recorded in the stage labels, stages built by older werf versions have no record
+func StageParentSignature(labels map[string]string) (string, error) {
+	inputs, err := parseStageSignatureInputs(labels)
+	if err != nil {
+		return "", err
+	}
+
+	return inputs[signatureInputsPrevStageSignature], nil
+}
+
+func parseStageSignatureInputs(labels map[string]string) (StageSignatureInputs, error) {
+	data, ok := labels[imagePkg.WerfStageSignatureInputsLabel]
+	if !ok {
+		return nil, nil
+	}
+
+	inputs := StageSignatureInputs{}
+	if err := json.Unmarshal([]byte(data), &inputs); err != nil {
+		return nil, fmt.Errorf("bad %s label: %s", imagePkg.WerfStageSignatureInputsLabel, err)
+	}
+
+	return inputs, nil
+}
+
+type StageSignatureInputsChange struct {
+	Key      string
+	OldValue string
+	NewValue string
+	Added    bool
+	Removed  bool
+}
+
+func DiffStageSignatureInputs(oldInputs, newInputs StageSignatureInputs) []StageSignatureInputsChange {
+	var changes []StageSignatureInputsChange
+
+	for key, newValue := range newInputs {
+		if oldValue, ok := oldInputs[key]; !ok {
+			changes = append(changes, StageSignatureInputsChange{Key: key, NewValue: newValue, Added: true})
+		} else if oldValue != newValue {
+			changes = append(changes, StageSignatureInputsChange{Key: key, OldValue: oldValue, NewValue: newValue})
+		}
+	}
+
+	for key, oldValue := range oldInputs {
+		if _, ok := newInputs[key]; !ok {
+			changes = append(changes, StageSignatureInputsChange{Key: key, OldValue: oldValue, Removed: true})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+
+	return changes
+}
@@ -0,0 +1,40 @@
+package build
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffStageSignatureInputs(t *testing.T) {
+	oldInputs := StageSignatureInputs{
+		"dependencies/commands":            "a",
+		"dependencies/git own commit":      "1",
+		"dependencies/import image base /": "x",
+		"buildCacheVersion":                "1",
+	}
+
+	newInputs := StageSignatureInputs{
+		"dependencies/commands":       "a",
+		"dependencies/git own commit": "2",
+		"buildCacheVersion":           "1",
+		"devMode":                     "dev",
+	}
+
+	expected := []StageSignatureInputsChange{
+		{Key: "dependencies/git own commit", OldValue: "1", NewValue: "2"},
+		{Key: "dependencies/import image base /", OldValue: "x", Removed: true},
+		{Key: "devMode", NewValue: "dev", Added: true},
+	}
+
+	if changes := DiffStageSignatureInputs(oldInputs, newInputs); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("unexpected changes %+v, expected %+v", changes, expected)
+	}
+}
+
+func TestDiffStageSignatureInputsEqual(t *testing.T) {
+	inputs := StageSignatureInputs{"dependencies/commands": "a", "buildCacheVersion": "1"}
+
+	if changes := DiffStageSignatureInputs(inputs, inputs); len(changes) != 0 {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+}
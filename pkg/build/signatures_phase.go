@@ -8,12 +8,14 @@ import (
 	"github.com/flant/werf/pkg/build/stage"
 	imagePkg "github.com/flant/werf/pkg/image"
 	"github.com/flant/shluz"
-	"github.com/flant/werf/pkg/util"
 )
 
 const (
 	BuildCacheVersion = "1"
 
+	// DevModeSignaturePart separates signatures of stages built in dev mode from others
+	DevModeSignaturePart = "dev"
+
 	LocalImageStageImageNameFormat = "werf-stages-storage/%s"
 	LocalImageStageImageFormat     = "werf-stages-storage/%s:%s"
 )
@@ -75,19 +77,11 @@ func (p *SignaturesPhase) calculateImageSignatures(c *Conveyor, image *Image) er
 			continue
 		}
 
-		stageDependencies, err := s.GetDependencies(c, prevImage, prevBuiltImage)
+		stageSig, _, err := calculateStageSignature(c, s, prevStage, prevImage, prevBuiltImage)
 		if err != nil {
 			return err
 		}
 
-		checksumArgs := []string{stageDependencies, BuildCacheVersion}
-
-		if prevStage != nil {
-			checksumArgs = append(checksumArgs, prevStage.GetSignature())
-		}
-
-		stageSig := util.Sha256Hash(checksumArgs...)
-
 		s.SetSignature(stageSig)
 
 		logboek.LogInfoF("%s:%s %s\n", s.Name(), strings.Repeat(" ", maxStageNameLength-len(s.Name())), stageSig)
@@ -112,6 +106,10 @@ func (p *SignaturesPhase) calculateImageSignatures(c *Conveyor, image *Image) er
 			}
 		}
 
+		if err = c.pullStageFromStagesStorage(s); err != nil {
+			return fmt.Errorf("error pulling stage %s from stages storage: %s", s.Name(), err)
+		}
+
 		if err = s.AfterImageSyncDockerStateHook(c); err != nil {
 			return err
 		}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/flant/werf/pkg/cache_mount"
 	"github.com/flant/werf/pkg/config"
 	imagePkg "github.com/flant/werf/pkg/image"
 	"github.com/flant/werf/pkg/slug"
@@ -138,6 +139,8 @@ func (s *BaseStage) PrepareImage(_ Conveyor, prevBuiltImage, image imagePkg.Imag
 		return fmt.Errorf("error adding mounts volumes: %s", err)
 	}
 
+	s.addCacheMountVolumes(image)
+
 	return nil
 }
 
@@ -312,6 +315,28 @@ func (s *BaseStage) addCustomMountLabels(mountpointsByFrom map[string][]string,
 	}
 }
 
+// addCacheMountVolumes mounts project cache volumes into the stage container,
+// cache mounts are neither saved in labels nor taken into account in signatures.
+// Volumes are prepared under the volume lock, so the cleanup cannot remove a volume between preparing and running the container
+func (s *BaseStage) addCacheMountVolumes(image imagePkg.ImageInterface) {
+	for _, mountCfg := range s.configMounts {
+		if mountCfg.Type != "cache" {
+			continue
+		}
+
+		projectName, id := s.projectName, mountCfg.ID
+		volumeName := cache_mount.VolumeName(projectName, id)
+
+		absoluteMountpoint := path.Join("/", path.Clean(mountCfg.To))
+		image.Container().RunOptions().AddVolume(fmt.Sprintf("%s:%s", volumeName, absoluteMountpoint))
+		image.Container().AddRunLocks(cache_mount.LockName(volumeName))
+		image.Container().AddRunPrepareFuncs(func() error {
+			_, err := cache_mount.Prepare(projectName, id)
+			return err
+		})
+	}
+}
+
 func (s *BaseStage) SetSignature(signature string) {
 	s.signature = signature
 }
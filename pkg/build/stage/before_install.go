@@ -25,8 +25,17 @@ type BeforeInstallStage struct {
 	*UserStage
 }
 
-func (s *BeforeInstallStage) GetDependencies(_ Conveyor, _, _ image.ImageInterface) (string, error) {
-	return s.builder.BeforeInstallChecksum(), nil
+func (s *BeforeInstallStage) GetDependencies(c Conveyor, prevImage, prevBuiltImage image.ImageInterface) (string, error) {
+	return dependenciesChecksum(s.calculateDependencies(c, prevImage, prevBuiltImage))
+}
+
+func (s *BeforeInstallStage) calculateDependencies(_ Conveyor, _, _ image.ImageInterface) (*dependencies, error) {
+	// the commands checksum is the stage dependencies as is
+	d := newDependencies()
+	d.checksumFunc = func(args ...string) string { return args[0] }
+	d.add("commands", s.builder.BeforeInstallChecksum())
+
+	return d, nil
 }
 
 func (s *BeforeInstallStage) PrepareImage(c Conveyor, prevBuiltImage, image image.ImageInterface) error {
@@ -4,7 +4,6 @@ import (
 	"github.com/flant/werf/pkg/build/builder"
 	"github.com/flant/werf/pkg/config"
 	"github.com/flant/werf/pkg/image"
-	"github.com/flant/werf/pkg/util"
 )
 
 func GenerateBeforeSetupStage(imageBaseConfig *config.StapelImageBase, gitPatchStageOptions *NewGitPatchStageOptions, baseStageOptions *NewBaseStageOptions) *BeforeSetupStage {
@@ -26,13 +25,12 @@ type BeforeSetupStage struct {
 	*UserWithGitPatchStage
 }
 
-func (s *BeforeSetupStage) GetDependencies(_ Conveyor, _, _ image.ImageInterface) (string, error) {
-	stageDependenciesChecksum, err := s.getStageDependenciesChecksum(BeforeSetup)
-	if err != nil {
-		return "", err
-	}
+func (s *BeforeSetupStage) GetDependencies(c Conveyor, prevImage, prevBuiltImage image.ImageInterface) (string, error) {
+	return dependenciesChecksum(s.calculateDependencies(c, prevImage, prevBuiltImage))
+}
 
-	return util.Sha256Hash(s.builder.BeforeSetupChecksum(), stageDependenciesChecksum), nil
+func (s *BeforeSetupStage) calculateDependencies(_ Conveyor, _, _ image.ImageInterface) (*dependencies, error) {
+	return s.calculateUserStageDependencies(s.builder.BeforeSetupChecksum(), BeforeSetup)
 }
 
 func (s *BeforeSetupStage) PrepareImage(c Conveyor, prevBuiltImage, image image.ImageInterface) error {
@@ -3,6 +3,9 @@ package stage
 type Conveyor interface {
 	GetImageLatestStageSignature(imageName string) string
 	GetImageLatestStageImageName(imageName string) string
+	IsImageLatestStageBuilt(imageName string) bool
+	IsBuildingStages() bool
+	BuildImageStages(imageName string) error
 	SetBuildingGitStage(imageName string, stageName StageName)
 	GetBuildingGitStage(imageName string) StageName
 }
@@ -1,11 +1,11 @@
 package stage
 
 import (
+	"fmt"
 	"sort"
 
 	"github.com/flant/werf/pkg/config"
 	"github.com/flant/werf/pkg/image"
-	"github.com/flant/werf/pkg/util"
 )
 
 func GenerateDockerInstructionsStage(imageConfig *config.StapelImage, baseStageOptions *NewBaseStageOptions) *DockerInstructionsStage {
@@ -29,35 +29,42 @@ type DockerInstructionsStage struct {
 	instructions *config.Docker
 }
 
-func (s *DockerInstructionsStage) GetDependencies(_ Conveyor, _, _ image.ImageInterface) (string, error) {
-	var args []string
-
-	args = append(args, s.instructions.Volume...)
-	args = append(args, s.instructions.Expose...)
-	args = append(args, mapToSortedArgs(s.instructions.Env)...)
-	args = append(args, mapToSortedArgs(s.instructions.Label)...)
-	args = append(args, s.instructions.Cmd)
-	args = append(args, s.instructions.Entrypoint)
-	args = append(args, s.instructions.Workdir)
-	args = append(args, s.instructions.User)
-	args = append(args, "") // legacy StopSignal
-	args = append(args, s.instructions.HealthCheck)
-
-	return util.Sha256Hash(args...), nil
+func (s *DockerInstructionsStage) GetDependencies(c Conveyor, prevImage, prevBuiltImage image.ImageInterface) (string, error) {
+	return dependenciesChecksum(s.calculateDependencies(c, prevImage, prevBuiltImage))
 }
 
-func mapToSortedArgs(h map[string]string) (result []string) {
+func (s *DockerInstructionsStage) calculateDependencies(_ Conveyor, _, _ image.ImageInterface) (*dependencies, error) {
+	d := newDependencies()
+
+	d.add("volume", s.instructions.Volume...)
+	d.add("expose", s.instructions.Expose...)
+
+	for _, key := range sortedKeys(s.instructions.Env) {
+		d.add(fmt.Sprintf("env %s", key), key, s.instructions.Env[key])
+	}
+
+	for _, key := range sortedKeys(s.instructions.Label) {
+		d.add(fmt.Sprintf("label %s", key), key, s.instructions.Label[key])
+	}
+
+	d.add("cmd", s.instructions.Cmd)
+	d.add("entrypoint", s.instructions.Entrypoint)
+	d.add("workdir", s.instructions.Workdir)
+	d.add("user", s.instructions.User)
+	d.add("stopSignal", "") // legacy StopSignal
+	d.add("healthcheck", s.instructions.HealthCheck)
+
+	return d, nil
+}
+
+func sortedKeys(h map[string]string) []string {
 	keys := make([]string, 0, len(h))
 	for key := range h {
 		keys = append(keys, key)
 	}
 	sort.Strings(keys)
 
-	for _, key := range keys {
-		result = append(result, key, h[key])
-	}
-
-	return
+	return keys
 }
 
 func (s *DockerInstructionsStage) PrepareImage(c Conveyor, prevBuiltImage, image image.ImageInterface) error {
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/docker/docker/pkg/fileutils"
 	"github.com/moby/buildkit/frontend/dockerfile/instructions"
@@ -175,6 +176,72 @@ func (s *DockerfileStage) DockerBuildArgs() []string {
 	return result
 }
 
+func (s *DockerfileStage) DockerfilePath() string {
+	return s.dockerfilePath
+}
+
+func (s *DockerfileStage) Target() string {
+	return s.target
+}
+
+func (s *DockerfileStage) Context() string {
+	return s.context
+}
+
+func (s *DockerfileStage) BuildArgs() map[string]string {
+	result := map[string]string{}
+	for key, value := range s.buildArgs {
+		result[key] = fmt.Sprintf("%v", value)
+	}
+
+	return result
+}
+
+func (s *DockerfileStage) AddHost() []string {
+	return s.addHost
+}
+
+// BaseImagesReferences returns resolved FROM references of the target and preceding Dockerfile stages,
+// references to other Dockerfile stages and scratch are skipped
+func (s *DockerfileStage) BaseImagesReferences() ([]string, error) {
+	var dockerMetaArgsString []string
+	for key, value := range s.dockerArgsHash {
+		dockerMetaArgsString = append(dockerMetaArgsString, fmt.Sprintf("%s=%s", key, value))
+	}
+
+	shlex := shell.NewLex(parser.DefaultEscapeToken)
+
+	var result []string
+	for ind, stage := range s.dockerStages {
+		if ind > s.dockerTargetStageIndex {
+			break
+		}
+
+		resolvedBaseName, err := shlex.ProcessWord(stage.BaseName, dockerMetaArgsString)
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.ToLower(resolvedBaseName) == "scratch" {
+			continue
+		}
+
+		isStageReference := false
+		for _, relatedStage := range s.dockerStages[:ind] {
+			if relatedStage.Name != "" && strings.EqualFold(relatedStage.Name, resolvedBaseName) {
+				isStageReference = true
+				break
+			}
+		}
+
+		if !isStageReference {
+			result = append(result, resolvedBaseName)
+		}
+	}
+
+	return result, nil
+}
+
 func (s *DockerfileStage) calculateFilesHashsum(wildcards []string) (string, error) {
 	var dependencies []string
 
@@ -0,0 +1,109 @@
+package stage
+
+import (
+	"fmt"
+
+	"github.com/flant/werf/pkg/image"
+	"github.com/flant/werf/pkg/util"
+)
+
+// dependencies are the named components the stage dependencies checksum is calculated from.
+// The checksum and its explanation are both derived from the components, so they cannot diverge.
+type dependencies struct {
+	components []dependenciesComponent
+
+	// checksumFunc calculates the checksum from args of all components in order of addition
+	checksumFunc func(args ...string) string
+}
+
+type dependenciesComponent struct {
+	name string
+	args []string
+
+	// nested dependencies are the component with the only arg, the nested checksum
+	nested *dependencies
+}
+
+type dependenciesCalculator interface {
+	calculateDependencies(c Conveyor, prevImage, prevBuiltImage image.ImageInterface) (*dependencies, error)
+}
+
+func newDependencies() *dependencies {
+	return &dependencies{checksumFunc: util.Sha256Hash}
+}
+
+func (d *dependencies) add(name string, args ...string) {
+	d.components = append(d.components, dependenciesComponent{name: name, args: args})
+}
+
+func (d *dependencies) addNested(name string, nested *dependencies) {
+	d.components = append(d.components, dependenciesComponent{name: name, nested: nested})
+}
+
+func (d *dependencies) checksum() string {
+	var args []string
+	for _, component := range d.components {
+		if component.nested != nil {
+			args = append(args, component.nested.checksum())
+		} else {
+			args = append(args, component.args...)
+		}
+	}
+
+	return d.checksumFunc(args...)
+}
+
+func (d *dependencies) explain() map[string]string {
+	res := map[string]string{}
+	for _, component := range d.components {
+		if component.nested != nil {
+			for name, value := range component.nested.explain() {
+				res[fmt.Sprintf("%s %s", component.name, name)] = value
+			}
+
+			continue
+		}
+
+		var value string
+		if len(component.args) == 1 {
+			value = component.args[0]
+		} else {
+			value = fmt.Sprintf("%q", component.args)
+		}
+
+		if prevValue, ok := res[component.name]; ok {
+			value = fmt.Sprintf("%s %s", prevValue, value)
+		}
+
+		res[component.name] = value
+	}
+
+	return res
+}
+
+func dependenciesChecksum(d *dependencies, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+
+	return d.checksum(), nil
+}
+
+// ExplainDependencies returns the stage dependencies checksum and the named components it is calculated from
+func ExplainDependencies(s Interface, c Conveyor, prevImage, prevBuiltImage image.ImageInterface) (string, map[string]string, error) {
+	if calculator, ok := s.(dependenciesCalculator); ok {
+		d, err := calculator.calculateDependencies(c, prevImage, prevBuiltImage)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return d.checksum(), d.explain(), nil
+	}
+
+	checksum, err := s.GetDependencies(c, prevImage, prevBuiltImage)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return checksum, map[string]string{"dependencies": checksum}, nil
+}
@@ -0,0 +1,247 @@
+package stage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flant/werf/pkg/build/builder"
+	"github.com/flant/werf/pkg/config"
+	"github.com/flant/werf/pkg/image"
+	"github.com/flant/werf/pkg/util"
+)
+
+type testConveyor struct {
+	Conveyor
+	signatures map[string]string
+}
+
+func (c *testConveyor) GetImageLatestStageSignature(imageName string) string {
+	return c.signatures[imageName]
+}
+
+type testImage struct {
+	image.ImageInterface
+	name string
+}
+
+func (i *testImage) Name() string {
+	return i.name
+}
+
+type testBuilder struct {
+	builder.Builder
+	checksum string
+}
+
+func (b *testBuilder) BeforeInstallChecksum() string { return "beforeInstall " + b.checksum }
+func (b *testBuilder) InstallChecksum() string       { return "install " + b.checksum }
+func (b *testBuilder) BeforeSetupChecksum() string   { return "beforeSetup " + b.checksum }
+func (b *testBuilder) SetupChecksum() string         { return "setup " + b.checksum }
+
+func testDockerInstructions() *config.Docker {
+	return &config.Docker{
+		Volume:      []string{"/data"},
+		Expose:      []string{"80"},
+		Env:         map[string]string{"A": "1", "B": "2"},
+		Label:       map[string]string{"app": "test"},
+		Cmd:         "cmd",
+		Workdir:     "/app",
+		User:        "user",
+		Entrypoint:  "entrypoint",
+		HealthCheck: "healthcheck",
+	}
+}
+
+func testImport(imageName string, mutate func(i *config.Import)) *config.Import {
+	i := &config.Import{
+		ArtifactExport: &config.ArtifactExport{ExportBase: &config.ExportBase{
+			Add:          "/app",
+			To:           "/app",
+			IncludePaths: []string{"bin"},
+			ExcludePaths: []string{"tmp"},
+			Owner:        "owner",
+			Group:        "group",
+		}},
+		ImageName: imageName,
+	}
+
+	if mutate != nil {
+		mutate(i)
+	}
+
+	return i
+}
+
+// TestExplainDependencies checks that every change of the stage dependencies checksum is explained
+// and the explanation is calculated from the same inputs as the checksum
+func TestExplainDependencies(t *testing.T) {
+	baseStageOptions := &NewBaseStageOptions{ImageName: "image"}
+	c := &testConveyor{signatures: map[string]string{"base": "base-signature", "other": "other-signature"}}
+
+	dockerInstructionsStage := func(mutate func(d *config.Docker)) Interface {
+		d := testDockerInstructions()
+		if mutate != nil {
+			mutate(d)
+		}
+
+		return newDockerInstructionsStage(d, baseStageOptions)
+	}
+
+	fromStage := func(baseImageRepoId, cacheVersion string, mounts ...*config.Mount) Interface {
+		return newFromStage(baseImageRepoId, cacheVersion, &NewBaseStageOptions{ImageName: "image", ConfigMounts: mounts})
+	}
+
+	importsStage := func(imports ...*config.Import) Interface {
+		return newImportsStage(imports, ImportsBeforeInstall, baseStageOptions)
+	}
+
+	tests := []struct {
+		name string
+		// the first stage is compared with each of the others, which differ in a single input
+		stages []Interface
+	}{
+		{
+			name: "dockerInstructions",
+			stages: []Interface{
+				dockerInstructionsStage(nil),
+				dockerInstructionsStage(func(d *config.Docker) { d.Volume = append(d.Volume, "/cache") }),
+				dockerInstructionsStage(func(d *config.Docker) { d.Expose = nil }),
+				dockerInstructionsStage(func(d *config.Docker) { d.Env["A"] = "3" }),
+				dockerInstructionsStage(func(d *config.Docker) { d.Env["C"] = "" }),
+				dockerInstructionsStage(func(d *config.Docker) { d.Label["app"] = "other" }),
+				dockerInstructionsStage(func(d *config.Docker) { d.Cmd = "other" }),
+				dockerInstructionsStage(func(d *config.Docker) { d.Workdir = "/other" }),
+				dockerInstructionsStage(func(d *config.Docker) { d.User = "other" }),
+				dockerInstructionsStage(func(d *config.Docker) { d.Entrypoint = "other" }),
+				dockerInstructionsStage(func(d *config.Docker) { d.HealthCheck = "other" }),
+			},
+		},
+		{
+			name: "from",
+			stages: []Interface{
+				fromStage("", "", &config.Mount{From: "/host", To: "/container", Type: "custom_dir"}),
+				fromStage("repo-id", "", &config.Mount{From: "/host", To: "/container", Type: "custom_dir"}),
+				fromStage("", "1", &config.Mount{From: "/host", To: "/container", Type: "custom_dir"}),
+				fromStage("", "", &config.Mount{From: "/other", To: "/container", Type: "custom_dir"}),
+				fromStage("", ""),
+			},
+		},
+		{
+			name: "beforeInstall",
+			stages: []Interface{
+				newBeforeInstallStage(&testBuilder{checksum: "a"}, baseStageOptions),
+				newBeforeInstallStage(&testBuilder{checksum: "b"}, baseStageOptions),
+			},
+		},
+		{
+			name: "install",
+			stages: []Interface{
+				newInstallStage(&testBuilder{checksum: "a"}, &NewGitPatchStageOptions{}, baseStageOptions),
+				newInstallStage(&testBuilder{checksum: "b"}, &NewGitPatchStageOptions{}, baseStageOptions),
+			},
+		},
+		{
+			name: "imports",
+			stages: []Interface{
+				importsStage(testImport("base", nil)),
+				importsStage(testImport("other", nil)),
+				importsStage(testImport("base", func(i *config.Import) { i.To = "/other" })),
+				importsStage(testImport("base", func(i *config.Import) { i.Owner = "other" })),
+				importsStage(testImport("base", func(i *config.Import) { i.IncludePaths = nil })),
+				importsStage(testImport("base", func(i *config.Import) { i.ExcludePaths = append(i.ExcludePaths, "log") })),
+				importsStage(testImport("base", nil), testImport("other", nil)),
+			},
+		},
+	}
+
+	prevImage := &testImage{name: "prev-image"}
+
+	for _, tt := range tests {
+		var checksums []string
+		var explanations []map[string]string
+		for ind, s := range tt.stages {
+			checksum, explanation, err := ExplainDependencies(s, c, prevImage, nil)
+			if err != nil {
+				t.Fatalf("%s[%d]: %s", tt.name, ind, err)
+			}
+
+			dependencies, err := s.GetDependencies(c, prevImage, nil)
+			if err != nil {
+				t.Fatalf("%s[%d]: %s", tt.name, ind, err)
+			}
+
+			if dependencies != checksum {
+				t.Errorf("%s[%d]: explained checksum %s differs from dependencies %s", tt.name, ind, checksum, dependencies)
+			}
+
+			checksums = append(checksums, checksum)
+			explanations = append(explanations, explanation)
+		}
+
+		for ind := 1; ind < len(tt.stages); ind++ {
+			if checksums[ind] == checksums[0] {
+				t.Errorf("%s[%d]: dependencies checksum expected to change", tt.name, ind)
+			}
+
+			if reflect.DeepEqual(explanations[ind], explanations[0]) {
+				t.Errorf("%s[%d]: dependencies checksum change is not explained: %v", tt.name, ind, explanations[ind])
+			}
+		}
+	}
+}
+
+// TestDependenciesChecksumCompatibility checks that the stages signatures are not changed by the dependencies explanation
+func TestDependenciesChecksumCompatibility(t *testing.T) {
+	baseStageOptions := &NewBaseStageOptions{ImageName: "image"}
+	c := &testConveyor{signatures: map[string]string{"base": "base-signature"}}
+	prevImage := &testImage{name: "prev-image"}
+
+	tests := []struct {
+		name     string
+		stage    Interface
+		expected string
+	}{
+		{
+			name:  "dockerInstructions",
+			stage: newDockerInstructionsStage(testDockerInstructions(), baseStageOptions),
+			expected: util.Sha256Hash(
+				"/data", "80", "A", "1", "B", "2", "app", "test",
+				"cmd", "entrypoint", "/app", "user", "", "healthcheck",
+			),
+		},
+		{
+			name: "from",
+			stage: newFromStage("repo-id", "1", &NewBaseStageOptions{ConfigMounts: []*config.Mount{
+				{From: "/host", To: "/container", Type: "custom_dir"},
+				{From: "/cache", To: "/cache", Type: "cache"},
+			}}),
+			expected: util.Sha256Hash("1", "repo-id", "/host", "/container", "custom_dir", "prev-image"),
+		},
+		{
+			name:     "beforeInstall",
+			stage:    newBeforeInstallStage(&testBuilder{checksum: "a"}, baseStageOptions),
+			expected: "beforeInstall a",
+		},
+		{
+			name:     "setup",
+			stage:    newSetupStage(&testBuilder{checksum: "a"}, &NewGitPatchStageOptions{}, baseStageOptions),
+			expected: util.Sha256Hash("setup a", util.Sha256Hash()),
+		},
+		{
+			name:     "imports",
+			stage:    newImportsStage([]*config.Import{testImport("base", nil)}, ImportsBeforeInstall, baseStageOptions),
+			expected: util.Sha256Hash("base-signature", "/app", "/app", "group", "owner", "bin", "tmp"),
+		},
+	}
+
+	for _, tt := range tests {
+		checksum, err := tt.stage.GetDependencies(c, prevImage, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+
+		if checksum != tt.expected {
+			t.Errorf("%s: expected dependencies %s, got %s", tt.name, tt.expected, checksum)
+		}
+	}
+}
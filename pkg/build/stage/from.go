@@ -9,7 +9,6 @@ import (
 	"github.com/flant/werf/pkg/config"
 	"github.com/flant/werf/pkg/image"
 	"github.com/flant/werf/pkg/stapel"
-	"github.com/flant/werf/pkg/util"
 )
 
 func GenerateFromStage(imageBaseConfig *config.StapelImageBase, baseImageRepoId string, baseStageOptions *NewBaseStageOptions) *FromStage {
@@ -36,24 +35,32 @@ type FromStage struct {
 	cacheVersion          string
 }
 
-func (s *FromStage) GetDependencies(_ Conveyor, prevImage, _ image.ImageInterface) (string, error) {
-	var args []string
+func (s *FromStage) GetDependencies(c Conveyor, prevImage, prevBuiltImage image.ImageInterface) (string, error) {
+	return dependenciesChecksum(s.calculateDependencies(c, prevImage, prevBuiltImage))
+}
+
+func (s *FromStage) calculateDependencies(_ Conveyor, prevImage, _ image.ImageInterface) (*dependencies, error) {
+	d := newDependencies()
 
 	if s.cacheVersion != "" {
-		args = append(args, s.cacheVersion)
+		d.add("fromCacheVersion", s.cacheVersion)
 	}
 
 	if s.baseImageRepoIdOrNone != "" {
-		args = append(args, s.baseImageRepoIdOrNone)
+		d.add("fromLatest", s.baseImageRepoIdOrNone)
 	}
 
 	for _, mount := range s.configMounts {
-		args = append(args, filepath.ToSlash(filepath.Clean(mount.From)), path.Clean(mount.To), mount.Type)
+		if mount.Type == "cache" {
+			continue
+		}
+
+		d.add(fmt.Sprintf("mount %s", path.Clean(mount.To)), filepath.ToSlash(filepath.Clean(mount.From)), path.Clean(mount.To), mount.Type)
 	}
 
-	args = append(args, prevImage.Name())
+	d.add("baseImage", prevImage.Name())
 
-	return util.Sha256Hash(args...), nil
+	return d, nil
 }
 
 func (s *FromStage) PrepareImage(c Conveyor, prevBuiltImage, image image.ImageInterface) error {
@@ -65,6 +72,10 @@ func (s *FromStage) PrepareImage(c Conveyor, prevBuiltImage, image image.ImageIn
 
 	var mountpoints []string
 	for _, mountCfg := range s.configMounts {
+		if mountCfg.Type == "cache" {
+			continue
+		}
+
 		mountpoints = append(mountpoints, mountCfg.To)
 	}
 	if len(mountpoints) != 0 {
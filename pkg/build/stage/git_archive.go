@@ -37,22 +37,29 @@ type GitArchiveStage struct {
 	ContainerScriptsDir  string
 }
 
-func (s *GitArchiveStage) GetDependencies(_ Conveyor, _, _ image.ImageInterface) (string, error) {
-	var args []string
+func (s *GitArchiveStage) GetDependencies(c Conveyor, prevImage, prevBuiltImage image.ImageInterface) (string, error) {
+	return dependenciesChecksum(s.calculateDependencies(c, prevImage, prevBuiltImage))
+}
+
+func (s *GitArchiveStage) calculateDependencies(_ Conveyor, _, _ image.ImageInterface) (*dependencies, error) {
+	d := newDependencies()
+	d.checksumFunc = func(args ...string) string {
+		sort.Strings(args)
+		return util.Sha256Hash(args...)
+	}
+
 	for _, gitMapping := range s.gitMappings {
-		args = append(args, gitMapping.GetParamshash())
+		d.add(fmt.Sprintf("git %s params", gitMapping.GetFullName()), gitMapping.GetParamshash())
 
 		commit, err := gitMapping.GitRepo().FindCommitIdByMessage(GitArchiveResetCommitRegex)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
-		args = append(args, commit)
+		d.add(fmt.Sprintf("git %s reset commit", gitMapping.GetFullName()), commit)
 	}
 
-	sort.Strings(args)
-
-	return util.Sha256Hash(args...), nil
+	return d, nil
 }
 
 func (s *GitArchiveStage) PrepareImage(c Conveyor, prevBuiltImage, image image.ImageInterface) error {
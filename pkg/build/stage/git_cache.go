@@ -4,7 +4,6 @@ import (
 	"fmt"
 
 	"github.com/flant/werf/pkg/image"
-	"github.com/flant/werf/pkg/util"
 )
 
 const patchSizeStep = 1024 * 1024
@@ -36,13 +35,20 @@ func (s *GitCacheStage) IsEmpty(c Conveyor, prevBuiltImage image.ImageInterface)
 	return isEmpty, nil
 }
 
-func (s *GitCacheStage) GetDependencies(_ Conveyor, _, prevBuiltImage image.ImageInterface) (string, error) {
+func (s *GitCacheStage) GetDependencies(c Conveyor, prevImage, prevBuiltImage image.ImageInterface) (string, error) {
+	return dependenciesChecksum(s.calculateDependencies(c, prevImage, prevBuiltImage))
+}
+
+func (s *GitCacheStage) calculateDependencies(_ Conveyor, _, prevBuiltImage image.ImageInterface) (*dependencies, error) {
 	patchSize, err := s.gitMappingsPatchSize(prevBuiltImage)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return util.Sha256Hash(fmt.Sprintf("%d", patchSize/patchSizeStep)), nil
+	d := newDependencies()
+	d.add("patchSizeStep", fmt.Sprintf("%d", patchSize/patchSizeStep))
+
+	return d, nil
 }
 
 func (s *GitCacheStage) gitMappingsPatchSize(prevBuiltImage image.ImageInterface) (int64, error) {
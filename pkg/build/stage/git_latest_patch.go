@@ -1,8 +1,9 @@
 package stage
 
 import (
+	"fmt"
+
 	"github.com/flant/werf/pkg/image"
-	"github.com/flant/werf/pkg/util"
 )
 
 func NewGitLatestPatchStage(gitPatchStageOptions *NewGitPatchStageOptions, baseStageOptions *NewBaseStageOptions) *GitLatestPatchStage {
@@ -42,17 +43,20 @@ func (s *GitLatestPatchStage) IsEmpty(c Conveyor, prevBuiltImage image.ImageInte
 	return isEmpty, nil
 }
 
-func (s *GitLatestPatchStage) GetDependencies(_ Conveyor, _, _ image.ImageInterface) (string, error) {
-	var args []string
+func (s *GitLatestPatchStage) GetDependencies(c Conveyor, prevImage, prevBuiltImage image.ImageInterface) (string, error) {
+	return dependenciesChecksum(s.calculateDependencies(c, prevImage, prevBuiltImage))
+}
 
+func (s *GitLatestPatchStage) calculateDependencies(_ Conveyor, _, _ image.ImageInterface) (*dependencies, error) {
+	d := newDependencies()
 	for _, gitMapping := range s.gitMappings {
 		commit, err := gitMapping.LatestCommit()
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
-		args = append(args, commit)
+		d.add(fmt.Sprintf("git %s commit", gitMapping.GetFullName()), commit)
 	}
 
-	return util.Sha256Hash(args...), nil
+	return d, nil
 }
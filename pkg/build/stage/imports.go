@@ -2,12 +2,15 @@ package stage
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 	"sort"
 	"strings"
 
+	"github.com/flant/logboek"
+
 	"github.com/flant/werf/pkg/stapel"
 
 	"github.com/flant/werf/pkg/config"
@@ -46,25 +49,45 @@ type ImportsStage struct {
 	*BaseStage
 
 	imports []*config.Import
+
+	contentChecksums map[string]string
 }
 
-func (s *ImportsStage) GetDependencies(c Conveyor, _, _ imagePkg.ImageInterface) (string, error) {
-	var args []string
+func (s *ImportsStage) GetDependencies(c Conveyor, prevImage, prevBuiltImage imagePkg.ImageInterface) (string, error) {
+	return dependenciesChecksum(s.calculateDependencies(c, prevImage, prevBuiltImage))
+}
+
+func (s *ImportsStage) calculateDependencies(c Conveyor, _, _ imagePkg.ImageInterface) (*dependencies, error) {
+	d := newDependencies()
 
 	for _, elm := range s.imports {
+		var key string
 		if elm.ImageName != "" {
-			args = append(args, c.GetImageLatestStageSignature(elm.ImageName))
+			key = fmt.Sprintf("import image %s %s", elm.ImageName, elm.Add)
+		} else if elm.ArtifactName != "" {
+			key = fmt.Sprintf("import artifact %s %s", elm.ArtifactName, elm.Add)
 		} else {
-			args = append(args, c.GetImageLatestStageSignature(elm.ArtifactName))
+			key = fmt.Sprintf("import external image %s %s", elm.ExternalImage, elm.Add)
 		}
 
-		args = append(args, elm.Add, elm.To)
-		args = append(args, elm.Group, elm.Owner)
-		args = append(args, elm.IncludePaths...)
-		args = append(args, elm.ExcludePaths...)
+		if elm.ContentChecksum {
+			checksum, err := s.importContentChecksum(c, elm)
+			if err != nil {
+				return nil, err
+			}
+
+			d.add(key+" content checksum", checksum)
+		} else {
+			d.add(key+" signature", importSourceSignature(c, elm))
+		}
+
+		d.add(key+" add to", elm.Add, elm.To)
+		d.add(key+" group owner", elm.Group, elm.Owner)
+		d.add(key+" includePaths", elm.IncludePaths...)
+		d.add(key+" excludePaths", elm.ExcludePaths...)
 	}
 
-	return util.Sha256Hash(args...), nil
+	return d, nil
 }
 
 func (s *ImportsStage) PrepareImage(c Conveyor, _, image imagePkg.ImageInterface) error {
@@ -83,16 +106,8 @@ func (s *ImportsStage) PrepareImage(c Conveyor, _, image imagePkg.ImageInterface
 
 		imageServiceCommitChangeOptions := image.Container().ServiceCommitChangeOptions()
 
-		var labelKey, labelValue string
-		if elm.ImageName != "" {
-			labelKey = imagePkg.WerfImportLabelPrefix + slug.Slug(elm.ImageName)
-			labelValue = c.GetImageLatestStageSignature(elm.ImageName)
-		} else {
-			labelKey = imagePkg.WerfImportLabelPrefix + slug.Slug(elm.ArtifactName)
-			labelValue = c.GetImageLatestStageSignature(elm.ArtifactName)
-		}
-
-		imageServiceCommitChangeOptions.AddLabel(map[string]string{labelKey: labelValue})
+		labelKey := imagePkg.WerfImportLabelPrefix + slug.Slug(importSourceName(elm))
+		imageServiceCommitChangeOptions.AddLabel(map[string]string{labelKey: importSourceSignature(c, elm)})
 	}
 
 	return nil
@@ -123,10 +138,13 @@ func (s *ImportsStage) prepareImportData(c Conveyor, i *config.Import) error {
 	importImageTmp, importImageContainerTmp := s.importImageTmpDirs(i)
 
 	var dockerImageName string
-	if i.ImageName != "" {
-		dockerImageName = c.GetImageLatestStageImageName(i.ImageName)
+	if i.ExternalImage != "" {
+		dockerImageName = i.ExternalImage
+		if err := pullExternalImage(dockerImageName); err != nil {
+			return err
+		}
 	} else {
-		dockerImageName = c.GetImageLatestStageImageName(i.ArtifactName)
+		dockerImageName = c.GetImageLatestStageImageName(importSourceName(i))
 	}
 
 	if err := os.MkdirAll(importImageTmp, os.ModePerm); err != nil {
@@ -153,6 +171,142 @@ func (s *ImportsStage) prepareImportData(c Conveyor, i *config.Import) error {
 	return nil
 }
 
+// importContentChecksum calculates checksum of files which will be imported (paths, modes, owners, symlinks targets and files content),
+// the source image is built beforehand if necessary by build commands, other commands require the source image to be built
+func (s *ImportsStage) importContentChecksum(c Conveyor, i *config.Import) (string, error) {
+	importID := util.Sha256Hash(fmt.Sprintf("%+v", i))
+	if checksum, hasKey := s.contentChecksums[importID]; hasKey {
+		return checksum, nil
+	}
+
+	sourceImageName := importSourceName(i)
+
+	if !c.IsImageLatestStageBuilt(sourceImageName) {
+		if !c.IsBuildingStages() {
+			return "", fmt.Errorf("unable to calculate content checksum of import %s from %s: stages of %s are not built, the source is built in advance only by build commands (run werf build first)", i.Add, sourceImageName, sourceImageName)
+		}
+
+		if err := c.BuildImageStages(sourceImageName); err != nil {
+			return "", fmt.Errorf("unable to calculate content checksum of import %s from %s: %s", i.Add, sourceImageName, err)
+		}
+	}
+
+	checksumTmpDir := filepath.Join(s.imageTmpDir, "import-checksum", importID)
+	checksumContainerTmpDir := path.Join(s.containerWerfDir, "import-checksum", importID)
+
+	if err := os.MkdirAll(checksumTmpDir, os.ModePerm); err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(checksumTmpDir)
+
+	if err := ioutil.WriteFile(filepath.Join(checksumTmpDir, "checksum.py"), []byte(importContentChecksumScript), 0644); err != nil {
+		return "", err
+	}
+
+	dataContainerPath := path.Join(checksumContainerTmpDir, "data")
+	copyCommand := generateSafeCp(i.Add, path.Join(dataContainerPath, path.Base(i.Add)), "", "", i.IncludePaths, i.ExcludePaths)
+	checksumCommand := fmt.Sprintf("%s %s %s > %s", stapel.PythonBinPath(), path.Join(checksumContainerTmpDir, "checksum.py"), dataContainerPath, path.Join(checksumContainerTmpDir, "checksum"))
+	cleanupCommand := fmt.Sprintf("%s -rf %s", stapel.RmBinPath(), dataContainerPath)
+
+	stapelContainerName, err := stapel.GetOrCreateContainer()
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{
+		"--rm",
+		"--user=0:0",
+		"--workdir=/",
+		fmt.Sprintf("--volumes-from=%s", stapelContainerName),
+		fmt.Sprintf("--entrypoint=%s", stapel.BashBinPath()),
+		fmt.Sprintf("--volume=%s:%s", checksumTmpDir, checksumContainerTmpDir),
+		c.GetImageLatestStageImageName(sourceImageName),
+		"-ec",
+		imagePkg.ShelloutPack(strings.Join([]string{copyCommand, checksumCommand, cleanupCommand}, " && ")),
+	}
+
+	if err := docker.CliRun(args...); err != nil {
+		return "", err
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(checksumTmpDir, "checksum"))
+	if err != nil {
+		return "", fmt.Errorf("unable to read content checksum of import %s from %s: %s", i.Add, sourceImageName, err)
+	}
+
+	checksum := strings.TrimSpace(string(data))
+
+	if s.contentChecksums == nil {
+		s.contentChecksums = map[string]string{}
+	}
+	s.contentChecksums[importID] = checksum
+
+	return checksum, nil
+}
+
+// importContentChecksumScript does not take into account modification times, so byte-identical output of rebuilt image gives the same checksum.
+// Paths are walked as raw bytes, so the script gives the same checksum with python 2 and 3 regardless of the file names encoding
+const importContentChecksumScript = `import hashlib
+import os
+import sys
+
+root = sys.argv[1]
+if not isinstance(root, bytes):
+    root = root.encode(sys.getfilesystemencoding(), "surrogateescape")
+h = hashlib.sha256()
+
+for dirPath, dirNames, fileNames in os.walk(root):
+    dirNames.sort()
+    for name in sorted(dirNames + fileNames):
+        p = os.path.join(dirPath, name)
+        st = os.lstat(p)
+        h.update(b"%s\0%o\0%d\0%d\0" % (os.path.relpath(p, root), st.st_mode, st.st_uid, st.st_gid))
+        if os.path.islink(p):
+            h.update(os.readlink(p))
+        elif os.path.isfile(p):
+            f = open(p, "rb")
+            for chunk in iter(lambda: f.read(65536), b""):
+                h.update(chunk)
+            f.close()
+
+print(h.hexdigest())
+`
+
+// importSourceName returns image or artifact name from the config or external image reference
+func importSourceName(i *config.Import) string {
+	switch {
+	case i.ImageName != "":
+		return i.ImageName
+	case i.ArtifactName != "":
+		return i.ArtifactName
+	default:
+		return i.ExternalImage
+	}
+}
+
+// importSourceSignature returns latest stage signature of image or artifact, external image reference is pinned by digest and used as is
+func importSourceSignature(c Conveyor, i *config.Import) string {
+	if i.ExternalImage != "" {
+		return i.ExternalImage
+	}
+
+	return c.GetImageLatestStageSignature(importSourceName(i))
+}
+
+func pullExternalImage(reference string) error {
+	exist, err := docker.ImageExist(reference)
+	if err != nil {
+		return fmt.Errorf("unable to check existence of external image %s: %s", reference, err)
+	} else if exist {
+		return nil
+	}
+
+	logProcessOptions := logboek.LogProcessOptions{ColorizeMsgFunc: logboek.ColorizeHighlight}
+	return logboek.LogProcess(fmt.Sprintf("Pulling external image %s", reference), logProcessOptions, func() error {
+		return docker.CliPull(reference)
+	})
+}
+
 func (s *ImportsStage) importContainerTmpPath(i *config.Import) string {
 	importID := util.Sha256Hash(fmt.Sprintf("%+v", i))
 	_, importImageContainerTmpPath := s.importImageTmpDirs(i)
@@ -162,12 +316,7 @@ func (s *ImportsStage) importContainerTmpPath(i *config.Import) string {
 }
 
 func (s *ImportsStage) importImageTmpDirs(i *config.Import) (string, string) {
-	var importNamePathPart string
-	if i.ImageName != "" {
-		importNamePathPart = slug.Slug(i.ImageName)
-	} else {
-		importNamePathPart = slug.Slug(i.ArtifactName)
-	}
+	importNamePathPart := slug.Slug(importSourceName(i))
 
 	importImageTmpDir := filepath.Join(s.imageTmpDir, "import", importNamePathPart)
 	importImageContainerTmpDir := path.Join(s.containerWerfDir, "import", importNamePathPart)
@@ -0,0 +1,136 @@
+package stage
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/flant/werf/pkg/config"
+)
+
+func availablePythonInterpreters() []string {
+	var res []string
+	for _, name := range []string{"python2.7", "python2", "python3"} {
+		if err := exec.Command(name, "-c", "import hashlib").Run(); err == nil {
+			res = append(res, name)
+		}
+	}
+
+	return res
+}
+
+func runImportContentChecksumScript(t *testing.T, python, scriptPath, root string) string {
+	output, err := exec.Command(python, scriptPath, root).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s: checksum script failed: %s\n%s", python, err, output)
+	}
+
+	return strings.TrimSpace(string(output))
+}
+
+// TestImportContentChecksumScript checks the script with non-ascii names and content, and symlinks,
+// the script runs with python of the stapel and should give the same checksum with python 2 and 3
+func TestImportContentChecksumScript(t *testing.T) {
+	interpreters := availablePythonInterpreters()
+	if len(interpreters) == 0 {
+		t.Skip("python is not available")
+	}
+
+	tmpDir, err := ioutil.TempDir("", "werf-import-checksum-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	scriptPath := filepath.Join(tmpDir, "checksum.py")
+	if err := ioutil.WriteFile(scriptPath, []byte(importContentChecksumScript), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	root := filepath.Join(tmpDir, "data")
+	if err := os.MkdirAll(filepath.Join(root, "каталог"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(root, "каталог", "файл.txt"), []byte("содержимое"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(root, "bin"), []byte{0xff, 0xfe, 0x00}, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink("каталог/файл.txt", filepath.Join(root, "ссылка")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink("missing", filepath.Join(root, "broken")); err != nil {
+		t.Fatal(err)
+	}
+
+	checksums := map[string]string{}
+	for _, python := range interpreters {
+		checksum := runImportContentChecksumScript(t, python, scriptPath, root)
+		if len(checksum) != 64 {
+			t.Fatalf("%s: unexpected checksum %q", python, checksum)
+		}
+
+		checksums[python] = checksum
+	}
+
+	expected := checksums[interpreters[0]]
+	for python, checksum := range checksums {
+		if checksum != expected {
+			t.Errorf("%s: checksum %s differs from %s of %s", python, checksum, expected, interpreters[0])
+		}
+	}
+
+	if err := os.Remove(filepath.Join(root, "ссылка")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink("bin", filepath.Join(root, "ссылка")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, python := range interpreters {
+		if checksum := runImportContentChecksumScript(t, python, scriptPath, root); checksum == expected {
+			t.Errorf("%s: checksum expected to change with the symlink target", python)
+		}
+	}
+}
+
+type notBuiltSourceConveyor struct {
+	testConveyor
+	buildImageStagesCalled bool
+}
+
+func (c *notBuiltSourceConveyor) IsImageLatestStageBuilt(_ string) bool { return false }
+func (c *notBuiltSourceConveyor) IsBuildingStages() bool                { return false }
+
+func (c *notBuiltSourceConveyor) BuildImageStages(_ string) error {
+	c.buildImageStagesCalled = true
+	return nil
+}
+
+func TestImportContentChecksum_NotBuildingConveyor(t *testing.T) {
+	i := testImport("base", func(i *config.Import) { i.ContentChecksum = true })
+	s := newImportsStage([]*config.Import{i}, ImportsBeforeInstall, &NewBaseStageOptions{ImageName: "image"})
+	c := &notBuiltSourceConveyor{}
+
+	_, err := s.GetDependencies(c, &testImage{name: "prev-image"}, nil)
+	if err == nil {
+		t.Fatalf("expected error when stages of the import source are not built")
+	}
+
+	if !strings.Contains(err.Error(), "stages of base are not built") {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if c.buildImageStagesCalled {
+		t.Errorf("stages of the import source must not be built by not building conveyor")
+	}
+}
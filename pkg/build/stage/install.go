@@ -4,7 +4,6 @@ import (
 	"github.com/flant/werf/pkg/build/builder"
 	"github.com/flant/werf/pkg/config"
 	"github.com/flant/werf/pkg/image"
-	"github.com/flant/werf/pkg/util"
 )
 
 func GenerateInstallStage(imageBaseConfig *config.StapelImageBase, gitPatchStageOptions *NewGitPatchStageOptions, baseStageOptions *NewBaseStageOptions) *InstallStage {
@@ -26,13 +25,12 @@ type InstallStage struct {
 	*UserWithGitPatchStage
 }
 
-func (s *InstallStage) GetDependencies(_ Conveyor, _, _ image.ImageInterface) (string, error) {
-	stageDependenciesChecksum, err := s.getStageDependenciesChecksum(Install)
-	if err != nil {
-		return "", err
-	}
+func (s *InstallStage) GetDependencies(c Conveyor, prevImage, prevBuiltImage image.ImageInterface) (string, error) {
+	return dependenciesChecksum(s.calculateDependencies(c, prevImage, prevBuiltImage))
+}
 
-	return util.Sha256Hash(s.builder.InstallChecksum(), stageDependenciesChecksum), nil
+func (s *InstallStage) calculateDependencies(_ Conveyor, _, _ image.ImageInterface) (*dependencies, error) {
+	return s.calculateUserStageDependencies(s.builder.InstallChecksum(), Install)
 }
 
 func (s *InstallStage) PrepareImage(c Conveyor, prevBuiltImage, image image.ImageInterface) error {
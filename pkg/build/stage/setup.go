@@ -4,7 +4,6 @@ import (
 	"github.com/flant/werf/pkg/build/builder"
 	"github.com/flant/werf/pkg/config"
 	"github.com/flant/werf/pkg/image"
-	"github.com/flant/werf/pkg/util"
 )
 
 func GenerateSetupStage(imageBaseConfig *config.StapelImageBase, gitPatchStageOptions *NewGitPatchStageOptions, baseStageOptions *NewBaseStageOptions) *SetupStage {
@@ -26,13 +25,12 @@ type SetupStage struct {
 	*UserWithGitPatchStage
 }
 
-func (s *SetupStage) GetDependencies(_ Conveyor, _, _ image.ImageInterface) (string, error) {
-	stageDependenciesChecksum, err := s.getStageDependenciesChecksum(Setup)
-	if err != nil {
-		return "", err
-	}
+func (s *SetupStage) GetDependencies(c Conveyor, prevImage, prevBuiltImage image.ImageInterface) (string, error) {
+	return dependenciesChecksum(s.calculateDependencies(c, prevImage, prevBuiltImage))
+}
 
-	return util.Sha256Hash(s.builder.SetupChecksum(), stageDependenciesChecksum), nil
+func (s *SetupStage) calculateDependencies(_ Conveyor, _, _ image.ImageInterface) (*dependencies, error) {
+	return s.calculateUserStageDependencies(s.builder.SetupChecksum(), Setup)
 }
 
 func (s *SetupStage) PrepareImage(c Conveyor, prevBuiltImage, image image.ImageInterface) error {
@@ -1,13 +1,13 @@
 package stage
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/flant/logboek"
 
 	"github.com/flant/werf/pkg/build/builder"
 	"github.com/flant/werf/pkg/config"
-	"github.com/flant/werf/pkg/util"
 )
 
 func getBuilder(imageBaseConfig *config.StapelImageBase, baseStageOptions *NewBaseStageOptions) builder.Builder {
@@ -35,22 +35,26 @@ type UserStage struct {
 	builder builder.Builder
 }
 
-func (s *UserStage) getStageDependenciesChecksum(name StageName) (string, error) {
-	var args []string
+func (s *UserStage) calculateUserStageDependencies(commandsChecksum string, name StageName) (*dependencies, error) {
+	stageDependencies := newDependencies()
 	for _, gitMapping := range s.gitMappings {
 		checksum, err := gitMapping.StageDependenciesChecksum(name)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
 		if debugUserStageChecksum() {
 			logboek.LogHighlightF("DEBUG: %s stage git mapping %s checksum %v\n", name, gitMapping.Name, checksum)
 		}
 
-		args = append(args, checksum)
+		stageDependencies.add(fmt.Sprintf("git %s", gitMapping.GetFullName()), checksum)
 	}
 
-	return util.Sha256Hash(args...), nil
+	d := newDependencies()
+	d.add("commands", commandsChecksum)
+	d.addNested("stageDependencies", stageDependencies)
+
+	return d, nil
 }
 
 func debugUserStageChecksum() bool {
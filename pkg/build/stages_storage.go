@@ -0,0 +1,169 @@
+package build
+
+import (
+	"fmt"
+
+	"github.com/flant/logboek"
+
+	"github.com/flant/werf/pkg/build/stage"
+	"github.com/flant/werf/pkg/docker_registry"
+	"github.com/flant/werf/pkg/util"
+)
+
+const (
+	LocalStagesStorage = ":local"
+
+	RepoImageStageTagFormat = "image-stage-%s"
+)
+
+func (c *Conveyor) isLocalStagesStorage() bool {
+	return c.stagesStorage == LocalStagesStorage
+}
+
+// canPushIntoStagesStorage is false in dev mode, stages with uncommitted changes are not shared with other werf processes
+func (c *Conveyor) canPushIntoStagesStorage() bool {
+	return !c.isLocalStagesStorage() && !c.devMode
+}
+
+func (c *Conveyor) stagesStorageImageName(signature string) string {
+	return fmt.Sprintf("%s:%s", c.stagesStorage, fmt.Sprintf(RepoImageStageTagFormat, signature))
+}
+
+// hasStagesStorageTag fetches stages storage tags once, the tags are accessed by parallel image builds
+func (c *Conveyor) hasStagesStorageTag(tag string) (bool, error) {
+	c.stagesStorageTagsMutex.Lock()
+	defer c.stagesStorageTagsMutex.Unlock()
+
+	if c.stagesStorageTags == nil {
+		tags, err := docker_registry.Tags(c.stagesStorage)
+		if err != nil {
+			return false, fmt.Errorf("unable to fetch stages storage %s tags: %s", c.stagesStorage, err)
+		}
+
+		c.stagesStorageTags = append([]string{}, tags...)
+	}
+
+	return util.IsStringsContainValue(c.stagesStorageTags, tag), nil
+}
+
+func (c *Conveyor) addStagesStorageTag(tag string) {
+	c.stagesStorageTagsMutex.Lock()
+	defer c.stagesStorageTagsMutex.Unlock()
+
+	if c.stagesStorageTags != nil {
+		c.stagesStorageTags = append(c.stagesStorageTags, tag)
+	}
+}
+
+func (c *Conveyor) removeStagesStorageTag(tag string) {
+	c.stagesStorageTagsMutex.Lock()
+	defer c.stagesStorageTagsMutex.Unlock()
+
+	var tags []string
+	for _, t := range c.stagesStorageTags {
+		if t != tag {
+			tags = append(tags, t)
+		}
+	}
+	c.stagesStorageTags = tags
+}
+
+func (c *Conveyor) isStageExistInStagesStorage(signature string) (bool, error) {
+	return c.hasStagesStorageTag(fmt.Sprintf(RepoImageStageTagFormat, signature))
+}
+
+func (c *Conveyor) pullStageFromStagesStorage(s stage.Interface) error {
+	if c.isLocalStagesStorage() || s.GetImage().IsExists() {
+		return nil
+	}
+
+	if exist, err := c.isStageExistInStagesStorage(s.GetSignature()); err != nil {
+		return err
+	} else if !exist {
+		return nil
+	}
+
+	stageImage := c.GetStageImage(s.GetImage().Name())
+	stagesStorageImageName := c.stagesStorageImageName(s.GetSignature())
+
+	logProcessOptions := logboek.LogProcessOptions{ColorizeMsgFunc: logboek.ColorizeHighlight}
+	if err := logboek.LogProcess(fmt.Sprintf("Pulling %s from stages storage", s.LogDetailedName()), logProcessOptions, func() error {
+		if err := stageImage.Import(stagesStorageImageName); err != nil {
+			return fmt.Errorf("unable to import %s: %s", stagesStorageImageName, err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return stageImage.SyncDockerState()
+}
+
+func (c *Conveyor) pushStageIntoStagesStorage(s stage.Interface) error {
+	if !c.canPushIntoStagesStorage() {
+		return nil
+	}
+
+	if exist, err := c.isStageExistInStagesStorage(s.GetSignature()); err != nil {
+		return err
+	} else if exist {
+		return nil
+	}
+
+	stageImage := c.GetStageImage(s.GetImage().Name())
+	stagesStorageImageName := c.stagesStorageImageName(s.GetSignature())
+
+	successInfoSectionFunc := func() {
+		_ = logboek.WithIndent(func() error {
+			logboek.LogInfoF("stages-storage: %s\n", c.stagesStorage)
+			logboek.LogInfoF("         image: %s\n", stagesStorageImageName)
+
+			return nil
+		})
+	}
+
+	logProcessOptions := logboek.LogProcessOptions{SuccessInfoSectionFunc: successInfoSectionFunc, ColorizeMsgFunc: logboek.ColorizeHighlight}
+	if err := logboek.LogProcess(fmt.Sprintf("Publishing %s into stages storage", s.LogDetailedName()), logProcessOptions, func() error {
+		if err := stageImage.Export(stagesStorageImageName); err != nil {
+			return fmt.Errorf("error pushing %s: %s", stagesStorageImageName, err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	c.addStagesStorageTag(fmt.Sprintf(RepoImageStageTagFormat, s.GetSignature()))
+
+	return nil
+}
+
+func (c *Conveyor) removeStageFromStagesStorage(s stage.Interface) error {
+	if c.isLocalStagesStorage() {
+		return nil
+	}
+
+	if exist, err := c.isStageExistInStagesStorage(s.GetSignature()); err != nil {
+		return err
+	} else if !exist {
+		return nil
+	}
+
+	stagesStorageImageName := c.stagesStorageImageName(s.GetSignature())
+
+	repoImage, err := docker_registry.GetRepoImage(c.stagesStorage, fmt.Sprintf(RepoImageStageTagFormat, s.GetSignature()))
+	if err != nil {
+		return fmt.Errorf("unable to get %s: %s", stagesStorageImageName, err)
+	}
+
+	logboek.LogF("Remove %s from stages storage\n", stagesStorageImageName)
+
+	if err := docker_registry.DeleteRepoImage(repoImage); err != nil {
+		return err
+	}
+
+	c.removeStagesStorageTag(fmt.Sprintf(RepoImageStageTagFormat, s.GetSignature()))
+
+	return nil
+}
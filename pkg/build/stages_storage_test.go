@@ -0,0 +1,108 @@
+package build
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/flant/werf/pkg/docker_registry"
+)
+
+func TestConveyor_CanPushIntoStagesStorage(t *testing.T) {
+	tests := []struct {
+		stagesStorage string
+		devMode       bool
+		expected      bool
+	}{
+		{LocalStagesStorage, false, false},
+		{LocalStagesStorage, true, false},
+		{"registry.example.com/project/stages", false, true},
+		{"registry.example.com/project/stages", true, false},
+	}
+
+	for _, tt := range tests {
+		c := &Conveyor{conveyorPermanentFields: &conveyorPermanentFields{stagesStorage: tt.stagesStorage, devMode: tt.devMode}}
+		if res := c.canPushIntoStagesStorage(); res != tt.expected {
+			t.Errorf("stages storage %q, dev mode %v: expected %v, got %v", tt.stagesStorage, tt.devMode, tt.expected, res)
+		}
+	}
+}
+
+func TestConveyor_PushStageIntoStagesStorage_DevMode(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	stagesStorage := strings.TrimPrefix(server.URL, "http://") + "/project/stages"
+	c := &Conveyor{conveyorPermanentFields: &conveyorPermanentFields{stagesStorage: stagesStorage, devMode: true}}
+
+	if err := c.pushStageIntoStagesStorage(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if requests != 0 {
+		t.Errorf("expected no stages storage requests in dev mode, got %d", requests)
+	}
+}
+
+func TestConveyor_StagesStorageTags_Concurrent(t *testing.T) {
+	var tagsRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/tags/list") {
+			atomic.AddInt32(&tagsRequests, 1)
+			_, _ = fmt.Fprint(w, `{"name":"project/stages","tags":["image-stage-existing"]}`)
+			return
+		}
+
+		if r.URL.Path == "/v2/" {
+			return
+		}
+
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	if err := docker_registry.Init(docker_registry.Options{Implementation: docker_registry.ImplementationDefault}); err != nil {
+		t.Fatal(err)
+	}
+	defer docker_registry.Init(docker_registry.Options{})
+
+	stagesStorage := strings.TrimPrefix(server.URL, "http://") + "/project/stages"
+	c := &Conveyor{conveyorPermanentFields: &conveyorPermanentFields{stagesStorage: stagesStorage}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if exist, err := c.isStageExistInStagesStorage("existing"); err != nil {
+				t.Error(err)
+			} else if !exist {
+				t.Errorf("expected existing stage to be found")
+			}
+
+			c.addStagesStorageTag(fmt.Sprintf(RepoImageStageTagFormat, fmt.Sprintf("built-%d", i)))
+		}(i)
+	}
+	wg.Wait()
+
+	if tagsRequests != 1 {
+		t.Errorf("expected stages storage tags to be fetched once, got %d requests", tagsRequests)
+	}
+
+	for i := 0; i < 10; i++ {
+		if exist, err := c.isStageExistInStagesStorage(fmt.Sprintf("built-%d", i)); err != nil {
+			t.Fatal(err)
+		} else if !exist {
+			t.Errorf("expected built stage %d to be found", i)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package buildkit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/auth/authprovider"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/moby/buildkit/session/sshforward/sshprovider"
+	"github.com/moby/buildkit/util/progress/progressui"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/flant/logboek"
+
+	"github.com/flant/werf/pkg/docker"
+	"github.com/flant/werf/pkg/util/secretvalues"
+)
+
+const (
+	DockerfileFrontend = "dockerfile.v0"
+
+	// DefaultSSHId is the id of the ssh agent used by RUN --mount=type=ssh without an explicit id
+	DefaultSSHId = "default"
+)
+
+type BuildOptions struct {
+	ContextDir     string
+	DockerfilePath string
+	Target         string
+	BuildArgs      map[string]string
+	AddHost        []string
+	Labels         map[string]string
+	Tag            string
+
+	Secrets     map[string][]byte
+	SSHAuthSock string
+
+	// CacheRef is the registry reference to import build cache from and export build cache into
+	CacheRef string
+}
+
+// Build builds the Dockerfile with the buildkitd and loads the resulting image into the docker daemon
+func Build(addr string, opts BuildOptions) error {
+	ctx := context.Background()
+
+	c, err := client.New(ctx, addr, client.WithFailFast())
+	if err != nil {
+		return fmt.Errorf("unable to connect to buildkitd %s: %s", addr, err)
+	}
+	defer c.Close()
+
+	attachables, err := sessionAttachables(opts)
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+
+	solveOpt := client.SolveOpt{
+		Exporter:       client.ExporterDocker,
+		ExporterAttrs:  map[string]string{"name": opts.Tag},
+		ExporterOutput: pw,
+		LocalDirs: map[string]string{
+			"context":    opts.ContextDir,
+			"dockerfile": filepath.Dir(opts.DockerfilePath),
+		},
+		Frontend:      DockerfileFrontend,
+		FrontendAttrs: frontendAttrs(opts),
+		Session:       attachables,
+	}
+
+	if opts.CacheRef != "" {
+		solveOpt.ImportCache = []string{opts.CacheRef}
+		solveOpt.ExportCache = opts.CacheRef
+		solveOpt.ExportCacheAttrs = map[string]string{"mode": "max"}
+	}
+
+	ch := make(chan *client.SolveStatus)
+	eg, ctx := errgroup.WithContext(ctx)
+
+	eg.Go(func() error {
+		_, err := c.Solve(ctx, nil, solveOpt, ch)
+		_ = pw.CloseWithError(err)
+		return err
+	})
+
+	eg.Go(func() error {
+		err := docker.ImageLoad(pr)
+		_ = pr.CloseWithError(err)
+		if err != nil {
+			return fmt.Errorf("unable to load image %s: %s", opts.Tag, err)
+		}
+		return nil
+	})
+
+	eg.Go(func() error {
+		return progressui.DisplaySolveStatus(context.TODO(), "", nil, secretvalues.NewMaskingWriter(logboek.GetOutStream(), docker.OutputSecretValuesToMask), ch)
+	})
+
+	return eg.Wait()
+}
+
+func frontendAttrs(opts BuildOptions) map[string]string {
+	attrs := map[string]string{
+		"filename": filepath.Base(opts.DockerfilePath),
+	}
+
+	if opts.Target != "" {
+		attrs["target"] = opts.Target
+	}
+
+	if len(opts.AddHost) != 0 {
+		attrs["add-hosts"] = strings.Join(opts.AddHost, ",")
+	}
+
+	for key, value := range opts.BuildArgs {
+		attrs[fmt.Sprintf("build-arg:%s", key)] = value
+	}
+
+	for key, value := range opts.Labels {
+		attrs[fmt.Sprintf("label:%s", key)] = value
+	}
+
+	return attrs
+}
+
+func sessionAttachables(opts BuildOptions) ([]session.Attachable, error) {
+	attachables := []session.Attachable{authprovider.NewDockerAuthProvider()}
+
+	if len(opts.Secrets) != 0 {
+		attachables = append(attachables, secretsprovider.FromMap(opts.Secrets))
+	}
+
+	if opts.SSHAuthSock != "" {
+		sshProvider, err := sshprovider.NewSSHAgentProvider([]sshprovider.AgentConfig{
+			{ID: DefaultSSHId, Paths: []string{opts.SSHAuthSock}},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to forward ssh agent %s: %s", opts.SSHAuthSock, err)
+		}
+
+		attachables = append(attachables, sshProvider)
+	}
+
+	return attachables, nil
+}
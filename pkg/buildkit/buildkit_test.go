@@ -0,0 +1,40 @@
+package buildkit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFrontendAttrs(t *testing.T) {
+	attrs := frontendAttrs(BuildOptions{
+		DockerfilePath: "/project/docker/Dockerfile.app",
+		Target:         "prod",
+		BuildArgs:      map[string]string{"VERSION": "1.0"},
+		AddHost:        []string{"a:127.0.0.1", "b:127.0.0.2"},
+		Labels:         map[string]string{"werf": "project"},
+	})
+
+	expected := map[string]string{
+		"filename":          "Dockerfile.app",
+		"target":            "prod",
+		"build-arg:VERSION": "1.0",
+		"add-hosts":         "a:127.0.0.1,b:127.0.0.2",
+		"label:werf":        "project",
+	}
+
+	if !reflect.DeepEqual(attrs, expected) {
+		t.Errorf("unexpected frontend attrs %v, expected %v", attrs, expected)
+	}
+}
+
+func TestFrontendAttrsWithoutTarget(t *testing.T) {
+	attrs := frontendAttrs(BuildOptions{DockerfilePath: "/project/Dockerfile"})
+
+	if _, hasKey := attrs["target"]; hasKey {
+		t.Errorf("unexpected target in frontend attrs %v", attrs)
+	}
+
+	if attrs["filename"] != "Dockerfile" {
+		t.Errorf("unexpected filename %q", attrs["filename"])
+	}
+}
@@ -0,0 +1,141 @@
+package cache_mount
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/api/types"
+
+	"github.com/flant/werf/pkg/docker"
+	"github.com/flant/werf/pkg/werf"
+)
+
+const (
+	ProjectLabel = "werf-cache-mount-project"
+	IDLabel      = "werf-cache-mount-id"
+)
+
+type Volume struct {
+	Name        string
+	ProjectName string
+	ID          string
+	Size        int64
+	RefCount    int64
+	LastUsed    time.Time
+}
+
+func VolumeName(projectName, id string) string {
+	return fmt.Sprintf("werf-cache-%s-%s", projectName, id)
+}
+
+func LockName(volumeName string) string {
+	return fmt.Sprintf("cache_mount.%s", volumeName)
+}
+
+// Prepare creates docker volume for the project cache mount if it does not exist and records the time of usage
+func Prepare(projectName, id string) (string, error) {
+	volumeName := VolumeName(projectName, id)
+
+	if err := docker.VolumeCreate(volumeName, map[string]string{ProjectLabel: projectName, IDLabel: id}); err != nil {
+		return "", fmt.Errorf("unable to create cache mount volume %s: %s", volumeName, err)
+	}
+
+	if err := touchLastUsed(volumeName); err != nil {
+		return "", fmt.Errorf("unable to record usage of cache mount volume %s: %s", volumeName, err)
+	}
+
+	return volumeName, nil
+}
+
+// List returns cache mounts volumes of all projects sorted by name
+func List() ([]*Volume, error) {
+	dockerVolumes, err := docker.VolumesDiskUsage()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get volumes disk usage: %s", err)
+	}
+
+	var res []*Volume
+	for _, dockerVolume := range dockerVolumes {
+		projectName, hasProject := dockerVolume.Labels[ProjectLabel]
+		id, hasID := dockerVolume.Labels[IDLabel]
+		if !hasProject || !hasID {
+			continue
+		}
+
+		v := &Volume{
+			Name:        dockerVolume.Name,
+			ProjectName: projectName,
+			ID:          id,
+			RefCount:    -1,
+			Size:        -1,
+			LastUsed:    lastUsed(dockerVolume),
+		}
+
+		if dockerVolume.UsageData != nil {
+			v.Size = dockerVolume.UsageData.Size
+			v.RefCount = dockerVolume.UsageData.RefCount
+		}
+
+		res = append(res, v)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+
+	return res, nil
+}
+
+func Remove(volumeName string) error {
+	if err := docker.VolumeRm(volumeName, false); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(lastUsedPath(volumeName)); err != nil {
+		return fmt.Errorf("unable to remove usage record of cache mount volume %s: %s", volumeName, err)
+	}
+
+	return nil
+}
+
+// IsUsedSince checks whether the volume has been prepared for usage after the specified time,
+// the cleanup should check it under the volume lock before removing the volume
+func IsUsedSince(volumeName string, t time.Time) bool {
+	fi, err := os.Stat(lastUsedPath(volumeName))
+	return err == nil && fi.ModTime().After(t)
+}
+
+func lastUsed(dockerVolume *types.Volume) time.Time {
+	if fi, err := os.Stat(lastUsedPath(dockerVolume.Name)); err == nil {
+		return fi.ModTime()
+	}
+
+	createdAt, _ := time.Parse(time.RFC3339, dockerVolume.CreatedAt)
+	return createdAt
+}
+
+func touchLastUsed(volumeName string) error {
+	p := lastUsedPath(volumeName)
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := os.Chtimes(p, now, now); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func lastUsedPath(volumeName string) string {
+	return filepath.Join(werf.GetServiceDir(), "cache_mounts", volumeName)
+}
@@ -0,0 +1,41 @@
+package cache_mount
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/flant/werf/pkg/werf"
+)
+
+func TestIsUsedSince(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "werf-cache-mount-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := werf.Init(tmpDir, tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	volumeName := VolumeName("project", "go-build")
+	listedAt := time.Now().Add(-time.Hour)
+
+	if IsUsedSince(volumeName, listedAt) {
+		t.Errorf("volume without usage record is not expected to be used")
+	}
+
+	if err := touchLastUsed(volumeName); err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsUsedSince(volumeName, listedAt) {
+		t.Errorf("volume prepared after listing is expected to be used")
+	}
+
+	if IsUsedSince(volumeName, time.Now().Add(time.Hour)) {
+		t.Errorf("volume is not expected to be used after the last usage")
+	}
+}
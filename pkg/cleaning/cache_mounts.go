@@ -0,0 +1,113 @@
+package cleaning
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/docker/go-units"
+
+	"github.com/flant/logboek"
+	"github.com/flant/shluz"
+	"github.com/flant/werf/pkg/cache_mount"
+)
+
+type cacheMountDecision struct {
+	Volume *cache_mount.Volume
+	Remove bool
+	Reason string
+}
+
+// cacheMountsDecisions applies size and age limits to cache mounts volumes, zero limit is not checked
+func cacheMountsDecisions(volumes []*cache_mount.Volume, maxSize int64, maxAge time.Duration, now time.Time) []*cacheMountDecision {
+	var res []*cacheMountDecision
+
+	for _, v := range volumes {
+		d := &cacheMountDecision{Volume: v}
+
+		if v.RefCount > 0 {
+			d.Reason = "used by container"
+		} else if maxAge != 0 && now.Sub(v.LastUsed) > maxAge {
+			d.Remove = true
+			d.Reason = fmt.Sprintf("not used for more than %s", units.HumanDuration(maxAge))
+		} else if maxSize != 0 && v.Size > maxSize {
+			d.Remove = true
+			d.Reason = fmt.Sprintf("size %s exceeds limit %s", units.BytesSize(float64(v.Size)), units.BytesSize(float64(maxSize)))
+		} else {
+			d.Reason = "within limits"
+		}
+
+		res = append(res, d)
+	}
+
+	return res
+}
+
+func safeCacheMountsCleanup(maxSize int64, maxAge time.Duration, options CommonOptions) error {
+	volumes, err := cache_mount.List()
+	if err != nil {
+		return err
+	}
+
+	for _, d := range cacheMountsDecisions(volumes, maxSize, maxAge, time.Now()) {
+		if !d.Remove {
+			options.Report.add(ReportObjectVolume, d.Volume.Name, ReportDecisionKeep, d.Reason)
+			continue
+		}
+
+		if err := cacheMountRemove(d.Volume, d.Reason, options); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func cacheMountsPurge(options CommonOptions) error {
+	volumes, err := cache_mount.List()
+	if err != nil {
+		return err
+	}
+
+	for _, v := range volumes {
+		if err := cacheMountRemove(v, "purge", options); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func cacheMountRemove(v *cache_mount.Volume, reason string, options CommonOptions) error {
+	lockName := cache_mount.LockName(v.Name)
+	isLocked, err := shluz.TryLock(lockName, shluz.TryLockOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to lock %s for cache mount volume %s: %s", lockName, v.Name, err)
+	}
+
+	if !isLocked {
+		logboek.LogInfoF("Ignore cache mount volume %s used by another process\n", v.Name)
+		options.Report.add(ReportObjectVolume, v.Name, ReportDecisionKeep, "used by another process")
+		return nil
+	}
+	defer shluz.Unlock(lockName)
+
+	if cache_mount.IsUsedSince(v.Name, v.LastUsed) {
+		logboek.LogInfoF("Ignore cache mount volume %s used by another process\n", v.Name)
+		options.Report.add(ReportObjectVolume, v.Name, ReportDecisionKeep, "used by another process")
+		return nil
+	}
+
+	options.Report.add(ReportObjectVolume, v.Name, ReportDecisionRemove, reason)
+
+	if options.DryRun {
+		logboek.LogLn(v.Name)
+		logboek.LogOptionalLn()
+		return nil
+	}
+
+	if err := cache_mount.Remove(v.Name); err != nil {
+		return fmt.Errorf("failed to remove cache mount volume %s: %s", v.Name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package cleaning
+
+import (
+	"testing"
+	"time"
+
+	"github.com/flant/werf/pkg/cache_mount"
+)
+
+func TestCacheMountsDecisions(t *testing.T) {
+	now := time.Date(2020, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	volumes := []*cache_mount.Volume{
+		{Name: "fresh", Size: 100, LastUsed: now.Add(-time.Hour)},
+		{Name: "old", Size: 100, LastUsed: now.Add(-10 * 24 * time.Hour)},
+		{Name: "big", Size: 2000, LastUsed: now.Add(-time.Hour)},
+		{Name: "used", Size: 2000, RefCount: 1, LastUsed: now.Add(-10 * 24 * time.Hour)},
+	}
+
+	expected := map[string]bool{"fresh": false, "old": true, "big": true, "used": false}
+	for _, d := range cacheMountsDecisions(volumes, 1000, 7*24*time.Hour, now) {
+		if d.Remove != expected[d.Volume.Name] {
+			t.Errorf("volume %s: expected remove %v, got %v (%s)", d.Volume.Name, expected[d.Volume.Name], d.Remove, d.Reason)
+		}
+	}
+
+	for _, d := range cacheMountsDecisions(volumes, 0, 0, now) {
+		if d.Remove {
+			t.Errorf("volume %s: expected to be kept without limits, got %s", d.Volume.Name, d.Reason)
+		}
+	}
+}
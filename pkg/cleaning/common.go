@@ -19,6 +19,7 @@ type CommonOptions struct {
 	RmiForce                      bool
 	SkipUsedImages                bool
 	RmContainersThatUseWerfImages bool
+	Report                        *Report
 }
 
 func werfImagesFlushByFilterSet(filterSet filters.Args, options CommonOptions) error {
@@ -119,6 +120,7 @@ func processUsedImages(images []types.ImageSummary, options CommonOptions) ([]ty
 			if img.ID == container.ImageID {
 				if options.SkipUsedImages {
 					logboek.LogInfoF("Skip image %s (used by container %s)\n", logImageName(img), logContainerName(container))
+					options.Report.add(reportObjectTypeByImage(img), logImageName(img), ReportDecisionKeep, fmt.Sprintf("used by container %s", logContainerName(container)))
 					imagesToExclude = append(imagesToExclude, img)
 				} else if options.RmContainersThatUseWerfImages {
 					containersToRemove = append(containersToRemove, container)
@@ -8,10 +8,6 @@ import (
 	"github.com/flant/werf/pkg/build"
 )
 
-const (
-	localStagesStorage string = ":local"
-)
-
 type CommonProjectOptions struct {
 	ProjectName   string
 	CommonOptions CommonOptions
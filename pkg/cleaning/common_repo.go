@@ -14,6 +14,9 @@ type CommonRepoOptions struct {
 	ImagesRepoManager ImagesRepoManager
 	ImagesNames       []string
 	DryRun            bool
+	Report            *Report
+
+	imagesArtifacts *imagesArtifacts
 }
 
 type ImagesRepoManager interface {
@@ -110,61 +113,38 @@ func repoImageStagesImages(options CommonRepoOptions) ([]docker_registry.RepoIma
 }
 
 func repoImagesRemove(images []docker_registry.RepoImage, options CommonRepoOptions) error {
-	isGCR, err := docker_registry.IsGCR(options.ImagesRepoManager.ImagesRepo())
-	if err != nil {
-		return err
-	}
-
 	for _, image := range images {
-		if isGCR {
-			if err := GCRImageRemove(image, options); err != nil {
-				return err
-			}
-		} else {
-			if err := repoImageRemove(image, options); err != nil {
-				return err
-			}
+		if err := repoImageRemove(image, options); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func GCRImageRemove(image docker_registry.RepoImage, options CommonRepoOptions) error {
-	reference := strings.Join([]string{image.Repository, image.Tag}, ":")
-	if err := repoReferenceRemove(reference, options); err != nil {
-		return err
-	}
-
-	return nil
-}
-
 func repoImageRemove(image docker_registry.RepoImage, options CommonRepoOptions) error {
 	digest, err := image.Digest()
 	if err != nil {
 		return err
 	}
 
-	reference := strings.Join([]string{image.Repository, digest.String()}, "@")
-	if err := repoReferenceRemove(reference, options); err != nil {
-		return err
+	logboek.LogLn(strings.Join([]string{image.Repository, digest.String()}, "@"))
+	if !options.DryRun {
+		if err := docker_registry.DeleteRepoImage(image); err != nil {
+			return err
+		}
 	}
 
 	logboek.LogInfoF("  tag: %s\n", image.Tag)
-	logboek.LogOptionalLn()
 
-	return nil
-}
-
-func repoReferenceRemove(reference string, options CommonRepoOptions) error {
-	logboek.LogLn(reference)
-	if !options.DryRun {
-		err := docker_registry.ImageDelete(reference)
-		if err != nil {
+	if options.imagesArtifacts != nil {
+		if err := options.imagesArtifacts.removeImageArtifacts(image, digest.String(), options); err != nil {
 			return err
 		}
 	}
 
+	logboek.LogOptionalLn()
+
 	return nil
 }
 
@@ -2,6 +2,7 @@ package cleaning
 
 type GitRepo interface {
 	IsCommitExists(commit string) (bool, error)
+	IsCommitReachableFromHeads(commit string) (bool, error)
 	TagsList() ([]string, error)
 	RemoteBranchesList() ([]string, error)
 }
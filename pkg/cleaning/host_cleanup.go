@@ -10,12 +10,18 @@ import (
 
 	"github.com/flant/logboek"
 	"github.com/flant/shluz"
+	"github.com/flant/werf/pkg/docker_registry"
 	"github.com/flant/werf/pkg/image"
 	"github.com/flant/werf/pkg/tmp_manager"
 )
 
 type HostCleanupOptions struct {
 	DryRun bool
+	Report *Report
+
+	// CacheMountsMaxSize and CacheMountsMaxAge are limits for cache mounts volumes, zero value disables the limit
+	CacheMountsMaxSize int64
+	CacheMountsMaxAge  time.Duration
 }
 
 func HostCleanup(options HostCleanupOptions) error {
@@ -24,6 +30,7 @@ func HostCleanup(options HostCleanupOptions) error {
 		RmiForce:       false,
 		RmForce:        true,
 		DryRun:         options.DryRun,
+		Report:         options.Report,
 	}
 
 	return shluz.WithLock("host-cleanup", shluz.LockOptions{Timeout: time.Second * 600}, func() error {
@@ -39,6 +46,24 @@ func HostCleanup(options HostCleanupOptions) error {
 			return nil
 		}
 
+		if err := logboek.LogProcess("Running cleanup for docker images built in development mode", logboek.LogProcessOptions{}, func() error {
+			return safeDevImagesCleanup(commonOptions)
+		}); err != nil {
+			return err
+		}
+
+		if err := logboek.LogProcess("Running cleanup for cache mounts docker volumes", logboek.LogProcessOptions{}, func() error {
+			return safeCacheMountsCleanup(options.CacheMountsMaxSize, options.CacheMountsMaxAge, commonOptions)
+		}); err != nil {
+			return err
+		}
+
+		if err := logboek.LogProcess("Running cleanup for registry images cache", logboek.LogProcessOptions{}, func() error {
+			return docker_registry.ImageCacheGC(commonOptions.DryRun)
+		}); err != nil {
+			return err
+		}
+
 		return shluz.WithLock("gc", shluz.LockOptions{}, func() error {
 			if err := tmp_manager.GC(commonOptions.DryRun); err != nil {
 				return fmt.Errorf("tmp files gc failed: %s", err)
@@ -67,6 +92,7 @@ func safeDanglingImagesCleanup(options CommonOptions) error {
 
 			if !isLocked {
 				logboek.LogInfoF("Ignore dangling image %s used by another process\n", imgName)
+				options.Report.addImages(ReportObjectImage, []types.ImageSummary{img}, ReportDecisionKeep, "used by another process")
 				continue
 			}
 
@@ -83,6 +109,55 @@ func safeDanglingImagesCleanup(options CommonOptions) error {
 		return err
 	}
 
+	options.Report.addImages(ReportObjectImage, imagesToRemove, ReportDecisionRemove, "dangling image")
+
+	if err := imagesRemove(imagesToRemove, options); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// safeDevImagesCleanup removes all stages built in development mode which are not used by other processes and containers
+func safeDevImagesCleanup(options CommonOptions) error {
+	filterSet := filters.NewArgs()
+	filterSet.Add("label", fmt.Sprintf("%s=true", image.WerfDevLabel))
+
+	images, err := werfImagesByFilterSet(filterSet)
+	if err != nil {
+		return err
+	}
+
+	var imagesToRemove []types.ImageSummary
+
+	for _, img := range images {
+		imgName := img.Labels[image.WerfDockerImageName]
+		if imgName != "" {
+			imageLockName := image.ImageLockName(imgName)
+			isLocked, err := shluz.TryLock(imageLockName, shluz.TryLockOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to lock %s for image %s: %s", imageLockName, imgName, err)
+			}
+
+			if !isLocked {
+				logboek.LogInfoF("Ignore dev image %s used by another process\n", imgName)
+				options.Report.addImages(ReportObjectImage, []types.ImageSummary{img}, ReportDecisionKeep, "used by another process")
+				continue
+			}
+
+			shluz.Unlock(imageLockName) // no need to hold a lock
+		}
+
+		imagesToRemove = append(imagesToRemove, img)
+	}
+
+	imagesToRemove, err = processUsedImages(imagesToRemove, options)
+	if err != nil {
+		return err
+	}
+
+	options.Report.addImages(ReportObjectImage, imagesToRemove, ReportDecisionRemove, "built in development mode")
+
 	if err := imagesRemove(imagesToRemove, options); err != nil {
 		return err
 	}
@@ -107,6 +182,7 @@ func safeContainersCleanup(options CommonOptions) error {
 
 		if containerName == "" {
 			logboek.LogErrorF("Ignore bad container %s\n", container.ID)
+			options.Report.add(ReportObjectContainer, container.ID, ReportDecisionKeep, "bad container name")
 			continue
 		}
 
@@ -119,10 +195,13 @@ func safeContainersCleanup(options CommonOptions) error {
 
 			if !isLocked {
 				logboek.LogInfoF("Ignore container %s used by another process\n", logContainerName(container))
+				options.Report.add(ReportObjectContainer, logContainerName(container), ReportDecisionKeep, "used by another process")
 				return nil
 			}
 			defer shluz.Unlock(containerLockName)
 
+			options.Report.add(ReportObjectContainer, logContainerName(container), ReportDecisionRemove, "werf build container")
+
 			if err := containersRemove([]types.Container{container}, options); err != nil {
 				return fmt.Errorf("failed to remove container %s: %s", logContainerName(container), err)
 			}
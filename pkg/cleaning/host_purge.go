@@ -48,6 +48,12 @@ func HostPurge(options HostPurgeOptions) error {
 		return err
 	}
 
+	if err := logboek.LogProcess("Running werf cache mounts docker volumes purge", logboek.LogProcessOptions{}, func() error {
+		return cacheMountsPurge(commonOptions)
+	}); err != nil {
+		return err
+	}
+
 	if err := tmp_manager.Purge(commonOptions.DryRun); err != nil {
 		return fmt.Errorf("tmp files purge failed: %s", err)
 	}
@@ -0,0 +1,115 @@
+package cleaning
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/flant/logboek"
+
+	"github.com/flant/werf/pkg/build"
+	"github.com/flant/werf/pkg/docker_registry"
+	"github.com/flant/werf/pkg/image_signing"
+)
+
+const reportReasonImageArtifactNotLinked = "artifact not linked to any image tag"
+
+var gitCommitRecordSuffixRegexp = regexp.MustCompile(`^[0-9a-f]{12}$`)
+
+// imagesArtifacts tracks image tags by digest, signature and provenance artifacts are addressed by the image digest
+// and removed together with the last image tag of the digest, git commit records are removed together with the image tag
+type imagesArtifacts struct {
+	referencesByDigest map[string]map[string]bool
+	tagsByRepository   map[string][]string
+}
+
+func newImagesArtifacts(repoImages []docker_registry.RepoImage) (*imagesArtifacts, error) {
+	a := &imagesArtifacts{
+		referencesByDigest: map[string]map[string]bool{},
+		tagsByRepository:   map[string][]string{},
+	}
+
+	for _, repoImage := range repoImages {
+		digest, err := repoImage.Digest()
+		if err != nil {
+			return nil, err
+		}
+
+		key := a.digestKey(repoImage.Repository, digest.String())
+		if _, exist := a.referencesByDigest[key]; !exist {
+			a.referencesByDigest[key] = map[string]bool{}
+		}
+		a.referencesByDigest[key][repoImageReference(repoImage)] = true
+
+		if _, exist := a.tagsByRepository[repoImage.Repository]; !exist {
+			tags, err := docker_registry.Tags(repoImage.Repository)
+			if err != nil {
+				return nil, fmt.Errorf("unable to get repository %s tags: %s", repoImage.Repository, err)
+			}
+
+			a.tagsByRepository[repoImage.Repository] = tags
+		}
+	}
+
+	return a, nil
+}
+
+func (a *imagesArtifacts) digestKey(repository, digest string) string {
+	return strings.Join([]string{repository, digest}, "@")
+}
+
+func (a *imagesArtifacts) gitCommitRecordTags(repoImage docker_registry.RepoImage) []string {
+	var res []string
+	for _, tag := range a.tagsByRepository[repoImage.Repository] {
+		if strings.HasPrefix(tag, build.GitCommitRecordTagPrefix(repoImage.Tag)) && gitCommitRecordSuffixRegexp.MatchString(strings.TrimPrefix(tag, build.GitCommitRecordTagPrefix(repoImage.Tag))) {
+			res = append(res, tag)
+		}
+	}
+
+	return res
+}
+
+// removeImageArtifacts removes git commit records of the removed image and other artifacts if there are no more tags of the image digest
+func (a *imagesArtifacts) removeImageArtifacts(repoImage docker_registry.RepoImage, digest string, options CommonRepoOptions) error {
+	artifactsTags := a.gitCommitRecordTags(repoImage)
+
+	key := a.digestKey(repoImage.Repository, digest)
+	delete(a.referencesByDigest[key], repoImageReference(repoImage))
+	if len(a.referencesByDigest[key]) == 0 {
+		for _, artifactTag := range []string{image_signing.SignatureTag(digest), build.ProvenanceTag(digest)} {
+			if a.hasTag(repoImage.Repository, artifactTag) {
+				artifactsTags = append(artifactsTags, artifactTag)
+			}
+		}
+	}
+
+	for _, artifactTag := range artifactsTags {
+		artifactReference := strings.Join([]string{repoImage.Repository, artifactTag}, ":")
+		options.Report.add(ReportObjectRepoImageArtifact, artifactReference, ReportDecisionRemove, reportReasonImageArtifactNotLinked)
+
+		if !options.DryRun {
+			artifact, err := docker_registry.GetRepoImage(repoImage.Repository, artifactTag)
+			if err != nil {
+				return fmt.Errorf("unable to get artifact %s: %s", artifactReference, err)
+			}
+
+			if err := docker_registry.DeleteRepoImage(artifact); err != nil {
+				return err
+			}
+		}
+
+		logboek.LogInfoF("  artifact: %s\n", artifactTag)
+	}
+
+	return nil
+}
+
+func (a *imagesArtifacts) hasTag(repository, tag string) bool {
+	for _, t := range a.tagsByRepository[repository] {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,154 @@
+package cleaning
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/flant/go-containerregistry/pkg/name"
+	"github.com/flant/go-containerregistry/pkg/registry"
+	"github.com/flant/go-containerregistry/pkg/v1/random"
+	"github.com/flant/go-containerregistry/pkg/v1/remote"
+
+	"github.com/flant/werf/pkg/build"
+	"github.com/flant/werf/pkg/docker_registry"
+	"github.com/flant/werf/pkg/image_signing"
+)
+
+// testRegistry extends the in-memory registry with tags list and records deleted manifests
+type testRegistry struct {
+	handler http.Handler
+	tags    []string
+
+	mutex          sync.Mutex
+	deletedDigests []string
+}
+
+func (r *testRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch {
+	case req.Method == http.MethodGet && strings.HasSuffix(req.URL.Path, "/tags/list"):
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": "project", "tags": r.tags})
+	case req.Method == http.MethodDelete && strings.Contains(req.URL.Path, "/manifests/"):
+		r.mutex.Lock()
+		r.deletedDigests = append(r.deletedDigests, req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:])
+		r.mutex.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		r.handler.ServeHTTP(w, req)
+	}
+}
+
+func (r *testRegistry) resetDeletedDigests() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	digests := r.deletedDigests
+	r.deletedDigests = nil
+	sort.Strings(digests)
+
+	return digests
+}
+
+func TestImagesArtifacts(t *testing.T) {
+	testRegistry := &testRegistry{handler: registry.New()}
+	server := httptest.NewServer(testRegistry)
+	defer server.Close()
+
+	if err := docker_registry.Init(docker_registry.Options{Implementation: docker_registry.ImplementationDefault}); err != nil {
+		t.Fatal(err)
+	}
+	defer docker_registry.Init(docker_registry.Options{})
+
+	repository := strings.TrimPrefix(server.URL, "http://") + "/project"
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tag := range []string{"v1", "latest"} {
+		ref, err := name.ParseReference(repository+":"+tag, name.WeakValidation)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := remote.Write(ref, img); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signatureTag := image_signing.SignatureTag(digest.String())
+	provenanceTag := build.ProvenanceTag(digest.String())
+	for _, tag := range []string{signatureTag, provenanceTag} {
+		if err := docker_registry.PushArtifact(repository+":"+tag, map[string]string{"tag": tag}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testRegistry.tags = []string{"v1", "latest", signatureTag, provenanceTag}
+
+	var artifactsDigests []string
+	for _, tag := range []string{signatureTag, provenanceTag} {
+		artifactDigest, err := docker_registry.ImageDigest(repository + ":" + tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		artifactsDigests = append(artifactsDigests, artifactDigest)
+	}
+
+	var repoImages []docker_registry.RepoImage
+	for _, tag := range []string{"v1", "latest"} {
+		repoImage, err := docker_registry.GetRepoImage(repository, tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		repoImages = append(repoImages, repoImage)
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		options := CommonRepoOptions{DryRun: dryRun, Report: NewReport(dryRun)}
+		options.imagesArtifacts, err = newImagesArtifacts(repoImages)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := repoImageRemove(repoImages[0], options); err != nil {
+			t.Fatal(err)
+		}
+
+		if options.Report.hasRecord(ReportObjectRepoImageArtifact, repository+":"+signatureTag) {
+			t.Errorf("dry run %v: signature must be kept while the image digest has tags", dryRun)
+		}
+
+		if err := repoImageRemove(repoImages[1], options); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tag := range []string{signatureTag, provenanceTag} {
+			if !options.Report.hasRecord(ReportObjectRepoImageArtifact, repository+":"+tag) {
+				t.Errorf("dry run %v: artifact %s expected to be removed with the last image tag", dryRun, tag)
+			}
+		}
+
+		var expectedDeletedDigests []string
+		if !dryRun {
+			expectedDeletedDigests = append([]string{digest.String(), digest.String()}, artifactsDigests...)
+			sort.Strings(expectedDeletedDigests)
+		}
+
+		if deletedDigests := testRegistry.resetDeletedDigests(); strings.Join(deletedDigests, ",") != strings.Join(expectedDeletedDigests, ",") {
+			t.Errorf("dry run %v: expected deleted digests %v, got %v", dryRun, expectedDeletedDigests, deletedDigests)
+		}
+	}
+}
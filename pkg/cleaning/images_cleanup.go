@@ -3,6 +3,7 @@ package cleaning
 import (
 	"fmt"
 	"log"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -12,9 +13,9 @@ import (
 
 	"github.com/flant/logboek"
 
-	"github.com/flant/shluz"
 	"github.com/flant/werf/pkg/docker_registry"
 	"github.com/flant/werf/pkg/image"
+	"github.com/flant/werf/pkg/lock_manager"
 	"github.com/flant/werf/pkg/logging"
 	"github.com/flant/werf/pkg/slug"
 	"github.com/flant/werf/pkg/tag_strategy"
@@ -32,6 +33,20 @@ type ImagesCleanupPolicies struct {
 
 	GitCommitStrategyHasExpiryPeriod bool // No expiration by default!
 	GitCommitStrategyExpiryPeriod    time.Duration
+
+	// KeepPolicies are declared in werf.yaml and replace git-tag and git-commit strategies policies
+	KeepPolicies []ImagesCleanupKeepPolicy
+}
+
+type ImagesCleanupKeepPolicy struct {
+	TagStrategy tag_strategy.TagStrategy
+	Regexp      *regexp.Regexp // Matches all images meta tags if nil
+
+	HasLimit bool
+	Limit    int64
+
+	HasExpiryPeriod bool
+	ExpiryPeriod    time.Duration
 }
 
 type ImagesCleanupOptions struct {
@@ -51,16 +66,26 @@ func ImagesCleanup(options ImagesCleanupOptions) error {
 
 func imagesCleanup(options ImagesCleanupOptions) error {
 	imagesCleanupLockName := fmt.Sprintf("images-cleanup.%s", options.CommonRepoOptions.ImagesRepoManager.ImagesRepo())
-	return shluz.WithLock(imagesCleanupLockName, shluz.LockOptions{Timeout: time.Second * 600}, func() error {
+	return lock_manager.WithLock(imagesCleanupLockName, lock_manager.LockOptions{Timeout: time.Second * 600}, func() error {
 		repoImagesByImageName, err := repoImagesByImageName(options.CommonRepoOptions)
 		if err != nil {
 			return err
 		}
 
+		var allRepoImages []docker_registry.RepoImage
+		for _, repoImages := range repoImagesByImageName {
+			allRepoImages = append(allRepoImages, repoImages...)
+		}
+
+		options.CommonRepoOptions.imagesArtifacts, err = newImagesArtifacts(allRepoImages)
+		if err != nil {
+			return err
+		}
+
 		if options.LocalGit != nil {
 			if !options.WithoutKube {
 				if err := logboek.LogProcess("Skipping repo images that are being used in Kubernetes", logboek.LogProcessOptions{}, func() error {
-					repoImagesByImageName, err = exceptRepoImagesByWhitelist(repoImagesByImageName, options.KubernetesContextsClients)
+					repoImagesByImageName, err = exceptRepoImagesByWhitelist(repoImagesByImageName, options.KubernetesContextsClients, options.CommonRepoOptions.Report)
 					return err
 				}); err != nil {
 					return err
@@ -89,12 +114,55 @@ func imagesCleanup(options ImagesCleanupOptions) error {
 			}
 		}
 
+		for _, repoImages := range repoImagesByImageName {
+			if err := reportKeptRepoImages(repoImages, options); err != nil {
+				return err
+			}
+		}
+
 		return nil
 	})
 }
 
-func exceptRepoImagesByWhitelist(repoImagesByImageName map[string][]docker_registry.RepoImage, kubernetesContextsClients map[string]kubernetes.Interface) (map[string][]docker_registry.RepoImage, error) {
+func reportKeptRepoImages(repoImages []docker_registry.RepoImage, options ImagesCleanupOptions) error {
+	for _, repoImage := range repoImages {
+		if options.CommonRepoOptions.Report.hasRecord(ReportObjectRepoImage, repoImageReference(repoImage)) {
+			continue
+		}
+
+		var reason string
+		if options.LocalGit == nil {
+			reason = "git repository not found"
+		} else {
+			labels, err := repoImageLabels(repoImage)
+			if err != nil {
+				return err
+			}
+
+			switch labels[image.WerfTagStrategyLabel] {
+			// images kept by cleanup policies are reported by the policies
+			case string(tag_strategy.GitTag):
+				reason = "git tag exists, not covered by cleanup policies"
+			case string(tag_strategy.GitBranch):
+				reason = "git branch exists, not covered by cleanup policies"
+			case string(tag_strategy.GitCommit):
+				reason = "git commit exists, not covered by cleanup policies"
+			case string(tag_strategy.StagesSignature):
+				reason = "git commit of publication exists"
+			default:
+				reason = "not covered by cleanup policies"
+			}
+		}
+
+		options.CommonRepoOptions.Report.add(ReportObjectRepoImage, repoImageReference(repoImage), ReportDecisionKeep, reason)
+	}
+
+	return nil
+}
+
+func exceptRepoImagesByWhitelist(repoImagesByImageName map[string][]docker_registry.RepoImage, kubernetesContextsClients map[string]kubernetes.Interface, report *Report) (map[string][]docker_registry.RepoImage, error) {
 	var deployedDockerImagesNames []string
+	deployedDockerImagesContexts := map[string]string{}
 	for contextName, kubernetesClient := range kubernetesContextsClients {
 		if err := logboek.LogProcessInline(fmt.Sprintf("Getting deployed docker images (context %s)", contextName), logboek.LogProcessInlineOptions{}, func() error {
 			kubernetesClientDeployedDockerImagesNames, err := deployedDockerImages(kubernetesClient)
@@ -103,6 +171,11 @@ func exceptRepoImagesByWhitelist(repoImagesByImageName map[string][]docker_regis
 			}
 
 			deployedDockerImagesNames = append(deployedDockerImagesNames, kubernetesClientDeployedDockerImagesNames...)
+			for _, deployedDockerImageName := range kubernetesClientDeployedDockerImagesNames {
+				if _, ok := deployedDockerImagesContexts[deployedDockerImageName]; !ok {
+					deployedDockerImagesContexts[deployedDockerImageName] = contextName
+				}
+			}
 
 			return nil
 		}); err != nil {
@@ -119,6 +192,7 @@ func exceptRepoImagesByWhitelist(repoImagesByImageName map[string][]docker_regis
 			for _, deployedDockerImageName := range deployedDockerImagesNames {
 				if deployedDockerImageName == imageName {
 					logboek.LogInfoLn(imageName)
+					report.add(ReportObjectRepoImage, imageName, ReportDecisionKeep, fmt.Sprintf("used in Kubernetes context %s", deployedDockerImagesContexts[deployedDockerImageName]))
 					continue Loop
 				}
 			}
@@ -133,7 +207,7 @@ func exceptRepoImagesByWhitelist(repoImagesByImageName map[string][]docker_regis
 }
 
 func repoImagesCleanupByNonexistentGitPrimitive(repoImages []docker_registry.RepoImage, options ImagesCleanupOptions) ([]docker_registry.RepoImage, error) {
-	var nonexistentGitTagRepoImages, nonexistentGitCommitRepoImages, nonexistentGitBranchRepoImages []docker_registry.RepoImage
+	var nonexistentGitTagRepoImages, nonexistentGitCommitRepoImages, nonexistentGitBranchRepoImages, nonexistentStagesSignatureRepoImages []docker_registry.RepoImage
 
 	var gitTags []string
 	var gitBranches []string
@@ -200,11 +274,42 @@ Loop:
 			if !exist {
 				nonexistentGitCommitRepoImages = append(nonexistentGitCommitRepoImages, repoImage)
 			}
+		case string(tag_strategy.StagesSignature):
+			if options.LocalGit == nil {
+				continue Loop
+			}
+
+			commits, err := stagesSignatureRepoImageCommits(repoImage, labels, options.CommonRepoOptions)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(commits) == 0 {
+				continue Loop
+			}
+
+			for _, commit := range commits {
+				reachable, err := options.LocalGit.IsCommitReachableFromHeads(commit)
+				if err != nil {
+					if strings.HasPrefix(err.Error(), "bad commit hash") {
+						continue
+					}
+
+					return nil, err
+				}
+
+				if reachable {
+					continue Loop
+				}
+			}
+
+			nonexistentStagesSignatureRepoImages = append(nonexistentStagesSignatureRepoImages, repoImage)
 		}
 	}
 
 	var err error
 	if len(nonexistentGitTagRepoImages) != 0 {
+		options.CommonRepoOptions.Report.addRepoImages(ReportObjectRepoImage, nonexistentGitTagRepoImages, ReportDecisionRemove, "git tag deleted")
 		logboek.LogBlock("Removed tags by nonexistent git-tag policy", logboek.LogBlockOptions{}, func() {
 			err = repoImagesRemove(nonexistentGitTagRepoImages, options.CommonRepoOptions)
 		})
@@ -217,6 +322,7 @@ Loop:
 	}
 
 	if len(nonexistentGitBranchRepoImages) != 0 {
+		options.CommonRepoOptions.Report.addRepoImages(ReportObjectRepoImage, nonexistentGitBranchRepoImages, ReportDecisionRemove, "git branch deleted")
 		logboek.LogBlock("Removed tags by nonexistent git-branch policy", logboek.LogBlockOptions{}, func() {
 			err = repoImagesRemove(nonexistentGitBranchRepoImages, options.CommonRepoOptions)
 		})
@@ -229,6 +335,7 @@ Loop:
 	}
 
 	if len(nonexistentGitCommitRepoImages) != 0 {
+		options.CommonRepoOptions.Report.addRepoImages(ReportObjectRepoImage, nonexistentGitCommitRepoImages, ReportDecisionRemove, "git commit deleted")
 		logboek.LogBlock("Removed tags by nonexistent git-commit policy", logboek.LogBlockOptions{}, func() {
 			err = repoImagesRemove(nonexistentGitCommitRepoImages, options.CommonRepoOptions)
 		})
@@ -240,9 +347,49 @@ Loop:
 		repoImages = exceptRepoImages(repoImages, nonexistentGitCommitRepoImages...)
 	}
 
+	if len(nonexistentStagesSignatureRepoImages) != 0 {
+		options.CommonRepoOptions.Report.addRepoImages(ReportObjectRepoImage, nonexistentStagesSignatureRepoImages, ReportDecisionRemove, "git commit of publication deleted")
+		logboek.LogBlock("Removed tags by nonexistent stages-signature git commit policy", logboek.LogBlockOptions{}, func() {
+			err = repoImagesRemove(nonexistentStagesSignatureRepoImages, options.CommonRepoOptions)
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		repoImages = exceptRepoImages(repoImages, nonexistentStagesSignatureRepoImages...)
+	}
+
 	return repoImages, nil
 }
 
+// stagesSignatureRepoImageCommits returns the commit of the image publication and commits of the following publications
+// of the same image by other branches, which are recorded separately because the up-to-date image is not republished
+func stagesSignatureRepoImageCommits(repoImage docker_registry.RepoImage, labels map[string]string, options CommonRepoOptions) ([]string, error) {
+	var commits []string
+	if commit := labels[image.WerfImageGitCommitLabel]; commit != "" {
+		commits = append(commits, commit)
+	}
+
+	if options.imagesArtifacts == nil {
+		return commits, nil
+	}
+
+	for _, recordTag := range options.imagesArtifacts.gitCommitRecordTags(repoImage) {
+		recordReference := strings.Join([]string{repoImage.Repository, recordTag}, ":")
+		configFile, err := docker_registry.ImageConfigFile(recordReference)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get git commit record %s: %s", recordReference, err)
+		}
+
+		if commit := configFile.Config.Labels[image.WerfImageGitCommitLabel]; commit != "" {
+			commits = append(commits, commit)
+		}
+	}
+
+	return commits, nil
+}
+
 func repoImageMetaTagMatch(imageMetaTag string, matches ...string) bool {
 	for _, match := range matches {
 		if imageMetaTag == slug.DockerTag(match) {
@@ -254,6 +401,10 @@ func repoImageMetaTagMatch(imageMetaTag string, matches ...string) bool {
 }
 
 func repoImagesCleanupByPolicies(repoImages []docker_registry.RepoImage, options ImagesCleanupOptions) ([]docker_registry.RepoImage, error) {
+	if len(options.Policies.KeepPolicies) != 0 {
+		return repoImagesCleanupByKeepPolicies(repoImages, options)
+	}
+
 	var repoImagesWithGitTagScheme, repoImagesWithGitCommitScheme []docker_registry.RepoImage
 
 	for _, repoImage := range repoImages {
@@ -307,6 +458,60 @@ func repoImagesCleanupByPolicies(repoImages []docker_registry.RepoImage, options
 	return repoImages, nil
 }
 
+func repoImagesCleanupByKeepPolicies(repoImages []docker_registry.RepoImage, options ImagesCleanupOptions) ([]docker_registry.RepoImage, error) {
+	var processedRepoImages []docker_registry.RepoImage
+	for _, policy := range options.Policies.KeepPolicies {
+		var repoImagesByPolicy []docker_registry.RepoImage
+
+		for _, repoImage := range exceptRepoImages(repoImages, processedRepoImages...) {
+			labels, err := repoImageLabels(repoImage)
+			if err != nil {
+				return nil, err
+			}
+
+			if keepPolicyMatchesRepoImage(policy, repoImage, labels) {
+				repoImagesByPolicy = append(repoImagesByPolicy, repoImage)
+			}
+		}
+
+		processedRepoImages = append(processedRepoImages, repoImagesByPolicy...)
+
+		cleanupByPolicyOptions := repoImagesCleanupByPolicyOptions{
+			hasLimit:          policy.HasLimit,
+			limit:             policy.Limit,
+			hasExpiryPeriod:   policy.HasExpiryPeriod,
+			expiryPeriod:      policy.ExpiryPeriod,
+			gitPrimitive:      strings.TrimPrefix(string(policy.TagStrategy), "git-"),
+			commonRepoOptions: options.CommonRepoOptions,
+		}
+
+		if policy.Regexp != nil {
+			cleanupByPolicyOptions.metaTagRegexp = policy.Regexp.String()
+		}
+
+		var err error
+		repoImages, err = repoImagesCleanupByPolicy(repoImages, repoImagesByPolicy, cleanupByPolicyOptions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return repoImages, nil
+}
+
+func keepPolicyMatchesRepoImage(policy ImagesCleanupKeepPolicy, repoImage docker_registry.RepoImage, labels map[string]string) bool {
+	if strategy, ok := labels[image.WerfTagStrategyLabel]; !ok || strategy != string(policy.TagStrategy) {
+		return false
+	}
+
+	repoImageMetaTag, ok := labels[image.WerfImageTagLabel]
+	if !ok { // legacy
+		repoImageMetaTag = repoImage.Tag
+	}
+
+	return policy.Regexp == nil || policy.Regexp.MatchString(repoImageMetaTag)
+}
+
 type repoImagesCleanupByPolicyOptions struct {
 	hasLimit        bool
 	limit           int64
@@ -314,9 +519,35 @@ type repoImagesCleanupByPolicyOptions struct {
 	expiryPeriod    time.Duration
 
 	gitPrimitive      string
+	metaTagRegexp     string
 	commonRepoOptions CommonRepoOptions
 }
 
+func (o repoImagesCleanupByPolicyOptions) policyName() string {
+	if o.metaTagRegexp != "" {
+		return fmt.Sprintf("git-%s %s", o.gitPrimitive, o.metaTagRegexp)
+	}
+
+	return fmt.Sprintf("git-%s", o.gitPrimitive)
+}
+
+func (o repoImagesCleanupByPolicyOptions) keepReason() string {
+	var conditions []string
+	if o.hasLimit {
+		conditions = append(conditions, fmt.Sprintf("within limit %d", o.limit))
+	}
+
+	if o.hasExpiryPeriod {
+		conditions = append(conditions, fmt.Sprintf("within expiry period %s", o.expiryPeriod))
+	}
+
+	if len(conditions) == 0 {
+		return fmt.Sprintf("git %s exists, %s policy has no limit and expiry period", o.gitPrimitive, o.policyName())
+	}
+
+	return fmt.Sprintf("git %s exists, %s by %s policy", o.gitPrimitive, strings.Join(conditions, " and "), o.policyName())
+}
+
 func repoImagesCleanupByPolicy(repoImages, repoImagesWithScheme []docker_registry.RepoImage, options repoImagesCleanupByPolicyOptions) ([]docker_registry.RepoImage, error) {
 	var expiryTime time.Time
 	if options.hasExpiryPeriod {
@@ -353,7 +584,9 @@ func repoImagesCleanupByPolicy(repoImages, repoImagesWithScheme []docker_registr
 
 	var err error
 	if len(expiredRepoImages) != 0 {
-		logBlockMessage := fmt.Sprintf("Removed tags by git-%s date policy (created before %s)", options.gitPrimitive, expiryTime.Format("2006-01-02T15:04:05-0700"))
+		logBlockMessage := fmt.Sprintf("Removed tags by %s date policy (created before %s)", options.policyName(), expiryTime.Format("2006-01-02T15:04:05-0700"))
+		reportReason := fmt.Sprintf("exceeded %s expiry period (created before %s)", options.policyName(), expiryTime.Format("2006-01-02T15:04:05-0700"))
+		options.commonRepoOptions.Report.addRepoImages(ReportObjectRepoImage, expiredRepoImages, ReportDecisionRemove, reportReason)
 		logboek.LogBlock(logBlockMessage, logboek.LogBlockOptions{}, func() {
 			err = repoImagesRemove(expiredRepoImages, options.commonRepoOptions)
 		})
@@ -368,7 +601,9 @@ func repoImagesCleanupByPolicy(repoImages, repoImagesWithScheme []docker_registr
 	if options.hasLimit && int64(len(notExpiredRepoImages)) > options.limit {
 		excessImagesByLimit := notExpiredRepoImages[:int64(len(notExpiredRepoImages))-options.limit]
 
-		logBlockMessage := fmt.Sprintf("Removed tags by git-%s limit policy (> %d)", options.gitPrimitive, options.limit)
+		logBlockMessage := fmt.Sprintf("Removed tags by %s limit policy (> %d)", options.policyName(), options.limit)
+		reportReason := fmt.Sprintf("exceeded %s limit (%d)", options.policyName(), options.limit)
+		options.commonRepoOptions.Report.addRepoImages(ReportObjectRepoImage, excessImagesByLimit, ReportDecisionRemove, reportReason)
 		logboek.LogBlock(logBlockMessage, logboek.LogBlockOptions{}, func() {
 			err = repoImagesRemove(excessImagesByLimit, options.commonRepoOptions)
 		})
@@ -380,6 +615,12 @@ func repoImagesCleanupByPolicy(repoImages, repoImagesWithScheme []docker_registr
 		repoImages = exceptRepoImages(repoImages, excessImagesByLimit...)
 	}
 
+	keptRepoImages := exceptRepoImages(repoImagesWithScheme, expiredRepoImages...)
+	if options.hasLimit && int64(len(notExpiredRepoImages)) > options.limit {
+		keptRepoImages = notExpiredRepoImages[int64(len(notExpiredRepoImages))-options.limit:]
+	}
+	options.commonRepoOptions.Report.addRepoImages(ReportObjectRepoImage, keptRepoImages, ReportDecisionKeep, options.keepReason())
+
 	return repoImages, nil
 }
 
@@ -0,0 +1,276 @@
+package cleaning
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flant/go-containerregistry/pkg/name"
+	"github.com/flant/go-containerregistry/pkg/registry"
+	v1 "github.com/flant/go-containerregistry/pkg/v1"
+	"github.com/flant/go-containerregistry/pkg/v1/mutate"
+	"github.com/flant/go-containerregistry/pkg/v1/random"
+	"github.com/flant/go-containerregistry/pkg/v1/remote"
+
+	"github.com/flant/werf/pkg/build"
+	"github.com/flant/werf/pkg/docker_registry"
+	"github.com/flant/werf/pkg/git_repo"
+	"github.com/flant/werf/pkg/image"
+	"github.com/flant/werf/pkg/tag_strategy"
+)
+
+func TestKeepPolicyMatchesRepoImage(t *testing.T) {
+	releasePolicy := ImagesCleanupKeepPolicy{TagStrategy: tag_strategy.GitBranch, Regexp: regexp.MustCompile("^(?:release-.*)$")}
+	tagPolicy := ImagesCleanupKeepPolicy{TagStrategy: tag_strategy.GitTag}
+
+	tests := []struct {
+		name     string
+		policy   ImagesCleanupKeepPolicy
+		tag      string
+		labels   map[string]string
+		expected bool
+	}{
+		{"matching meta tag", releasePolicy, "release-1-0", map[string]string{image.WerfTagStrategyLabel: "git-branch", image.WerfImageTagLabel: "release-1.0"}, true},
+		{"not matching meta tag", releasePolicy, "master", map[string]string{image.WerfTagStrategyLabel: "git-branch", image.WerfImageTagLabel: "master"}, false},
+		{"other strategy", releasePolicy, "release-1-0", map[string]string{image.WerfTagStrategyLabel: "git-tag", image.WerfImageTagLabel: "release-1.0"}, false},
+		{"without strategy label", tagPolicy, "v1.0", map[string]string{}, false},
+		{"legacy image without meta tag label", releasePolicy, "release-2", map[string]string{image.WerfTagStrategyLabel: "git-branch"}, true},
+		{"policy without regexp", tagPolicy, "v1-0", map[string]string{image.WerfTagStrategyLabel: "git-tag", image.WerfImageTagLabel: "v1.0"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoImage := docker_registry.RepoImage{Repository: "repo", Tag: tt.tag}
+			if res := keepPolicyMatchesRepoImage(tt.policy, repoImage, tt.labels); res != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, res)
+			}
+		})
+	}
+}
+
+func TestRepoImagesCleanupByNonexistentGitPrimitive_StagesSignature(t *testing.T) {
+	gitDir, err := ioutil.TempDir("", "werf-cleanup-git-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(gitDir)
+
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = gitDir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %s\n%s", args, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	commit := func(message string) string {
+		git("commit", "-q", "--allow-empty", "-m", message)
+		return git("rev-parse", "HEAD")
+	}
+
+	git("init", "-q")
+	firstMainCommit := commit("first")
+	mainHeadCommit := commit("second")
+	git("checkout", "-q", "-b", "feature", firstMainCommit)
+	featureCommit := commit("feature")
+	git("checkout", "-q", "-b", "release", firstMainCommit)
+	releaseCommit := commit("release")
+
+	// feature branch has been deleted from origin, but its commits are still in the local repository
+	git("update-ref", "refs/remotes/origin/main", mainHeadCommit)
+	git("tag", "v1.0", releaseCommit)
+
+	testRegistry := &testRegistry{handler: registry.New()}
+	server := httptest.NewServer(testRegistry)
+	defer server.Close()
+
+	if err := docker_registry.Init(docker_registry.Options{Implementation: docker_registry.ImplementationDefault}); err != nil {
+		t.Fatal(err)
+	}
+	defer docker_registry.Init(docker_registry.Options{})
+
+	repository := strings.TrimPrefix(server.URL, "http://") + "/project"
+
+	pushImage := func(tag, gitCommit string) {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		labels := map[string]string{
+			image.WerfImageLabel:       "true",
+			image.WerfTagStrategyLabel: string(tag_strategy.StagesSignature),
+		}
+		if gitCommit != "" {
+			labels[image.WerfImageGitCommitLabel] = gitCommit
+		}
+
+		img, err = mutate.Config(img, v1.Config{Labels: labels})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ref, err := name.ParseReference(repository+":"+tag, name.WeakValidation)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := remote.Write(ref, img); err != nil {
+			t.Fatal(err)
+		}
+
+		testRegistry.tags = append(testRegistry.tags, tag)
+	}
+
+	recordGitCommit := func(tag, gitCommit string) {
+		recordTag := build.GitCommitRecordTag(tag, gitCommit)
+		if err := docker_registry.PushArtifact(repository+":"+recordTag, map[string]string{image.WerfImageGitCommitLabel: gitCommit, "tag": tag}); err != nil {
+			t.Fatal(err)
+		}
+
+		testRegistry.tags = append(testRegistry.tags, recordTag)
+	}
+
+	// first publication commit is an ancestor of the main branch head which has the same content
+	pushImage("published-by-main-ancestor", firstMainCommit)
+	// published by the deleted feature branch first, the main branch head with the same content has been recorded
+	pushImage("published-by-feature-and-main", featureCommit)
+	recordGitCommit("published-by-feature-and-main", mainHeadCommit)
+	// published by the deleted feature branch only
+	pushImage("published-by-feature", featureCommit)
+	recordGitCommit("published-by-feature", featureCommit)
+	pushImage("published-by-tag", releaseCommit)
+	pushImage("published-by-unknown-commit", "0123456789012345678901234567890123456789")
+	pushImage("legacy", "")
+
+	var repoImages []docker_registry.RepoImage
+	for _, tag := range testRegistry.tags {
+		if strings.Contains(tag, ".git-") {
+			continue
+		}
+
+		repoImage, err := docker_registry.GetRepoImage(repository, tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		repoImages = append(repoImages, repoImage)
+	}
+
+	options := ImagesCleanupOptions{
+		CommonRepoOptions: CommonRepoOptions{DryRun: true, Report: NewReport(true)},
+		LocalGit:          &git_repo.Local{Path: gitDir, GitDir: filepath.Join(gitDir, ".git")},
+	}
+	options.CommonRepoOptions.imagesArtifacts, err = newImagesArtifacts(repoImages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keptRepoImages, err := repoImagesCleanupByNonexistentGitPrimitive(repoImages, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keptTags []string
+	for _, repoImage := range keptRepoImages {
+		keptTags = append(keptTags, repoImage.Tag)
+	}
+	sort.Strings(keptTags)
+
+	expectedKeptTags := []string{"legacy", "published-by-feature-and-main", "published-by-main-ancestor", "published-by-tag"}
+	if strings.Join(keptTags, ",") != strings.Join(expectedKeptTags, ",") {
+		t.Errorf("expected kept tags %v, got %v", expectedKeptTags, keptTags)
+	}
+
+	for _, reference := range []string{
+		repository + ":published-by-feature",
+		repository + ":published-by-unknown-commit",
+	} {
+		if !options.CommonRepoOptions.Report.hasRecord(ReportObjectRepoImage, reference) {
+			t.Errorf("expected %s to be removed", reference)
+		}
+	}
+
+	recordReference := repository + ":" + build.GitCommitRecordTag("published-by-feature", featureCommit)
+	if !options.CommonRepoOptions.Report.hasRecord(ReportObjectRepoImageArtifact, recordReference) {
+		t.Errorf("expected git commit record %s to be removed with the image tag", recordReference)
+	}
+}
+
+func TestRepoImagesCleanupByPolicy_ReportReasons(t *testing.T) {
+	newRepoImage := func(tag string, created time.Time) docker_registry.RepoImage {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		img, err = mutate.CreatedAt(img, v1.Time{Time: created})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return docker_registry.RepoImage{Repository: "registry.example.com/app", Tag: tag, Image: img}
+	}
+
+	now := time.Now()
+	expired := newRepoImage("release-1", now.Add(-72*time.Hour))
+	excess := newRepoImage("release-2", now.Add(-3*time.Hour))
+	kept1 := newRepoImage("release-3", now.Add(-2*time.Hour))
+	kept2 := newRepoImage("release-4", now.Add(-time.Hour))
+	repoImages := []docker_registry.RepoImage{kept2, expired, kept1, excess}
+
+	report := NewReport(true)
+	options := repoImagesCleanupByPolicyOptions{
+		hasLimit:          true,
+		limit:             2,
+		hasExpiryPeriod:   true,
+		expiryPeriod:      24 * time.Hour,
+		gitPrimitive:      "branch",
+		metaTagRegexp:     "^release-.*",
+		commonRepoOptions: CommonRepoOptions{DryRun: true, Report: report},
+	}
+
+	res, err := repoImagesCleanupByPolicy(repoImages, append([]docker_registry.RepoImage{}, repoImages...), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 2 {
+		t.Errorf("expected 2 kept images, got %+v", res)
+	}
+
+	reasons := map[string]string{}
+	decisions := map[string]ReportDecision{}
+	for _, record := range report.Records {
+		reasons[record.Reference] = record.Reason
+		decisions[record.Reference] = record.Decision
+	}
+
+	reference := func(repoImage docker_registry.RepoImage) string {
+		return repoImage.Repository + ":" + repoImage.Tag
+	}
+
+	if decisions[reference(expired)] != ReportDecisionRemove || !strings.HasPrefix(reasons[reference(expired)], "exceeded git-branch ^release-.* expiry period") {
+		t.Errorf("unexpected record for expired image: %s %q", decisions[reference(expired)], reasons[reference(expired)])
+	}
+
+	if decisions[reference(excess)] != ReportDecisionRemove || reasons[reference(excess)] != "exceeded git-branch ^release-.* limit (2)" {
+		t.Errorf("unexpected record for excess image: %s %q", decisions[reference(excess)], reasons[reference(excess)])
+	}
+
+	expectedKeepReason := "git branch exists, within limit 2 and within expiry period 24h0m0s by git-branch ^release-.* policy"
+	for _, repoImage := range []docker_registry.RepoImage{kept1, kept2} {
+		if decisions[reference(repoImage)] != ReportDecisionKeep || reasons[reference(repoImage)] != expectedKeepReason {
+			t.Errorf("unexpected record for %s: %s %q", repoImage.Tag, decisions[reference(repoImage)], reasons[reference(repoImage)])
+		}
+	}
+}
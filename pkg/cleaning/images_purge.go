@@ -27,6 +27,11 @@ func imagesPurge(options ImagesPurgeOptions) error {
 		return err
 	}
 
+	commonRepoOptions.imagesArtifacts, err = newImagesArtifacts(imageImages)
+	if err != nil {
+		return err
+	}
+
 	err = repoImagesRemove(imageImages, commonRepoOptions)
 	if err != nil {
 		return err
@@ -0,0 +1,150 @@
+package cleaning
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+
+	"github.com/flant/werf/pkg/build"
+	"github.com/flant/werf/pkg/docker_registry"
+)
+
+type ReportFormat string
+
+const (
+	ReportFormatJson ReportFormat = "json"
+)
+
+type ReportDecision string
+
+const (
+	ReportDecisionKeep   ReportDecision = "keep"
+	ReportDecisionRemove ReportDecision = "remove"
+)
+
+type ReportObjectType string
+
+const (
+	ReportObjectRepoImage         ReportObjectType = "repo-image"
+	ReportObjectRepoImageArtifact ReportObjectType = "repo-image-artifact"
+	ReportObjectRepoStage         ReportObjectType = "repo-stage"
+	ReportObjectStage             ReportObjectType = "stage"
+	ReportObjectImage             ReportObjectType = "image"
+	ReportObjectContainer         ReportObjectType = "container"
+	ReportObjectVolume            ReportObjectType = "volume"
+)
+
+type ReportRecord struct {
+	Type      ReportObjectType `json:"type"`
+	Reference string           `json:"reference"`
+	Decision  ReportDecision   `json:"decision"`
+	Reason    string           `json:"reason"`
+}
+
+// Report collects cleanup decisions, nil report ignores all records
+type Report struct {
+	DryRun  bool            `json:"dryRun"`
+	Records []*ReportRecord `json:"records"`
+
+	// Incomplete report contains decisions made before the cleanup has failed with Error
+	Incomplete bool   `json:"incomplete,omitempty"`
+	Error      string `json:"error,omitempty"`
+
+	recordByKey map[reportRecordKey]*ReportRecord
+}
+
+type reportRecordKey struct {
+	Type      ReportObjectType
+	Reference string
+}
+
+func NewReport(dryRun bool) *Report {
+	return &Report{DryRun: dryRun, Records: []*ReportRecord{}, recordByKey: map[reportRecordKey]*ReportRecord{}}
+}
+
+func (r *Report) WriteFile(path string, format ReportFormat) error {
+	var data []byte
+	var err error
+
+	switch format {
+	case ReportFormatJson:
+		data, err = json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	default:
+		return fmt.Errorf("unsupported report format %s", format)
+	}
+
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("unable to write report %s: %s", path, err)
+	}
+
+	return nil
+}
+
+func (r *Report) MarkIncomplete(err error) {
+	if r == nil {
+		return
+	}
+
+	r.Incomplete = true
+	r.Error = err.Error()
+}
+
+func (r *Report) add(objectType ReportObjectType, reference string, decision ReportDecision, reason string) {
+	if r == nil {
+		return
+	}
+
+	if r.hasRecord(objectType, reference) {
+		return
+	}
+
+	if r.recordByKey == nil {
+		r.recordByKey = map[reportRecordKey]*ReportRecord{}
+	}
+
+	record := &ReportRecord{Type: objectType, Reference: reference, Decision: decision, Reason: reason}
+	r.Records = append(r.Records, record)
+	r.recordByKey[reportRecordKey{Type: objectType, Reference: reference}] = record
+}
+
+func (r *Report) hasRecord(objectType ReportObjectType, reference string) bool {
+	if r == nil {
+		return false
+	}
+
+	_, exist := r.recordByKey[reportRecordKey{Type: objectType, Reference: reference}]
+	return exist
+}
+
+func (r *Report) addRepoImages(objectType ReportObjectType, repoImages []docker_registry.RepoImage, decision ReportDecision, reason string) {
+	for _, repoImage := range repoImages {
+		r.add(objectType, repoImageReference(repoImage), decision, reason)
+	}
+}
+
+func (r *Report) addImages(objectType ReportObjectType, images []types.ImageSummary, decision ReportDecision, reason string) {
+	for _, img := range images {
+		r.add(objectType, logImageName(img), decision, reason)
+	}
+}
+
+func repoImageReference(repoImage docker_registry.RepoImage) string {
+	return strings.Join([]string{repoImage.Repository, repoImage.Tag}, ":")
+}
+
+func reportObjectTypeByImage(img types.ImageSummary) ReportObjectType {
+	for _, repoTag := range img.RepoTags {
+		if strings.HasPrefix(repoTag, fmt.Sprintf(build.LocalImageStageImageNameFormat, "")) {
+			return ReportObjectStage
+		}
+	}
+
+	return ReportObjectImage
+}
@@ -0,0 +1,121 @@
+package cleaning
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flant/werf/pkg/docker_registry"
+)
+
+func TestReport_KeepsFirstDecision(t *testing.T) {
+	r := NewReport(true)
+
+	r.add(ReportObjectRepoImage, "repo:tag", ReportDecisionKeep, "used in kubernetes")
+	r.add(ReportObjectRepoImage, "repo:tag", ReportDecisionRemove, "git-commit limit policy")
+	r.add(ReportObjectRepoStage, "repo:tag", ReportDecisionRemove, "unused stage")
+
+	if len(r.Records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(r.Records))
+	}
+
+	if r.Records[0].Decision != ReportDecisionKeep {
+		t.Errorf("expected the first decision to be kept, got %s", r.Records[0].Decision)
+	}
+
+	if !r.hasRecord(ReportObjectRepoStage, "repo:tag") || r.hasRecord(ReportObjectStage, "repo:tag") {
+		t.Errorf("unexpected records: %+v", r.Records)
+	}
+}
+
+func TestReport_Nil(t *testing.T) {
+	var r *Report
+
+	r.add(ReportObjectImage, "image", ReportDecisionRemove, "")
+	r.addRepoImages(ReportObjectRepoImage, []docker_registry.RepoImage{{Repository: "repo", Tag: "tag"}}, ReportDecisionRemove, "")
+
+	if r.hasRecord(ReportObjectImage, "image") {
+		t.Errorf("nil report should not have records")
+	}
+}
+
+func TestReport_ManyRecords(t *testing.T) {
+	r := NewReport(false)
+
+	for i := 0; i < 100000; i++ {
+		r.add(ReportObjectRepoImage, fmt.Sprintf("repo:%d", i), ReportDecisionRemove, "")
+		r.add(ReportObjectRepoImage, fmt.Sprintf("repo:%d", i), ReportDecisionKeep, "")
+	}
+
+	if len(r.Records) != 100000 {
+		t.Errorf("expected 100000 records, got %d", len(r.Records))
+	}
+}
+
+func TestReport_WriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "werf-cleanup-report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := NewReport(true)
+	r.addRepoImages(ReportObjectRepoImage, []docker_registry.RepoImage{{Repository: "registry.example.com/app", Tag: "v1"}}, ReportDecisionRemove, "git-tag limit policy")
+
+	path := filepath.Join(dir, "report.json")
+	if err := r.WriteFile(path, ReportFormatJson); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res struct {
+		DryRun  bool                `json:"dryRun"`
+		Records []map[string]string `json:"records"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		t.Fatalf("bad report json: %s\n%s", err, data)
+	}
+
+	expected := map[string]string{"type": "repo-image", "reference": "registry.example.com/app:v1", "decision": "remove", "reason": "git-tag limit policy"}
+	if !res.DryRun || len(res.Records) != 1 || fmt.Sprint(res.Records[0]) != fmt.Sprint(expected) {
+		t.Errorf("unexpected report: %s", data)
+	}
+
+	if err := r.WriteFile(path, "yaml"); err == nil {
+		t.Errorf("expected error for unsupported format")
+	}
+}
+
+func TestReport_MarkIncomplete(t *testing.T) {
+	r := NewReport(false)
+	r.add(ReportObjectRepoImage, "repo:v1", ReportDecisionRemove, "git-tag limit policy")
+	r.MarkIncomplete(fmt.Errorf("registry is unavailable"))
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res struct {
+		Incomplete bool                `json:"incomplete"`
+		Error      string              `json:"error"`
+		Records    []map[string]string `json:"records"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.Incomplete || res.Error != "registry is unavailable" || len(res.Records) != 1 {
+		t.Errorf("unexpected report: %s", data)
+	}
+
+	var nilReport *Report
+	nilReport.MarkIncomplete(fmt.Errorf("error"))
+}
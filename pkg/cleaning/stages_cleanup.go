@@ -12,10 +12,15 @@ import (
 	"github.com/flant/werf/pkg/build"
 	"github.com/flant/werf/pkg/docker_registry"
 	"github.com/flant/werf/pkg/image"
-	"github.com/flant/shluz"
+	"github.com/flant/werf/pkg/lock_manager"
 )
 
-const stagesCleanupDefaultIgnorePeriodPolicy = 2 * 60 * 60
+const (
+	stagesCleanupDefaultIgnorePeriodPolicy = 2 * 60 * 60
+
+	reportReasonStageNotLinked    = "stage not linked to any image"
+	reportReasonStageIgnorePeriod = "stage created less than 2 hours ago"
+)
 
 type StagesCleanupOptions struct {
 	ProjectName       string
@@ -23,6 +28,7 @@ type StagesCleanupOptions struct {
 	StagesStorage     string
 	ImagesNames       []string
 	DryRun            bool
+	Report            *Report
 }
 
 func StagesCleanup(options StagesCleanupOptions) error {
@@ -40,6 +46,7 @@ func stagesCleanup(options StagesCleanupOptions) error {
 			RmiForce:       false,
 			RmForce:        false,
 			DryRun:         options.DryRun,
+			Report:         options.Report,
 		},
 	}
 
@@ -48,37 +55,61 @@ func stagesCleanup(options StagesCleanupOptions) error {
 		StagesStorage:     options.StagesStorage,
 		ImagesNames:       options.ImagesNames,
 		DryRun:            options.DryRun,
+		Report:            options.Report,
 	}
 
 	projectStagesCleanupLockName := fmt.Sprintf("stages-cleanup.%s", commonProjectOptions.ProjectName)
-	return shluz.WithLock(projectStagesCleanupLockName, shluz.LockOptions{Timeout: time.Second * 600}, func() error {
+	return lock_manager.WithLock(projectStagesCleanupLockName, lock_manager.LockOptions{Timeout: time.Second * 600}, func() error {
 		repoImages, err := repoImages(commonRepoOptions)
 		if err != nil {
 			return err
 		}
 
-		if len(repoImages) != 0 {
-			if commonRepoOptions.StagesStorage == localStagesStorage {
-				if err := projectImageStagesSyncByRepoImages(repoImages, commonProjectOptions); err != nil {
-					return err
-				}
-			} else {
-				if err := repoImageStagesSyncByRepoImages(repoImages, commonRepoOptions); err != nil {
-					return err
-				}
-			}
-		} else {
-			if err := projectStagesPurge(commonProjectOptions); err != nil {
-				return err
+		if commonRepoOptions.StagesStorage == build.LocalStagesStorage {
+			if len(repoImages) != 0 {
+				return projectImageStagesSyncByRepoImages(repoImages, commonProjectOptions)
 			}
+
+			return projectImageStagesRemoveNotLinked(commonProjectOptions)
 		}
 
-		return nil
+		if len(repoImages) != 0 {
+			return repoImageStagesSyncByRepoImages(repoImages, commonProjectOptions.ProjectName, commonRepoOptions)
+		}
+
+		return repoImageStagesRemoveNotLinked(commonProjectOptions.ProjectName, commonRepoOptions)
 	})
 }
 
-func repoImageStagesSyncByRepoImages(repoImages []docker_registry.RepoImage, options CommonRepoOptions) error {
-	repoImageStages, err := repoImageStagesImages(options)
+func projectImageStagesRemoveNotLinked(options CommonProjectOptions) error {
+	imageStages, err := projectImageStages(options)
+	if err != nil {
+		return err
+	}
+
+	imageStages, err = processUsedImages(imageStages, options.CommonOptions)
+	if err != nil {
+		return err
+	}
+
+	options.CommonOptions.Report.addImages(ReportObjectStage, imageStages, ReportDecisionRemove, reportReasonStageNotLinked)
+
+	return imagesRemove(imageStages, options.CommonOptions)
+}
+
+func repoImageStagesRemoveNotLinked(projectName string, options CommonRepoOptions) error {
+	repoImageStages, err := projectRepoImageStages(projectName, options)
+	if err != nil {
+		return err
+	}
+
+	options.Report.addRepoImages(ReportObjectRepoStage, repoImageStages, ReportDecisionRemove, reportReasonStageNotLinked)
+
+	return repoImagesRemove(repoImageStages, options)
+}
+
+func repoImageStagesSyncByRepoImages(repoImages []docker_registry.RepoImage, projectName string, options CommonRepoOptions) error {
+	repoImageStages, err := projectRepoImageStages(projectName, options)
 	if err != nil {
 		return err
 	}
@@ -93,12 +124,32 @@ func repoImageStagesSyncByRepoImages(repoImages []docker_registry.RepoImage, opt
 			return err
 		}
 
+		linkedRepoImageStages := repoImageStages
 		repoImageStages, err = exceptRepoImageStagesByImageId(repoImageStages, parentId)
 		if err != nil {
 			return err
 		}
+
+		linkedRepoImageStages = exceptRepoImages(linkedRepoImageStages, repoImageStages...)
+		options.Report.addRepoImages(ReportObjectRepoStage, linkedRepoImageStages, ReportDecisionKeep, fmt.Sprintf("linked to image %s", repoImageReference(repoImage)))
+	}
+
+	if os.Getenv("WERF_DISABLE_STAGES_CLEANUP_DATE_PERIOD_POLICY") == "" {
+		for _, repoImageStage := range repoImageStages {
+			created, err := repoImageCreated(repoImageStage)
+			if err != nil {
+				return err
+			}
+
+			if time.Now().Unix()-created.Unix() < stagesCleanupDefaultIgnorePeriodPolicy {
+				options.Report.addRepoImages(ReportObjectRepoStage, []docker_registry.RepoImage{repoImageStage}, ReportDecisionKeep, reportReasonStageIgnorePeriod)
+				repoImageStages = exceptRepoImages(repoImageStages, repoImageStage)
+			}
+		}
 	}
 
+	options.Report.addRepoImages(ReportObjectRepoStage, repoImageStages, ReportDecisionRemove, reportReasonStageNotLinked)
+
 	err = repoImagesRemove(repoImageStages, options)
 	if err != nil {
 		return err
@@ -241,15 +292,22 @@ func projectImageStagesSyncByRepoImages(repoImages []docker_registry.RepoImage,
 			return err
 		}
 
+		linkedImageStages := imageStages
 		imageStages, err = exceptImageStagesByImageId(imageStages, parentId, options)
 		if err != nil {
 			return err
 		}
+
+		for _, imageStage := range imageStages {
+			linkedImageStages = exceptImage(linkedImageStages, imageStage)
+		}
+		options.CommonOptions.Report.addImages(ReportObjectStage, linkedImageStages, ReportDecisionKeep, fmt.Sprintf("linked to image %s", repoImageReference(repoImage)))
 	}
 
 	if os.Getenv("WERF_DISABLE_STAGES_CLEANUP_DATE_PERIOD_POLICY") == "" {
 		for _, imageStage := range imageStages {
 			if time.Now().Unix()-imageStage.Created < stagesCleanupDefaultIgnorePeriodPolicy {
+				options.CommonOptions.Report.addImages(ReportObjectStage, []types.ImageSummary{imageStage}, ReportDecisionKeep, reportReasonStageIgnorePeriod)
 				imageStages = exceptImage(imageStages, imageStage)
 			}
 		}
@@ -260,6 +318,8 @@ func projectImageStagesSyncByRepoImages(repoImages []docker_registry.RepoImage,
 		return err
 	}
 
+	options.CommonOptions.Report.addImages(ReportObjectStage, imageStages, ReportDecisionRemove, reportReasonStageNotLinked)
+
 	err = imagesRemove(imageStages, options.CommonOptions)
 	if err != nil {
 		return err
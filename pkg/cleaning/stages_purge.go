@@ -1,11 +1,18 @@
 package cleaning
 
 import (
+	"strings"
+
 	"github.com/flant/logboek"
+
+	"github.com/flant/werf/pkg/build"
+	"github.com/flant/werf/pkg/docker_registry"
+	"github.com/flant/werf/pkg/image"
 )
 
 type StagesPurgeOptions struct {
 	ProjectName                   string
+	StagesStorage                 string
 	DryRun                        bool
 	RmContainersThatUseWerfImages bool
 }
@@ -31,6 +38,17 @@ func stagesPurge(options StagesPurgeOptions) error {
 		return err
 	}
 
+	if options.StagesStorage != build.LocalStagesStorage {
+		commonRepoOptions := CommonRepoOptions{
+			StagesStorage: options.StagesStorage,
+			DryRun:        options.DryRun,
+		}
+
+		if err := repoStagesPurge(options.ProjectName, commonRepoOptions); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -41,3 +59,72 @@ func projectStagesPurge(options CommonProjectOptions) error {
 
 	return nil
 }
+
+func repoStagesPurge(projectName string, options CommonRepoOptions) error {
+	repoImageStages, err := projectRepoImageStages(projectName, options)
+	if err != nil {
+		return err
+	}
+
+	repoBuildKitCacheImages, err := projectRepoBuildKitCacheImages(projectName, options)
+	if err != nil {
+		return err
+	}
+
+	return repoImagesRemove(append(repoImageStages, repoBuildKitCacheImages...), options)
+}
+
+// projectRepoBuildKitCacheImages returns build cache exported by BuildKit into stages storage.
+// The cache without werf labels cannot be bound to a project and is removed too
+func projectRepoBuildKitCacheImages(projectName string, options CommonRepoOptions) ([]docker_registry.RepoImage, error) {
+	tags, err := docker_registry.Tags(options.StagesStorage)
+	if err != nil {
+		return nil, err
+	}
+
+	var repoImages []docker_registry.RepoImage
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, image.BuildKitCacheTagPrefix) {
+			continue
+		}
+
+		repoImage, err := docker_registry.GetRepoImage(options.StagesStorage, tag)
+		if err != nil {
+			return nil, err
+		}
+
+		labels, err := repoImageLabels(repoImage)
+		if err != nil {
+			return nil, err
+		}
+
+		if project, ok := labels[image.WerfLabel]; ok && project != projectName {
+			continue
+		}
+
+		repoImages = append(repoImages, repoImage)
+	}
+
+	return repoImages, nil
+}
+
+func projectRepoImageStages(projectName string, options CommonRepoOptions) ([]docker_registry.RepoImage, error) {
+	repoImageStages, err := repoImageStagesImages(options)
+	if err != nil {
+		return nil, err
+	}
+
+	var projectRepoImageStages []docker_registry.RepoImage
+	for _, repoImageStage := range repoImageStages {
+		labels, err := repoImageLabels(repoImageStage)
+		if err != nil {
+			return nil, err
+		}
+
+		if labels[image.WerfLabel] == projectName {
+			projectRepoImageStages = append(projectRepoImageStages, repoImageStage)
+		}
+	}
+
+	return projectRepoImageStages, nil
+}
@@ -0,0 +1,93 @@
+package cleaning
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/flant/go-containerregistry/pkg/name"
+	"github.com/flant/go-containerregistry/pkg/registry"
+	v1 "github.com/flant/go-containerregistry/pkg/v1"
+	"github.com/flant/go-containerregistry/pkg/v1/mutate"
+	"github.com/flant/go-containerregistry/pkg/v1/random"
+	"github.com/flant/go-containerregistry/pkg/v1/remote"
+
+	"github.com/flant/werf/pkg/docker_registry"
+	"github.com/flant/werf/pkg/image"
+	"github.com/flant/werf/pkg/werf"
+)
+
+func TestRepoStagesPurge_BuildKitCache(t *testing.T) {
+	werfHome, err := ioutil.TempDir("", "werf-home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(werfHome)
+
+	if err := werf.Init(werfHome, werfHome); err != nil {
+		t.Fatal(err)
+	}
+
+	testRegistry := &testRegistry{handler: registry.New()}
+	server := httptest.NewServer(testRegistry)
+	defer server.Close()
+
+	if err := docker_registry.Init(docker_registry.Options{Implementation: docker_registry.ImplementationDefault}); err != nil {
+		t.Fatal(err)
+	}
+	defer docker_registry.Init(docker_registry.Options{})
+
+	stagesStorage := strings.TrimPrefix(server.URL, "http://") + "/stages"
+
+	pushImage := func(tag string, labels map[string]string) string {
+		img, err := random.Image(1024, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		img, err = mutate.Config(img, v1.Config{Labels: labels})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ref, err := name.ParseReference(stagesStorage+":"+tag, name.WeakValidation)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := remote.Write(ref, img); err != nil {
+			t.Fatal(err)
+		}
+
+		digest, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		testRegistry.tags = append(testRegistry.tags, tag)
+
+		return digest.String()
+	}
+
+	expectedDeletedDigests := []string{
+		pushImage("image-stage-signature", map[string]string{image.WerfLabel: "project", image.WerfImageLabel: "false"}),
+		pushImage(image.BuildKitCacheTagPrefix+"image", map[string]string{image.WerfLabel: "project", image.WerfImageLabel: "false"}),
+		pushImage(image.BuildKitCacheTagPrefix+"cache", nil),
+	}
+	sort.Strings(expectedDeletedDigests)
+
+	pushImage("other-image-stage-signature", map[string]string{image.WerfLabel: "other", image.WerfImageLabel: "false"})
+	pushImage(image.BuildKitCacheTagPrefix+"other-image", map[string]string{image.WerfLabel: "other", image.WerfImageLabel: "false"})
+
+	options := CommonRepoOptions{StagesStorage: stagesStorage, Report: NewReport(false)}
+	if err := repoStagesPurge("project", options); err != nil {
+		t.Fatal(err)
+	}
+
+	if deletedDigests := testRegistry.resetDeletedDigests(); strings.Join(deletedDigests, ",") != strings.Join(expectedDeletedDigests, ",") {
+		t.Errorf("expected deleted digests %v, got %v", expectedDeletedDigests, deletedDigests)
+	}
+}
@@ -0,0 +1,20 @@
+package config
+
+import "regexp"
+
+const (
+	CleanupReferencesTag    = "tag"
+	CleanupReferencesBranch = "branch"
+	CleanupReferencesCommit = "commit"
+)
+
+type Cleanup struct {
+	KeepPolicies []*CleanupKeepPolicy
+}
+
+type CleanupKeepPolicy struct {
+	References string
+	Regexp     *regexp.Regexp // nil matches all references
+	Limit      *int64
+	ExpiryDays *int64
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+func parseMetaForTest(content string) (*Meta, error) {
+	docs, err := splitByDocs(content, "werf.yaml")
+	if err != nil {
+		return nil, err
+	}
+
+	meta, _, _, err := splitByMetaAndRawImages(docs)
+	return meta, err
+}
+
+type cleanupKeepPolicyEntry struct {
+	references string
+	regexp     string
+	limit      *int64
+	expiryDays *int64
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+var _ = DescribeTable("parsing cleanup keep policies", func(cleanupSection string, expected []cleanupKeepPolicyEntry) {
+	meta, err := parseMetaForTest("configVersion: 1\nproject: test\n" + cleanupSection)
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(meta.Cleanup.KeepPolicies).Should(HaveLen(len(expected)))
+
+	for ind, policy := range meta.Cleanup.KeepPolicies {
+		Ω(policy.References).Should(Equal(expected[ind].references))
+		Ω(policy.Limit).Should(Equal(expected[ind].limit))
+		Ω(policy.ExpiryDays).Should(Equal(expected[ind].expiryDays))
+
+		if expected[ind].regexp == "" {
+			Ω(policy.Regexp).Should(BeNil())
+		} else {
+			Ω(policy.Regexp.String()).Should(Equal(expected[ind].regexp))
+		}
+	}
+},
+	Entry("without cleanup section", "", nil),
+	Entry("policies keep order", `cleanup:
+  keepPolicies:
+  - references: tag
+    limit: 10
+  - references: branch
+    regexp: "release-.*"
+    expiryDays: 30
+  - references: commit
+    limit: 5
+    expiryDays: 7
+`, []cleanupKeepPolicyEntry{
+		{references: CleanupReferencesTag, limit: int64Ptr(10)},
+		{references: CleanupReferencesBranch, regexp: "^(?:release-.*)$", expiryDays: int64Ptr(30)},
+		{references: CleanupReferencesCommit, limit: int64Ptr(5), expiryDays: int64Ptr(7)},
+	}),
+)
+
+var _ = DescribeTable("validating cleanup keep policies", func(cleanupSection string, expectedErrSubstring string) {
+	_, err := parseMetaForTest("configVersion: 1\nproject: test\n" + cleanupSection)
+	Ω(err).Should(HaveOccurred())
+	Ω(err.Error()).Should(ContainSubstring(expectedErrSubstring))
+},
+	Entry("without references", "cleanup:\n  keepPolicies:\n  - limit: 1\n", "references field"),
+	Entry("unknown references", "cleanup:\n  keepPolicies:\n  - references: pr\n    limit: 1\n", "invalid references `pr`"),
+	Entry("bad regexp", "cleanup:\n  keepPolicies:\n  - references: tag\n    regexp: \"(\"\n    limit: 1\n", "invalid regexp"),
+	Entry("without limit and expiryDays", "cleanup:\n  keepPolicies:\n  - references: tag\n", "`limit: N` or `expiryDays: N` required"),
+	Entry("negative limit", "cleanup:\n  keepPolicies:\n  - references: tag\n    limit: -1\n", "limit field cannot be negative"),
+	Entry("negative expiryDays", "cleanup:\n  keepPolicies:\n  - references: tag\n    expiryDays: -1\n", "expiryDays field cannot be negative"),
+	Entry("unknown field", "cleanup:\n  keepPolicies:\n  - references: tag\n    limit: 1\n    days: 1\n", "days"),
+)
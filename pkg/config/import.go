@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+
+	"github.com/flant/go-containerregistry/pkg/name"
 )
 
 type Import struct {
@@ -11,6 +13,12 @@ type Import struct {
 	Before       string
 	After        string
 
+	// ExternalImage is an image reference pinned by digest (REPO@sha256:DIGEST)
+	ExternalImage string
+
+	// ContentChecksum makes dependent stages signature depend on the imported files instead of the source image signature
+	ContentChecksum bool
+
 	raw *rawImport
 }
 
@@ -23,10 +31,21 @@ func (c *Import) validate() error {
 		return err
 	}
 
-	if c.ArtifactName == "" && c.ImageName == "" {
-		return newDetailedConfigError("artifact name `artifact: NAME` or image name `image: NAME` required for import!", c.raw, c.raw.rawStapelImage.doc)
-	} else if c.ArtifactName != "" && c.ImageName != "" {
-		return newDetailedConfigError("specify only one artifact name using `artifact: NAME` or image name using `image: NAME` for import!", c.raw, c.raw.rawStapelImage.doc)
+	sourcesNumber := 0
+	for _, source := range []string{c.ArtifactName, c.ImageName, c.ExternalImage} {
+		if source != "" {
+			sourcesNumber++
+		}
+	}
+
+	if sourcesNumber == 0 {
+		return newDetailedConfigError("artifact name `artifact: NAME`, image name `image: NAME` or external image `externalImage: REPO@sha256:DIGEST` required for import!", c.raw, c.raw.rawStapelImage.doc)
+	} else if sourcesNumber > 1 {
+		return newDetailedConfigError("specify only one artifact name using `artifact: NAME`, image name using `image: NAME` or external image using `externalImage: REPO@sha256:DIGEST` for import!", c.raw, c.raw.rawStapelImage.doc)
+	} else if c.ExternalImage != "" && !isImageReferencePinnedByDigest(c.ExternalImage) {
+		return newDetailedConfigError(fmt.Sprintf("external image `%s` should be pinned by digest `externalImage: REPO@sha256:DIGEST` for import!", c.ExternalImage), c.raw, c.raw.rawStapelImage.doc)
+	} else if c.ExternalImage != "" && c.ContentChecksum {
+		return newDetailedConfigError("`contentChecksum: true` cannot be used with `externalImage` for import: external image content is already pinned by digest!", c.raw, c.raw.rawStapelImage.doc)
 	} else if c.Before != "" && c.After != "" {
 		return newDetailedConfigError("specify only one artifact stage using `before: install|setup` or `after: install|setup` for import!", c.raw, c.raw.rawStapelImage.doc)
 	} else if c.Before == "" && c.After == "" {
@@ -39,6 +58,11 @@ func (c *Import) validate() error {
 	return nil
 }
 
+func isImageReferencePinnedByDigest(reference string) bool {
+	_, err := name.NewDigest(reference, name.WeakValidation)
+	return err == nil
+}
+
 func checkInvalidRelation(rel string) bool {
 	return !(rel == "install" || rel == "setup")
 }
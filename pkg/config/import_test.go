@@ -0,0 +1,16 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = DescribeTable("checking external image reference is pinned by digest", func(reference string, expected bool) {
+	Ω(isImageReferencePinnedByDigest(reference)).Should(Equal(expected))
+},
+	Entry("digest", "golang@sha256:4f5b8d6e8a5c1e0dbd4e1c4a6c3e4b0a6ac7cbbb3e6c5d5a5ef0de3b1a7f6e2c", true),
+	Entry("registry and digest", "registry.example.com:5000/vendor/tool@sha256:4f5b8d6e8a5c1e0dbd4e1c4a6c3e4b0a6ac7cbbb3e6c5d5a5ef0de3b1a7f6e2c", true),
+	Entry("tag", "golang:1.13", false),
+	Entry("without tag", "docker.io/library/golang", false),
+	Entry("bad digest", "golang@sha256:123", false),
+)
@@ -4,4 +4,5 @@ type Meta struct {
 	ConfigVersion   int
 	Project         string
 	DeployTemplates DeployTemplates
+	Cleanup         Cleanup
 }
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"regexp"
 )
 
 type Mount struct {
@@ -9,6 +10,9 @@ type Mount struct {
 	From string
 	Type string
 
+	// ID is an identifier of the project cache for `cache` mount type
+	ID string
+
 	raw *rawMount
 }
 
@@ -19,8 +23,21 @@ func (c *Mount) validate() error {
 		if c.From == "" {
 			return newDetailedConfigError("`fromPath: PATH` absolute or relative path required for mount!", c.raw, c.raw.rawStapelImage.doc)
 		}
+	} else if c.Type == "cache" {
+		if c.ID == "" {
+			return newDetailedConfigError("`id: ID` required for cache mount!", c.raw, c.raw.rawStapelImage.doc)
+		} else if !mountIDRegexp.MatchString(c.ID) {
+			return newDetailedConfigError(fmt.Sprintf("invalid `id: %s` for cache mount: expected lowercase letters, digits, `.`, `_` and `-` (e.g. `apt`, `npm` or `go-mod`)!", c.ID), c.raw, c.raw.rawStapelImage.doc)
+		}
 	} else if c.Type != "tmp_dir" && c.Type != "build_dir" {
-		return newDetailedConfigError(fmt.Sprintf("invalid `from: %s` for mount: expected `tmp_dir` or `build_dir`!", c.Type), c.raw, c.raw.rawStapelImage.doc)
+		return newDetailedConfigError(fmt.Sprintf("invalid `from: %s` for mount: expected `tmp_dir`, `build_dir` or `cache`!", c.Type), c.raw, c.raw.rawStapelImage.doc)
+	}
+
+	if c.Type != "cache" && c.ID != "" {
+		return newDetailedConfigError("`id: ID` can be used only for cache mount `from: cache`!", c.raw, c.raw.rawStapelImage.doc)
 	}
+
 	return nil
 }
+
+var mountIDRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
@@ -0,0 +1,12 @@
+package config
+
+// Policy describes constraints checked on the built image
+type Policy struct {
+	// MaxSize is a maximum size of the image in bytes, zero value disables the check
+	MaxSize          int64
+	ForbidRootUser   bool
+	RequiredLabels   []string
+	ForbidLatestFrom bool
+
+	raw *rawPolicy
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+type rawCleanup struct {
+	KeepPolicies []*rawCleanupKeepPolicy `yaml:"keepPolicies,omitempty"`
+
+	rawMeta *rawMeta
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+type rawCleanupKeepPolicy struct {
+	References *string `yaml:"references,omitempty"`
+	Regexp     *string `yaml:"regexp,omitempty"`
+	Limit      *int64  `yaml:"limit,omitempty"`
+	ExpiryDays *int64  `yaml:"expiryDays,omitempty"`
+
+	rawCleanup *rawCleanup
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawCleanup) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMeta); ok {
+		c.rawMeta = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawCleanup
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, nil, c.rawMeta.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawCleanupKeepPolicy) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawCleanup); ok {
+		c.rawCleanup = parent
+	}
+
+	type plain rawCleanupKeepPolicy
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	doc := c.rawCleanup.rawMeta.doc
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, doc); err != nil {
+		return err
+	}
+
+	if c.References == nil {
+		return newDetailedConfigError("references field `references: tag|branch|commit` required for cleanup keep policy!", c, doc)
+	}
+
+	switch *c.References {
+	case CleanupReferencesTag, CleanupReferencesBranch, CleanupReferencesCommit:
+	default:
+		return newDetailedConfigError(fmt.Sprintf("invalid references `%s` for cleanup keep policy: expected tag, branch or commit!", *c.References), c, doc)
+	}
+
+	if c.Regexp != nil {
+		if _, err := regexp.Compile(*c.Regexp); err != nil {
+			return newDetailedConfigError(fmt.Sprintf("invalid regexp `%s` for cleanup keep policy: %s", *c.Regexp, err), c, doc)
+		}
+	}
+
+	if c.Limit == nil && c.ExpiryDays == nil {
+		return newDetailedConfigError("`limit: N` or `expiryDays: N` required for cleanup keep policy!", c, doc)
+	}
+
+	if c.Limit != nil && *c.Limit < 0 {
+		return newDetailedConfigError("limit field cannot be negative!", c, doc)
+	}
+
+	if c.ExpiryDays != nil && *c.ExpiryDays < 0 {
+		return newDetailedConfigError("expiryDays field cannot be negative!", c, doc)
+	}
+
+	return nil
+}
+
+func (c *rawCleanup) toCleanup() Cleanup {
+	cleanup := Cleanup{}
+
+	for _, rawKeepPolicy := range c.KeepPolicies {
+		cleanup.KeepPolicies = append(cleanup.KeepPolicies, rawKeepPolicy.toCleanupKeepPolicy())
+	}
+
+	return cleanup
+}
+
+func (c *rawCleanupKeepPolicy) toCleanupKeepPolicy() *CleanupKeepPolicy {
+	keepPolicy := &CleanupKeepPolicy{
+		References: *c.References,
+		Limit:      c.Limit,
+		ExpiryDays: c.ExpiryDays,
+	}
+
+	if c.Regexp != nil {
+		keepPolicy.Regexp = regexp.MustCompile(fmt.Sprintf("^(?:%s)$", *c.Regexp))
+	}
+
+	return keepPolicy
+}
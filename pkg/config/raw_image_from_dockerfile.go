@@ -11,6 +11,8 @@ type rawImageFromDockerfile struct {
 	Target     string                 `yaml:"target,omitempty"`
 	Args       map[string]interface{} `yaml:"args,omitempty"`
 	AddHost    interface{}            `yaml:"addHost,omitempty"`
+	RawSecrets []*rawSecret           `yaml:"secrets,omitempty"`
+	RawPolicy  *rawPolicy             `yaml:"policy,omitempty"`
 
 	doc *doc `yaml:"-"` // parent
 
@@ -87,6 +89,22 @@ func (c *rawImageFromDockerfile) toImageFromDockerfileDirective(imageName string
 		image.AddHost = addHost
 	}
 
+	for _, rawSecret := range c.RawSecrets {
+		if secret, err := rawSecret.toDirective(); err != nil {
+			return nil, err
+		} else {
+			image.Secrets = append(image.Secrets, secret)
+		}
+	}
+
+	if c.RawPolicy != nil {
+		if policy, err := c.RawPolicy.toDirective(); err != nil {
+			return nil, err
+		} else {
+			image.Policy = policy
+		}
+	}
+
 	image.raw = c
 
 	return image, nil
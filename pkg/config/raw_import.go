@@ -1,10 +1,13 @@
 package config
 
 type rawImport struct {
-	ImageName    string `yaml:"image,omitempty"`
-	ArtifactName string `yaml:"artifact,omitempty"`
-	Before       string `yaml:"before,omitempty"`
-	After        string `yaml:"after,omitempty"`
+	ImageName     string `yaml:"image,omitempty"`
+	ArtifactName  string `yaml:"artifact,omitempty"`
+	ExternalImage string `yaml:"externalImage,omitempty"`
+	Before        string `yaml:"before,omitempty"`
+	After         string `yaml:"after,omitempty"`
+
+	ContentChecksum bool `yaml:"contentChecksum,omitempty"`
 
 	rawArtifactExport `yaml:",inline"`
 	rawStapelImage    *rawStapelImage `yaml:"-"` // parent
@@ -57,8 +60,10 @@ func (c *rawImport) toDirective() (imp *Import, err error) {
 
 	imp.ImageName = c.ImageName
 	imp.ArtifactName = c.ArtifactName
+	imp.ExternalImage = c.ExternalImage
 	imp.Before = c.Before
 	imp.After = c.After
+	imp.ContentChecksum = c.ContentChecksum
 
 	imp.raw = c
 
@@ -11,6 +11,7 @@ type rawMeta struct {
 	ConfigVersion   *int               `yaml:"configVersion,omitempty"`
 	Project         *string            `yaml:"project,omitempty"`
 	DeployTemplates rawDeployTemplates `yaml:"deploy,omitempty"`
+	Cleanup         rawCleanup         `yaml:"cleanup,omitempty"`
 
 	doc *doc `yaml:"-"` // parent
 
@@ -62,6 +63,7 @@ func (c *rawMeta) toMeta() *Meta {
 	}
 
 	meta.DeployTemplates = c.DeployTemplates.toDeployTemplates()
+	meta.Cleanup = c.Cleanup.toCleanup()
 
 	return meta
 }
@@ -6,6 +6,7 @@ type rawMount struct {
 	To       string `yaml:"to,omitempty"`
 	From     string `yaml:"from,omitempty"`
 	FromPath string `yaml:"fromPath,omitempty"`
+	ID       string `yaml:"id,omitempty"`
 
 	rawStapelImage *rawStapelImage `yaml:"-"` // parent
 
@@ -33,6 +34,7 @@ func (c *rawMount) toDirective() (mount *Mount, err error) {
 	mount = &Mount{}
 	mount.To = c.To
 	mount.From = c.FromPath
+	mount.ID = c.ID
 
 	if c.From == "" {
 		mount.Type = "custom_dir"
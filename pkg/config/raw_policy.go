@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/docker/go-units"
+)
+
+type rawPolicy struct {
+	MaxSize          string   `yaml:"maxSize,omitempty"`
+	ForbidRootUser   bool     `yaml:"forbidRootUser,omitempty"`
+	RequiredLabels   []string `yaml:"requiredLabels,omitempty"`
+	ForbidLatestFrom bool     `yaml:"forbidLatestFrom,omitempty"`
+
+	doc *doc `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawPolicy) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	switch parent := parentStack.Peek().(type) {
+	case *rawImageFromDockerfile:
+		c.doc = parent.doc
+	case *rawStapelImage:
+		c.doc = parent.doc
+	}
+
+	type plain rawPolicy
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawPolicy) toDirective() (policy *Policy, err error) {
+	policy = &Policy{}
+	policy.ForbidRootUser = c.ForbidRootUser
+	policy.RequiredLabels = c.RequiredLabels
+	policy.ForbidLatestFrom = c.ForbidLatestFrom
+
+	if c.MaxSize != "" {
+		if policy.MaxSize, err = units.RAMInBytes(c.MaxSize); err != nil || policy.MaxSize <= 0 {
+			return nil, newDetailedConfigError(fmt.Sprintf("invalid `maxSize: %s` for policy: expected positive size (e.g. `500MiB` or `1GB`)!", c.MaxSize), c, c.doc)
+		}
+	}
+
+	for _, label := range policy.RequiredLabels {
+		if label == "" {
+			return nil, newDetailedConfigError("empty label in `requiredLabels` for policy!", c, c.doc)
+		}
+	}
+
+	policy.raw = c
+
+	return policy, nil
+}
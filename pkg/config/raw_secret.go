@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var secretIdRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+type rawSecret struct {
+	Id  string `yaml:"id,omitempty"`
+	Src string `yaml:"src,omitempty"`
+	Env string `yaml:"env,omitempty"`
+
+	Stages []string `yaml:"stages,omitempty"`
+
+	rawStapelImage *rawStapelImage `yaml:"-"` // parent
+	doc            *doc            `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawSecret) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	switch parent := parentStack.Peek().(type) {
+	case *rawImageFromDockerfile:
+		c.doc = parent.doc
+	case *rawStapelImage:
+		c.rawStapelImage = parent
+		c.doc = parent.doc
+	}
+
+	type plain rawSecret
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawSecret) toDirective() (secret *Secret, err error) {
+	secret = &Secret{}
+	secret.Id = c.Id
+	secret.Src = c.Src
+	secret.Env = c.Env
+	secret.Stages = c.Stages
+
+	secret.raw = c
+
+	if err := c.validateDirective(secret); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+func (c *rawSecret) validateDirective(secret *Secret) error {
+	if secret.Id == "" {
+		return newDetailedConfigError("`id: ID` required for secret!", c, c.doc)
+	} else if !secretIdRegexp.MatchString(secret.Id) {
+		return newDetailedConfigError(fmt.Sprintf("invalid `id: %s` for secret: only letters, digits, `_`, `-` and `.` are allowed!", secret.Id), c, c.doc)
+	}
+
+	if secret.Src != "" && secret.Env != "" {
+		return newDetailedConfigError(fmt.Sprintf("cannot use `src: %s` and `env: %s` at the same time for secret!", secret.Src, secret.Env), c, c.doc)
+	} else if secret.Src == "" && secret.Env == "" {
+		return newDetailedConfigError("`src: PATH` or `env: NAME` required for secret!", c, c.doc)
+	}
+
+	if len(secret.Stages) != 0 {
+		if c.rawStapelImage == nil {
+			return newDetailedConfigError("`stages: [STAGE, ...]` can be used only for secrets of stapel image!", c, c.doc)
+		}
+
+		for _, stage := range secret.Stages {
+			if !isSecretStage(stage) {
+				return newDetailedConfigError(fmt.Sprintf("invalid stage `%s` for secret: expected %s!", stage, "`"+strings.Join(SecretStages, "`, `")+"`"), c, c.doc)
+			}
+		}
+	}
+
+	return nil
+}
@@ -19,6 +19,8 @@ type rawStapelImage struct {
 	RawMount          []*rawMount  `yaml:"mount,omitempty"`
 	RawDocker         *rawDocker   `yaml:"docker,omitempty"`
 	RawImport         []*rawImport `yaml:"import,omitempty"`
+	RawSecrets        []*rawSecret `yaml:"secrets,omitempty"`
+	RawPolicy         *rawPolicy   `yaml:"policy,omitempty"`
 	AsLayers          bool         `yaml:"asLayers,omitempty"`
 
 	doc *doc `yaml:"-"` // parent
@@ -670,6 +672,26 @@ func (c *rawStapelImage) toBaseStapelImageBaseDirective(name string) (imageBase
 		}
 	}
 
+	for _, rawSecret := range c.RawSecrets {
+		if secret, err := rawSecret.toDirective(); err != nil {
+			return nil, err
+		} else {
+			imageBase.Secrets = append(imageBase.Secrets, secret)
+		}
+	}
+
+	if c.RawPolicy != nil {
+		if c.Artifact != "" {
+			return nil, newDetailedConfigError("`policy` cannot be used for artifact!", c.RawPolicy, c.doc)
+		}
+
+		if policy, err := c.RawPolicy.toDirective(); err != nil {
+			return nil, err
+		} else {
+			imageBase.Policy = policy
+		}
+	}
+
 	imageBase.Git = &GitManager{}
 
 	imageBase.raw = c
@@ -0,0 +1,39 @@
+package config
+
+// SecretStages are stages of stapel image which build container can use secrets
+var SecretStages = []string{"beforeInstall", "install", "beforeSetup", "setup"}
+
+type Secret struct {
+	Id  string
+	Src string
+	Env string
+
+	// Stages limits stapel image stages which use the secret, all user stages by default
+	Stages []string
+
+	raw *rawSecret
+}
+
+func (c *Secret) IsUsedByStage(stage string) bool {
+	if len(c.Stages) == 0 {
+		return true
+	}
+
+	for _, s := range c.Stages {
+		if s == stage {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isSecretStage(stage string) bool {
+	for _, s := range SecretStages {
+		if s == stage {
+			return true
+		}
+	}
+
+	return false
+}
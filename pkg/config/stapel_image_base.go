@@ -16,6 +16,8 @@ type StapelImageBase struct {
 	Ansible               *Ansible
 	Mount                 []*Mount
 	Import                []*Import
+	Secrets               []*Secret
+	Policy                *Policy
 
 	raw *rawStapelImage
 }
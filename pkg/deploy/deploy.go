@@ -1,6 +1,7 @@
 package deploy
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 	"time"
@@ -8,6 +9,7 @@ import (
 	"github.com/flant/werf/pkg/util/secretvalues"
 
 	"github.com/ghodss/yaml"
+	"golang.org/x/crypto/ed25519"
 
 	"k8s.io/helm/pkg/chartutil"
 	"k8s.io/helm/pkg/proto/hapi/chart"
@@ -32,8 +34,19 @@ type DeployOptions struct {
 	UserExtraLabels      map[string]string
 	IgnoreSecretKey      bool
 	ThreeWayMergeMode    helm.ThreeWayMergeModeType
+	AutoRollback         bool
+	VerificationKey      ed25519.PublicKey
+
+	// ImagesTags overrides common tag for the images published with stages-signature tagging strategy
+	ImagesTags map[string]string
+
+	// Diff only prints changes of the release and returns ErrDiffHasChanges if there are any
+	Diff        bool
+	DiffOptions helm.DiffOptions
 }
 
+var ErrDiffHasChanges = errors.New("release has changes")
+
 type ImagesRepoManager interface {
 	ImagesRepo() string
 	ImageRepo(imageName string) string
@@ -53,7 +66,17 @@ func Deploy(projectDir string, imagesRepoManager ImagesRepoManager, release, nam
 		logboek.LogF("Using helm release name: %s\n", release)
 		logboek.LogF("Using Kubernetes namespace: %s\n", namespace)
 
-		images := GetImagesInfoGetters(werfConfig.StapelImages, werfConfig.ImagesFromDockerfile, imagesRepoManager, tag, false)
+		images := GetImagesInfoGetters(werfConfig.StapelImages, werfConfig.ImagesFromDockerfile, imagesRepoManager, tag, opts.ImagesTags, false)
+
+		if opts.VerificationKey != nil {
+			verifiedImages, err := VerifyImages(images, imagesRepoManager, opts.VerificationKey)
+			if err != nil {
+				logBlockErr = err
+				return
+			}
+
+			images = verifiedImages
+		}
 
 		m, err := GetSafeSecretManager(projectDir, opts.SecretValues, opts.IgnoreSecretKey)
 		if err != nil {
@@ -94,22 +117,38 @@ func Deploy(projectDir string, imagesRepoManager ImagesRepoManager, release, nam
 	helm.WerfTemplateEngine.InitWerfEngineExtraTemplatesFunctions(werfChart.DecodedSecretFilesData)
 	patchLoadChartfile(werfChart.Name)
 
+	chartOptions := helm.ChartOptions{
+		Timeout: opts.Timeout,
+		ChartValuesOptions: helm.ChartValuesOptions{
+			Set:       opts.Set,
+			SetString: opts.SetString,
+			Values:    opts.Values,
+		},
+		ThreeWayMergeMode: opts.ThreeWayMergeMode,
+		AutoRollback:      opts.AutoRollback,
+	}
+
+	var hasChanges bool
 	err := helm.WerfTemplateEngineWithExtraAnnotationsAndLabels(werfChart.ExtraAnnotations, werfChart.ExtraLabels, func() error {
-		return werfChart.Deploy(release, namespace, helm.ChartOptions{
-			Timeout: opts.Timeout,
-			ChartValuesOptions: helm.ChartValuesOptions{
-				Set:       opts.Set,
-				SetString: opts.SetString,
-				Values:    opts.Values,
-			},
-			ThreeWayMergeMode: opts.ThreeWayMergeMode,
-		})
+		if opts.Diff {
+			return logboek.LogProcess("Running diff", logboek.LogProcessOptions{ColorizeMsgFunc: logboek.ColorizeHighlight}, func() error {
+				var err error
+				hasChanges, err = werfChart.Diff(release, namespace, chartOptions, opts.DiffOptions)
+				return err
+			})
+		}
+
+		return werfChart.Deploy(release, namespace, chartOptions)
 	})
 
 	if err != nil {
 		return fmt.Errorf("%s", secretvalues.MaskSecretValuesInString(werfChart.SecretValuesToMask, err.Error()))
 	}
 
+	if hasChanges {
+		return ErrDiffHasChanges
+	}
+
 	return nil
 }
 
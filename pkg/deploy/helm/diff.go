@@ -0,0 +1,429 @@
+package helm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/sergi/go-diff/diffmatchpatch"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/helm/pkg/releaseutil"
+
+	"github.com/flant/kubedog/pkg/kube"
+	"github.com/flant/logboek"
+
+	"github.com/flant/werf/pkg/util/secretvalues"
+)
+
+const diffContextLines = 3
+
+type DiffOptions struct {
+	// Live compares chart templates with the objects in the cluster instead of the latest deployed release revision
+	Live bool
+}
+
+type diffResource struct {
+	Key    string
+	Before map[string]interface{}
+	After  map[string]interface{}
+}
+
+// DiffHelmChart prints the changes which deploy of the chart would bring and returns whether there are any.
+// Nothing is changed in the cluster and in the release storage.
+func DiffHelmChart(chartPath, releaseName, namespace string, opts ChartOptions, diffOpts DiffOptions) (bool, error) {
+	var rawTemplatesFromChart, rawTemplatesFromRevision string
+
+	if err := logboek.LogProcessInline("Getting chart templates", logboek.LogProcessInlineOptions{}, func() error {
+		var err error
+		rawTemplatesFromChart, err = getRawTemplatesFromChart(chartPath, releaseName, namespace, opts.Values, opts.SecretValues, opts.Set, opts.SetString)
+		return err
+	}); err != nil {
+		return false, err
+	}
+
+	revision, err := latestSuccessfullyDeployedReleaseRevision(releaseName)
+	if err != nil && err != ErrNoSuccessfullyDeployedReleaseRevisionFound && !isReleaseNotFoundError(err) {
+		return false, err
+	}
+
+	if err == nil {
+		logProcessMsg := fmt.Sprintf("Getting templates from release revision %d", revision)
+		if err := logboek.LogProcessInline(logProcessMsg, logboek.LogProcessInlineOptions{}, func() error {
+			var err error
+			rawTemplatesFromRevision, err = getRawTemplatesFromRevision(releaseName, revision)
+			return err
+		}); err != nil {
+			return false, fmt.Errorf("get templates from release revision failed: %s", err)
+		}
+	} else {
+		logboek.LogInfoLn("Release has not been deployed yet")
+	}
+
+	objectsFromChart, err := parseDiffObjects(rawTemplatesFromChart, namespace)
+	if err != nil {
+		return false, fmt.Errorf("unable to parse chart templates: %s", err)
+	}
+
+	objectsFromRevision, err := parseDiffObjects(rawTemplatesFromRevision, namespace)
+	if err != nil {
+		return false, fmt.Errorf("unable to parse revision templates: %s", err)
+	}
+
+	before := objectsFromRevision
+	if diffOpts.Live {
+		if err := logboek.LogProcessInline("Getting live objects", logboek.LogProcessInlineOptions{}, func() error {
+			before, err = getLiveObjects(objectsFromChart, objectsFromRevision)
+			return err
+		}); err != nil {
+			return false, err
+		}
+	}
+
+	resources := diffResources(before, objectsFromChart)
+
+	logboek.LogOptionalLn()
+
+	var hasChanges bool
+	for _, resource := range resources {
+		diff := resourceDiff(resource)
+		if diff == "" {
+			continue
+		}
+
+		hasChanges = true
+		logboek.LogLn(secretvalues.MaskSecretValuesInString(releaseLogSecretValuesToMask, diff))
+	}
+
+	if !hasChanges {
+		logboek.LogHighlightLn("No changes")
+	}
+
+	return hasChanges, nil
+}
+
+func parseDiffObjects(rawTemplates, namespace string) (map[string]map[string]interface{}, error) {
+	objects := map[string]map[string]interface{}{}
+
+	for _, doc := range releaseutil.SplitManifests(rawTemplates) {
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			return nil, err
+		}
+
+		if len(obj) == 0 {
+			continue
+		}
+
+		key := diffObjectKey(obj, namespace)
+		if key == "" {
+			continue
+		}
+
+		objects[key] = obj
+	}
+
+	return objects, nil
+}
+
+func diffObjectKey(obj map[string]interface{}, namespace string) string {
+	kind, _ := obj["kind"].(string)
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	if kind == "" || name == "" {
+		return ""
+	}
+
+	if objNamespace, _ := metadata["namespace"].(string); objNamespace != "" {
+		namespace = objNamespace
+	}
+
+	return fmt.Sprintf("%s/%s/%s", namespace, kind, name)
+}
+
+func diffResources(before, after map[string]map[string]interface{}) []diffResource {
+	var keys []string
+	for key := range after {
+		keys = append(keys, key)
+	}
+
+	for key := range before {
+		if _, exist := after[key]; !exist {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	var resources []diffResource
+	for _, key := range keys {
+		resources = append(resources, diffResource{Key: key, Before: before[key], After: after[key]})
+	}
+
+	return resources
+}
+
+func resourceDiff(resource diffResource) string {
+	before, after := maskSecretData(resource.Before, resource.After)
+
+	beforeManifest, afterManifest := diffManifest(before), diffManifest(after)
+	if beforeManifest == afterManifest {
+		return ""
+	}
+
+	var header string
+	parts := strings.SplitN(resource.Key, "/", 3)
+	switch {
+	case before == nil:
+		header = fmt.Sprintf("+ %s/%s will be created", parts[1], parts[2])
+	case after == nil:
+		header = fmt.Sprintf("- %s/%s will be deleted", parts[1], parts[2])
+	default:
+		header = fmt.Sprintf("~ %s/%s will be changed", parts[1], parts[2])
+	}
+
+	if parts[0] != "" {
+		header += fmt.Sprintf(" (namespace %s)", parts[0])
+	}
+
+	return fmt.Sprintf("%s\n%s", logboek.ColorizeHighlight(header), diffLines(beforeManifest, afterManifest))
+}
+
+func diffManifest(obj map[string]interface{}) string {
+	if obj == nil {
+		return ""
+	}
+
+	data, _ := yaml.Marshal(obj)
+	return string(data)
+}
+
+// diffLines returns the changed lines of two texts with a few lines of context
+func diffLines(before, after string) string {
+	dmp := diffmatchpatch.New()
+	beforeChars, afterChars, lines := dmp.DiffLinesToChars(before, after)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(beforeChars, afterChars, false), lines)
+
+	type diffLine struct {
+		op   diffmatchpatch.Operation
+		text string
+	}
+
+	var result []diffLine
+	for _, diff := range diffs {
+		for _, line := range strings.SplitAfter(diff.Text, "\n") {
+			if line == "" {
+				continue
+			}
+
+			result = append(result, diffLine{op: diff.Type, text: strings.TrimSuffix(line, "\n")})
+		}
+	}
+
+	isNearChange := func(ind int) bool {
+		for i := ind - diffContextLines; i <= ind+diffContextLines; i++ {
+			if i >= 0 && i < len(result) && result[i].op != diffmatchpatch.DiffEqual {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	var out []string
+	var skipped bool
+	for ind, line := range result {
+		if !isNearChange(ind) {
+			if !skipped {
+				out = append(out, "  ...")
+				skipped = true
+			}
+			continue
+		}
+		skipped = false
+
+		switch line.op {
+		case diffmatchpatch.DiffInsert:
+			out = append(out, logboek.ColorizeSuccess("+ "+line.text))
+		case diffmatchpatch.DiffDelete:
+			out = append(out, logboek.ColorizeFail("- "+line.text))
+		default:
+			out = append(out, "  "+line.text)
+		}
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// maskSecretData replaces values of Secret data with a mask which shows only whether value has been changed
+func maskSecretData(before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	if !isSecretObject(before) && !isSecretObject(after) {
+		return before, after
+	}
+
+	before, after = copyObject(before), copyObject(after)
+
+	for _, field := range []string{"data", "stringData"} {
+		beforeData, _ := getMap(before, field)
+		afterData, _ := getMap(after, field)
+
+		for key, value := range afterData {
+			beforeValue, exist := beforeData[key]
+			if exist && !reflect.DeepEqual(beforeValue, value) {
+				beforeData[key] = "*** (before)"
+				afterData[key] = "*** (after)"
+			} else {
+				afterData[key] = "***"
+			}
+		}
+
+		for key, value := range beforeData {
+			if value != "*** (before)" {
+				beforeData[key] = "***"
+			}
+		}
+	}
+
+	return before, after
+}
+
+func isSecretObject(obj map[string]interface{}) bool {
+	return obj != nil && obj["kind"] == "Secret"
+}
+
+func getMap(obj map[string]interface{}, field string) (map[string]interface{}, bool) {
+	if obj == nil {
+		return nil, false
+	}
+
+	value, ok := obj[field].(map[string]interface{})
+	return value, ok
+}
+
+func copyObject(obj map[string]interface{}) map[string]interface{} {
+	if obj == nil {
+		return nil
+	}
+
+	data, _ := yaml.Marshal(obj)
+
+	var res map[string]interface{}
+	_ = yaml.Unmarshal(data, &res)
+
+	return res
+}
+
+// getLiveObjects gets objects from the cluster for the desired objects and the objects which will be deleted
+func getLiveObjects(desiredObjects, previousObjects map[string]map[string]interface{}) (map[string]map[string]interface{}, error) {
+	liveObjects := map[string]map[string]interface{}{}
+	resourcesByKind := map[string]metav1.APIResource{}
+	groupVersionByKind := map[string]schema.GroupVersion{}
+
+	for _, objects := range []map[string]map[string]interface{}{desiredObjects, previousObjects} {
+		for key, obj := range objects {
+			if _, exist := liveObjects[key]; exist {
+				continue
+			}
+
+			if len(resourcesByKind) == 0 {
+				if err := getServerPreferredResources(resourcesByKind, groupVersionByKind); err != nil {
+					return nil, fmt.Errorf("unable to get server resources: %s", err)
+				}
+			}
+
+			kind := obj["kind"].(string)
+			resource, exist := resourcesByKind[kind]
+			if !exist {
+				return nil, fmt.Errorf("unknown resource kind %s", kind)
+			}
+
+			parts := strings.SplitN(key, "/", 3)
+			namespace, name := parts[0], parts[2]
+			gvr := groupVersionByKind[kind].WithResource(resource.Name)
+
+			var res dynamic.ResourceInterface
+			if resource.Namespaced {
+				res = kube.DynamicClient.Resource(gvr).Namespace(namespace)
+			} else {
+				res = kube.DynamicClient.Resource(gvr)
+			}
+
+			liveObj, err := res.Get(name, metav1.GetOptions{})
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+
+				return nil, fmt.Errorf("unable to get %s/%s: %s", kind, name, err)
+			}
+
+			liveObjects[key] = pruneLiveObject(liveObj.Object, obj).(map[string]interface{})
+		}
+	}
+
+	return liveObjects, nil
+}
+
+func getServerPreferredResources(resourcesByKind map[string]metav1.APIResource, groupVersionByKind map[string]schema.GroupVersion) error {
+	lists, err := kube.Kubernetes.Discovery().ServerPreferredResources()
+	if err != nil {
+		return err
+	}
+
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+
+		for _, resource := range list.APIResources {
+			if _, exist := resourcesByKind[resource.Kind]; exist {
+				continue
+			}
+
+			resourcesByKind[resource.Kind] = resource
+			groupVersionByKind[resource.Kind] = gv
+		}
+	}
+
+	return nil
+}
+
+// pruneLiveObject leaves only fields of the live object which are set in the desired object, so fields defaulted or managed by the cluster are not shown as changes
+func pruneLiveObject(live, desired interface{}) interface{} {
+	switch desiredValue := desired.(type) {
+	case map[string]interface{}:
+		liveValue, ok := live.(map[string]interface{})
+		if !ok {
+			return live
+		}
+
+		res := map[string]interface{}{}
+		for key, value := range desiredValue {
+			if liveFieldValue, exist := liveValue[key]; exist {
+				res[key] = pruneLiveObject(liveFieldValue, value)
+			}
+		}
+
+		return res
+	case []interface{}:
+		liveValue, ok := live.([]interface{})
+		if !ok || len(liveValue) != len(desiredValue) {
+			return live
+		}
+
+		var res []interface{}
+		for ind := range liveValue {
+			res = append(res, pruneLiveObject(liveValue[ind], desiredValue[ind]))
+		}
+
+		return res
+	default:
+		return live
+	}
+}
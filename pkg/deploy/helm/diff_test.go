@@ -0,0 +1,114 @@
+package helm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMaskSecretData(t *testing.T) {
+	before := map[string]interface{}{
+		"kind": "Secret",
+		"data": map[string]interface{}{"same": "c2FtZQ==", "changed": "b2xk", "removed": "cmVtb3ZlZA=="},
+	}
+	after := map[string]interface{}{
+		"kind": "Secret",
+		"data": map[string]interface{}{"same": "c2FtZQ==", "changed": "bmV3", "added": "YWRkZWQ="},
+	}
+
+	maskedBefore, maskedAfter := maskSecretData(before, after)
+
+	expectedBefore := map[string]interface{}{"same": "***", "changed": "*** (before)", "removed": "***"}
+	if !reflect.DeepEqual(maskedBefore["data"], expectedBefore) {
+		t.Errorf("unexpected masked before data: %v", maskedBefore["data"])
+	}
+
+	expectedAfter := map[string]interface{}{"same": "***", "changed": "*** (after)", "added": "***"}
+	if !reflect.DeepEqual(maskedAfter["data"], expectedAfter) {
+		t.Errorf("unexpected masked after data: %v", maskedAfter["data"])
+	}
+
+	if after["data"].(map[string]interface{})["changed"] != "bmV3" {
+		t.Errorf("original object should not be changed")
+	}
+}
+
+func TestResourceDiff(t *testing.T) {
+	objects, err := parseDiffObjects(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  key: value
+`, "ns")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj := objects["ns/ConfigMap/config"]
+	if obj == nil {
+		t.Fatalf("unexpected objects: %v", objects)
+	}
+
+	if diff := resourceDiff(diffResource{Key: "ns/ConfigMap/config", Before: obj, After: obj}); diff != "" {
+		t.Errorf("expected no diff, got:\n%s", diff)
+	}
+
+	changed := copyObject(obj)
+	changed["data"].(map[string]interface{})["key"] = "new-value"
+
+	diff := resourceDiff(diffResource{Key: "ns/ConfigMap/config", Before: obj, After: changed})
+	for _, expected := range []string{"ConfigMap/config will be changed", "- ", "key: value", "+ ", "key: new-value"} {
+		if !strings.Contains(diff, expected) {
+			t.Errorf("expected diff to contain %q, got:\n%s", expected, diff)
+		}
+	}
+
+	diff = resourceDiff(diffResource{Key: "ns/ConfigMap/config", After: obj})
+	if !strings.Contains(diff, "ConfigMap/config will be created") {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+}
+
+func TestPruneLiveObject(t *testing.T) {
+	live := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "app", "uid": "123", "resourceVersion": "1"},
+		"spec": map[string]interface{}{
+			"replicas": float64(2),
+			"template": map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "app", "image": "app:2", "imagePullPolicy": "IfNotPresent"},
+				},
+			},
+		},
+		"status": map[string]interface{}{"replicas": float64(2)},
+	}
+	desired := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "app"},
+		"spec": map[string]interface{}{
+			"replicas": float64(3),
+			"template": map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "app", "image": "app:3"},
+				},
+			},
+		},
+	}
+
+	expected := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "app"},
+		"spec": map[string]interface{}{
+			"replicas": float64(2),
+			"template": map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "app", "image": "app:2"},
+				},
+			},
+		},
+	}
+
+	if res := pruneLiveObject(live, desired); !reflect.DeepEqual(res, expected) {
+		t.Errorf("unexpected pruned object: %v", res)
+	}
+}
@@ -82,6 +82,21 @@ func templatesRules(linter *support.Linter, chartPath, namespace string, values
 			linter.RunLinterRule(support.WarningSev, "templates/", err)
 		}
 
+		if _, err := parseStatusTrackAnnotations(metadataName, kind, template.Metadata.Annotations); err != nil {
+			linter.RunLinterRule(support.WarningSev, "templates/", err)
+		}
+
+		if _, err := getTemplateWeight(template); err != nil {
+			linter.RunLinterRule(support.ErrorSev, "templates/", err)
+		}
+
+		if _, hasWeight := template.Metadata.Annotations[WeightAnnoName]; hasWeight {
+			if _, isHelmHook := template.Metadata.Annotations[HelmHookAnnoName]; isHelmHook {
+				err := fmt.Errorf("%s/%s: %s annotation is ignored for helm hooks, use helm.sh/hook-weight instead", kind, metadataName, WeightAnnoName)
+				linter.RunLinterRule(support.WarningSev, "templates/", err)
+			}
+		}
+
 	templateAnnotationsLoop:
 		for annoName := range template.Metadata.Annotations {
 			if strings.HasPrefix(annoName, "werf.io/") {
@@ -15,7 +15,7 @@ import (
 
 	"github.com/flant/kubedog/pkg/kube"
 	"github.com/flant/logboek"
-	"github.com/flant/shluz"
+	"github.com/flant/werf/pkg/lock_manager"
 	"github.com/flant/werf/pkg/util"
 	"github.com/flant/werf/pkg/werf"
 )
@@ -53,6 +53,10 @@ var (
 		ShowLogsUntilAnnoName,
 		ShowEventsAnnoName,
 		RecreateAnnoName,
+		WeightAnnoName,
+		TrackReadyConditionAnnoName,
+		TrackFailedConditionAnnoName,
+		TrackObservedGenerationAnnoName,
 		helm_kube.SetReplicasOnlyOnCreationAnnotation,
 		helm_kube.SetResourcesOnlyOnCreationAnnotation,
 	}
@@ -171,12 +175,25 @@ type ChartOptions struct {
 	Debug             bool
 	ThreeWayMergeMode ThreeWayMergeModeType
 
+	// AutoRollback rolls back failed release upgrade to the latest successfully deployed revision and deletes failed release installation
+	AutoRollback bool
+
 	ChartValuesOptions
 }
 
 func withLockedHelmRelease(releaseName string, f func() error) error {
-	lockName := fmt.Sprintf("helm_release.%s-kube_context.%s", releaseName, helmSettings.KubeContext)
-	return shluz.WithLock(lockName, shluz.LockOptions{}, f)
+	lockName := helmReleaseLockName(releaseName, helmSettings.KubeContext, helmSettings.TillerNamespace, lock_manager.IsDistributed())
+	return lock_manager.WithLock(lockName, lock_manager.LockOptions{}, f)
+}
+
+// helmReleaseLockName does not depend on the kube context for distributed locks: the lock is stored in the cluster,
+// and kube contexts of the same cluster can be named differently on different hosts
+func helmReleaseLockName(releaseName, kubeContext, releaseStorageNamespace string, isDistributed bool) string {
+	if isDistributed {
+		return fmt.Sprintf("helm_release.%s-release_storage_namespace.%s", releaseName, releaseStorageNamespace)
+	}
+
+	return fmt.Sprintf("helm_release.%s-kube_context.%s", releaseName, kubeContext)
 }
 
 func DeployHelmChart(chartPath, releaseName, namespace string, opts ChartOptions) error {
@@ -472,8 +489,9 @@ func doDeployHelmChart(chartPath, releaseName, namespace string, opts ChartOptio
 		}
 	}
 
+	var deployErr error
 	logProcessOptions := logboek.LogProcessOptions{ColorizeMsgFunc: logboek.ColorizeHighlight}
-	return logboek.LogProcess("Running deploy", logProcessOptions, func() error {
+	if err := logboek.LogProcess("Running deploy", logProcessOptions, func() error {
 		var templatesFromChart ChartTemplates
 
 		if err := logboek.LogProcessInline("Getting chart templates", logboek.LogProcessInlineOptions{}, func() error {
@@ -483,8 +501,75 @@ func doDeployHelmChart(chartPath, releaseName, namespace string, opts ChartOptio
 			return err
 		}
 
-		return runDeployProcess(releaseName, namespace, opts, templatesFromChart, deployFunc)
-	})
+		deployErr = runDeployProcess(releaseName, namespace, opts, templatesFromChart, deployFunc)
+		return deployErr
+	}); err != nil {
+		if deployErr != nil && opts.AutoRollback && !opts.DryRun {
+			return autoRollbackRelease(releaseName, namespace, isReleaseExists, opts, deployErr)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func autoRollbackRelease(releaseName, namespace string, isReleaseExists bool, opts ChartOptions, deployErr error) error {
+	logProcessOptions := logboek.LogProcessOptions{ColorizeMsgFunc: logboek.ColorizeHighlight}
+
+	if !isReleaseExists {
+		if err := logboek.LogProcess("Deleting failed release", logProcessOptions, func() error {
+			if err := releaseDelete(releaseName, releaseDeleteOptions{Purge: true}); err != nil && !isReleaseNotFoundError(err) {
+				return err
+			}
+
+			return deleteAutoPurgeTriggerFilePath(releaseName)
+		}); err != nil {
+			return fmt.Errorf("%s; auto rollback failed: release delete failed: %s", deployErr, err)
+		}
+
+		return fmt.Errorf("%s; release has been deleted by auto rollback", deployErr)
+	}
+
+	revision, err := latestSuccessfullyDeployedReleaseRevision(releaseName)
+	if err == ErrNoSuccessfullyDeployedReleaseRevisionFound {
+		return fmt.Errorf("%s; auto rollback skipped: %s", deployErr, err)
+	} else if err != nil {
+		return fmt.Errorf("%s; auto rollback failed: get latest successfully deployed release revision failed: %s", deployErr, err)
+	}
+
+	logProcessMsg := fmt.Sprintf("Rolling back release to revision %d", revision)
+	if err := logboek.LogProcess(logProcessMsg, logProcessOptions, func() error {
+		var templatesFromRevision ChartTemplates
+		logProcessMsg := fmt.Sprintf("Getting templates from release revision %d", revision)
+		if err := logboek.LogProcessInline(logProcessMsg, logboek.LogProcessInlineOptions{}, func() error {
+			templatesFromRevision, err = GetTemplatesFromReleaseRevision(releaseName, revision)
+			return err
+		}); err != nil {
+			return fmt.Errorf("get templates from release revision failed: %s", err)
+		}
+
+		rollbackFunc := func() error {
+			logboek.LogF("Running helm rollback...\n")
+			logboek.LogOptionalLn()
+
+			releaseRollbackOpts := ReleaseRollbackOptions{
+				releaseRollbackOptions: releaseRollbackOptions{
+					Timeout:       int64(opts.Timeout / time.Second),
+					CleanupOnFail: true,
+					Wait:          true,
+				},
+			}
+
+			return ReleaseRollback(releaseName, revision, opts.ThreeWayMergeMode, releaseRollbackOpts)
+		}
+
+		return runDeployProcess(releaseName, namespace, opts, templatesFromRevision, rollbackFunc)
+	}); err != nil {
+		return fmt.Errorf("%s; auto rollback to revision %d failed: %s", deployErr, revision, err)
+	}
+
+	return fmt.Errorf("%s; release has been rolled back to revision %d", deployErr, revision)
 }
 
 func latestSuccessfullyDeployedReleaseRevision(releaseName string) (int32, error) {
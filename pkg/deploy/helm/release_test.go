@@ -0,0 +1,22 @@
+package helm
+
+import "testing"
+
+func TestHelmReleaseLockName(t *testing.T) {
+	if helmReleaseLockName("app", "ctx-a", "kube-system", false) == helmReleaseLockName("app", "ctx-b", "kube-system", false) {
+		t.Errorf("expected local lock name to depend on kube context")
+	}
+
+	distributedLockName := helmReleaseLockName("app", "ctx-a", "kube-system", true)
+	if distributedLockName != helmReleaseLockName("app", "ctx-b", "kube-system", true) {
+		t.Errorf("expected distributed lock name not to depend on kube context")
+	}
+
+	if distributedLockName == helmReleaseLockName("app", "ctx-a", "werf-releases", true) {
+		t.Errorf("expected distributed lock name to depend on release storage namespace")
+	}
+
+	if distributedLockName == helmReleaseLockName("other", "ctx-a", "kube-system", true) {
+		t.Errorf("expected distributed lock name to depend on release name")
+	}
+}
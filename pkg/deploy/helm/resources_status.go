@@ -0,0 +1,364 @@
+package helm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/dynamic"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+
+	"github.com/flant/kubedog/pkg/kube"
+	"github.com/flant/kubedog/pkg/trackers/rollout/multitrack"
+	"github.com/flant/logboek"
+)
+
+const (
+	TrackReadyConditionAnnoName     = "werf.io/track-ready-condition"
+	TrackFailedConditionAnnoName    = "werf.io/track-failed-condition"
+	TrackObservedGenerationAnnoName = "werf.io/track-observed-generation"
+)
+
+var (
+	statusTrackerPollPeriod = 2 * time.Second
+
+	// getResourceStatusFunc is replaced in tests
+	getResourceStatusFunc = getResourceStatus
+)
+
+// statusTrackSpec describes a resource which readiness is determined by its status, these resources are not supported by kubedog multitrack
+type statusTrackSpec struct {
+	Kind                 string
+	Name                 string
+	Namespace            string
+	GroupVersionResource schema.GroupVersionResource
+	Namespaced           bool
+
+	ReadyCondition          string
+	FailedCondition         string
+	CheckObservedGeneration bool
+
+	FailMode multitrack.FailMode
+}
+
+func (spec statusTrackSpec) LogName() string {
+	return fmt.Sprintf("%s/%s", strings.ToLower(spec.Kind), spec.Name)
+}
+
+type statusTrackAnnotations struct {
+	ReadyCondition          string
+	FailedCondition         string
+	CheckObservedGeneration bool
+}
+
+func parseStatusTrackAnnotations(metadataName, kind string, annotations map[string]string) (statusTrackAnnotations, error) {
+	var res statusTrackAnnotations
+
+	for annoName, annoValue := range annotations {
+		invalidAnnoValueError := fmt.Errorf("%s/%s annotation %s with invalid value %s", kind, metadataName, annoName, annoValue)
+
+		switch annoName {
+		case TrackReadyConditionAnnoName:
+			if annoValue == "" {
+				return res, fmt.Errorf("%s: condition type expected", invalidAnnoValueError)
+			}
+
+			res.ReadyCondition = annoValue
+		case TrackFailedConditionAnnoName:
+			if annoValue == "" {
+				return res, fmt.Errorf("%s: condition type expected", invalidAnnoValueError)
+			}
+
+			res.FailedCondition = annoValue
+		case TrackObservedGenerationAnnoName:
+			boolValue, err := strconv.ParseBool(annoValue)
+			if err != nil {
+				return res, fmt.Errorf("%s: bool expected: %s", invalidAnnoValueError, err)
+			}
+
+			res.CheckObservedGeneration = boolValue
+		}
+	}
+
+	return res, nil
+}
+
+func hasStatusTrackAnnotations(annotations map[string]string) bool {
+	for _, annoName := range []string{TrackReadyConditionAnnoName, TrackFailedConditionAnnoName, TrackObservedGenerationAnnoName} {
+		if _, hasKey := annotations[annoName]; hasKey {
+			return true
+		}
+	}
+
+	return false
+}
+
+// makeStatusTrackSpec returns nil if the resource should not be tracked
+func makeStatusTrackSpec(info *resource.Info, kind string) (*statusTrackSpec, error) {
+	accessor, err := meta.Accessor(info.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := accessor.GetAnnotations()
+
+	multitrackSpec, err := prepareMultitrackSpec(info.Name, strings.ToLower(kind), info.Namespace, annotations, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if multitrackSpec.TrackTerminationMode == multitrack.NonBlocking {
+		return nil, nil
+	}
+
+	trackAnnotations, err := parseStatusTrackAnnotations(info.Name, strings.ToLower(kind), annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &statusTrackSpec{
+		Kind:                    kind,
+		Name:                    info.Name,
+		Namespace:               info.Namespace,
+		Namespaced:              info.Namespaced(),
+		ReadyCondition:          trackAnnotations.ReadyCondition,
+		FailedCondition:         trackAnnotations.FailedCondition,
+		CheckObservedGeneration: trackAnnotations.CheckObservedGeneration,
+		FailMode:                multitrackSpec.FailMode,
+	}
+
+	if info.Mapping != nil {
+		spec.GroupVersionResource = info.Mapping.Resource
+	}
+
+	return spec, nil
+}
+
+// resourceStatus returns whether the resource is ready, the failure reason and the current status description
+func resourceStatus(spec statusTrackSpec, obj map[string]interface{}) (bool, string, string) {
+	if spec.FailedCondition != "" {
+		if isTrue, message := conditionStatus(obj, spec.FailedCondition); isTrue {
+			return false, fmt.Sprintf("condition %s is True: %s", spec.FailedCondition, message), ""
+		}
+	}
+
+	if spec.CheckObservedGeneration {
+		if ok, status := observedGenerationStatus(obj); !ok {
+			return false, "", status
+		}
+	}
+
+	if spec.ReadyCondition != "" {
+		if isTrue, message := conditionStatus(obj, spec.ReadyCondition); !isTrue {
+			status := fmt.Sprintf("waiting for condition %s", spec.ReadyCondition)
+			if message != "" {
+				status += fmt.Sprintf(": %s", message)
+			}
+
+			return false, "", status
+		}
+
+		return true, "", ""
+	}
+
+	switch spec.Kind {
+	case "PersistentVolumeClaim":
+		phase, _, _ := unstructured.NestedString(obj, "status", "phase")
+		switch phase {
+		case string(v1.ClaimBound):
+			return true, "", ""
+		case string(v1.ClaimLost):
+			return false, "claim lost its underlying persistent volume", ""
+		default:
+			return false, "", fmt.Sprintf("waiting for claim to be bound, phase %s", phase)
+		}
+	case "Service", "Ingress":
+		ingress, _, _ := unstructured.NestedSlice(obj, "status", "loadBalancer", "ingress")
+		if len(ingress) == 0 {
+			return false, "", "waiting for load balancer address"
+		}
+
+		return true, "", ""
+	case "ReplicaSet", "ReplicationController":
+		if ok, status := observedGenerationStatus(obj); !ok {
+			return false, "", status
+		}
+
+		replicas, found, _ := unstructured.NestedInt64(obj, "spec", "replicas")
+		if !found {
+			replicas = 1
+		}
+
+		readyReplicas, _, _ := unstructured.NestedInt64(obj, "status", "readyReplicas")
+		if readyReplicas < replicas {
+			return false, "", fmt.Sprintf("%d/%d replicas ready", readyReplicas, replicas)
+		}
+
+		return true, "", ""
+	}
+
+	return true, "", ""
+}
+
+// conditionStatus returns whether condition of the specified type has True status and the condition message
+func conditionStatus(obj map[string]interface{}, conditionType string) (bool, string) {
+	conditions, _, _ := unstructured.NestedSlice(obj, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != conditionType {
+			continue
+		}
+
+		message, _ := condition["message"].(string)
+		return condition["status"] == string(metav1.ConditionTrue), message
+	}
+
+	return false, ""
+}
+
+func observedGenerationStatus(obj map[string]interface{}) (bool, string) {
+	generation, found, _ := unstructured.NestedInt64(obj, "metadata", "generation")
+	if !found {
+		return true, ""
+	}
+
+	observedGeneration, _, _ := unstructured.NestedInt64(obj, "status", "observedGeneration")
+	if observedGeneration < generation {
+		return false, fmt.Sprintf("waiting for generation %d to be observed", generation)
+	}
+
+	return true, ""
+}
+
+// pvcWaitsForFirstConsumer returns true if the claim will not be bound until a pod using it is created
+func pvcWaitsForFirstConsumer(pvc *v1.PersistentVolumeClaim) bool {
+	var storageClass *storagev1.StorageClass
+	if pvc.Spec.StorageClassName != nil {
+		if *pvc.Spec.StorageClassName == "" {
+			return false
+		}
+
+		sc, err := kube.Kubernetes.StorageV1().StorageClasses().Get(*pvc.Spec.StorageClassName, metav1.GetOptions{})
+		if err != nil {
+			return false
+		}
+		storageClass = sc
+	} else {
+		list, err := kube.Kubernetes.StorageV1().StorageClasses().List(metav1.ListOptions{})
+		if err != nil {
+			return false
+		}
+
+		for i := range list.Items {
+			if list.Items[i].Annotations["storageclass.kubernetes.io/is-default-class"] == "true" {
+				storageClass = &list.Items[i]
+				break
+			}
+		}
+	}
+
+	return storageClass != nil && storageClass.VolumeBindingMode != nil && *storageClass.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer
+}
+
+// trackResourcesStatuses polls resources statuses until the deadline, zero deadline means waiting without timeout
+func (waiter *ResourcesWaiter) trackResourcesStatuses(specs []statusTrackSpec, deadline time.Time) error {
+	return logboek.LogProcess("Waiting for release resources statuses", logboek.LogProcessOptions{}, func() error {
+		statusProgressPeriod := waiter.StatusProgressPeriod
+		if statusProgressPeriod == 0 {
+			statusProgressPeriod = 5 * time.Second
+		}
+		lastStatusProgressTime := time.Now()
+		pending := specs
+		statuses := map[string]string{}
+
+		for {
+			var stillPending []statusTrackSpec
+			for _, spec := range pending {
+				ready, failReason, status, err := getResourceStatusFunc(spec)
+				if err != nil {
+					return fmt.Errorf("cannot get %s status: %s", spec.LogName(), err)
+				}
+
+				if failReason != "" {
+					if spec.FailMode == multitrack.IgnoreAndContinueDeployProcess {
+						logboek.LogErrorF("WARNING: %s failed: %s\n", spec.LogName(), failReason)
+						continue
+					}
+
+					return fmt.Errorf("%s failed: %s", spec.LogName(), failReason)
+				}
+
+				if ready {
+					logboek.LogF("%s is ready\n", spec.LogName())
+					continue
+				}
+
+				statuses[spec.LogName()] = status
+				stillPending = append(stillPending, spec)
+			}
+
+			pending = stillPending
+			if len(pending) == 0 {
+				return nil
+			}
+
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				var notReady []string
+				for _, spec := range pending {
+					msg := fmt.Sprintf("%s (%s)", spec.LogName(), statuses[spec.LogName()])
+					if spec.FailMode == multitrack.IgnoreAndContinueDeployProcess {
+						logboek.LogErrorF("WARNING: %s is not ready\n", msg)
+						continue
+					}
+
+					notReady = append(notReady, msg)
+				}
+
+				if len(notReady) != 0 {
+					return fmt.Errorf("timed out waiting for %s", strings.Join(notReady, ", "))
+				}
+
+				return nil
+			}
+
+			if statusProgressPeriod > 0 && time.Since(lastStatusProgressTime) >= statusProgressPeriod {
+				for _, spec := range pending {
+					logboek.LogInfoF("%s: %s\n", spec.LogName(), statuses[spec.LogName()])
+				}
+				lastStatusProgressTime = time.Now()
+			}
+
+			time.Sleep(statusTrackerPollPeriod)
+		}
+	})
+}
+
+func getResourceStatus(spec statusTrackSpec) (bool, string, string, error) {
+	var res dynamic.ResourceInterface
+	if spec.Namespaced {
+		res = kube.DynamicClient.Resource(spec.GroupVersionResource).Namespace(spec.Namespace)
+	} else {
+		res = kube.DynamicClient.Resource(spec.GroupVersionResource)
+	}
+
+	obj, err := res.Get(spec.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, "", "not found", nil
+		}
+
+		return false, "", "", err
+	}
+
+	ready, failReason, status := resourceStatus(spec, obj.Object)
+	return ready, failReason, status, nil
+}
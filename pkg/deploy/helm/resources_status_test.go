@@ -0,0 +1,192 @@
+package helm
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flant/kubedog/pkg/trackers/rollout/multitrack"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+func TestResourceStatus(t *testing.T) {
+	certificate := func(generation, observedGeneration int64, conditions ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"metadata": map[string]interface{}{"generation": generation},
+			"status":   map[string]interface{}{"observedGeneration": observedGeneration, "conditions": conditions},
+		}
+	}
+
+	condition := func(conditionType, status, message string) map[string]interface{} {
+		return map[string]interface{}{"type": conditionType, "status": status, "message": message}
+	}
+
+	conditionsSpec := statusTrackSpec{Kind: "Certificate", ReadyCondition: "Ready", FailedCondition: "Failed", CheckObservedGeneration: true}
+
+	tests := []struct {
+		name           string
+		spec           statusTrackSpec
+		obj            map[string]interface{}
+		expectedReady  bool
+		expectedFailed bool
+	}{
+		{"ready condition", conditionsSpec, certificate(2, 2, condition("Ready", "True", "")), true, false},
+		{"ready condition is false", conditionsSpec, certificate(2, 2, condition("Ready", "False", "issuing")), false, false},
+		{"no conditions", conditionsSpec, certificate(1, 1), false, false},
+		{"old generation observed", conditionsSpec, certificate(3, 2, condition("Ready", "True", "")), false, false},
+		{"failed condition", conditionsSpec, certificate(2, 2, condition("Failed", "True", "invalid issuer")), false, true},
+		{
+			"bound pvc",
+			statusTrackSpec{Kind: "PersistentVolumeClaim"},
+			map[string]interface{}{"status": map[string]interface{}{"phase": "Bound"}},
+			true, false,
+		},
+		{
+			"pending pvc",
+			statusTrackSpec{Kind: "PersistentVolumeClaim"},
+			map[string]interface{}{"status": map[string]interface{}{"phase": "Pending"}},
+			false, false,
+		},
+		{
+			"lost pvc",
+			statusTrackSpec{Kind: "PersistentVolumeClaim"},
+			map[string]interface{}{"status": map[string]interface{}{"phase": "Lost"}},
+			false, true,
+		},
+		{
+			"load balancer without address",
+			statusTrackSpec{Kind: "Service"},
+			map[string]interface{}{"status": map[string]interface{}{"loadBalancer": map[string]interface{}{}}},
+			false, false,
+		},
+		{
+			"ingress with address",
+			statusTrackSpec{Kind: "Ingress"},
+			map[string]interface{}{"status": map[string]interface{}{"loadBalancer": map[string]interface{}{"ingress": []interface{}{map[string]interface{}{"ip": "10.0.0.1"}}}}},
+			true, false,
+		},
+		{
+			"replica set not ready",
+			statusTrackSpec{Kind: "ReplicaSet"},
+			map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(3)}, "status": map[string]interface{}{"readyReplicas": int64(2)}},
+			false, false,
+		},
+	}
+
+	for _, tt := range tests {
+		ready, failReason, _ := resourceStatus(tt.spec, tt.obj)
+		if ready != tt.expectedReady {
+			t.Errorf("%s: expected ready %v, got %v", tt.name, tt.expectedReady, ready)
+		}
+
+		if (failReason != "") != tt.expectedFailed {
+			t.Errorf("%s: expected failed %v, got fail reason %q", tt.name, tt.expectedFailed, failReason)
+		}
+	}
+}
+
+func TestMakeStatusTrackSpec(t *testing.T) {
+	info := func(annotations map[string]string) *resource.Info {
+		obj := &unstructured.Unstructured{}
+		obj.SetName("app")
+		obj.SetNamespace("ns")
+		obj.SetAnnotations(annotations)
+
+		return &resource.Info{Name: "app", Namespace: "ns", Object: obj}
+	}
+
+	tests := []struct {
+		name             string
+		kind             string
+		annotations      map[string]string
+		expectedFailMode multitrack.FailMode
+	}{
+		{"ingress by default", "Ingress", nil, ""},
+		{"load balancer by default", "Service", nil, ""},
+		{
+			"ingress with ignore fail mode",
+			"Ingress",
+			map[string]string{FailModeAnnoName: string(multitrack.IgnoreAndContinueDeployProcess)},
+			multitrack.IgnoreAndContinueDeployProcess,
+		},
+		{
+			"load balancer with track termination mode",
+			"Service",
+			map[string]string{TrackTerminationModeAnnoName: string(multitrack.WaitUntilResourceReady)},
+			"",
+		},
+		{"pvc by default", "PersistentVolumeClaim", nil, ""},
+	}
+
+	for _, tt := range tests {
+		spec, err := makeStatusTrackSpec(info(tt.annotations), tt.kind)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+
+		if spec.FailMode != tt.expectedFailMode {
+			t.Errorf("%s: expected fail mode %q, got %q", tt.name, tt.expectedFailMode, spec.FailMode)
+		}
+	}
+
+	for _, annotations := range []map[string]string{
+		{FailModeAnnoName: "Unknown"},
+		{TrackTerminationModeAnnoName: "Unknown"},
+		{TrackReadyConditionAnnoName: ""},
+		{TrackObservedGenerationAnnoName: "yes please"},
+	} {
+		if _, err := makeStatusTrackSpec(info(annotations), "Ingress"); err == nil {
+			t.Errorf("expected error for malformed annotations %v", annotations)
+		}
+	}
+
+	if spec, err := makeStatusTrackSpec(info(map[string]string{TrackTerminationModeAnnoName: string(multitrack.NonBlocking)}), "Ingress"); err != nil || spec != nil {
+		t.Errorf("expected ingress with %s track termination mode not to be tracked, got %+v, %v", multitrack.NonBlocking, spec, err)
+	}
+}
+
+func TestTrackResourcesStatuses(t *testing.T) {
+	oldPollPeriod, oldGetResourceStatusFunc := statusTrackerPollPeriod, getResourceStatusFunc
+	defer func() {
+		statusTrackerPollPeriod, getResourceStatusFunc = oldPollPeriod, oldGetResourceStatusFunc
+	}()
+	statusTrackerPollPeriod = time.Millisecond
+
+	pvc := statusTrackSpec{Kind: "PersistentVolumeClaim", Name: "data"}
+	ingress := statusTrackSpec{Kind: "Ingress", Name: "app"}
+	ignoredIngress := statusTrackSpec{Kind: "Ingress", Name: "ignored", FailMode: multitrack.IgnoreAndContinueDeployProcess}
+
+	// resources become ready on the third poll
+	var polls map[string]int
+	getResourceStatusFunc = func(spec statusTrackSpec) (bool, string, string, error) {
+		polls[spec.Name]++
+		if spec.Name == "ignored" || polls[spec.Name] < 3 {
+			return false, "", "waiting for load balancer address", nil
+		}
+
+		return true, "", "", nil
+	}
+
+	waiter := &ResourcesWaiter{StatusProgressPeriod: -1}
+
+	polls = map[string]int{}
+	if err := waiter.trackResourcesStatuses([]statusTrackSpec{pvc, ingress}, time.Time{}); err != nil {
+		t.Fatalf("expected resources to be awaited without deadline: %s", err)
+	}
+
+	if polls["data"] != 3 || polls["app"] != 3 {
+		t.Errorf("expected resources to be polled until ready, got %v", polls)
+	}
+
+	polls = map[string]int{}
+	err := waiter.trackResourcesStatuses([]statusTrackSpec{pvc, ingress}, time.Now().Add(-time.Second))
+	if err == nil || !strings.Contains(err.Error(), "timed out waiting for persistentvolumeclaim/data") {
+		t.Errorf("expected timeout error for passed deadline, got %v", err)
+	}
+
+	polls = map[string]int{}
+	if err := waiter.trackResourcesStatuses([]statusTrackSpec{ignoredIngress}, time.Now().Add(10*time.Millisecond)); err != nil {
+		t.Errorf("expected only warning for %s fail mode, got %s", multitrack.IgnoreAndContinueDeployProcess, err)
+	}
+}
@@ -18,6 +18,8 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	extensions "k8s.io/api/extensions/v1beta1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -42,6 +44,19 @@ func extractSpecReplicas(specReplicas *int32) int {
 
 func (waiter *ResourcesWaiter) WaitForResources(timeout time.Duration, created helmKube.Result) error {
 	specs := multitrack.MultitrackSpecs{}
+	var statusSpecs []statusTrackSpec
+
+	addStatusSpec := func(info *resource.Info, kind string) error {
+		spec, err := makeStatusTrackSpec(info, kind)
+		if err != nil {
+			return fmt.Errorf("cannot track %s %s: %s", kind, info.Name, err)
+		}
+		if spec != nil {
+			statusSpecs = append(statusSpecs, *spec)
+		}
+
+		return nil
+	}
 
 	for _, v := range created {
 		switch value := asVersioned(v).(type) {
@@ -140,16 +155,49 @@ func (waiter *ResourcesWaiter) WaitForResources(timeout time.Duration, created h
 				specs.Jobs = append(specs.Jobs, *spec)
 			}
 		case *v1.ReplicationController:
-		case *extensions.ReplicaSet:
-		case *appsv1beta2.ReplicaSet:
-		case *appsv1.ReplicaSet:
+			if err := addStatusSpec(v, "ReplicationController"); err != nil {
+				return err
+			}
+		case *extensions.ReplicaSet, *appsv1beta2.ReplicaSet, *appsv1.ReplicaSet:
+			if err := addStatusSpec(v, "ReplicaSet"); err != nil {
+				return err
+			}
 		case *v1.PersistentVolumeClaim:
+			if pvcWaitsForFirstConsumer(value) {
+				logboek.LogInfoF("Will not wait for persistentvolumeclaim/%s to be bound: storage class volume binding mode is WaitForFirstConsumer\n", value.Name)
+				continue
+			}
+
+			if err := addStatusSpec(v, "PersistentVolumeClaim"); err != nil {
+				return err
+			}
 		case *v1.Service:
+			if value.Spec.Type == v1.ServiceTypeLoadBalancer {
+				if err := addStatusSpec(v, "Service"); err != nil {
+					return err
+				}
+			}
+		case *extensions.Ingress, *networkingv1beta1.Ingress:
+			if err := addStatusSpec(v, "Ingress"); err != nil {
+				return err
+			}
+		default:
+			if v.Mapping == nil {
+				continue
+			}
+
+			if accessor, err := meta.Accessor(v.Object); err == nil && hasStatusTrackAnnotations(accessor.GetAnnotations()) {
+				if err := addStatusSpec(v, v.Mapping.GroupVersionKind.Kind); err != nil {
+					return err
+				}
+			}
 		}
 	}
 
+	startTime := time.Now()
+
 	logboek.LogOptionalLn()
-	return logboek.LogProcess("Waiting for release resources to become ready", logboek.LogProcessOptions{}, func() error {
+	if err := logboek.LogProcess("Waiting for release resources to become ready", logboek.LogProcessOptions{}, func() error {
 		return multitrack.Multitrack(kube.Kubernetes, specs, multitrack.MultitrackOptions{
 			StatusProgressPeriod: waiter.StatusProgressPeriod,
 			Options: tracker.Options{
@@ -157,7 +205,21 @@ func (waiter *ResourcesWaiter) WaitForResources(timeout time.Duration, created h
 				LogsFromTime: waiter.LogsFromTime,
 			},
 		})
-	})
+	}); err != nil {
+		return err
+	}
+
+	if len(statusSpecs) == 0 {
+		return nil
+	}
+
+	// zero timeout means waiting without timeout as for kubedog
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = startTime.Add(timeout)
+	}
+
+	return waiter.trackResourcesStatuses(statusSpecs, deadline)
 }
 
 func makeMultitrackSpec(objMeta *metav1.ObjectMeta, allowFailuresCountMultiplier int, kind string) (*multitrack.MultitrackSpec, error) {
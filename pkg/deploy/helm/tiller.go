@@ -132,7 +132,7 @@ func Init(options InitOptions) error {
 	}
 	kubeClient.SetResourcesWaiter(resourcesWaiter)
 
-	tillerSettings.KubeClient = kubeClient
+	tillerSettings.KubeClient = &WeightedKubeClient{Client: kubeClient}
 	tillerSettings.EngineYard[WerfTemplateEngineName] = WerfTemplateEngine
 
 	clientset, err := kubeClient.KubernetesClientSet()
@@ -0,0 +1,243 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/helm/pkg/kube"
+
+	"github.com/flant/logboek"
+)
+
+const WeightAnnoName = "werf.io/weight"
+
+var manifestsSeparator = regexp.MustCompile("(?:^|\\s*\n)---\\s*")
+
+// WeightedKubeClient applies regular release resources in groups of werf.io/weight annotation in ascending order
+// and waits until resources of the group are ready before applying the next group
+type WeightedKubeClient struct {
+	*kube.Client
+
+	// resourcesClient applies and builds resources instead of the Client if set
+	resourcesClient weightedResourcesClient
+}
+
+type weightedResourcesClient interface {
+	CreateWithOptions(namespace string, reader io.Reader, opts kube.CreateOptions) error
+	UpdateWithOptions(namespace string, originalReader, targetReader io.Reader, opts kube.UpdateOptions) error
+	Build(namespace string, reader io.Reader) (kube.Result, error)
+}
+
+type weightedManifest struct {
+	Key      string
+	Weight   int
+	Manifest string
+}
+
+type manifestsGroup struct {
+	Weight    int
+	Manifests []weightedManifest
+}
+
+func (c *WeightedKubeClient) resources() weightedResourcesClient {
+	if c.resourcesClient != nil {
+		return c.resourcesClient
+	}
+
+	return c.Client
+}
+
+func (c *WeightedKubeClient) CreateWithOptions(namespace string, reader io.Reader, opts kube.CreateOptions) error {
+	manifests, err := readWeightedManifests(namespace, reader)
+	if err != nil {
+		return err
+	}
+
+	groups := groupManifestsByWeight(manifests)
+	if len(groups) <= 1 {
+		return c.resources().CreateWithOptions(namespace, manifestsReader(manifests), opts)
+	}
+
+	warnNotWaitedGroups(opts.ShouldWait)
+
+	for _, group := range groups {
+		if err := logResourcesGroupProcess(group, func() error {
+			return c.resources().CreateWithOptions(namespace, manifestsReader(group.Manifests), opts)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateWithOptions applies each group against the original manifests of the group resources,
+// resources which are not in the target release are deleted after applying the last group
+func (c *WeightedKubeClient) UpdateWithOptions(namespace string, originalReader, targetReader io.Reader, opts kube.UpdateOptions) error {
+	originalManifests, err := readWeightedManifests(namespace, originalReader)
+	if err != nil {
+		return err
+	}
+
+	targetManifests, err := readWeightedManifests(namespace, targetReader)
+	if err != nil {
+		return err
+	}
+
+	groups := groupManifestsByWeight(targetManifests)
+	if len(groups) <= 1 {
+		return c.resources().UpdateWithOptions(namespace, manifestsReader(originalManifests), manifestsReader(targetManifests), opts)
+	}
+
+	warnNotWaitedGroups(opts.ShouldWait)
+
+	targetKeys := map[string]bool{}
+	for _, m := range targetManifests {
+		targetKeys[m.Key] = true
+	}
+
+	groupOpts := opts
+	groupOpts.ShouldWait = false
+
+	for ind, group := range groups {
+		groupKeys := map[string]bool{}
+		for _, m := range group.Manifests {
+			groupKeys[m.Key] = true
+		}
+
+		// resources of the original release which are not in the target release are deleted with the last group
+		var original []weightedManifest
+		for _, m := range originalManifests {
+			if groupKeys[m.Key] || (ind == len(groups)-1 && !targetKeys[m.Key]) {
+				original = append(original, m)
+			}
+		}
+
+		if err := logResourcesGroupProcess(group, func() error {
+			if err := c.resources().UpdateWithOptions(namespace, manifestsReader(original), manifestsReader(group.Manifests), groupOpts); err != nil {
+				return err
+			}
+
+			if !opts.ShouldWait {
+				return nil
+			}
+
+			return c.waitForManifests(namespace, group.Manifests, time.Duration(opts.Timeout)*time.Second)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *WeightedKubeClient) waitForManifests(namespace string, manifests []weightedManifest, timeout time.Duration) error {
+	if c.ResourcesWaiter == nil {
+		return nil
+	}
+
+	result, err := c.resources().Build(namespace, manifestsReader(manifests))
+	if err != nil {
+		return err
+	}
+
+	return c.ResourcesWaiter.WaitForResources(timeout, result)
+}
+
+func warnNotWaitedGroups(shouldWait bool) {
+	if !shouldWait {
+		logboek.LogErrorF("WARNING: resources are not tracked, groups of %s annotation are applied in order without waiting for readiness of the previous group\n", WeightAnnoName)
+	}
+}
+
+func logResourcesGroupProcess(group manifestsGroup, f func() error) error {
+	logProcessMsg := fmt.Sprintf("Applying resources with weight %d", group.Weight)
+	return logboek.LogProcess(logProcessMsg, logboek.LogProcessOptions{}, f)
+}
+
+func readWeightedManifests(namespace string, reader io.Reader) ([]weightedManifest, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []weightedManifest
+	for _, doc := range manifestsSeparator.Split(string(data), -1) {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+
+		t, err := parseTemplate(doc)
+		if err != nil {
+			return nil, err
+		}
+
+		weight, err := getTemplateWeight(t)
+		if err != nil {
+			return nil, err
+		}
+
+		manifests = append(manifests, weightedManifest{
+			Key:      fmt.Sprintf("%s/%s/%s", t.Kind, t.Namespace(namespace), t.Metadata.Name),
+			Weight:   weight,
+			Manifest: doc,
+		})
+	}
+
+	return manifests, nil
+}
+
+func getTemplateWeight(t Template) (int, error) {
+	value, hasKey := t.Metadata.Annotations[WeightAnnoName]
+	if !hasKey {
+		return 0, nil
+	}
+
+	weight, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s/%s: invalid %s annotation value %q: expected integer", t.Kind, t.Metadata.Name, WeightAnnoName, value)
+	}
+
+	return weight, nil
+}
+
+// groupManifestsByWeight keeps the order of manifests inside a group
+func groupManifestsByWeight(manifests []weightedManifest) []manifestsGroup {
+	groupByWeight := map[int]*manifestsGroup{}
+	for _, m := range manifests {
+		group, exist := groupByWeight[m.Weight]
+		if !exist {
+			group = &manifestsGroup{Weight: m.Weight}
+			groupByWeight[m.Weight] = group
+		}
+
+		group.Manifests = append(group.Manifests, m)
+	}
+
+	var groups []manifestsGroup
+	for _, group := range groupByWeight {
+		groups = append(groups, *group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Weight < groups[j].Weight
+	})
+
+	return groups
+}
+
+func manifestsReader(manifests []weightedManifest) io.Reader {
+	var docs []string
+	for _, m := range manifests {
+		docs = append(docs, m.Manifest)
+	}
+
+	return bytes.NewBufferString(strings.Join(docs, "\n---\n"))
+}
@@ -0,0 +1,257 @@
+package helm
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/helm/pkg/kube"
+)
+
+const weightedManifests = `---
+# Source: chart/templates/app.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    werf.io/weight: "-10"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: other
+  annotations:
+    werf.io/weight: "-10"
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: worker
+  annotations:
+    werf.io/weight: "5"
+`
+
+func TestGroupManifestsByWeight(t *testing.T) {
+	manifests, err := readWeightedManifests("ns", strings.NewReader(weightedManifests))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := groupManifestsByWeight(manifests)
+
+	var got [][]string
+	for _, group := range groups {
+		var keys []string
+		for _, m := range group.Manifests {
+			keys = append(keys, m.Key)
+		}
+		got = append(got, keys)
+	}
+
+	expected := [][]string{
+		{"Job/ns/migrate", "ConfigMap/other/config"},
+		{"Deployment/ns/app"},
+		{"Deployment/ns/worker"},
+	}
+
+	if len(groups) != 3 || groups[0].Weight != -10 || groups[1].Weight != 0 || groups[2].Weight != 5 {
+		t.Fatalf("unexpected groups: %v", got)
+	}
+
+	for i := range expected {
+		if strings.Join(got[i], ",") != strings.Join(expected[i], ",") {
+			t.Errorf("group %d: expected %v, got %v", i, expected[i], got[i])
+		}
+	}
+
+	data, _ := ioutil.ReadAll(manifestsReader(groups[0].Manifests))
+	if !strings.Contains(string(data), "name: migrate") || !strings.Contains(string(data), "\n---\n") || strings.Contains(string(data), "name: app") {
+		t.Errorf("unexpected group manifests:\n%s", data)
+	}
+}
+
+func TestReadWeightedManifestsInvalidWeight(t *testing.T) {
+	_, err := readWeightedManifests("ns", strings.NewReader(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  annotations:
+    werf.io/weight: high
+`))
+	if err == nil {
+		t.Fatal("expected error for invalid weight")
+	}
+}
+
+const originalWeightedManifests = `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: old
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: other
+  annotations:
+    werf.io/weight: "-10"
+`
+
+// fakeWeightedResourcesClient records applied and awaited resources keys
+type fakeWeightedResourcesClient struct {
+	calls []string
+}
+
+func (c *fakeWeightedResourcesClient) CreateWithOptions(namespace string, reader io.Reader, _ kube.CreateOptions) error {
+	c.calls = append(c.calls, fmt.Sprintf("create %s", c.keys(namespace, reader)))
+	return nil
+}
+
+func (c *fakeWeightedResourcesClient) UpdateWithOptions(namespace string, originalReader, targetReader io.Reader, _ kube.UpdateOptions) error {
+	c.calls = append(c.calls, fmt.Sprintf("update %s -> %s", c.keys(namespace, originalReader), c.keys(namespace, targetReader)))
+	return nil
+}
+
+func (c *fakeWeightedResourcesClient) Build(namespace string, reader io.Reader) (kube.Result, error) {
+	manifests, err := readWeightedManifests(namespace, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var result kube.Result
+	for _, m := range manifests {
+		result = append(result, &resource.Info{Name: m.Key})
+	}
+
+	return result, nil
+}
+
+func (c *fakeWeightedResourcesClient) WatchUntilReady(_ string, _ io.Reader, _ time.Duration) error {
+	return nil
+}
+
+func (c *fakeWeightedResourcesClient) WaitForResources(_ time.Duration, created kube.Result) error {
+	var names []string
+	for _, info := range created {
+		names = append(names, info.Name)
+	}
+
+	c.calls = append(c.calls, fmt.Sprintf("wait [%s]", strings.Join(names, " ")))
+	return nil
+}
+
+func (c *fakeWeightedResourcesClient) keys(namespace string, reader io.Reader) string {
+	manifests, err := readWeightedManifests(namespace, reader)
+	if err != nil {
+		return err.Error()
+	}
+
+	var keys []string
+	for _, m := range manifests {
+		keys = append(keys, m.Key)
+	}
+
+	return fmt.Sprintf("[%s]", strings.Join(keys, " "))
+}
+
+func newFakeWeightedKubeClient() (*WeightedKubeClient, *fakeWeightedResourcesClient) {
+	fake := &fakeWeightedResourcesClient{}
+	return &WeightedKubeClient{Client: &kube.Client{ResourcesWaiter: fake}, resourcesClient: fake}, fake
+}
+
+func TestWeightedKubeClient_UpdateWithOptions(t *testing.T) {
+	applyCalls := []string{
+		"update [ConfigMap/other/config] -> [Job/ns/migrate ConfigMap/other/config]",
+		"update [Deployment/ns/app] -> [Deployment/ns/app]",
+		"update [ConfigMap/ns/old] -> [Deployment/ns/worker]",
+	}
+	waitCalls := []string{
+		"wait [Job/ns/migrate ConfigMap/other/config]",
+		"wait [Deployment/ns/app]",
+		"wait [Deployment/ns/worker]",
+	}
+
+	tests := []struct {
+		name       string
+		shouldWait bool
+		expected   []string
+	}{
+		{"with wait", true, []string{applyCalls[0], waitCalls[0], applyCalls[1], waitCalls[1], applyCalls[2], waitCalls[2]}},
+		{"without wait", false, applyCalls},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, fake := newFakeWeightedKubeClient()
+
+			if err := c.UpdateWithOptions("ns", strings.NewReader(originalWeightedManifests), strings.NewReader(weightedManifests), kube.UpdateOptions{ShouldWait: tt.shouldWait}); err != nil {
+				t.Fatal(err)
+			}
+
+			if strings.Join(fake.calls, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("expected calls:\n%s\ngot:\n%s", strings.Join(tt.expected, "\n"), strings.Join(fake.calls, "\n"))
+			}
+		})
+	}
+}
+
+func TestWeightedKubeClient_WithoutWeights(t *testing.T) {
+	c, fake := newFakeWeightedKubeClient()
+
+	manifests := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+`
+
+	if err := c.UpdateWithOptions("ns", strings.NewReader(manifests), strings.NewReader(manifests), kube.UpdateOptions{ShouldWait: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// resources with the only weight are applied and awaited by the kube client at once
+	expected := "update [ConfigMap/ns/config Deployment/ns/app] -> [ConfigMap/ns/config Deployment/ns/app]"
+	if strings.Join(fake.calls, "\n") != expected {
+		t.Errorf("unexpected calls:\n%s", strings.Join(fake.calls, "\n"))
+	}
+}
+
+func TestWeightedKubeClient_CreateWithOptions(t *testing.T) {
+	c, fake := newFakeWeightedKubeClient()
+
+	if err := c.CreateWithOptions("ns", strings.NewReader(weightedManifests), kube.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"create [Job/ns/migrate ConfigMap/other/config]",
+		"create [Deployment/ns/app]",
+		"create [Deployment/ns/worker]",
+	}
+	if strings.Join(fake.calls, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected calls:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(fake.calls, "\n"))
+	}
+}
@@ -43,7 +43,7 @@ func RunLint(projectDir string, werfConfig *config.WerfConfig, opts LintOptions)
 	tagStrategy := tag_strategy.GitBranch
 	namespace := "NAMESPACE"
 
-	images := GetImagesInfoGetters(werfConfig.StapelImages, werfConfig.ImagesFromDockerfile, imagesRepoManager, tag, true)
+	images := GetImagesInfoGetters(werfConfig.StapelImages, werfConfig.ImagesFromDockerfile, imagesRepoManager, tag, nil, true)
 
 	serviceValues, err := GetServiceValues(werfConfig.Meta.Project, imagesRepoManager, namespace, tag, tagStrategy, images, ServiceValuesOptions{Env: opts.Env})
 	if err != nil {
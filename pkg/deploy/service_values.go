@@ -22,16 +22,27 @@ type ImageInfoGetter interface {
 	GetImageDigest() (string, error)
 }
 
-func GetImagesInfoGetters(configImages []*config.StapelImage, configImagesFromDockerfile []*config.ImageFromDockerfile, imagesRepoManager ImagesRepoManager, tag string, withoutRegistry bool) []ImageInfoGetter {
+// GetImagesInfoGetters uses imagesTags as tag of the image with the same name if specified and common tag otherwise
+func GetImagesInfoGetters(configImages []*config.StapelImage, configImagesFromDockerfile []*config.ImageFromDockerfile, imagesRepoManager ImagesRepoManager, tag string, imagesTags map[string]string, withoutRegistry bool) []ImageInfoGetter {
 	var images []ImageInfoGetter
 
+	imageTag := func(imageName string) string {
+		if imagesTags != nil {
+			if t, hasKey := imagesTags[imageName]; hasKey {
+				return t
+			}
+		}
+
+		return tag
+	}
+
 	for _, image := range configImages {
-		d := &ImageInfo{Name: image.Name, WithoutRegistry: withoutRegistry, ImagesRepoManager: imagesRepoManager, Tag: tag}
+		d := &ImageInfo{Name: image.Name, WithoutRegistry: withoutRegistry, ImagesRepoManager: imagesRepoManager, Tag: imageTag(image.Name)}
 		images = append(images, d)
 	}
 
 	for _, image := range configImagesFromDockerfile {
-		d := &ImageInfo{Name: image.Name, WithoutRegistry: withoutRegistry, ImagesRepoManager: imagesRepoManager, Tag: tag}
+		d := &ImageInfo{Name: image.Name, WithoutRegistry: withoutRegistry, ImagesRepoManager: imagesRepoManager, Tag: imageTag(image.Name)}
 		images = append(images, d)
 	}
 
@@ -46,12 +57,13 @@ func GetServiceValues(projectName string, imagesRepoManager ImagesRepoManager, n
 	res := make(map[string]interface{})
 
 	ciInfo := map[string]interface{}{
-		"is_tag":    false,
-		"is_branch": false,
-		"is_custom": false,
-		"branch":    TemplateEmptyValue,
-		"tag":       TemplateEmptyValue,
-		"ref":       TemplateEmptyValue,
+		"is_tag":              false,
+		"is_branch":           false,
+		"is_custom":           false,
+		"is_stages_signature": false,
+		"branch":              TemplateEmptyValue,
+		"tag":                 TemplateEmptyValue,
+		"ref":                 TemplateEmptyValue,
 	}
 
 	werfInfo := map[string]interface{}{
@@ -83,6 +95,9 @@ func GetServiceValues(projectName string, imagesRepoManager ImagesRepoManager, n
 
 	case tag_strategy.Custom:
 		ciInfo["is_custom"] = true
+
+	case tag_strategy.StagesSignature:
+		ciInfo["is_stages_signature"] = true
 	}
 
 	imagesInfo := make(map[string]interface{})
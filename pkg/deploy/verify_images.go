@@ -0,0 +1,65 @@
+package deploy
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/flant/logboek"
+
+	"github.com/flant/werf/pkg/docker_registry"
+	"github.com/flant/werf/pkg/image_signing"
+)
+
+// VerifyImages returns images which are referenced by the verified digests,
+// so the tag moved after verification does not affect the release
+func VerifyImages(images []ImageInfoGetter, imagesRepoManager ImagesRepoManager, key ed25519.PublicKey) ([]ImageInfoGetter, error) {
+	var verifiedImages []ImageInfoGetter
+
+	logboek.LogLn()
+	err := logboek.LogProcess("Verifying images signatures", logboek.LogProcessOptions{}, func() error {
+		for _, image := range images {
+			imageName := image.GetImageName()
+			imageRepo := imagesRepoManager.ImageRepo(image.GetName())
+
+			digest, err := image_signing.Verify(imageRepo, imageName, key)
+			if err != nil {
+				return fmt.Errorf("image %s verification failed: %s", imageName, err)
+			}
+
+			logboek.LogF("%s: signature verified (%s)\n", imageName, digest)
+
+			verifiedImages = append(verifiedImages, &verifiedImageInfo{ImageInfoGetter: image, imageRepo: imageRepo, digest: digest})
+		}
+
+		return nil
+	})
+
+	return verifiedImages, err
+}
+
+type verifiedImageInfo struct {
+	ImageInfoGetter
+	imageRepo string
+	digest    string
+}
+
+func (d *verifiedImageInfo) GetImageName() string {
+	return fmt.Sprintf("%s@%s", d.imageRepo, d.digest)
+}
+
+func (d *verifiedImageInfo) GetImageId() (string, error) {
+	imageName := d.GetImageName()
+
+	res, err := docker_registry.ImageId(imageName)
+	if err != nil {
+		logboek.LogErrorF("WARNING: Getting image %s id failed: %s\n", imageName, err)
+		return "", nil
+	}
+
+	return res, nil
+}
+
+func (d *verifiedImageInfo) GetImageDigest() (string, error) {
+	return d.digest, nil
+}
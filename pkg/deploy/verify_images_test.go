@@ -0,0 +1,99 @@
+package deploy
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flant/go-containerregistry/pkg/name"
+	"github.com/flant/go-containerregistry/pkg/registry"
+	"github.com/flant/go-containerregistry/pkg/v1/random"
+	"github.com/flant/go-containerregistry/pkg/v1/remote"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/flant/werf/pkg/image_signing"
+	"github.com/flant/werf/pkg/tag_strategy"
+)
+
+type testImagesRepoManager struct {
+	imagesRepo string
+}
+
+func (m testImagesRepoManager) ImagesRepo() string {
+	return m.imagesRepo
+}
+
+func (m testImagesRepoManager) ImageRepo(imageName string) string {
+	return strings.Join([]string{m.imagesRepo, imageName}, "/")
+}
+
+func (m testImagesRepoManager) ImageRepoWithTag(imageName, tag string) string {
+	return strings.Join([]string{m.ImageRepo(imageName), tag}, ":")
+}
+
+func pushRandomImage(t *testing.T, reference string) string {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref, err := name.ParseReference(reference, name.WeakValidation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return digest.String()
+}
+
+func TestVerifyImages(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+
+	imagesRepoManager := testImagesRepoManager{imagesRepo: strings.TrimPrefix(server.URL, "http://")}
+	imageRepo := imagesRepoManager.ImageRepo("backend")
+
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := pushRandomImage(t, imagesRepoManager.ImageRepoWithTag("backend", "master"))
+	if err := image_signing.Sign(imageRepo, digest, key); err != nil {
+		t.Fatal(err)
+	}
+
+	images := []ImageInfoGetter{&ImageInfo{Name: "backend", ImagesRepoManager: imagesRepoManager, Tag: "master"}}
+	verifiedImages, err := VerifyImages(images, imagesRepoManager, key.Public().(ed25519.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the tag moved after verification must not affect the release
+	pushRandomImage(t, imagesRepoManager.ImageRepoWithTag("backend", "master"))
+
+	values, err := GetServiceValues("project", imagesRepoManager, "namespace", "master", tag_strategy.GitBranch, verifiedImages, ServiceValuesOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imageValues := values["global"].(map[string]interface{})["werf"].(map[string]interface{})["image"].(map[string]interface{})["backend"].(map[string]interface{})
+
+	expectedImage := fmt.Sprintf("%s@%s", imageRepo, digest)
+	if imageValues["docker_image"] != expectedImage {
+		t.Errorf("expected docker_image %s, got %v", expectedImage, imageValues["docker_image"])
+	}
+
+	if imageValues["docker_image_digest"] != digest {
+		t.Errorf("expected docker_image_digest %s, got %v", digest, imageValues["docker_image_digest"])
+	}
+}
@@ -108,6 +108,15 @@ func (chart *WerfChart) Deploy(releaseName string, namespace string, opts helm.C
 	return helm.DeployHelmChart(chart.ChartDir, releaseName, namespace, opts)
 }
 
+func (chart *WerfChart) Diff(releaseName string, namespace string, opts helm.ChartOptions, diffOpts helm.DiffOptions) (bool, error) {
+	opts.SecretValues = append(chart.SecretValues, opts.SecretValues...)
+	opts.Set = append(chart.Set, opts.Set...)
+	opts.SetString = append(chart.SetString, opts.SetString...)
+	opts.Values = append(chart.Values, opts.Values...)
+
+	return helm.DiffHelmChart(chart.ChartDir, releaseName, namespace, opts, diffOpts)
+}
+
 func (chart *WerfChart) MergeExtraAnnotations(extraAnnotations map[string]string) {
 	for annoName, annoValue := range extraAnnotations {
 		chart.ExtraAnnotations[annoName] = annoValue
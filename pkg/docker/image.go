@@ -1,10 +1,21 @@
 package docker
 
 import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+
 	"github.com/docker/cli/cli/command/image"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
 	"golang.org/x/net/context"
+
+	"github.com/flant/logboek"
+
+	"github.com/flant/werf/pkg/util/secretvalues"
 )
 
 func Images(options types.ImageListOptions) ([]types.ImageSummary, error) {
@@ -106,3 +117,40 @@ func CliBuild(args ...string) error {
 
 	return nil
 }
+
+// CliBuildWithBuildKit runs docker build using BuildKit embedded into the docker daemon.
+// The embedded docker cli reads DOCKER_BUILDKIT from the process env, so the docker binary is run with its own env instead
+func CliBuildWithBuildKit(args ...string) error {
+	cmd := cliBuildWithBuildKitCommand(args...)
+	cmd.Stdout = secretvalues.NewMaskingWriter(logboek.GetOutStream(), OutputSecretValuesToMask)
+	cmd.Stderr = secretvalues.NewMaskingWriter(logboek.GetErrStream(), OutputSecretValuesToMask)
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("docker build failed: %s", err)
+	}
+
+	return nil
+}
+
+func cliBuildWithBuildKitCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command("docker", append([]string{"build"}, args...)...)
+	cmd.Env = append(os.Environ(), "DOCKER_BUILDKIT=1")
+
+	return cmd
+}
+
+func ImageLoad(input io.Reader) error {
+	ctx := context.Background()
+	resp, err := apiClient.ImageLoad(ctx, input, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return jsonmessage.DisplayJSONMessagesStream(resp.Body, ioutil.Discard, 0, false, nil)
+}
+
+func ImageSave(refs ...string) (io.ReadCloser, error) {
+	ctx := context.Background()
+	return apiClient.ImageSave(ctx, refs)
+}
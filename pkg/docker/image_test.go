@@ -0,0 +1,36 @@
+package docker
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCliBuildWithBuildKitCommand(t *testing.T) {
+	prevValue, isSet := os.LookupEnv("DOCKER_BUILDKIT")
+	if err := os.Setenv("DOCKER_BUILDKIT", "0"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if isSet {
+			_ = os.Setenv("DOCKER_BUILDKIT", prevValue)
+		} else {
+			_ = os.Unsetenv("DOCKER_BUILDKIT")
+		}
+	}()
+
+	cmd := cliBuildWithBuildKitCommand("--tag=image", "context")
+
+	if args := strings.Join(cmd.Args, " "); args != "docker build --tag=image context" {
+		t.Errorf("unexpected command args %q", args)
+	}
+
+	// the last value of the duplicated variable is used by the command
+	if cmd.Env[len(cmd.Env)-1] != "DOCKER_BUILDKIT=1" {
+		t.Errorf("DOCKER_BUILDKIT=1 expected to override the process env, got %v", cmd.Env)
+	}
+
+	if value := os.Getenv("DOCKER_BUILDKIT"); value != "0" {
+		t.Errorf("process env must not be changed, got DOCKER_BUILDKIT=%q", value)
+	}
+}
@@ -13,13 +13,26 @@ import (
 	"github.com/docker/cli/cli/flags"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
+
+	"github.com/flant/werf/pkg/util/secretvalues"
 )
 
 var (
 	cli       *command.DockerCli
 	apiClient *client.Client
+
+	outputSecretValuesToMask []string
 )
 
+// SetOutputSecretValuesToMask sets the values which are masked in the output of docker commands
+func SetOutputSecretValuesToMask(secretValuesToMask []string) {
+	outputSecretValuesToMask = secretValuesToMask
+}
+
+func OutputSecretValuesToMask() []string {
+	return outputSecretValuesToMask
+}
+
 func Init(dockerConfigDir string) error {
 	if dockerConfigDir != "" {
 		cliconfig.SetDir(dockerConfigDir)
@@ -52,8 +65,8 @@ func ServerVersion() (*types.Version, error) {
 
 func setDockerClient() error {
 	cliOpts := []command.DockerCliOption{
-		command.WithOutputStream(logboek.GetOutStream()),
-		command.WithErrorStream(logboek.GetErrStream()),
+		command.WithOutputStream(secretvalues.NewMaskingWriter(logboek.GetOutStream(), OutputSecretValuesToMask)),
+		command.WithErrorStream(secretvalues.NewMaskingWriter(logboek.GetErrStream(), OutputSecretValuesToMask)),
 		command.WithContentTrust(false),
 	}
 
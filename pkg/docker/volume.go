@@ -1,6 +1,9 @@
 package docker
 
 import (
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	volumetypes "github.com/docker/docker/api/types/volume"
 	"golang.org/x/net/context"
 )
 
@@ -8,3 +11,30 @@ func VolumeRm(volumeName string, force bool) error {
 	ctx := context.Background()
 	return apiClient.VolumeRemove(ctx, volumeName, force)
 }
+
+func VolumeCreate(volumeName string, labels map[string]string) error {
+	ctx := context.Background()
+	_, err := apiClient.VolumeCreate(ctx, volumetypes.VolumeCreateBody{Name: volumeName, Labels: labels})
+	return err
+}
+
+func Volumes(filterSet filters.Args) ([]*types.Volume, error) {
+	ctx := context.Background()
+	resp, err := apiClient.VolumeList(ctx, filterSet)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Volumes, nil
+}
+
+// VolumesDiskUsage returns volumes with usage data (size and references count) calculated by docker
+func VolumesDiskUsage() ([]*types.Volume, error) {
+	ctx := context.Background()
+	du, err := apiClient.DiskUsage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return du.Volumes, nil
+}
@@ -0,0 +1,31 @@
+package docker_registry
+
+import (
+	"fmt"
+
+	"github.com/flant/go-containerregistry/pkg/authn"
+	"github.com/flant/go-containerregistry/pkg/name"
+	v1 "github.com/flant/go-containerregistry/pkg/v1"
+	"github.com/flant/go-containerregistry/pkg/v1/empty"
+	"github.com/flant/go-containerregistry/pkg/v1/mutate"
+	"github.com/flant/go-containerregistry/pkg/v1/remote"
+)
+
+// PushArtifact pushes an image without layers which carries the data in the config labels
+func PushArtifact(reference string, labels map[string]string) error {
+	ref, err := name.ParseReference(reference, parseReferenceOptions()...)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %v", reference, err)
+	}
+
+	img, err := mutate.Config(empty.Image, v1.Config{Labels: labels})
+	if err != nil {
+		return err
+	}
+
+	if err := remote.Write(ref, img, remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithTransport(getHttpTransport())); err != nil {
+		return fmt.Errorf("writing artifact %q: %v", ref, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,19 @@
+package docker_registry
+
+import (
+	"strings"
+
+	"github.com/flant/go-containerregistry/pkg/name"
+)
+
+// defaultImplementation uses Docker Registry HTTP API V2 and deletes images by digest
+type defaultImplementation struct{}
+
+func (defaultImplementation) DeleteRepoImage(_ name.Repository, repoImage RepoImage) error {
+	digest, err := repoImage.Digest()
+	if err != nil {
+		return err
+	}
+
+	return ImageDelete(strings.Join([]string{repoImage.Repository, digest.String()}, "@"))
+}
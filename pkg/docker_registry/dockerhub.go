@@ -0,0 +1,80 @@
+package docker_registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/flant/go-containerregistry/pkg/name"
+)
+
+const dockerHubApiUrl = "https://hub.docker.com/v2"
+
+var (
+	dockerHubTokens      = map[string]string{}
+	dockerHubTokensMutex sync.Mutex
+)
+
+// dockerHub does not support deletion through Docker Registry HTTP API V2,
+// tags are deleted with Docker Hub API using JWT token of the user from docker config
+type dockerHub struct{}
+
+func (dockerHub) DeleteRepoImage(repository name.Repository, repoImage RepoImage) error {
+	token, err := dockerHubToken(repository.Registry)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/repositories/%s/tags/%s/", dockerHubApiUrl, repository.RepositoryStr(), repoImage.Tag)
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("JWT %s", token))
+
+	_, err = doRequest(req, http.StatusOK, http.StatusAccepted, http.StatusNoContent)
+	return err
+}
+
+func dockerHubToken(registry name.Registry) (string, error) {
+	username, password, err := registryBasicCredentials(registry)
+	if err != nil {
+		return "", err
+	}
+
+	dockerHubTokensMutex.Lock()
+	defer dockerHubTokensMutex.Unlock()
+
+	if token, ok := dockerHubTokens[username]; ok {
+		return token, nil
+	}
+
+	data, err := json.Marshal(map[string]string{"username": username, "password": password})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/users/login/", dockerHubApiUrl), bytes.NewBuffer(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	body, err := doRequest(req, http.StatusOK)
+	if err != nil {
+		return "", fmt.Errorf("docker hub login failed: %s", err)
+	}
+
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("unable to parse docker hub login response: %s", err)
+	}
+
+	dockerHubTokens[username] = resp.Token
+
+	return resp.Token, nil
+}
@@ -0,0 +1,36 @@
+package docker_registry
+
+import (
+	"strings"
+
+	"github.com/flant/go-containerregistry/pkg/authn"
+	"github.com/flant/go-containerregistry/pkg/name"
+	"github.com/flant/go-containerregistry/pkg/v1/google"
+)
+
+// gcr does not allow to delete a manifest which is referenced by tags:
+// the tag is deleted first and the manifest is deleted only when it is not tagged anymore
+type gcr struct{}
+
+func (gcr) DeleteRepoImage(repository name.Repository, repoImage RepoImage) error {
+	if err := ImageDelete(strings.Join([]string{repoImage.Repository, repoImage.Tag}, ":")); err != nil {
+		return err
+	}
+
+	digest, err := repoImage.Digest()
+	if err != nil {
+		return err
+	}
+
+	tags, err := google.List(repository, google.WithAuthFromKeychain(authn.DefaultKeychain), google.WithTransport(getHttpTransport()))
+	if err != nil {
+		return err
+	}
+
+	manifest, ok := tags.Manifests[digest.String()]
+	if !ok || len(manifest.Tags) != 0 {
+		return nil
+	}
+
+	return ImageDelete(strings.Join([]string{repoImage.Repository, digest.String()}, "@"))
+}
@@ -0,0 +1,69 @@
+package docker_registry
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/flant/go-containerregistry/pkg/authn"
+	"github.com/flant/go-containerregistry/pkg/name"
+)
+
+// gitLab deletes images by digest with the scope that GitLab Container Registry requires
+type gitLab struct{}
+
+func (gitLab) DeleteRepoImage(repository name.Repository, repoImage RepoImage) error {
+	digest, err := repoImage.Digest()
+	if err != nil {
+		return err
+	}
+
+	reference := strings.Join([]string{repoImage.Repository, digest.String()}, "@")
+	r, err := name.ParseReference(reference, parseReferenceOptions()...)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %v", reference, err)
+	}
+
+	auth, err := authn.DefaultKeychain.Resolve(repository.Registry)
+	if err != nil {
+		return fmt.Errorf("getting creds for %q: %v", r, err)
+	}
+
+	return GitlabRegistryDelete(r, auth, getHttpTransport())
+}
+
+var bearerRealmRegexp = regexp.MustCompile(`(?i)^bearer .*realm="([^"]+)"`)
+
+// isGitLab checks the token auth realm of Docker Registry HTTP API V2: GitLab Container Registry issues tokens with GitLab /jwt/auth endpoint
+func isGitLab(registry name.Registry) bool {
+	u := url.URL{
+		Scheme: registry.Scheme(),
+		Host:   registry.RegistryStr(),
+		Path:   "/v2/",
+	}
+
+	client := &http.Client{Transport: getHttpTransport()}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+
+	match := bearerRealmRegexp.FindStringSubmatch(resp.Header.Get("WWW-Authenticate"))
+	if match == nil {
+		return false
+	}
+
+	realm, err := url.Parse(match[1])
+	if err != nil {
+		return false
+	}
+
+	return strings.HasSuffix(realm.Path, "/jwt/auth")
+}
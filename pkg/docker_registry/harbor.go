@@ -0,0 +1,89 @@
+package docker_registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/flant/go-containerregistry/pkg/name"
+)
+
+const (
+	harborApiV1 = 1
+	harborApiV2 = 2
+)
+
+// harbor does not support deletion through Docker Registry HTTP API V2, tags are deleted with Harbor API:
+// Harbor 1.x provides API v1 and Harbor 2.x provides API v2.0
+type harbor struct {
+	apiVersion int
+}
+
+func (h harbor) DeleteRepoImage(repository name.Repository, repoImage RepoImage) error {
+	username, password, err := registryBasicCredentials(repository.Registry)
+	if err != nil {
+		return err
+	}
+
+	var path string
+	switch h.apiVersion {
+	case harborApiV2:
+		parts := strings.SplitN(repository.RepositoryStr(), "/", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("harbor repository %q should be in the project: PROJECT/REPOSITORY", repository.RepositoryStr())
+		}
+
+		// nested repository name should be double escaped
+		path = fmt.Sprintf("/api/v2.0/projects/%s/repositories/%s/artifacts/%s", url.PathEscape(parts[0]), url.PathEscape(url.PathEscape(parts[1])), url.PathEscape(repoImage.Tag))
+	default:
+		path = fmt.Sprintf("/api/repositories/%s/tags/%s", repository.RepositoryStr(), url.PathEscape(repoImage.Tag))
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s://%s%s", repository.Registry.Scheme(), repository.RegistryStr(), path), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(username, password)
+
+	_, err = doRequest(req, http.StatusOK)
+	return err
+}
+
+// detectHarborApiVersion requests unauthenticated system info API, API v1 is used if the version cannot be detected
+func detectHarborApiVersion(registry name.Registry) (int, bool) {
+	for _, apiVersion := range []int{harborApiV2, harborApiV1} {
+		path := "/api/systeminfo"
+		if apiVersion == harborApiV2 {
+			path = "/api/v2.0/systeminfo"
+		}
+
+		u := url.URL{
+			Scheme: registry.Scheme(),
+			Host:   registry.RegistryStr(),
+			Path:   path,
+		}
+
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			continue
+		}
+
+		body, err := doRequest(req, http.StatusOK)
+		if err != nil {
+			continue
+		}
+
+		var systemInfo map[string]interface{}
+		if err := json.Unmarshal(body, &systemInfo); err != nil {
+			continue
+		}
+
+		if _, ok := systemInfo["harbor_version"]; ok {
+			return apiVersion, true
+		}
+	}
+
+	return harborApiV1, false
+}
@@ -0,0 +1,376 @@
+package docker_registry
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flant/go-containerregistry/pkg/authn"
+	"github.com/flant/go-containerregistry/pkg/name"
+	v1 "github.com/flant/go-containerregistry/pkg/v1"
+	"github.com/flant/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/flant/go-containerregistry/pkg/v1/types"
+	"github.com/flant/logboek"
+
+	"github.com/flant/werf/pkg/werf"
+)
+
+const (
+	ImageConfigCacheVersion   = "1"
+	ImageManifestCacheVersion = "1"
+)
+
+var (
+	cacheableManifestMediaTypes = []types.MediaType{types.DockerManifestSchema2, types.OCIManifestSchema1}
+
+	// ImageCacheMaxUnusedPeriod is the period after which unused manifests and config files are removed by host cleanup
+	ImageCacheMaxUnusedPeriod = 7 * 24 * time.Hour
+)
+
+// cachedImage is a remote image which manifest and config file have been taken from the local cache,
+// the remote image is requested only when layers are needed
+type cachedImage struct {
+	ref           name.Reference
+	digest        v1.Hash
+	mediaType     types.MediaType
+	rawManifest   []byte
+	rawConfigFile []byte
+
+	remoteImageMutex sync.Mutex
+	remoteImage      v1.Image
+}
+
+func (i *cachedImage) MediaType() (types.MediaType, error) {
+	return i.mediaType, nil
+}
+
+func (i *cachedImage) Digest() (v1.Hash, error) {
+	return i.digest, nil
+}
+
+func (i *cachedImage) RawManifest() ([]byte, error) {
+	return i.rawManifest, nil
+}
+
+func (i *cachedImage) Manifest() (*v1.Manifest, error) {
+	return v1.ParseManifest(bytes.NewReader(i.rawManifest))
+}
+
+func (i *cachedImage) ConfigName() (v1.Hash, error) {
+	manifest, err := i.Manifest()
+	if err != nil {
+		return v1.Hash{}, err
+	}
+
+	return manifest.Config.Digest, nil
+}
+
+func (i *cachedImage) RawConfigFile() ([]byte, error) {
+	return i.rawConfigFile, nil
+}
+
+func (i *cachedImage) ConfigFile() (*v1.ConfigFile, error) {
+	return v1.ParseConfigFile(bytes.NewReader(i.rawConfigFile))
+}
+
+func (i *cachedImage) Layers() ([]v1.Layer, error) {
+	remoteImage, err := i.getRemoteImage()
+	if err != nil {
+		return nil, err
+	}
+
+	return remoteImage.Layers()
+}
+
+func (i *cachedImage) LayerByDigest(hash v1.Hash) (v1.Layer, error) {
+	remoteImage, err := i.getRemoteImage()
+	if err != nil {
+		return nil, err
+	}
+
+	return remoteImage.LayerByDigest(hash)
+}
+
+func (i *cachedImage) LayerByDiffID(hash v1.Hash) (v1.Layer, error) {
+	remoteImage, err := i.getRemoteImage()
+	if err != nil {
+		return nil, err
+	}
+
+	return remoteImage.LayerByDiffID(hash)
+}
+
+// getRemoteImage requests the image by digest, so the tag can be moved meanwhile
+func (i *cachedImage) getRemoteImage() (v1.Image, error) {
+	i.remoteImageMutex.Lock()
+	defer i.remoteImageMutex.Unlock()
+
+	if i.remoteImage != nil {
+		return i.remoteImage, nil
+	}
+
+	digestReference := fmt.Sprintf("%s@%s", i.ref.Context().Name(), i.digest)
+	remoteImage, _, err := image(digestReference)
+	if err != nil {
+		return nil, err
+	}
+
+	i.remoteImage = remoteImage
+
+	return i.remoteImage, nil
+}
+
+func getImageConfigCacheDir() string {
+	return filepath.Join(werf.GetLocalCacheDir(), "registry_image_configs", ImageConfigCacheVersion)
+}
+
+func getImageManifestCacheDir() string {
+	return filepath.Join(werf.GetLocalCacheDir(), "registry_image_manifests", ImageManifestCacheVersion)
+}
+
+// cachedImageByReference returns the image with manifest and config file from the local cache.
+// Manifest and config file are addressed by digest, so they never change and are fetched from the registry only once,
+// the digest of the tag is requested with a HEAD request which has no body and is not counted by Docker Hub rate limits
+func cachedImageByReference(reference string) (v1.Image, error) {
+	ref, err := name.ParseReference(reference, parseReferenceOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reference %q: %v", reference, err)
+	}
+
+	if digest, mediaType, ok := headManifest(ref); ok {
+		if rawManifest, ok, err := readImageCacheFile(manifestCachePath(digest), digest); err != nil {
+			return nil, err
+		} else if ok {
+			return newCachedImage(ref, digest, mediaType, rawManifest, nil)
+		}
+	}
+
+	remoteImage, _, err := image(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := remoteImage.Digest()
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, err := remoteImage.MediaType()
+	if err != nil {
+		return nil, err
+	}
+
+	rawManifest, err := remoteImage.RawManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	cachedImage, err := newCachedImage(ref, digest, mediaType, rawManifest, remoteImage)
+	if err != nil {
+		return nil, err
+	}
+
+	if isCacheableManifestMediaType(mediaType) {
+		if err := writeImageCacheFile(manifestCachePath(digest), rawManifest); err != nil {
+			return nil, err
+		}
+	}
+
+	return cachedImage, nil
+}
+
+func newCachedImage(ref name.Reference, digest v1.Hash, mediaType types.MediaType, rawManifest []byte, remoteImage v1.Image) (*cachedImage, error) {
+	i := &cachedImage{ref: ref, digest: digest, mediaType: mediaType, rawManifest: rawManifest, remoteImage: remoteImage}
+
+	configDigest, err := i.ConfigName()
+	if err != nil {
+		return nil, err
+	}
+
+	cachePath := filepath.Join(getImageConfigCacheDir(), configDigest.Algorithm, configDigest.Hex)
+	if rawConfigFile, ok, err := readImageCacheFile(cachePath, configDigest); err != nil {
+		return nil, err
+	} else if ok {
+		i.rawConfigFile = rawConfigFile
+		return i, nil
+	}
+
+	remoteImage, err = i.getRemoteImage()
+	if err != nil {
+		return nil, err
+	}
+
+	rawConfigFile, err := remoteImage.RawConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeImageCacheFile(cachePath, rawConfigFile); err != nil {
+		return nil, err
+	}
+
+	i.rawConfigFile = rawConfigFile
+
+	return i, nil
+}
+
+// headManifest returns the manifest digest of the reference,
+// the caller falls back to fetching the manifest if the registry does not provide the digest
+func headManifest(ref name.Reference) (v1.Hash, types.MediaType, bool) {
+	auth, err := authn.DefaultKeychain.Resolve(ref.Context().Registry)
+	if err != nil {
+		return v1.Hash{}, "", false
+	}
+
+	rt, err := transport.New(ref.Context().Registry, auth, getHttpTransport(), []string{ref.Scope(transport.PullScope)})
+	if err != nil {
+		return v1.Hash{}, "", false
+	}
+
+	u := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", ref.Context().Registry.Scheme(), ref.Context().RegistryStr(), ref.Context().RepositoryStr(), ref.Identifier())
+	req, err := http.NewRequest(http.MethodHead, u, nil)
+	if err != nil {
+		return v1.Hash{}, "", false
+	}
+
+	var accept []string
+	for _, mediaType := range cacheableManifestMediaTypes {
+		accept = append(accept, string(mediaType))
+	}
+	req.Header.Set("Accept", strings.Join(accept, ","))
+
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		return v1.Hash{}, "", false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return v1.Hash{}, "", false
+	}
+
+	mediaType := types.MediaType(resp.Header.Get("Content-Type"))
+	if !isCacheableManifestMediaType(mediaType) {
+		return v1.Hash{}, "", false
+	}
+
+	digest, err := v1.NewHash(resp.Header.Get("Docker-Content-Digest"))
+	if err != nil {
+		return v1.Hash{}, "", false
+	}
+
+	return digest, mediaType, true
+}
+
+func isCacheableManifestMediaType(mediaType types.MediaType) bool {
+	for _, cacheableMediaType := range cacheableManifestMediaTypes {
+		if mediaType == cacheableMediaType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func manifestCachePath(digest v1.Hash) string {
+	return filepath.Join(getImageManifestCacheDir(), digest.Algorithm, digest.Hex)
+}
+
+// readImageCacheFile returns the cached data only if it matches the digest, the corrupted file is removed to be fetched again
+func readImageCacheFile(cachePath string, digest v1.Hash) ([]byte, bool, error) {
+	data, err := ioutil.ReadFile(cachePath)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("unable to read image cache file %s: %s", cachePath, err)
+	}
+
+	dataDigest, _, err := v1.SHA256(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to calculate digest of image cache file %s: %s", cachePath, err)
+	}
+
+	if dataDigest != digest {
+		if err := os.Remove(cachePath); err != nil && !os.IsNotExist(err) {
+			return nil, false, fmt.Errorf("unable to remove corrupted image cache file %s: %s", cachePath, err)
+		}
+
+		return nil, false, nil
+	}
+
+	// modification time is the last usage time for ImageCacheGC
+	now := time.Now()
+	_ = os.Chtimes(cachePath, now, now)
+
+	return data, true, nil
+}
+
+func writeImageCacheFile(cachePath string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(cachePath), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create image cache dir: %s", err)
+	}
+
+	// rename is atomic, so concurrent werf processes never read a partially written file
+	tmpFile, err := ioutil.TempFile(filepath.Dir(cachePath), filepath.Base(cachePath))
+	if err != nil {
+		return fmt.Errorf("unable to create image cache file: %s", err)
+	}
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("unable to write image cache file %s: %s", tmpFile.Name(), err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("unable to write image cache file %s: %s", tmpFile.Name(), err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), cachePath); err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("unable to write image cache file %s: %s", cachePath, err)
+	}
+
+	return nil
+}
+
+// ImageCacheGC removes manifests and config files which have not been used for ImageCacheMaxUnusedPeriod
+func ImageCacheGC(dryRun bool) error {
+	expiryTime := time.Now().Add(-ImageCacheMaxUnusedPeriod)
+
+	for _, dir := range []string{getImageManifestCacheDir(), getImageConfigCacheDir()} {
+		if err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			if info.IsDir() || !info.ModTime().Before(expiryTime) {
+				return nil
+			}
+
+			logboek.LogInfoF("Removing %s\n", path)
+			if dryRun {
+				return nil
+			}
+
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+
+			return nil
+		}); err != nil {
+			return fmt.Errorf("unable to cleanup image cache dir %s: %s", dir, err)
+		}
+	}
+
+	return nil
+}
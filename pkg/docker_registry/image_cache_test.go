@@ -0,0 +1,317 @@
+package docker_registry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flant/go-containerregistry/pkg/name"
+	"github.com/flant/go-containerregistry/pkg/registry"
+	v1 "github.com/flant/go-containerregistry/pkg/v1"
+	"github.com/flant/go-containerregistry/pkg/v1/mutate"
+	"github.com/flant/go-containerregistry/pkg/v1/random"
+	"github.com/flant/go-containerregistry/pkg/v1/remote"
+
+	"github.com/flant/werf/pkg/werf"
+)
+
+type requestsRecorder struct {
+	mutex    sync.Mutex
+	requests []string
+}
+
+func (r *requestsRecorder) record(req *http.Request) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	path := req.URL.Path
+	switch {
+	case strings.Contains(path, "/manifests/"):
+		path = "manifests"
+	case strings.Contains(path, "/blobs/"):
+		path = "blobs"
+	}
+
+	r.requests = append(r.requests, fmt.Sprintf("%s %s", req.Method, path))
+}
+
+func (r *requestsRecorder) reset() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	requests := r.requests
+	r.requests = nil
+
+	return requests
+}
+
+func initTestWerfHome(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "werf-home")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := werf.Init(dir, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() { os.RemoveAll(dir) }
+}
+
+func pushTestImage(t *testing.T, reference string, label string) v1.Image {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err = mutate.Config(img, v1.Config{Labels: map[string]string{"label": label}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref, err := name.ParseReference(reference, name.WeakValidation, name.Insecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	// test registry stores manifests by the pushed reference only
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digestRef, err := name.NewDigest(fmt.Sprintf("%s@%s", ref.Context().Name(), digest), name.WeakValidation, name.Insecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := remote.Write(digestRef, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return img
+}
+
+func TestCachedImageByReference(t *testing.T) {
+	defer initTestWerfHome(t)()
+
+	recorder := &requestsRecorder{}
+	registryHandler := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/" {
+			recorder.record(r)
+		}
+		registryHandler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	reference := fmt.Sprintf("%s/werf/app:tag", strings.TrimPrefix(server.URL, "http://"))
+
+	expectImage := func(img v1.Image, expectedImage v1.Image, expectedLabel string) {
+		expectedDigest, err := expectedImage.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		digest, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if digest != expectedDigest {
+			t.Errorf("expected digest %s, got %s", expectedDigest, digest)
+		}
+
+		configFile, err := img.ConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if label := configFile.Config.Labels["label"]; label != expectedLabel {
+			t.Errorf("expected label %q, got %q", expectedLabel, label)
+		}
+	}
+
+	expectRequests := func(expected ...string) {
+		if requests := recorder.reset(); strings.Join(requests, ", ") != strings.Join(expected, ", ") {
+			t.Errorf("expected requests [%s], got [%s]", strings.Join(expected, ", "), strings.Join(requests, ", "))
+		}
+	}
+
+	pushedImage := pushTestImage(t, reference, "first")
+	recorder.reset()
+
+	img, err := cachedImageByReference(reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectImage(img, pushedImage, "first")
+	expectRequests("HEAD manifests", "GET manifests", "GET blobs")
+
+	img, err = cachedImageByReference(reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectImage(img, pushedImage, "first")
+	expectRequests("HEAD manifests")
+
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 1 {
+		t.Errorf("expected 1 layer, got %d", len(layers))
+	}
+	expectRequests("GET manifests")
+
+	movedImage := pushTestImage(t, reference, "second")
+	recorder.reset()
+
+	img, err = cachedImageByReference(reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectImage(img, movedImage, "second")
+	expectRequests("HEAD manifests", "GET manifests", "GET blobs")
+}
+
+func TestCachedImageByReference_NotFound(t *testing.T) {
+	defer initTestWerfHome(t)()
+
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+
+	reference := fmt.Sprintf("%s/werf/app:tag", strings.TrimPrefix(server.URL, "http://"))
+
+	if _, err := cachedImageByReference(reference); err == nil || !strings.Contains(err.Error(), "NAME_UNKNOWN") {
+		t.Errorf("expected NAME_UNKNOWN error, got %v", err)
+	}
+}
+
+func TestCachedImageByReference_CorruptedCache(t *testing.T) {
+	defer initTestWerfHome(t)()
+
+	recorder := &requestsRecorder{}
+	registryHandler := registry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/" {
+			recorder.record(r)
+		}
+		registryHandler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	reference := fmt.Sprintf("%s/werf/app:tag", strings.TrimPrefix(server.URL, "http://"))
+	pushedImage := pushTestImage(t, reference, "first")
+
+	if _, err := cachedImageByReference(reference); err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := pushedImage.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configDigest, err := pushedImage.ConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifestPath := manifestCachePath(digest)
+	configPath := filepath.Join(getImageConfigCacheDir(), configDigest.Algorithm, configDigest.Hex)
+	for _, path := range []string{manifestPath, configPath} {
+		if err := ioutil.WriteFile(path, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recorder.reset()
+
+	img, err := cachedImageByReference(reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configFile, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if label := configFile.Config.Labels["label"]; label != "first" {
+		t.Errorf("expected label %q, got %q", "first", label)
+	}
+
+	if requests := strings.Join(recorder.reset(), ", "); requests != "HEAD manifests, GET manifests, GET blobs" {
+		t.Errorf("expected corrupted cache to be refetched, got requests [%s]", requests)
+	}
+
+	for path, expectedDigest := range map[string]v1.Hash{manifestPath: digest, configPath: configDigest} {
+		if _, ok, err := readImageCacheFile(path, expectedDigest); err != nil {
+			t.Fatal(err)
+		} else if !ok {
+			t.Errorf("expected cache file %s to be rewritten", path)
+		}
+	}
+}
+
+func TestImageCacheGC(t *testing.T) {
+	defer initTestWerfHome(t)()
+
+	writeCacheFile := func(data string, modTime time.Time) (string, v1.Hash) {
+		digest, _, err := v1.SHA256(strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		path := manifestCachePath(digest)
+		if err := writeImageCacheFile(path, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+
+		return path, digest
+	}
+
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	unusedPath, _ := writeCacheFile("unused", time.Now().Add(-ImageCacheMaxUnusedPeriod-time.Hour))
+	recentPath, _ := writeCacheFile("recent", time.Now())
+	usedPath, usedDigest := writeCacheFile("used", time.Now().Add(-ImageCacheMaxUnusedPeriod-time.Hour))
+
+	if _, ok, err := readImageCacheFile(usedPath, usedDigest); err != nil || !ok {
+		t.Fatalf("expected cache file to be read: %v", err)
+	}
+
+	if err := ImageCacheGC(true); err != nil {
+		t.Fatal(err)
+	}
+
+	if !exists(unusedPath) {
+		t.Errorf("expected dry run to keep %s", unusedPath)
+	}
+
+	if err := ImageCacheGC(false); err != nil {
+		t.Fatal(err)
+	}
+
+	if exists(unusedPath) || !exists(recentPath) || !exists(usedPath) {
+		t.Errorf("unexpected cache files after gc: unused %v, recent %v, used %v", exists(unusedPath), exists(recentPath), exists(usedPath))
+	}
+}
@@ -0,0 +1,186 @@
+package docker_registry
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/flant/go-containerregistry/pkg/authn"
+	"github.com/flant/go-containerregistry/pkg/name"
+)
+
+const (
+	ImplementationAuto      = "auto"
+	ImplementationDefault   = "default"
+	ImplementationDockerHub = "dockerhub"
+	ImplementationGCR       = "gcr"
+	ImplementationGitLab    = "gitlab"
+	ImplementationHarbor    = "harbor"
+	ImplementationQuay      = "quay"
+)
+
+var (
+	Implementations = []string{ImplementationAuto, ImplementationDefault, ImplementationDockerHub, ImplementationGCR, ImplementationGitLab, ImplementationHarbor, ImplementationQuay}
+
+	Implementation = ImplementationAuto
+
+	// implementationsByRegistry caches implementations, so the detection requests are done once per registry
+	implementationsByRegistry = map[string]registryImplementation{}
+	implementationsMutex      sync.Mutex
+)
+
+// registryImplementation deletes tags using API of the certain registry, tags are listed with Docker Registry HTTP API V2 for all registries
+type registryImplementation interface {
+	DeleteRepoImage(repository name.Repository, repoImage RepoImage) error
+}
+
+func ValidateImplementation(implementation string) error {
+	for _, supportedImplementation := range Implementations {
+		if implementation == supportedImplementation {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unsupported registry implementation '%s': %s are supported", implementation, strings.Join(Implementations, ", "))
+}
+
+func DeleteRepoImage(repoImage RepoImage) error {
+	repository, err := name.NewRepository(repoImage.Repository, newRepositoryOptions()...)
+	if err != nil {
+		return fmt.Errorf("parsing repo %q: %v", repoImage.Repository, err)
+	}
+
+	implementation, err := getRegistryImplementation(repository.Registry)
+	if err != nil {
+		return err
+	}
+
+	if err := implementation.DeleteRepoImage(repository, repoImage); err != nil {
+		return fmt.Errorf("deleting image %s:%s: %s", repoImage.Repository, repoImage.Tag, err)
+	}
+
+	return nil
+}
+
+func getRegistryImplementation(registry name.Registry) (registryImplementation, error) {
+	implementationsMutex.Lock()
+	defer implementationsMutex.Unlock()
+
+	if implementation, ok := implementationsByRegistry[registry.RegistryStr()]; ok {
+		return implementation, nil
+	}
+
+	var implementation registryImplementation
+	if Implementation == "" || Implementation == ImplementationAuto {
+		var err error
+		implementation, err = detectImplementationByRegistry(registry)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		implementation = newRegistryImplementation(Implementation, registry)
+	}
+
+	implementationsByRegistry[registry.RegistryStr()] = implementation
+
+	return implementation, nil
+}
+
+func newRegistryImplementation(implementationName string, registry name.Registry) registryImplementation {
+	switch implementationName {
+	case ImplementationDockerHub:
+		return dockerHub{}
+	case ImplementationGCR:
+		return gcr{}
+	case ImplementationGitLab:
+		return gitLab{}
+	case ImplementationHarbor:
+		apiVersion, _ := detectHarborApiVersion(registry)
+		return harbor{apiVersion: apiVersion}
+	case ImplementationQuay:
+		return quay{token: QuayToken}
+	default:
+		return defaultImplementation{}
+	}
+}
+
+func detectImplementationByRegistry(registry name.Registry) (registryImplementation, error) {
+	host := registry.RegistryStr()
+
+	switch {
+	case host == name.DefaultRegistry || host == "docker.io" || host == "registry-1.docker.io":
+		return dockerHub{}, nil
+	case host == "quay.io":
+		return quay{token: QuayToken}, nil
+	}
+
+	if isGCR, err := IsGCR(host); err != nil {
+		return nil, err
+	} else if isGCR {
+		return gcr{}, nil
+	}
+
+	if isGitLab(registry) {
+		return gitLab{}, nil
+	}
+
+	if apiVersion, isHarbor := detectHarborApiVersion(registry); isHarbor {
+		return harbor{apiVersion: apiVersion}, nil
+	}
+
+	return defaultImplementation{}, nil
+}
+
+func registryBasicCredentials(registry name.Registry) (string, string, error) {
+	auth, err := authn.DefaultKeychain.Resolve(registry)
+	if err != nil {
+		return "", "", fmt.Errorf("getting creds for %q: %v", registry, err)
+	}
+
+	authorization, err := auth.Authorization()
+	if err != nil {
+		return "", "", fmt.Errorf("getting creds for %q: %v", registry, err)
+	}
+
+	if !strings.HasPrefix(authorization, "Basic ") {
+		return "", "", fmt.Errorf("username and password for %q required (docker login)", registry)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(authorization, "Basic "))
+	if err != nil {
+		return "", "", fmt.Errorf("decoding creds for %q: %v", registry, err)
+	}
+
+	parts := strings.SplitN(string(data), ":", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("bad creds for %q", registry)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+func doRequest(req *http.Request, expectedStatusCodes ...int) ([]byte, error) {
+	client := &http.Client{Transport: getHttpTransport()}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, statusCode := range expectedStatusCodes {
+		if resp.StatusCode == statusCode {
+			return body, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected status code during %s %s: %v; %v", req.Method, req.URL.String(), resp.Status, string(body))
+}
@@ -0,0 +1,292 @@
+package docker_registry
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/flant/go-containerregistry/pkg/name"
+)
+
+func newTestRegistry(t *testing.T, handler http.HandlerFunc) (*httptest.Server, name.Repository) {
+	server := httptest.NewServer(handler)
+
+	repository, err := name.NewRepository(fmt.Sprintf("%s/project/app/backend", strings.TrimPrefix(server.URL, "http://")), name.WeakValidation, name.Insecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return server, repository
+}
+
+func resetImplementations(t *testing.T, opts Options) {
+	if err := Init(opts); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setupDockerConfig(t *testing.T, registry, username, password string) func() {
+	dir, err := ioutil.TempDir("", "werf-docker-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", username, password)))
+	config := fmt.Sprintf(`{"auths": {%q: {"auth": %q}}}`, registry, auth)
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	oldDockerConfig, hasOldDockerConfig := os.LookupEnv("DOCKER_CONFIG")
+	os.Setenv("DOCKER_CONFIG", dir)
+
+	return func() {
+		if hasOldDockerConfig {
+			os.Setenv("DOCKER_CONFIG", oldDockerConfig)
+		} else {
+			os.Unsetenv("DOCKER_CONFIG")
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+func TestGetRegistryImplementation_DetectsOnce(t *testing.T) {
+	var systemInfoRequests int32
+	server, repository := newTestRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v2.0/systeminfo" {
+			atomic.AddInt32(&systemInfoRequests, 1)
+			fmt.Fprint(w, `{"harbor_version": "v2.1.0-1a2b3c"}`)
+			return
+		}
+
+		http.NotFound(w, r)
+	})
+	defer server.Close()
+
+	resetImplementations(t, Options{})
+
+	for i := 0; i < 3; i++ {
+		implementation, err := getRegistryImplementation(repository.Registry)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if implementation != (harbor{apiVersion: harborApiV2}) {
+			t.Fatalf("expected harbor API v2 implementation, got %#v", implementation)
+		}
+	}
+
+	if systemInfoRequests != 1 {
+		t.Errorf("expected detection to be done once, got %d system info requests", systemInfoRequests)
+	}
+}
+
+func TestGetRegistryImplementation_Detection(t *testing.T) {
+	tests := []struct {
+		name       string
+		systemInfo map[string]string
+		expected   registryImplementation
+	}{
+		{"harbor v1", map[string]string{"/api/systeminfo": `{"harbor_version": "v1.10.0"}`}, harbor{apiVersion: harborApiV1}},
+		{"harbor v2", map[string]string{"/api/v2.0/systeminfo": `{"harbor_version": "v2.0.0"}`}, harbor{apiVersion: harborApiV2}},
+		{"not harbor", map[string]string{"/api/systeminfo": `{"version": "1.0"}`}, defaultImplementation{}},
+		{"without api", nil, defaultImplementation{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, repository := newTestRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+				if body, ok := tt.systemInfo[r.URL.Path]; ok {
+					fmt.Fprint(w, body)
+					return
+				}
+
+				http.NotFound(w, r)
+			})
+			defer server.Close()
+
+			resetImplementations(t, Options{})
+
+			implementation, err := getRegistryImplementation(repository.Registry)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if implementation != tt.expected {
+				t.Errorf("expected %#v, got %#v", tt.expected, implementation)
+			}
+		})
+	}
+}
+
+func TestGetRegistryImplementation_DetectsGitLabByAuthRealm(t *testing.T) {
+	tests := []struct {
+		name            string
+		wwwAuthenticate string
+		expected        registryImplementation
+	}{
+		{"gitlab", `Bearer realm="https://gitlab.example.com/jwt/auth",service="container_registry"`, gitLab{}},
+		{"other token service", `Bearer realm="https://auth.example.com/token",service="registry"`, defaultImplementation{}},
+		{"basic auth", `Basic realm="registry"`, defaultImplementation{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, repository := newTestRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/v2/" {
+					w.Header().Set("WWW-Authenticate", tt.wwwAuthenticate)
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				http.NotFound(w, r)
+			})
+			defer server.Close()
+
+			resetImplementations(t, Options{})
+
+			implementation, err := getRegistryImplementation(repository.Registry)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if implementation != tt.expected {
+				t.Errorf("expected %#v, got %#v", tt.expected, implementation)
+			}
+		})
+	}
+}
+
+func TestTags_DoesNotDetectImplementation(t *testing.T) {
+	var apiRequests int32
+	server, repository := newTestRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+		case r.URL.Path == "/v2/project/app/backend/tags/list":
+			fmt.Fprint(w, `{"name": "project/app/backend", "tags": ["v1.0", "v2.0"]}`)
+		case strings.HasPrefix(r.URL.Path, "/api/"):
+			atomic.AddInt32(&apiRequests, 1)
+			http.NotFound(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	defer server.Close()
+
+	resetImplementations(t, Options{InsecureRegistry: true})
+	defer resetImplementations(t, Options{})
+
+	tags, err := Tags(repository.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(tags, ",") != "v1.0,v2.0" {
+		t.Errorf("unexpected tags %v", tags)
+	}
+
+	if apiRequests != 0 {
+		t.Errorf("expected no implementation detection requests, got %d", apiRequests)
+	}
+}
+
+func TestGetRegistryImplementation_Explicit(t *testing.T) {
+	var requests int32
+	server, repository := newTestRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.NotFound(w, r)
+	})
+	defer server.Close()
+
+	resetImplementations(t, Options{Implementation: ImplementationQuay, QuayToken: "token"})
+	defer resetImplementations(t, Options{Implementation: ImplementationAuto})
+
+	implementation, err := getRegistryImplementation(repository.Registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if implementation != (quay{token: "token"}) {
+		t.Errorf("expected quay implementation, got %#v", implementation)
+	}
+
+	if requests != 0 {
+		t.Errorf("expected no detection requests, got %d", requests)
+	}
+}
+
+func TestHarbor_DeleteRepoImage(t *testing.T) {
+	tests := []struct {
+		apiVersion   int
+		expectedPath string
+	}{
+		{harborApiV1, "/api/repositories/project/app/backend/tags/v1.0"},
+		{harborApiV2, "/api/v2.0/projects/project/repositories/app%252Fbackend/artifacts/v1.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("api v%d", tt.apiVersion), func(t *testing.T) {
+			var deleted bool
+			server, repository := newTestRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+				username, password, ok := r.BasicAuth()
+				if r.Method != http.MethodDelete || r.URL.EscapedPath() != tt.expectedPath || !ok || username != "user" || password != "password" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.EscapedPath())
+					http.NotFound(w, r)
+					return
+				}
+
+				deleted = true
+			})
+			defer server.Close()
+
+			defer setupDockerConfig(t, repository.RegistryStr(), "user", "password")()
+
+			if err := (harbor{apiVersion: tt.apiVersion}).DeleteRepoImage(repository, RepoImage{Repository: repository.Name(), Tag: "v1.0"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if !deleted {
+				t.Errorf("expected tag to be deleted")
+			}
+		})
+	}
+}
+
+func TestQuay_DeleteRepoImage(t *testing.T) {
+	var deleted bool
+	server, _ := newTestRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.EscapedPath() != "/api/v1/repository/namespace/app/tag/v1.0" || r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.EscapedPath())
+			http.NotFound(w, r)
+			return
+		}
+
+		deleted = true
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer server.Close()
+
+	repository, err := name.NewRepository(fmt.Sprintf("%s/namespace/app", strings.TrimPrefix(server.URL, "http://")), name.WeakValidation, name.Insecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (quay{token: "token"}).DeleteRepoImage(repository, RepoImage{Repository: repository.Name(), Tag: "v1.0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !deleted {
+		t.Errorf("expected tag to be deleted")
+	}
+
+	if err := (quay{}).DeleteRepoImage(repository, RepoImage{Repository: repository.Name(), Tag: "v1.0"}); err == nil {
+		t.Errorf("expected error without token")
+	}
+}
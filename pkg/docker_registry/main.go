@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/flant/go-containerregistry/pkg/authn"
 	"github.com/flant/go-containerregistry/pkg/name"
@@ -23,6 +24,9 @@ var (
 	InsecureRegistry      = false
 	SkipTlsVerifyRegistry = false
 	GCRUrlPatterns        = []string{"^container\\.cloud\\.google\\.com", "^gcr\\.io", "^.*\\.gcr\\.io"}
+
+	// ImagesFetchWorkersLimit is the number of tags inspected concurrently
+	ImagesFetchWorkersLimit = 10
 )
 
 type RepoImage struct {
@@ -34,11 +38,28 @@ type RepoImage struct {
 type Options struct {
 	InsecureRegistry      bool
 	SkipTlsVerifyRegistry bool
+	Implementation        string
+	QuayToken             string
 }
 
 func Init(opts Options) error {
 	InsecureRegistry = opts.InsecureRegistry
 	SkipTlsVerifyRegistry = opts.SkipTlsVerifyRegistry
+
+	if opts.Implementation != "" {
+		if err := ValidateImplementation(opts.Implementation); err != nil {
+			return err
+		}
+
+		Implementation = opts.Implementation
+	}
+
+	QuayToken = opts.QuayToken
+
+	implementationsMutex.Lock()
+	implementationsByRegistry = map[string]registryImplementation{}
+	implementationsMutex.Unlock()
+
 	return nil
 }
 
@@ -63,40 +84,63 @@ func IsGCR(reference string) (bool, error) {
 }
 
 func ImagesByWerfImageLabel(reference, labelValue string) ([]RepoImage, error) {
-	var repoImages []RepoImage
-
-	tags, err := Tags(reference)
+	allTags, err := Tags(reference)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, tag := range tags {
-		tagReference := strings.Join([]string{reference, tag}, ":")
-		v1Image, _, err := image(tagReference)
-		if err != nil {
-			if strings.Contains(err.Error(), "MANIFEST_UNKNOWN") {
-				logboek.LogErrorF("WARNING: Broken tag %s was skipped: %s\n", tagReference, err)
-				continue
+	// build cache exported by BuildKit is not an image
+	var tags []string
+	for _, tag := range allTags {
+		if !strings.HasPrefix(tag, imagePkg.BuildKitCacheTagPrefix) {
+			tags = append(tags, tag)
+		}
+	}
+
+	results := make([]*imageByTagResult, len(tags))
+	tagIndexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < ImagesFetchWorkersLimit && i < len(tags); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for ind := range tagIndexes {
+				results[ind] = imageByTag(reference, tags[ind])
 			}
+		}()
+	}
+
+	for ind := range tags {
+		tagIndexes <- ind
+	}
+	close(tagIndexes)
+	wg.Wait()
+
+	var repoImages []RepoImage
+	for ind, res := range results {
+		tagReference := strings.Join([]string{reference, tags[ind]}, ":")
 
-			if strings.Contains(err.Error(), "BLOB_UNKNOWN") {
-				logboek.LogErrorF("WARNING: Broken tag %s was skipped: %s\n", tagReference, err)
+		if res.err != nil {
+			if strings.Contains(res.err.Error(), "MANIFEST_UNKNOWN") {
+				logboek.LogErrorF("WARNING: Broken tag %s was skipped: %s\n", tagReference, res.err)
 				continue
 			}
-			return nil, err
-		}
 
-		configFile, err := v1Image.ConfigFile()
-		if err != nil {
-			return nil, err
+			if strings.Contains(res.err.Error(), "BLOB_UNKNOWN") {
+				logboek.LogErrorF("WARNING: Broken tag %s was skipped: %s\n", tagReference, res.err)
+				continue
+			}
+			return nil, res.err
 		}
 
-		for k, v := range configFile.Config.Labels {
+		for k, v := range res.configFile.Config.Labels {
 			if k == imagePkg.WerfImageLabel && v == labelValue {
 				repoImage := RepoImage{
 					Repository: reference,
-					Tag:        tag,
-					Image:      v1Image,
+					Tag:        tags[ind],
+					Image:      res.image,
 				}
 
 				repoImages = append(repoImages, repoImage)
@@ -108,8 +152,33 @@ func ImagesByWerfImageLabel(reference, labelValue string) ([]RepoImage, error) {
 	return repoImages, nil
 }
 
+type imageByTagResult struct {
+	image      v1.Image
+	configFile *v1.ConfigFile
+	err        error
+}
+
+func imageByTag(reference, tag string) *imageByTagResult {
+	v1Image, err := cachedImageByReference(strings.Join([]string{reference, tag}, ":"))
+	if err != nil {
+		return &imageByTagResult{err: err}
+	}
+
+	configFile, err := v1Image.ConfigFile()
+	if err != nil {
+		return &imageByTagResult{err: err}
+	}
+
+	return &imageByTagResult{image: v1Image, configFile: configFile}
+}
+
 func Tags(reference string) ([]string, error) {
-	tags, err := list(reference)
+	repo, err := name.NewRepository(reference, newRepositoryOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing repo %q: %v", reference, err)
+	}
+
+	tags, err := list(repo)
 	if err != nil {
 		if strings.Contains(err.Error(), "NAME_UNKNOWN") {
 			return []string{}, nil
@@ -120,12 +189,7 @@ func Tags(reference string) ([]string, error) {
 	return tags, nil
 }
 
-func list(reference string) ([]string, error) {
-	repo, err := name.NewRepository(reference, newRepositoryOptions()...)
-	if err != nil {
-		return nil, fmt.Errorf("parsing repo %q: %v", reference, err)
-	}
-
+func list(repo name.Repository) ([]string, error) {
 	tags, err := remote.List(repo, remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithTransport(getHttpTransport()))
 	if err != nil {
 		return nil, fmt.Errorf("reading tags for %q: %v", repo, err)
@@ -236,6 +300,29 @@ func GitlabRegistryDelete(ref name.Reference, auth authn.Authenticator, t http.R
 	}
 }
 
+func GetRepoImage(reference, tag string) (RepoImage, error) {
+	v1Image, _, err := image(strings.Join([]string{reference, tag}, ":"))
+	if err != nil {
+		return RepoImage{}, err
+	}
+
+	return RepoImage{Repository: reference, Tag: tag, Image: v1Image}, nil
+}
+
+// PushImage pushes the image as is, the image digest is preserved
+func PushImage(reference string, img v1.Image) error {
+	ref, err := name.ParseReference(reference, parseReferenceOptions()...)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %v", reference, err)
+	}
+
+	if err := remote.Write(ref, img, remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithTransport(getHttpTransport())); err != nil {
+		return fmt.Errorf("writing image %q: %v", ref, err)
+	}
+
+	return nil
+}
+
 func ImageDigest(reference string) (string, error) {
 	i, _, err := image(reference)
 	if err != nil {
@@ -256,14 +343,7 @@ func image(reference string) (v1.Image, name.Reference, error) {
 		return nil, nil, fmt.Errorf("parsing reference %q: %v", reference, err)
 	}
 
-	// FIXME: Hack for the go-containerregistry library,
-	// FIXME: that uses default transport without options to change transport to custom.
-	// FIXME: Needed for the insecure https registry to work.
-	oldDefaultTransport := http.DefaultTransport
-	http.DefaultTransport = getHttpTransport()
-	img, err := remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
-	http.DefaultTransport = oldDefaultTransport
-
+	img, err := remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithTransport(getHttpTransport()))
 	if err != nil {
 		return nil, nil, fmt.Errorf("reading image %q: %v", ref, err)
 	}
@@ -286,8 +366,8 @@ func parseReferenceOptions() []name.Option {
 	return options
 }
 
-func getHttpTransport() (transport http.RoundTripper) {
-	transport = http.DefaultTransport
+func getHttpTransport() http.RoundTripper {
+	var transport http.RoundTripper = http.DefaultTransport
 
 	if SkipTlsVerifyRegistry {
 		defaultTransport := http.DefaultTransport.(*http.Transport)
@@ -306,5 +386,5 @@ func getHttpTransport() (transport http.RoundTripper) {
 		transport = newTransport
 	}
 
-	return
+	return newRetryTransport(transport)
 }
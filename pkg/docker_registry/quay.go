@@ -0,0 +1,39 @@
+package docker_registry
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/flant/go-containerregistry/pkg/name"
+)
+
+// QuayToken is OAuth access token which is required for Quay API
+var QuayToken string
+
+// quay deletes tags with Quay API, the manifest is garbage collected by the registry when it is not tagged anymore
+type quay struct {
+	token string
+}
+
+func (q quay) DeleteRepoImage(repository name.Repository, repoImage RepoImage) error {
+	if q.token == "" {
+		return fmt.Errorf("quay OAuth access token required to delete tags (--repo-quay-token or $WERF_REPO_QUAY_TOKEN)")
+	}
+
+	parts := strings.SplitN(repository.RepositoryStr(), "/", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("quay repository %q should be in the namespace: NAMESPACE/REPOSITORY", repository.RepositoryStr())
+	}
+
+	path := fmt.Sprintf("/api/v1/repository/%s/%s/tag/%s", url.PathEscape(parts[0]), url.PathEscape(parts[1]), url.PathEscape(repoImage.Tag))
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s://%s%s", repository.Registry.Scheme(), repository.RegistryStr(), path), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", q.token))
+
+	_, err = doRequest(req, http.StatusOK, http.StatusNoContent)
+	return err
+}
@@ -0,0 +1,76 @@
+package docker_registry
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const retryAttempts = 5
+
+var (
+	retryInitialDelay = 500 * time.Millisecond
+	retryMaxDelay     = 30 * time.Second
+)
+
+// retryTransport retries idempotent requests when the registry responds with 429 Too Many Requests or 5xx
+type retryTransport struct {
+	inner http.RoundTripper
+}
+
+func newRetryTransport(inner http.RoundTripper) http.RoundTripper {
+	return &retryTransport{inner: inner}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return t.inner.RoundTrip(req)
+	}
+
+	delay := retryInitialDelay
+	for attempt := 1; ; attempt++ {
+		resp, err := t.inner.RoundTrip(req)
+		if err != nil || attempt == retryAttempts || !isRetryableStatusCode(resp.StatusCode) {
+			return resp, err
+		}
+
+		sleep := delay
+		if retryAfter := retryAfterDelay(resp); retryAfter > 0 {
+			sleep = retryAfter
+		}
+		if sleep > retryMaxDelay {
+			sleep = retryMaxDelay
+		}
+
+		resp.Body.Close()
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(sleep):
+		}
+
+		delay *= 2
+	}
+}
+
+func isRetryableStatusCode(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+func retryAfterDelay(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+
+	return 0
+}
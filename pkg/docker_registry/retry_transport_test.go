@@ -0,0 +1,147 @@
+package docker_registry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func setRetryDelays(initialDelay, maxDelay time.Duration) func() {
+	oldInitialDelay, oldMaxDelay := retryInitialDelay, retryMaxDelay
+	retryInitialDelay, retryMaxDelay = initialDelay, maxDelay
+
+	return func() {
+		retryInitialDelay, retryMaxDelay = oldInitialDelay, oldMaxDelay
+	}
+}
+
+func newStatusSequenceServer(statusCodes ...int) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ind := int(atomic.AddInt32(&requests, 1)) - 1
+		if ind >= len(statusCodes) {
+			ind = len(statusCodes) - 1
+		}
+
+		w.WriteHeader(statusCodes[ind])
+	}))
+
+	return server, &requests
+}
+
+func TestRetryTransport(t *testing.T) {
+	defer setRetryDelays(time.Millisecond, 10*time.Millisecond)()
+
+	tests := []struct {
+		name               string
+		method             string
+		statusCodes        []int
+		expectedStatusCode int
+		expectedRequests   int32
+	}{
+		{"success", http.MethodGet, []int{200}, 200, 1},
+		{"too many requests", http.MethodGet, []int{429, 429, 200}, 200, 3},
+		{"server error", http.MethodHead, []int{502, 503, 500, 200}, 200, 4},
+		{"attempts exceeded", http.MethodGet, []int{503}, 503, retryAttempts},
+		{"client error", http.MethodGet, []int{404, 200}, 404, 1},
+		{"not idempotent", http.MethodDelete, []int{503, 200}, 503, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newStatusSequenceServer(tt.statusCodes...)
+			defer server.Close()
+
+			req, err := http.NewRequest(tt.method, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := newRetryTransport(http.DefaultTransport).RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.expectedStatusCode {
+				t.Errorf("expected status code %d, got %d", tt.expectedStatusCode, resp.StatusCode)
+			}
+
+			if *requests != tt.expectedRequests {
+				t.Errorf("expected %d requests, got %d", tt.expectedRequests, *requests)
+			}
+		})
+	}
+}
+
+func TestRetryTransport_Backoff(t *testing.T) {
+	defer setRetryDelays(20*time.Millisecond, 50*time.Millisecond)()
+
+	server, _ := newStatusSequenceServer(503, 503, 503, 200)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	resp, err := newRetryTransport(http.DefaultTransport).RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// 20ms + 40ms + 50ms (limited by max delay)
+	if elapsed := time.Since(start); elapsed < 110*time.Millisecond {
+		t.Errorf("expected exponential backoff, requests have been done in %s", elapsed)
+	}
+}
+
+func TestRetryTransport_ContextCanceled(t *testing.T) {
+	defer setRetryDelays(time.Hour, time.Hour)()
+
+	server, requests := newStatusSequenceServer(503)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newRetryTransport(http.DefaultTransport).RoundTrip(req.WithContext(ctx)); err != context.DeadlineExceeded {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	if *requests != 1 {
+		t.Errorf("expected 1 request, got %d", *requests)
+	}
+}
+
+func TestRetryAfterDelay(t *testing.T) {
+	tests := []struct {
+		retryAfter  string
+		minExpected time.Duration
+		maxExpected time.Duration
+	}{
+		{"", 0, 0},
+		{"3", 3 * time.Second, 3 * time.Second},
+		{"invalid", 0, 0},
+		{time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), time.Second, time.Minute},
+	}
+
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set("Retry-After", tt.retryAfter)
+
+		if delay := retryAfterDelay(resp); delay < tt.minExpected || delay > tt.maxExpected {
+			t.Errorf("Retry-After %q: expected delay from %s to %s, got %s", tt.retryAfter, tt.minExpected, tt.maxExpected, delay)
+		}
+	}
+}
@@ -30,6 +30,7 @@ type Base struct {
 	TmpDir string
 
 	unreachableCommits []string
+	headsCommits       []string
 }
 
 func (repo *Base) HeadCommit() (string, error) {
@@ -340,6 +341,104 @@ func (repo *Base) isCommitExists(repoPath, gitDir string, commit string) (bool,
 	return true, nil
 }
 
+// isCommitReachableFromHeads checks that the commit is an ancestor of any remote branch or tag
+func (repo *Base) isCommitReachableFromHeads(repoPath, gitDir string, commit string) (bool, error) {
+	repository, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return false, fmt.Errorf("cannot open repo `%s`: %s", repoPath, err)
+	}
+
+	commitHash, err := newHash(commit)
+	if err != nil {
+		return false, fmt.Errorf("bad commit hash `%s`: %s", commit, err)
+	}
+
+	if _, err := repository.CommitObject(commitHash); err == plumbing.ErrObjectNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("bad commit `%s`: %s", commit, err)
+	}
+
+	if repo.headsCommits == nil {
+		headsCommits, err := repo.remoteBranchesAndTagsCommits(repoPath)
+		if err != nil {
+			return false, err
+		}
+
+		repo.headsCommits = headsCommits
+	}
+
+	for _, headCommit := range repo.headsCommits {
+		isAncestor, err := true_git.IsAncestor(gitDir, commit, headCommit)
+		if err != nil {
+			return false, err
+		}
+
+		if isAncestor {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (repo *Base) remoteBranchesAndTagsCommits(repoPath string) ([]string, error) {
+	repository, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open repo `%s`: %s", repoPath, err)
+	}
+
+	references, err := repository.References()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0)
+	if err := references.ForEach(func(ref *plumbing.Reference) error {
+		refName := ref.Name().String()
+
+		switch {
+		case strings.HasPrefix(refName, "refs/remotes/origin/") && refName != "refs/remotes/origin/HEAD":
+		case ref.Name().IsTag():
+		default:
+			return nil
+		}
+
+		hash := ref.Hash()
+		if ref.Type() == plumbing.SymbolicReference {
+			resolvedRef, err := repository.Reference(ref.Name(), true)
+			if err != nil {
+				return err
+			}
+			hash = resolvedRef.Hash()
+		}
+
+		if tagObject, err := repository.TagObject(hash); err == nil {
+			commit, err := tagObject.Commit()
+			if err != nil {
+				// annotated tags of non-commit objects
+				return nil
+			}
+			hash = commit.Hash
+		} else if err != plumbing.ErrObjectNotFound {
+			return err
+		}
+
+		for _, commit := range res {
+			if commit == hash.String() {
+				return nil
+			}
+		}
+		res = append(res, hash.String())
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
 func (repo *Base) tagsList(repoPath string) ([]string, error) {
 	repository, err := git.PlainOpen(repoPath)
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/flant/werf/pkg/true_git"
 	"github.com/flant/werf/pkg/util"
 
 	"github.com/flant/logboek"
@@ -17,6 +18,11 @@ type Local struct {
 	Base
 	Path   string
 	GitDir string
+
+	// DevMode makes HeadCommit a commit with uncommitted changes of the work tree on top of HEAD
+	DevMode bool
+
+	devCommit string
 }
 
 func (repo *Local) FindCommitIdByMessage(regex string) (string, error) {
@@ -40,9 +46,28 @@ func (repo *Local) HeadCommit() (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("cannot get repo `%s` head ref: %s", repo.Path, err)
 	}
+
+	if repo.DevMode {
+		return repo.getDevCommit(ref.Hash().String())
+	}
+
 	return fmt.Sprintf("%s", ref.Hash()), nil
 }
 
+func (repo *Local) getDevCommit(headCommit string) (string, error) {
+	if repo.devCommit != "" {
+		return repo.devCommit, nil
+	}
+
+	commit, err := true_git.CreateDevCommit(repo.GitDir, repo.Path, headCommit)
+	if err != nil {
+		return "", fmt.Errorf("cannot create dev commit of repo `%s`: %s", repo.Path, err)
+	}
+	repo.devCommit = commit
+
+	return commit, nil
+}
+
 func (repo *Local) HeadBranchName() (string, error) {
 	return repo.getHeadBranchName(repo.Path)
 }
@@ -63,6 +88,10 @@ func (repo *Local) IsCommitExists(commit string) (bool, error) {
 	return repo.isCommitExists(repo.Path, repo.GitDir, commit)
 }
 
+func (repo *Local) IsCommitReachableFromHeads(commit string) (bool, error) {
+	return repo.isCommitReachableFromHeads(repo.Path, repo.GitDir, commit)
+}
+
 func (repo *Local) TagsList() ([]string, error) {
 	return repo.tagsList(repo.Path)
 }
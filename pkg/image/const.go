@@ -8,6 +8,14 @@ const (
 	WerfImageNameLabel    = "werf-image-name"
 	WerfImageTagLabel     = "werf-image-tag"
 	WerfDockerImageName   = "werf-docker-image-name"
+	WerfDevLabel          = "werf-dev"
+
+	WerfImageGitCommitLabel = "werf-image-git-commit"
+
+	WerfStageNameLabel            = "werf-stage-name"
+	WerfStageImageNameLabel       = "werf-stage-image-name"
+	WerfStageSignatureInputsLabel = "werf-stage-signature-inputs"
+	WerfStageCommandsLabel        = "werf-stage-commands"
 
 	WerfMountTmpDirLabel          = "werf-mount-type-tmp-dir"
 	WerfMountBuildDirLabel        = "werf-mount-type-build-dir"
@@ -18,4 +26,6 @@ const (
 	WerfTagStrategyLabel = "werf-tag-strategy"
 
 	StageContainerNamePrefix = "werf.build."
+
+	BuildKitCacheTagPrefix = "buildkit-cache-"
 )
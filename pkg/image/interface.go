@@ -39,6 +39,15 @@ type Container interface {
 	AddServiceRunCommands(commands ...string)
 	AddRunCommands(commands ...string)
 
+	// AddRunLocks adds locks which are held in shared mode while the container is running
+	AddRunLocks(lockNames ...string)
+
+	// AddRunPrepareFuncs adds functions which are called under the run locks right before running the container
+	AddRunPrepareFuncs(funcs ...func() error)
+
+	// AddRunCleanupFuncs adds functions which are called under the run locks after running the container, even if the run has failed
+	AddRunCleanupFuncs(funcs ...func() error)
+
 	RunOptions() ContainerOptions
 	CommitChangeOptions() ContainerOptions
 	ServiceCommitChangeOptions() ContainerOptions
@@ -3,11 +3,13 @@ package image
 import (
 	"encoding/base64"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/docker/docker/api/types"
 
 	"github.com/flant/logboek"
+	"github.com/flant/shluz"
 	"github.com/flant/werf/pkg/docker"
 	"github.com/flant/werf/pkg/stapel"
 	"github.com/flant/werf/pkg/util"
@@ -18,6 +20,9 @@ type StageImageContainer struct {
 	name                       string
 	runCommands                []string
 	serviceRunCommands         []string
+	runLocks                   []string
+	runPrepareFuncs            []func() error
+	runCleanupFuncs            []func() error
 	runOptions                 *StageImageContainerOptions
 	commitChangeOptions        *StageImageContainerOptions
 	serviceCommitChangeOptions *StageImageContainerOptions
@@ -53,6 +58,18 @@ func (c *StageImageContainer) AddServiceRunCommands(commands ...string) {
 	c.serviceRunCommands = append(c.serviceRunCommands, commands...)
 }
 
+func (c *StageImageContainer) AddRunLocks(lockNames ...string) {
+	c.runLocks = util.UniqStrings(append(c.runLocks, lockNames...))
+}
+
+func (c *StageImageContainer) AddRunPrepareFuncs(funcs ...func() error) {
+	c.runPrepareFuncs = append(c.runPrepareFuncs, funcs...)
+}
+
+func (c *StageImageContainer) AddRunCleanupFuncs(funcs ...func() error) {
+	c.runCleanupFuncs = append(c.runCleanupFuncs, funcs...)
+}
+
 func (c *StageImageContainer) RunOptions() ContainerOptions {
 	return c.runOptions
 }
@@ -251,12 +268,40 @@ func (c *StageImageContainer) prepareInheritedCommitOptions() (*StageImageContai
 	return inheritedOptions, nil
 }
 
-func (c *StageImageContainer) run() error {
+func (c *StageImageContainer) run() (err error) {
 	runArgs, err := c.prepareRunArgs()
 	if err != nil {
 		return err
 	}
 
+	// locks are shared by running containers and taken in the same order by all processes to avoid deadlocks with exclusive lock holders
+	runLocks := append([]string{}, c.runLocks...)
+	sort.Strings(runLocks)
+	for _, lockName := range runLocks {
+		if err := shluz.Lock(lockName, shluz.LockOptions{ReadOnly: true}); err != nil {
+			return fmt.Errorf("failed to lock %s: %s", lockName, err)
+		}
+		defer shluz.Unlock(lockName)
+	}
+
+	defer func() {
+		for _, f := range c.runCleanupFuncs {
+			if cleanupErr := f(); cleanupErr != nil {
+				if err == nil {
+					err = cleanupErr
+				} else {
+					logboek.LogErrorF("WARNING: %s\n", cleanupErr)
+				}
+			}
+		}
+	}()
+
+	for _, f := range c.runPrepareFuncs {
+		if err := f(); err != nil {
+			return err
+		}
+	}
+
 	if err := docker.CliRun(runArgs...); err != nil {
 		return fmt.Errorf("container run failed: %s", err.Error())
 	}
@@ -0,0 +1,101 @@
+package image_signing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/flant/werf/pkg/util"
+	"github.com/flant/werf/pkg/werf"
+)
+
+// GenerateKey returns hex encoded ed25519 signing key and the corresponding public key
+func GenerateKey() (string, string, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+
+	return hex.EncodeToString(privateKey.Seed()), hex.EncodeToString(publicKey), nil
+}
+
+func GetSigningKey(projectDir string) (ed25519.PrivateKey, error) {
+	data, err := getKeyData(projectDir, "WERF_SIGNING_KEY", ".werf_signing_key", "global_signing_key")
+	if err != nil {
+		return nil, fmt.Errorf("signing key not found in: %s", err)
+	}
+
+	key, err := hex.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("bad signing key: %s", err)
+	}
+
+	switch len(key) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(key), nil
+	default:
+		return nil, fmt.Errorf("bad signing key: expected hex encoded ed25519 key of %d bytes", ed25519.SeedSize)
+	}
+}
+
+// GetVerificationKey returns the public key, if no public key has been specified the one is derived from the signing key
+func GetVerificationKey(projectDir string) (ed25519.PublicKey, error) {
+	data, err := getKeyData(projectDir, "WERF_SIGNING_PUBLIC_KEY", ".werf_signing_public_key", "global_signing_public_key")
+	if err != nil {
+		signingKey, signingKeyErr := GetSigningKey(projectDir)
+		if signingKeyErr != nil {
+			return nil, fmt.Errorf("public key not found in: %s; %s", err, signingKeyErr)
+		}
+
+		return signingKey.Public().(ed25519.PublicKey), nil
+	}
+
+	key, err := hex.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("bad public key: %s", err)
+	}
+
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("bad public key: expected hex encoded ed25519 public key of %d bytes", ed25519.PublicKeySize)
+	}
+
+	return ed25519.PublicKey(key), nil
+}
+
+func getKeyData(projectDir, envName, projectFileName, homeFileName string) (string, error) {
+	if data := strings.TrimSpace(os.Getenv(envName)); data != "" {
+		return data, nil
+	}
+
+	projectKeyPath, err := filepath.Abs(filepath.Join(projectDir, projectFileName))
+	if err != nil {
+		return "", err
+	}
+
+	paths := []string{projectKeyPath, filepath.Join(werf.GetHomeDir(), homeFileName)}
+	for _, path := range paths {
+		exist, err := util.FileExists(path)
+		if err != nil {
+			return "", err
+		}
+
+		if exist {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return "", err
+			}
+
+			return strings.TrimSpace(string(data)), nil
+		}
+	}
+
+	return "", fmt.Errorf("'$%s', '%s'", envName, strings.Join(paths, "', '"))
+}
@@ -0,0 +1,93 @@
+package image_signing
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/flant/werf/pkg/docker_registry"
+)
+
+const (
+	SignatureType = "werf image signature"
+
+	SignaturePayloadLabel = "werf.io/signature-payload"
+	SignatureLabel        = "werf.io/signature"
+)
+
+// Payload is the signed document, it binds the image repository with the manifest digest
+type Payload struct {
+	Type       string `json:"type"`
+	Repository string `json:"repository"`
+	Digest     string `json:"digest"`
+}
+
+// SignatureTag returns the tag of the signature artifact of the image with the specified digest
+func SignatureTag(digest string) string {
+	return fmt.Sprintf("%s.sig", strings.Replace(digest, ":", "-", 1))
+}
+
+func SignatureReference(repository, digest string) string {
+	return strings.Join([]string{repository, SignatureTag(digest)}, ":")
+}
+
+// Sign pushes the signature of the image with the specified digest into the image repository
+func Sign(repository, digest string, key ed25519.PrivateKey) error {
+	payload, err := json.Marshal(Payload{Type: SignatureType, Repository: repository, Digest: digest})
+	if err != nil {
+		return err
+	}
+
+	signature := ed25519.Sign(key, payload)
+
+	return docker_registry.PushArtifact(SignatureReference(repository, digest), map[string]string{
+		SignaturePayloadLabel: base64.StdEncoding.EncodeToString(payload),
+		SignatureLabel:        base64.StdEncoding.EncodeToString(signature),
+	})
+}
+
+// Verify checks that the image from the repository has a valid signature made with the key and returns the verified digest,
+// the tag can be moved after verification, so the image should be referenced by the returned digest
+func Verify(repository, imageName string, key ed25519.PublicKey) (string, error) {
+	digest, err := docker_registry.ImageDigest(imageName)
+	if err != nil {
+		return "", fmt.Errorf("unable to get image %s digest: %s", imageName, err)
+	}
+
+	signatureReference := SignatureReference(repository, digest)
+	configFile, err := docker_registry.ImageConfigFile(signatureReference)
+	if err != nil {
+		return "", fmt.Errorf("unable to get image %s signature %s: %s", imageName, signatureReference, err)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(configFile.Config.Labels[SignaturePayloadLabel])
+	if err != nil {
+		return "", fmt.Errorf("bad signature %s payload: %s", signatureReference, err)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(configFile.Config.Labels[SignatureLabel])
+	if err != nil {
+		return "", fmt.Errorf("bad signature %s: %s", signatureReference, err)
+	}
+
+	if !ed25519.Verify(key, payload, signature) {
+		return "", fmt.Errorf("signature %s of image %s is not valid", signatureReference, imageName)
+	}
+
+	var p Payload
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&p); err != nil {
+		return "", fmt.Errorf("bad signature %s payload: %s", signatureReference, err)
+	}
+
+	if p.Type != SignatureType || p.Repository != repository || p.Digest != digest {
+		return "", fmt.Errorf("signature %s does not match image %s (%s)", signatureReference, imageName, digest)
+	}
+
+	return digest, nil
+}
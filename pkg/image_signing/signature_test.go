@@ -0,0 +1,92 @@
+package image_signing
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flant/go-containerregistry/pkg/name"
+	"github.com/flant/go-containerregistry/pkg/registry"
+	"github.com/flant/go-containerregistry/pkg/v1/random"
+	"github.com/flant/go-containerregistry/pkg/v1/remote"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/flant/werf/pkg/docker_registry"
+)
+
+func pushRandomImage(t *testing.T, repository, tag string) string {
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref, err := name.ParseReference(strings.Join([]string{repository, tag}, ":"), name.WeakValidation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return digest.String()
+}
+
+func generateKey(t *testing.T) ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func TestSignAndVerify(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+
+	repository := strings.Join([]string{strings.TrimPrefix(server.URL, "http://"), "project"}, "/")
+
+	key := generateKey(t)
+	anotherKey := generateKey(t)
+
+	signedDigest := pushRandomImage(t, repository, "signed")
+	if err := Sign(repository, signedDigest, key); err != nil {
+		t.Fatalf("unable to sign image: %s", err)
+	}
+
+	pushRandomImage(t, repository, "unsigned")
+
+	if digest, err := Verify(repository, repository+":signed", key.Public().(ed25519.PublicKey)); err != nil {
+		t.Errorf("signed image verification failed: %s", err)
+	} else if digest != signedDigest {
+		t.Errorf("expected verified digest %s, got %s", signedDigest, digest)
+	}
+
+	if _, err := Verify(repository, repository+":signed", anotherKey.Public().(ed25519.PublicKey)); err == nil {
+		t.Errorf("signed image verification with another key expected to fail")
+	}
+
+	if _, err := Verify(repository, repository+":unsigned", key.Public().(ed25519.PublicKey)); err == nil {
+		t.Errorf("unsigned image verification expected to fail")
+	}
+
+	// the signature of one image must not be valid for another image with copied signature artifact
+	copiedDigest := pushRandomImage(t, repository, "copied")
+	configFile, err := docker_registry.ImageConfigFile(SignatureReference(repository, signedDigest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := docker_registry.PushArtifact(SignatureReference(repository, copiedDigest), configFile.Config.Labels); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Verify(repository, repository+":copied", key.Public().(ed25519.PublicKey)); err == nil {
+		t.Errorf("image with copied signature verification expected to fail")
+	}
+}
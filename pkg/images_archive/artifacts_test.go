@@ -0,0 +1,181 @@
+package images_archive
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/flant/go-containerregistry/pkg/registry"
+	v1 "github.com/flant/go-containerregistry/pkg/v1"
+	"github.com/flant/go-containerregistry/pkg/v1/empty"
+	"github.com/flant/go-containerregistry/pkg/v1/layout"
+	"github.com/flant/go-containerregistry/pkg/v1/mutate"
+	"github.com/flant/go-containerregistry/pkg/v1/random"
+
+	"github.com/flant/werf/pkg/build"
+	"github.com/flant/werf/pkg/docker_registry"
+	imagePkg "github.com/flant/werf/pkg/image"
+	"github.com/flant/werf/pkg/image_signing"
+)
+
+// testRegistry extends the in-memory registry with tags list of the pushed manifests
+type testRegistry struct {
+	handler http.Handler
+
+	mutex      sync.Mutex
+	tagsByRepo map[string][]string
+}
+
+func (r *testRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+
+	switch {
+	case req.Method == http.MethodGet && strings.HasSuffix(path, "/tags/list"):
+		repo := strings.TrimSuffix(path, "/tags/list")
+
+		r.mutex.Lock()
+		tags := r.tagsByRepo[repo]
+		r.mutex.Unlock()
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": repo, "tags": tags})
+		return
+	case req.Method == http.MethodPut && strings.Contains(path, "/manifests/"):
+		parts := strings.SplitN(path, "/manifests/", 2)
+		if !strings.HasPrefix(parts[1], "sha256:") {
+			r.mutex.Lock()
+			r.tagsByRepo[parts[0]] = append(r.tagsByRepo[parts[0]], parts[1])
+			r.mutex.Unlock()
+		}
+	}
+
+	r.handler.ServeHTTP(w, req)
+}
+
+type testImagesRepoManager struct {
+	repo string
+}
+
+func (m *testImagesRepoManager) ImageRepo(_ string) string {
+	return m.repo
+}
+
+func (m *testImagesRepoManager) ImageRepoTag(_, tag string) string {
+	return tag
+}
+
+func (m *testImagesRepoManager) ImageRepoWithTag(imageName, tag string) string {
+	return strings.Join([]string{m.ImageRepo(imageName), m.ImageRepoTag(imageName, tag)}, ":")
+}
+
+func TestExportImport_ImageArtifacts(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "werf-images-archive-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	server := httptest.NewServer(&testRegistry{handler: registry.New(), tagsByRepo: map[string][]string{}})
+	defer server.Close()
+
+	if err := docker_registry.Init(docker_registry.Options{Implementation: docker_registry.ImplementationDefault}); err != nil {
+		t.Fatal(err)
+	}
+	defer docker_registry.Init(docker_registry.Options{})
+
+	registryAddr := strings.TrimPrefix(server.URL, "http://")
+	exportRepoManager := &testImagesRepoManager{repo: registryAddr + "/project"}
+	importRepoManager := &testImagesRepoManager{repo: registryAddr + "/imported"}
+
+	img, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err = mutate.Config(img, v1.Config{Labels: map[string]string{
+		imagePkg.WerfImageLabel:       "true",
+		imagePkg.WerfImageNameLabel:   "backend",
+		imagePkg.WerfTagStrategyLabel: "custom",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := docker_registry.PushImage(exportRepoManager.ImageRepoWithTag("backend", "v1"), img); err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	artifactsTags := []string{image_signing.SignatureTag(digest.String()), build.ProvenanceTag(digest.String())}
+	for _, tag := range artifactsTags {
+		if err := docker_registry.PushArtifact(strings.Join([]string{exportRepoManager.repo, tag}, ":"), map[string]string{"tag": tag}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archivePath := filepath.Join(tmpDir, "images.tar")
+	if err := Export(archivePath, ExportOptions{ProjectName: "project", ImagesRepoManager: exportRepoManager, ImagesTags: map[string]string{"backend": "v1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Import(archivePath, ImportOptions{ImagesRepoManager: importRepoManager}); err != nil {
+		t.Fatal(err)
+	}
+
+	importedDigest, err := docker_registry.ImageDigest(importRepoManager.ImageRepoWithTag("backend", "v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if importedDigest != digest.String() {
+		t.Errorf("expected imported image digest %s, got %s", digest, importedDigest)
+	}
+
+	for _, tag := range artifactsTags {
+		exportedArtifactDigest, err := docker_registry.ImageDigest(strings.Join([]string{exportRepoManager.repo, tag}, ":"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		importedArtifactDigest, err := docker_registry.ImageDigest(strings.Join([]string{importRepoManager.repo, tag}, ":"))
+		if err != nil {
+			t.Fatalf("artifact %s expected to be imported: %s", tag, err)
+		}
+
+		if importedArtifactDigest != exportedArtifactDigest {
+			t.Errorf("artifact %s: expected digest %s, got %s", tag, exportedArtifactDigest, importedArtifactDigest)
+		}
+	}
+}
+
+func TestImportImageArtifacts_ForeignArtifact(t *testing.T) {
+	manifestImage := &ManifestImage{
+		Name:      "backend",
+		Digest:    "sha256:aaa",
+		Artifacts: []*ManifestArtifact{{Tag: image_signing.SignatureTag("sha256:bbb"), Digest: "sha256:ccc"}},
+	}
+
+	tmpDir, err := ioutil.TempDir("", "werf-images-archive-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	layoutPath, err := layout.Write(tmpDir, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := importImageArtifacts(layoutPath, "registry.example.com/project", manifestImage); err == nil {
+		t.Errorf("expected error for the artifact of another image digest")
+	}
+}
@@ -0,0 +1,462 @@
+package images_archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/flant/go-containerregistry/pkg/name"
+	v1 "github.com/flant/go-containerregistry/pkg/v1"
+	"github.com/flant/go-containerregistry/pkg/v1/empty"
+	"github.com/flant/go-containerregistry/pkg/v1/layout"
+	"github.com/flant/go-containerregistry/pkg/v1/tarball"
+	"github.com/flant/logboek"
+
+	"github.com/flant/werf/pkg/build"
+	"github.com/flant/werf/pkg/docker"
+	"github.com/flant/werf/pkg/docker_registry"
+	imagePkg "github.com/flant/werf/pkg/image"
+	"github.com/flant/werf/pkg/image_signing"
+	"github.com/flant/werf/pkg/stages_info"
+	"github.com/flant/werf/pkg/util"
+)
+
+// ManifestFileName is the file in the root of the archive which maps werf images to digests of the OCI image layout
+const ManifestFileName = "werf-images.json"
+
+type Manifest struct {
+	Project string           `json:"project"`
+	Images  []*ManifestImage `json:"images"`
+	Stages  []*ManifestStage `json:"stages,omitempty"`
+}
+
+type ManifestImage struct {
+	Name   string `json:"name"`
+	Tag    string `json:"tag"`
+	Digest string `json:"digest"`
+
+	// Stages are signatures of the image stages chain, the last stage first
+	Stages []string `json:"stages,omitempty"`
+
+	// Artifacts are the signature and the provenance of the image
+	Artifacts []*ManifestArtifact `json:"artifacts,omitempty"`
+}
+
+type ManifestArtifact struct {
+	Tag    string `json:"tag"`
+	Digest string `json:"digest"`
+}
+
+type ManifestStage struct {
+	Signature string `json:"signature"`
+	Digest    string `json:"digest"`
+}
+
+type ImagesRepoManager interface {
+	ImageRepo(imageName string) string
+	ImageRepoTag(imageName, tag string) string
+	ImageRepoWithTag(imageName, tag string) string
+}
+
+type ExportOptions struct {
+	ProjectName       string
+	ImagesRepoManager ImagesRepoManager
+
+	// ImagesTags maps werf image name to the tag of the published image
+	ImagesTags map[string]string
+
+	// WithStages enables export of the stages chain of each image from the stages storage
+	WithStages    bool
+	StagesStorage string
+}
+
+func Export(archivePath string, opts ExportOptions) error {
+	layoutDir, err := ioutil.TempDir("", "werf-images-export-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(layoutDir)
+
+	layoutPath, err := layout.Write(layoutDir, empty.Index)
+	if err != nil {
+		return fmt.Errorf("unable to init OCI image layout: %s", err)
+	}
+
+	var stages []*stages_info.Stage
+	if opts.WithStages {
+		stages, err = stages_info.ListStages(stages_info.Options{ProjectName: opts.ProjectName, StagesStorage: opts.StagesStorage})
+		if err != nil {
+			return fmt.Errorf("unable to list stages: %s", err)
+		}
+	}
+
+	manifest := &Manifest{Project: opts.ProjectName}
+	exportedStages := map[string]bool{}
+	tagsByImageRepo := map[string][]string{}
+
+	for _, imageName := range sortedImagesNames(opts.ImagesTags) {
+		tag := opts.ImagesTags[imageName]
+		reference := opts.ImagesRepoManager.ImageRepoWithTag(imageName, tag)
+
+		if err := logboek.LogProcessInline(fmt.Sprintf("Exporting image %s", reference), logboek.LogProcessInlineOptions{}, func() error {
+			repoImage, err := docker_registry.GetRepoImage(opts.ImagesRepoManager.ImageRepo(imageName), opts.ImagesRepoManager.ImageRepoTag(imageName, tag))
+			if err != nil {
+				return err
+			}
+
+			digest, err := appendImage(layoutPath, repoImage.Image, reference)
+			if err != nil {
+				return err
+			}
+
+			imageRepo := opts.ImagesRepoManager.ImageRepo(imageName)
+			if _, ok := tagsByImageRepo[imageRepo]; !ok {
+				tags, err := docker_registry.Tags(imageRepo)
+				if err != nil {
+					return err
+				}
+
+				tagsByImageRepo[imageRepo] = tags
+			}
+
+			artifacts, err := exportImageArtifacts(layoutPath, imageRepo, digest, tagsByImageRepo[imageRepo])
+			if err != nil {
+				return err
+			}
+
+			manifest.Images = append(manifest.Images, &ManifestImage{Name: imageName, Tag: tag, Digest: digest, Artifacts: artifacts})
+
+			return nil
+		}); err != nil {
+			return fmt.Errorf("unable to export image %s: %s", reference, err)
+		}
+
+		if !opts.WithStages {
+			continue
+		}
+
+		manifestImage := manifest.Images[len(manifest.Images)-1]
+		imageStages, err := imageStagesChain(stages, opts.ImagesRepoManager, imageName, tag)
+		if err != nil {
+			return err
+		}
+
+		for _, stage := range imageStages {
+			manifestImage.Stages = append(manifestImage.Stages, stage.Signature)
+
+			if exportedStages[stage.Signature] {
+				continue
+			}
+
+			if err := logboek.LogProcessInline(fmt.Sprintf("Exporting stage %s", stage.Name), logboek.LogProcessInlineOptions{}, func() error {
+				digest, err := exportStage(layoutPath, opts.StagesStorage, stage)
+				if err != nil {
+					return err
+				}
+
+				manifest.Stages = append(manifest.Stages, &ManifestStage{Signature: stage.Signature, Digest: digest})
+
+				return nil
+			}); err != nil {
+				return fmt.Errorf("unable to export stage %s: %s", stage.Name, err)
+			}
+
+			exportedStages[stage.Signature] = true
+		}
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(layoutDir, ManifestFileName), append(manifestData, '\n'), 0644); err != nil {
+		return err
+	}
+
+	return writeTar(layoutDir, archivePath)
+}
+
+type ImportOptions struct {
+	ImagesRepoManager ImagesRepoManager
+
+	// LocalDocker enables loading of images into the local docker daemon instead of pushing into images repo
+	LocalDocker bool
+
+	// StagesStorage is optional, stages from the archive are skipped when it is not specified
+	StagesStorage string
+}
+
+func Import(archivePath string, opts ImportOptions) error {
+	layoutDir, err := ioutil.TempDir("", "werf-images-import-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(layoutDir)
+
+	if err := readTar(archivePath, layoutDir); err != nil {
+		return fmt.Errorf("unable to unpack archive %s: %s", archivePath, err)
+	}
+
+	manifest, err := readManifest(layoutDir)
+	if err != nil {
+		return err
+	}
+
+	layoutPath, err := layout.FromPath(layoutDir)
+	if err != nil {
+		return fmt.Errorf("bad OCI image layout: %s", err)
+	}
+
+	for _, manifestImage := range manifest.Images {
+		reference := opts.ImagesRepoManager.ImageRepoWithTag(manifestImage.Name, manifestImage.Tag)
+
+		if err := logboek.LogProcessInline(fmt.Sprintf("Importing image %s", reference), logboek.LogProcessInlineOptions{}, func() error {
+			img, err := layoutImage(layoutPath, manifestImage.Digest)
+			if err != nil {
+				return err
+			}
+
+			if err := validateImageLabels(img, manifestImage); err != nil {
+				return err
+			}
+
+			if opts.LocalDocker {
+				if len(manifestImage.Artifacts) != 0 {
+					logboek.LogErrorF("WARNING: signature and provenance of image %s are skipped: they can be imported only into images repo\n", manifestImage.Name)
+				}
+
+				return loadImageIntoDocker(img, reference)
+			}
+
+			if err := docker_registry.PushImage(reference, img); err != nil {
+				return err
+			}
+
+			return importImageArtifacts(layoutPath, opts.ImagesRepoManager.ImageRepo(manifestImage.Name), manifestImage)
+		}); err != nil {
+			return fmt.Errorf("unable to import image %s: %s", manifestImage.Name, err)
+		}
+	}
+
+	if len(manifest.Stages) == 0 {
+		return nil
+	}
+
+	if opts.StagesStorage == "" {
+		logboek.LogErrorF("WARNING: archive contains stages, but stages storage is not specified: stages are skipped\n")
+		return nil
+	}
+
+	for _, manifestStage := range manifest.Stages {
+		var reference string
+		if opts.StagesStorage == build.LocalStagesStorage {
+			reference = fmt.Sprintf(build.LocalImageStageImageFormat, manifest.Project, manifestStage.Signature)
+		} else {
+			reference = fmt.Sprintf("%s:%s", opts.StagesStorage, fmt.Sprintf(build.RepoImageStageTagFormat, manifestStage.Signature))
+		}
+
+		if err := logboek.LogProcessInline(fmt.Sprintf("Importing stage %s", reference), logboek.LogProcessInlineOptions{}, func() error {
+			img, err := layoutImage(layoutPath, manifestStage.Digest)
+			if err != nil {
+				return err
+			}
+
+			if opts.StagesStorage == build.LocalStagesStorage {
+				return loadImageIntoDocker(img, reference)
+			}
+
+			return docker_registry.PushImage(reference, img)
+		}); err != nil {
+			return fmt.Errorf("unable to import stage %s: %s", manifestStage.Signature, err)
+		}
+	}
+
+	return nil
+}
+
+// exportImageArtifacts exports the signature and the provenance of the image if they are published
+func exportImageArtifacts(layoutPath layout.Path, imageRepo, digest string, imageRepoTags []string) ([]*ManifestArtifact, error) {
+	var artifacts []*ManifestArtifact
+	for _, tag := range imageArtifactsTags(digest) {
+		if !util.IsStringsContainValue(imageRepoTags, tag) {
+			continue
+		}
+
+		repoImage, err := docker_registry.GetRepoImage(imageRepo, tag)
+		if err != nil {
+			return nil, err
+		}
+
+		artifactDigest, err := appendImage(layoutPath, repoImage.Image, strings.Join([]string{imageRepo, tag}, ":"))
+		if err != nil {
+			return nil, err
+		}
+
+		artifacts = append(artifacts, &ManifestArtifact{Tag: tag, Digest: artifactDigest})
+	}
+
+	return artifacts, nil
+}
+
+// importImageArtifacts pushes the artifacts as is, so the signature stays valid for the image with the preserved digest
+func importImageArtifacts(layoutPath layout.Path, imageRepo string, manifestImage *ManifestImage) error {
+	for _, artifact := range manifestImage.Artifacts {
+		if !util.IsStringsContainValue(imageArtifactsTags(manifestImage.Digest), artifact.Tag) {
+			return fmt.Errorf("artifact %s does not belong to the image digest %s", artifact.Tag, manifestImage.Digest)
+		}
+
+		img, err := layoutImage(layoutPath, artifact.Digest)
+		if err != nil {
+			return err
+		}
+
+		if err := docker_registry.PushImage(strings.Join([]string{imageRepo, artifact.Tag}, ":"), img); err != nil {
+			return fmt.Errorf("unable to import artifact %s: %s", artifact.Tag, err)
+		}
+	}
+
+	return nil
+}
+
+func imageArtifactsTags(digest string) []string {
+	return []string{image_signing.SignatureTag(digest), build.ProvenanceTag(digest)}
+}
+
+func imageStagesChain(stages []*stages_info.Stage, imagesRepoManager ImagesRepoManager, imageName, tag string) ([]*stages_info.Stage, error) {
+	repoImage, err := docker_registry.GetRepoImage(imagesRepoManager.ImageRepo(imageName), imagesRepoManager.ImageRepoTag(imageName, tag))
+	if err != nil {
+		return nil, err
+	}
+
+	configFile, err := repoImage.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	lastStage := stages_info.FindStageByID(stages, configFile.ContainerConfig.Image)
+	if lastStage == nil {
+		return nil, fmt.Errorf("last stage %s of image %s is not found in the stages storage", configFile.ContainerConfig.Image, imageName)
+	}
+
+	return append([]*stages_info.Stage{lastStage}, stages_info.ParentChain(stages, lastStage)...), nil
+}
+
+func exportStage(layoutPath layout.Path, stagesStorage string, stage *stages_info.Stage) (string, error) {
+	if stagesStorage != build.LocalStagesStorage {
+		repoImage, err := docker_registry.GetRepoImage(stagesStorage, fmt.Sprintf(build.RepoImageStageTagFormat, stage.Signature))
+		if err != nil {
+			return "", err
+		}
+
+		return appendImage(layoutPath, repoImage.Image, stage.Name)
+	}
+
+	tmpFile, err := ioutil.TempFile("", "werf-stage-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if err := func() error {
+		defer tmpFile.Close()
+
+		rc, err := docker.ImageSave(stage.Name)
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		_, err = io.Copy(tmpFile, rc)
+		return err
+	}(); err != nil {
+		return "", fmt.Errorf("unable to save image %s: %s", stage.Name, err)
+	}
+
+	tag, err := name.NewTag(stage.Name)
+	if err != nil {
+		return "", err
+	}
+
+	img, err := tarball.ImageFromPath(tmpFile.Name(), &tag)
+	if err != nil {
+		return "", err
+	}
+
+	return appendImage(layoutPath, img, stage.Name)
+}
+
+func appendImage(layoutPath layout.Path, img v1.Image, reference string) (string, error) {
+	annotations := map[string]string{"org.opencontainers.image.ref.name": reference}
+	if err := layoutPath.AppendImage(img, layout.WithAnnotations(annotations)); err != nil {
+		return "", err
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return "", err
+	}
+
+	return digest.String(), nil
+}
+
+func layoutImage(layoutPath layout.Path, digest string) (v1.Image, error) {
+	hash, err := v1.NewHash(digest)
+	if err != nil {
+		return nil, err
+	}
+
+	return layoutPath.Image(hash)
+}
+
+func loadImageIntoDocker(img v1.Image, reference string) error {
+	tag, err := name.NewTag(reference)
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(tarball.Write(tag, img, pw))
+	}()
+
+	// the writer is blocked until the reader is closed if docker stops reading
+	if err := docker.ImageLoad(pr); err != nil {
+		pr.CloseWithError(err)
+		return err
+	}
+
+	return nil
+}
+
+func validateImageLabels(img v1.Image, manifestImage *ManifestImage) error {
+	configFile, err := img.ConfigFile()
+	if err != nil {
+		return err
+	}
+
+	labels := configFile.Config.Labels
+	if labels[imagePkg.WerfImageLabel] != "true" || labels[imagePkg.WerfImageNameLabel] != manifestImage.Name || labels[imagePkg.WerfTagStrategyLabel] == "" {
+		return fmt.Errorf("image is not published by werf: %s, %s and %s labels are expected", imagePkg.WerfImageLabel, imagePkg.WerfImageNameLabel, imagePkg.WerfTagStrategyLabel)
+	}
+
+	return nil
+}
+
+func readManifest(layoutDir string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(layoutDir, ManifestFileName))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", ManifestFileName, err)
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("bad %s: %s", ManifestFileName, err)
+	}
+
+	return manifest, nil
+}
@@ -0,0 +1,104 @@
+package images_archive
+
+import (
+	"archive/tar"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flant/go-containerregistry/pkg/v1/empty"
+	"github.com/flant/go-containerregistry/pkg/v1/layout"
+	"github.com/flant/go-containerregistry/pkg/v1/random"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "werf-images-archive-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	layoutDir := filepath.Join(tmpDir, "export")
+	layoutPath, err := layout.Write(layoutDir, empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := appendImage(layoutPath, img, "registry.example.com/project/backend:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(layoutDir, ManifestFileName), []byte(`{"project":"project","images":[{"name":"backend","tag":"v1","digest":"`+digest+`"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	archivePath := filepath.Join(tmpDir, "images.tar")
+	if err := writeTar(layoutDir, archivePath); err != nil {
+		t.Fatal(err)
+	}
+
+	importDir := filepath.Join(tmpDir, "import")
+	if err := readTar(archivePath, importDir); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := readManifest(importDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(manifest.Images) != 1 || manifest.Images[0].Name != "backend" || manifest.Images[0].Tag != "v1" {
+		t.Fatalf("unexpected manifest images %+v", manifest.Images)
+	}
+
+	importLayoutPath, err := layout.FromPath(importDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	importedImg, err := layoutImage(importLayoutPath, manifest.Images[0].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	importedDigest, err := importedImg.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if importedDigest.String() != digest {
+		t.Errorf("unexpected imported image digest %s, expected %s", importedDigest, digest)
+	}
+}
+
+func TestReadTarRejectsEntriesOutsideDir(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "werf-images-archive-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	archivePath := filepath.Join(tmpDir, "bad.tar")
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tw := tar.NewWriter(f)
+	if err := tw.WriteHeader(&tar.Header{Name: "../evil", Mode: 0644, Size: 0, Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+	f.Close()
+
+	if err := readTar(archivePath, filepath.Join(tmpDir, "import")); err == nil {
+		t.Errorf("expected error for entry outside of the target dir")
+	}
+}
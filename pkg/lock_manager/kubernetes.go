@@ -0,0 +1,298 @@
+package lock_manager
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/flant/logboek"
+
+	"github.com/flant/werf/pkg/util"
+)
+
+const (
+	LockNameAnnoName      = "werf.io/lock-name"
+	LockHolderAnnoName    = "werf.io/lock-holder"
+	LockRenewTimeAnnoName = "werf.io/lock-renew-time"
+	LockTTLAnnoName       = "werf.io/lock-ttl"
+
+	DefaultKubernetesLockTTL        = 60 * time.Second
+	DefaultKubernetesLockPollPeriod = 2 * time.Second
+)
+
+// KubernetesLockManager stores locks as ConfigMaps in the specified namespace, so processes on different hosts
+// working with the same cluster exclude each other. The lock holder renews the lock while it is held,
+// a lock which has not been renewed for TTL (e.g. the holder has been killed) can be taken by another process.
+// The operation under the lock is not interrupted when the lock is lost (taken over or not renewed for TTL),
+// Unlock returns an error in this case, so WithLock fails.
+// Locks are not reentrant: locking the name held by the manager fails immediately.
+type KubernetesLockManager struct {
+	Client    kubernetes.Interface
+	Namespace string
+	Holder    string
+
+	TTL        time.Duration
+	PollPeriod time.Duration
+
+	mutex    sync.Mutex
+	renewals map[string]*lockRenewal
+}
+
+type lockRenewal struct {
+	stop chan struct{}
+	done chan struct{}
+
+	// lostErr is set when the lock is lost, it can be read after done is closed
+	lostErr error
+}
+
+type lockTakenOverError struct {
+	holder string
+}
+
+func (e lockTakenOverError) Error() string {
+	return fmt.Sprintf("lock has been taken over by %s", e.holder)
+}
+
+func NewKubernetesLockManager(client kubernetes.Interface, namespace string) *KubernetesLockManager {
+	hostname, _ := os.Hostname()
+
+	return &KubernetesLockManager{
+		Client:     client,
+		Namespace:  namespace,
+		Holder:     fmt.Sprintf("%s/%s", hostname, uuid.NewV4().String()),
+		TTL:        DefaultKubernetesLockTTL,
+		PollPeriod: DefaultKubernetesLockPollPeriod,
+		renewals:   map[string]*lockRenewal{},
+	}
+}
+
+func (m *KubernetesLockManager) Lock(name string, opts LockOptions) error {
+	m.mutex.Lock()
+	_, isHeld := m.renewals[name]
+	m.mutex.Unlock()
+
+	if isHeld {
+		return fmt.Errorf("lock %q is already held by this process", name)
+	}
+
+	acquired, holder, err := m.tryAcquire(name)
+	if err != nil {
+		return err
+	}
+
+	if !acquired {
+		timeout := getTimeout(opts)
+		logProcessMsg := fmt.Sprintf("Waiting for locked resource %q held by %s", name, holder)
+		if err := logboek.LogProcessInline(logProcessMsg, logboek.LogProcessInlineOptions{}, func() error {
+			deadline := time.Now().Add(timeout)
+			for {
+				time.Sleep(m.PollPeriod)
+
+				acquired, holder, err = m.tryAcquire(name)
+				if err != nil {
+					return err
+				}
+
+				if acquired {
+					return nil
+				}
+
+				if time.Now().After(deadline) {
+					return fmt.Errorf("lock %q is held by %s: timeout %s exceeded", name, holder, timeout)
+				}
+			}
+		}); err != nil {
+			return err
+		}
+	}
+
+	m.startRenewal(name)
+
+	return nil
+}
+
+func (m *KubernetesLockManager) Unlock(name string) error {
+	m.mutex.Lock()
+	renewal, exist := m.renewals[name]
+	delete(m.renewals, name)
+	m.mutex.Unlock()
+
+	if !exist {
+		return fmt.Errorf("no such lock %q found", name)
+	}
+
+	close(renewal.stop)
+	<-renewal.done
+
+	cm, err := m.configMaps().Get(lockConfigMapName(name), metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			if renewal.lostErr != nil {
+				return renewal.lostErr
+			}
+
+			return fmt.Errorf("lock %q has been lost: lock has been deleted", name)
+		}
+
+		return fmt.Errorf("unable to get lock %q: %s", name, err)
+	}
+
+	if holder := cm.Annotations[LockHolderAnnoName]; holder != m.Holder {
+		return fmt.Errorf("lock %q has been lost: %s", name, lockTakenOverError{holder: holder})
+	}
+
+	if err := m.configMaps().Delete(cm.Name, &metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &cm.UID, ResourceVersion: &cm.ResourceVersion},
+	}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("unable to release lock %q: %s", name, err)
+	}
+
+	return renewal.lostErr
+}
+
+// tryAcquire returns whether the lock has been acquired and the current holder otherwise
+func (m *KubernetesLockManager) tryAcquire(name string) (bool, string, error) {
+	cm, err := m.configMaps().Get(lockConfigMapName(name), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		cm = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        lockConfigMapName(name),
+				Namespace:   m.Namespace,
+				Annotations: map[string]string{LockNameAnnoName: name},
+			},
+		}
+		m.setHolderAnnotations(cm)
+
+		if _, err := m.configMaps().Create(cm); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				return false, "another process", nil
+			}
+
+			return false, "", fmt.Errorf("unable to create lock %q: %s", name, err)
+		}
+
+		return true, "", nil
+	} else if err != nil {
+		return false, "", fmt.Errorf("unable to get lock %q: %s", name, err)
+	}
+
+	holder := cm.Annotations[LockHolderAnnoName]
+	if holder != "" && !isLockExpired(cm, time.Now()) {
+		return false, holder, nil
+	}
+
+	if holder != "" {
+		logboek.LogErrorF("WARNING: lock %q held by %s has expired\n", name, holder)
+	}
+
+	m.setHolderAnnotations(cm)
+	if _, err := m.configMaps().Update(cm); err != nil {
+		if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+			return false, "another process", nil
+		}
+
+		return false, "", fmt.Errorf("unable to update lock %q: %s", name, err)
+	}
+
+	return true, "", nil
+}
+
+func (m *KubernetesLockManager) startRenewal(name string) {
+	renewal := &lockRenewal{stop: make(chan struct{}), done: make(chan struct{})}
+
+	m.mutex.Lock()
+	m.renewals[name] = renewal
+	m.mutex.Unlock()
+
+	go func() {
+		defer close(renewal.done)
+
+		ticker := time.NewTicker(m.TTL / 3)
+		defer ticker.Stop()
+
+		lastRenewTime := time.Now()
+		for {
+			select {
+			case <-renewal.stop:
+				return
+			case <-ticker.C:
+				err := m.renew(name)
+				if err == nil {
+					lastRenewTime = time.Now()
+					continue
+				}
+
+				if _, isTakenOver := err.(lockTakenOverError); isTakenOver || apierrors.IsNotFound(err) || time.Since(lastRenewTime) > m.TTL {
+					renewal.lostErr = fmt.Errorf("lock %q has been lost: %s", name, err)
+					logboek.LogErrorF("WARNING: %s\n", renewal.lostErr)
+					return
+				}
+
+				logboek.LogErrorF("WARNING: unable to renew lock %q: %s\n", name, err)
+			}
+		}
+	}()
+}
+
+func (m *KubernetesLockManager) renew(name string) error {
+	cm, err := m.configMaps().Get(lockConfigMapName(name), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if holder := cm.Annotations[LockHolderAnnoName]; holder != m.Holder {
+		return lockTakenOverError{holder: holder}
+	}
+
+	m.setHolderAnnotations(cm)
+	_, err = m.configMaps().Update(cm)
+
+	return err
+}
+
+func (m *KubernetesLockManager) setHolderAnnotations(cm *v1.ConfigMap) {
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+
+	cm.Annotations[LockHolderAnnoName] = m.Holder
+	cm.Annotations[LockRenewTimeAnnoName] = time.Now().UTC().Format(time.RFC3339Nano)
+	cm.Annotations[LockTTLAnnoName] = m.TTL.String()
+}
+
+func (m *KubernetesLockManager) configMaps() corev1.ConfigMapInterface {
+	return m.Client.CoreV1().ConfigMaps(m.Namespace)
+}
+
+// isLockExpired treats a lock with broken annotations as expired.
+// The renew time is set by the clock of the holder host and compared with the local clock,
+// so clocks of the hosts sharing locks should be synchronized (e.g. by NTP) with the skew much less than TTL:
+// the lock is taken over earlier when the local clock is ahead of the holder clock and later otherwise
+func isLockExpired(cm *v1.ConfigMap, now time.Time) bool {
+	renewTime, err := time.Parse(time.RFC3339Nano, cm.Annotations[LockRenewTimeAnnoName])
+	if err != nil {
+		return true
+	}
+
+	ttl, err := time.ParseDuration(cm.Annotations[LockTTLAnnoName])
+	if err != nil {
+		return true
+	}
+
+	return now.After(renewTime.Add(ttl))
+}
+
+// lockConfigMapName returns a valid object name for an arbitrary lock name
+func lockConfigMapName(name string) string {
+	return fmt.Sprintf("werf-lock-%s", util.Sha256Hash(name)[:32])
+}
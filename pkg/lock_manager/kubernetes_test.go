@@ -0,0 +1,197 @@
+package lock_manager
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testNamespace = "kube-system"
+
+func newTestKubernetesLockManager(client kubernetes.Interface) *KubernetesLockManager {
+	m := NewKubernetesLockManager(client, testNamespace)
+	m.TTL = time.Minute
+	m.PollPeriod = 10 * time.Millisecond
+	return m
+}
+
+func getLockConfigMap(t *testing.T, client kubernetes.Interface, name string) *v1.ConfigMap {
+	cm, err := client.CoreV1().ConfigMaps(testNamespace).Get(lockConfigMapName(name), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unable to get lock configmap: %s", err)
+	}
+
+	return cm
+}
+
+func TestKubernetesLockManager_ExcludesOtherHolders(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	first := newTestKubernetesLockManager(client)
+	second := newTestKubernetesLockManager(client)
+
+	lockName := "helm_release.myrelease-kube_context."
+
+	if err := first.Lock(lockName, LockOptions{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cm := getLockConfigMap(t, client, lockName)
+	if cm.Annotations[LockHolderAnnoName] != first.Holder {
+		t.Errorf("expected holder %q, got %q", first.Holder, cm.Annotations[LockHolderAnnoName])
+	}
+	if cm.Annotations[LockNameAnnoName] != lockName {
+		t.Errorf("expected lock name %q, got %q", lockName, cm.Annotations[LockNameAnnoName])
+	}
+
+	if err := second.Lock(lockName, LockOptions{Timeout: 50 * time.Millisecond}); err == nil {
+		t.Fatalf("expected timeout error, lock is held by another holder")
+	}
+
+	if err := first.Unlock(lockName); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := second.Lock(lockName, LockOptions{Timeout: time.Second}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if cm := getLockConfigMap(t, client, lockName); cm.Annotations[LockHolderAnnoName] != second.Holder {
+		t.Errorf("expected holder %q, got %q", second.Holder, cm.Annotations[LockHolderAnnoName])
+	}
+
+	if err := second.Unlock(lockName); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := client.CoreV1().ConfigMaps(testNamespace).Get(lockConfigMapName(lockName), metav1.GetOptions{}); err == nil {
+		t.Errorf("expected lock configmap to be deleted on unlock")
+	}
+}
+
+func TestKubernetesLockManager_TakesOverExpiredLock(t *testing.T) {
+	lockName := "images-cleanup.registry.example.com/project"
+
+	client := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      lockConfigMapName(lockName),
+			Namespace: testNamespace,
+			Annotations: map[string]string{
+				LockNameAnnoName:      lockName,
+				LockHolderAnnoName:    "killed-runner/holder",
+				LockRenewTimeAnnoName: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339Nano),
+				LockTTLAnnoName:       time.Minute.String(),
+			},
+		},
+	})
+
+	m := newTestKubernetesLockManager(client)
+	if err := m.Lock(lockName, LockOptions{Timeout: 50 * time.Millisecond}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer m.Unlock(lockName)
+
+	if cm := getLockConfigMap(t, client, lockName); cm.Annotations[LockHolderAnnoName] != m.Holder {
+		t.Errorf("expected holder %q, got %q", m.Holder, cm.Annotations[LockHolderAnnoName])
+	}
+}
+
+func TestKubernetesLockManager_RenewsHeldLock(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	m := newTestKubernetesLockManager(client)
+	m.TTL = 150 * time.Millisecond
+
+	lockName := "stages-cleanup.project"
+	if err := m.Lock(lockName, LockOptions{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer m.Unlock(lockName)
+
+	time.Sleep(3 * m.TTL)
+
+	cm := getLockConfigMap(t, client, lockName)
+	if isLockExpired(cm, time.Now()) {
+		t.Errorf("expected held lock to be renewed, renew time %s", cm.Annotations[LockRenewTimeAnnoName])
+	}
+
+	other := newTestKubernetesLockManager(client)
+	if err := other.Lock(lockName, LockOptions{Timeout: 50 * time.Millisecond}); err == nil {
+		t.Errorf("expected renewed lock not to be taken over")
+	}
+}
+
+func TestLockConfigMapName(t *testing.T) {
+	name := lockConfigMapName("images-cleanup.registry.example.com:5000/Project")
+	if len(name) > 63 {
+		t.Errorf("name %q is too long", name)
+	}
+
+	if name != lockConfigMapName("images-cleanup.registry.example.com:5000/Project") {
+		t.Errorf("expected stable name")
+	}
+}
+
+func TestKubernetesLockManager_FailsOnTakeOverDuringRenewal(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	m := newTestKubernetesLockManager(client)
+	m.TTL = 150 * time.Millisecond
+
+	lockName := "helm_release.myrelease-release_storage_namespace.kube-system"
+
+	prevManager := manager
+	Init(m)
+	defer Init(prevManager)
+
+	err := WithLock(lockName, LockOptions{}, func() error {
+		// another process takes over the lock, e.g. the holder has been paused for longer than TTL
+		cm := getLockConfigMap(t, client, lockName)
+		cm.Annotations[LockHolderAnnoName] = "other-runner/holder"
+		cm.Annotations[LockRenewTimeAnnoName] = time.Now().UTC().Format(time.RFC3339Nano)
+		if _, err := client.CoreV1().ConfigMaps(testNamespace).Update(cm); err != nil {
+			t.Fatalf("unable to update lock configmap: %s", err)
+		}
+
+		time.Sleep(m.TTL)
+
+		return nil
+	})
+
+	if err == nil {
+		t.Fatalf("expected error when the lock has been taken over during the operation")
+	}
+
+	if cm := getLockConfigMap(t, client, lockName); cm.Annotations[LockHolderAnnoName] != "other-runner/holder" {
+		t.Errorf("expected lock of the new holder to be kept, got holder %q", cm.Annotations[LockHolderAnnoName])
+	}
+}
+
+func TestKubernetesLockManager_NotReentrant(t *testing.T) {
+	m := newTestKubernetesLockManager(fake.NewSimpleClientset())
+
+	lockName := "images_repo.registry.example.com/app"
+	if err := m.Lock(lockName, LockOptions{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	start := time.Now()
+	if err := m.Lock(lockName, LockOptions{Timeout: time.Hour}); err == nil {
+		t.Errorf("expected error on locking the held lock")
+	} else if time.Since(start) > time.Second {
+		t.Errorf("expected locking the held lock to fail immediately, took %s", time.Since(start))
+	}
+
+	if err := m.Unlock(lockName); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := m.Lock(lockName, LockOptions{}); err != nil {
+		t.Fatalf("expected released lock to be locked again: %s", err)
+	}
+
+	if err := m.Unlock(lockName); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,18 @@
+package lock_manager
+
+import "github.com/flant/shluz"
+
+// LocalLockManager uses file locks, which work only for processes on the same host
+type LocalLockManager struct{}
+
+func NewLocalLockManager() *LocalLockManager {
+	return &LocalLockManager{}
+}
+
+func (m *LocalLockManager) Lock(name string, opts LockOptions) error {
+	return shluz.Lock(name, shluz.LockOptions{Timeout: getTimeout(opts)})
+}
+
+func (m *LocalLockManager) Unlock(name string) error {
+	return shluz.Unlock(name)
+}
@@ -0,0 +1,69 @@
+package lock_manager
+
+import (
+	"time"
+
+	"github.com/flant/logboek"
+)
+
+const (
+	LocalBackend      = "local"
+	KubernetesBackend = "kubernetes"
+)
+
+var (
+	Backends = []string{LocalBackend, KubernetesBackend}
+
+	DefaultTimeout = 24 * time.Hour
+
+	manager LockManager = NewLocalLockManager()
+)
+
+type LockOptions struct {
+	Timeout time.Duration
+}
+
+// LockManager provides exclusive locks for resources which can be shared by several werf processes,
+// such as releases and repositories
+type LockManager interface {
+	Lock(name string, opts LockOptions) error
+	Unlock(name string) error
+}
+
+// Init sets the lock manager which is used by WithLock, local lock manager is used by default
+func Init(m LockManager) {
+	manager = m
+}
+
+// IsDistributed returns true if locks are shared by processes on different hosts
+func IsDistributed() bool {
+	_, isKubernetes := manager.(*KubernetesLockManager)
+	return isKubernetes
+}
+
+func WithLock(name string, opts LockOptions, f func() error) error {
+	if err := manager.Lock(name, opts); err != nil {
+		return err
+	}
+
+	err := f()
+
+	if unlockErr := manager.Unlock(name); unlockErr != nil {
+		if err != nil {
+			logboek.LogErrorF("WARNING: %s\n", unlockErr)
+			return err
+		}
+
+		return unlockErr
+	}
+
+	return err
+}
+
+func getTimeout(opts LockOptions) time.Duration {
+	if opts.Timeout != 0 {
+		return opts.Timeout
+	}
+
+	return DefaultTimeout
+}
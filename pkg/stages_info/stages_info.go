@@ -0,0 +1,309 @@
+package stages_info
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/flant/werf/pkg/build"
+	"github.com/flant/werf/pkg/docker"
+	"github.com/flant/werf/pkg/docker_registry"
+	imagePkg "github.com/flant/werf/pkg/image"
+	"github.com/flant/werf/pkg/tag_strategy"
+	"github.com/flant/werf/pkg/util"
+)
+
+type Stage struct {
+	Name      string `json:"name"`
+	ID        string `json:"id"`
+	ParentID  string `json:"parentId,omitempty"`
+	Signature string `json:"signature"`
+	// ParentSignature is empty for stages built by older werf versions
+	ParentSignature string            `json:"parentSignature,omitempty"`
+	ImageName       string            `json:"imageName,omitempty"`
+	StageName       string            `json:"stageName,omitempty"`
+	Size            int64             `json:"size"`
+	Created         time.Time         `json:"created"`
+	GitCommits      []string          `json:"gitCommits,omitempty"`
+	LinkedImages    []string          `json:"linkedImages,omitempty"`
+	Commands        []string          `json:"commands,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+}
+
+type ImagesRepoManager interface {
+	ImageRepo(imageName string) string
+}
+
+type Options struct {
+	ProjectName   string
+	StagesStorage string
+
+	// ImagesRepoManager and ImagesNames are optional and used to find published images linked to stages
+	ImagesRepoManager ImagesRepoManager
+	ImagesNames       []string
+}
+
+// ListStages returns stages of the project from the stages storage sorted by creation time, the latest first
+func ListStages(opts Options) ([]*Stage, error) {
+	var stages []*Stage
+	var err error
+
+	if opts.StagesStorage == build.LocalStagesStorage {
+		stages, err = localStages(opts.ProjectName)
+	} else {
+		stages, err = repoStages(opts.ProjectName, opts.StagesStorage)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ImagesRepoManager != nil {
+		if err := linkPublishedImages(stages, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	deriveImageNames(stages)
+
+	sort.SliceStable(stages, func(i, j int) bool { return stages[i].Created.After(stages[j].Created) })
+
+	return stages, nil
+}
+
+func FilterStagesByImageName(stages []*Stage, imageNames []string) []*Stage {
+	if len(imageNames) == 0 {
+		return stages
+	}
+
+	var res []*Stage
+	for _, stage := range stages {
+		if util.IsStringsContainValue(imageNames, stage.ImageName) {
+			res = append(res, stage)
+		}
+	}
+
+	return res
+}
+
+func FindStageBySignature(stages []*Stage, signature string) *Stage {
+	for _, stage := range stages {
+		if stage.Signature == signature {
+			return stage
+		}
+	}
+
+	return nil
+}
+
+func FindStageByID(stages []*Stage, id string) *Stage {
+	for _, stage := range stages {
+		if stage.ID == id {
+			return stage
+		}
+	}
+
+	return nil
+}
+
+// ParentChain returns the parent stages of the stage, the nearest parent first.
+// The parent is found by the recorded signature, image ID is used for stages built by older werf versions
+func ParentChain(stages []*Stage, stage *Stage) []*Stage {
+	var chain []*Stage
+
+	visited := map[*Stage]bool{stage: true}
+	for parent := findParentStage(stages, stage); parent != nil && !visited[parent]; parent = findParentStage(stages, parent) {
+		visited[parent] = true
+		chain = append(chain, parent)
+	}
+
+	return chain
+}
+
+func findParentStage(stages []*Stage, stage *Stage) *Stage {
+	if stage.ParentSignature != "" {
+		return FindStageBySignature(stages, stage.ParentSignature)
+	}
+
+	if stage.ParentID != "" {
+		return FindStageByID(stages, stage.ParentID)
+	}
+
+	return nil
+}
+
+// deriveImageNames sets the image name of stages built by older werf versions without werf-stage-image-name label,
+// the name is taken from the nearest descendant stage or published image
+func deriveImageNames(stages []*Stage) {
+	for _, stage := range stages {
+		if stage.ImageName == "" {
+			continue
+		}
+
+		for _, parent := range ParentChain(stages, stage) {
+			if _, hasLabel := parent.Labels[imagePkg.WerfStageImageNameLabel]; hasLabel || parent.ImageName != "" {
+				break
+			}
+
+			parent.ImageName = stage.ImageName
+		}
+	}
+}
+
+func localStages(projectName string) ([]*Stage, error) {
+	filterSet := filters.NewArgs()
+	filterSet.Add("label", fmt.Sprintf("%s=%s", imagePkg.WerfLabel, projectName))
+	filterSet.Add("reference", fmt.Sprintf(build.LocalImageStageImageNameFormat, projectName))
+
+	images, err := docker.Images(types.ImageListOptions{Filters: filterSet})
+	if err != nil {
+		return nil, err
+	}
+
+	var stages []*Stage
+	for _, img := range images {
+		for _, repoTag := range img.RepoTags {
+			parts := strings.SplitN(repoTag, ":", 2)
+			if len(parts) != 2 {
+				continue
+			}
+
+			stage, err := newStage(repoTag, img.ID, img.ParentID, parts[1], img.Size, time.Unix(img.Created, 0), img.Labels)
+			if err != nil {
+				return nil, err
+			}
+
+			stages = append(stages, stage)
+		}
+	}
+
+	return stages, nil
+}
+
+func repoStages(projectName, stagesStorage string) ([]*Stage, error) {
+	repoImages, err := docker_registry.ImagesByWerfImageLabel(stagesStorage, "false")
+	if err != nil {
+		return nil, err
+	}
+
+	var stages []*Stage
+	for _, repoImage := range repoImages {
+		configFile, err := repoImage.ConfigFile()
+		if err != nil {
+			return nil, err
+		}
+
+		if configFile.Config.Labels[imagePkg.WerfLabel] != projectName {
+			continue
+		}
+
+		manifest, err := repoImage.Manifest()
+		if err != nil {
+			return nil, err
+		}
+
+		var size int64
+		for _, layer := range manifest.Layers {
+			size += layer.Size
+		}
+
+		signature := strings.TrimPrefix(repoImage.Tag, strings.TrimSuffix(build.RepoImageStageTagFormat, "%s"))
+		name := strings.Join([]string{repoImage.Repository, repoImage.Tag}, ":")
+
+		stage, err := newStage(name, manifest.Config.Digest.String(), configFile.ContainerConfig.Image, signature, size, configFile.Created.Time, configFile.Config.Labels)
+		if err != nil {
+			return nil, err
+		}
+
+		stages = append(stages, stage)
+	}
+
+	return stages, nil
+}
+
+func newStage(name, id, parentID, signature string, size int64, created time.Time, labels map[string]string) (*Stage, error) {
+	parentSignature, err := build.StageParentSignature(labels)
+	if err != nil {
+		return nil, fmt.Errorf("stage %s: %s", name, err)
+	}
+
+	stage := &Stage{
+		Name:            name,
+		ID:              id,
+		ParentID:        parentID,
+		Signature:       signature,
+		ParentSignature: parentSignature,
+		ImageName:       labels[imagePkg.WerfStageImageNameLabel],
+		StageName:       labels[imagePkg.WerfStageNameLabel],
+		Size:            size,
+		Created:         created,
+		Labels:          labels,
+	}
+
+	for key, value := range labels {
+		if strings.HasPrefix(key, "werf-git-") && strings.HasSuffix(key, "-commit") && !util.IsStringsContainValue(stage.GitCommits, value) {
+			stage.GitCommits = append(stage.GitCommits, value)
+		}
+	}
+	sort.Strings(stage.GitCommits)
+
+	if data, ok := labels[imagePkg.WerfStageCommandsLabel]; ok {
+		if err := json.Unmarshal([]byte(data), &stage.Commands); err != nil {
+			return nil, fmt.Errorf("bad %s label of stage %s: %s", imagePkg.WerfStageCommandsLabel, name, err)
+		}
+	}
+
+	return stage, nil
+}
+
+func linkPublishedImages(stages []*Stage, opts Options) error {
+	var imagesRepos []string
+	for _, imageName := range opts.ImagesNames {
+		imageRepo := opts.ImagesRepoManager.ImageRepo(imageName)
+		if !util.IsStringsContainValue(imagesRepos, imageRepo) {
+			imagesRepos = append(imagesRepos, imageRepo)
+		}
+	}
+
+	for _, imageRepo := range imagesRepos {
+		repoImages, err := docker_registry.ImagesByWerfImageLabel(imageRepo, "true")
+		if err != nil {
+			return fmt.Errorf("unable to get images of repo %s: %s", imageRepo, err)
+		}
+
+		for _, repoImage := range repoImages {
+			configFile, err := repoImage.ConfigFile()
+			if err != nil {
+				return err
+			}
+
+			if stage := findPublishedImageStage(stages, repoImage.Tag, configFile.ContainerConfig.Image, configFile.Config.Labels); stage != nil {
+				stage.LinkedImages = append(stage.LinkedImages, strings.Join([]string{repoImage.Repository, repoImage.Tag}, ":"))
+
+				if stage.ImageName == "" {
+					stage.ImageName = configFile.Config.Labels[imagePkg.WerfImageNameLabel]
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// findPublishedImageStage returns the last stage of the published image by the parent image ID or by the tag of stages-signature tag strategy
+func findPublishedImageStage(stages []*Stage, tag, parentID string, labels map[string]string) *Stage {
+	if stage := FindStageByID(stages, parentID); stage != nil {
+		return stage
+	}
+
+	if labels[imagePkg.WerfTagStrategyLabel] == string(tag_strategy.StagesSignature) {
+		return FindStageBySignature(stages, tag)
+	}
+
+	return nil
+}
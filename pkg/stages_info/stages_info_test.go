@@ -0,0 +1,137 @@
+package stages_info
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewStage(t *testing.T) {
+	labels := map[string]string{
+		"werf":                  "project",
+		"werf-stage-name":       "install",
+		"werf-stage-image-name": "backend",
+		"werf-git-aaa-commit":   "c2",
+		"werf-git-bbb-commit":   "c1",
+		"werf-git-ccc-commit":   "c2",
+		"werf-git-aaa-type":     "directory",
+		"werf-stage-commands":   `["npm ci","npm run build"]`,
+	}
+
+	stage, err := newStage("werf-stages-storage/project:sig", "id", "parent", "sig", 10, time.Unix(0, 0), labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stage.ImageName != "backend" || stage.StageName != "install" {
+		t.Errorf("unexpected image name %q and stage name %q", stage.ImageName, stage.StageName)
+	}
+
+	if expected := []string{"c1", "c2"}; !reflect.DeepEqual(stage.GitCommits, expected) {
+		t.Errorf("unexpected git commits %v, expected %v", stage.GitCommits, expected)
+	}
+
+	if expected := []string{"npm ci", "npm run build"}; !reflect.DeepEqual(stage.Commands, expected) {
+		t.Errorf("unexpected commands %v, expected %v", stage.Commands, expected)
+	}
+}
+
+func TestParentChain(t *testing.T) {
+	stages := []*Stage{
+		{ID: "3", ParentID: "2", Signature: "s3"},
+		{ID: "1", ParentID: "base", Signature: "s1"},
+		{ID: "2", ParentID: "1", Signature: "s2"},
+	}
+
+	var signatures []string
+	for _, parent := range ParentChain(stages, stages[0]) {
+		signatures = append(signatures, parent.Signature)
+	}
+
+	if expected := []string{"s2", "s1"}; !reflect.DeepEqual(signatures, expected) {
+		t.Errorf("unexpected parent chain %v, expected %v", signatures, expected)
+	}
+}
+
+func TestParentChain_BySignature(t *testing.T) {
+	// remote stages have image IDs of the building host, the recorded parent signature is used instead
+	stages := []*Stage{
+		{ID: "remote-3", ParentID: "local-2", Signature: "s3", ParentSignature: "s2"},
+		{ID: "remote-2", ParentID: "local-1", Signature: "s2", ParentSignature: "s1"},
+		{ID: "remote-1", ParentID: "remote-0", Signature: "s1"},
+		{ID: "remote-0", ParentID: "base", Signature: "s0"},
+	}
+
+	var signatures []string
+	for _, parent := range ParentChain(stages, stages[0]) {
+		signatures = append(signatures, parent.Signature)
+	}
+
+	if expected := []string{"s2", "s1", "s0"}; !reflect.DeepEqual(signatures, expected) {
+		t.Errorf("unexpected parent chain %v, expected %v", signatures, expected)
+	}
+}
+
+func TestNewStage_ParentSignature(t *testing.T) {
+	labels := map[string]string{
+		"werf-stage-signature-inputs": `{"prevStageSignature":"parent-sig","buildCacheVersion":"1"}`,
+	}
+
+	stage, err := newStage("werf-stages-storage/project:sig", "id", "parent", "sig", 10, time.Unix(0, 0), labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stage.ParentSignature != "parent-sig" {
+		t.Errorf("unexpected parent signature %q", stage.ParentSignature)
+	}
+
+	if _, err := newStage("werf-stages-storage/project:sig", "id", "parent", "sig", 10, time.Unix(0, 0), map[string]string{"werf-stage-signature-inputs": "{"}); err == nil {
+		t.Errorf("expected error on bad signature inputs label")
+	}
+}
+
+func TestFindPublishedImageStage(t *testing.T) {
+	stages := []*Stage{
+		{ID: "1", Signature: "s1"},
+		{ID: "2", Signature: "s2"},
+	}
+
+	if stage := findPublishedImageStage(stages, "v1.0", "2", nil); stage != stages[1] {
+		t.Errorf("expected the stage found by parent image id, got %+v", stage)
+	}
+
+	// the image is published from the pulled stage with another local image id
+	if stage := findPublishedImageStage(stages, "s1", "local-id", map[string]string{"werf-tag-strategy": "stages-signature"}); stage != stages[0] {
+		t.Errorf("expected the stage found by stages-signature tag, got %+v", stage)
+	}
+
+	if stage := findPublishedImageStage(stages, "s1", "local-id", map[string]string{"werf-tag-strategy": "git-branch"}); stage != nil {
+		t.Errorf("expected no stage, got %+v", stage)
+	}
+}
+
+func TestDeriveImageNames(t *testing.T) {
+	stages := []*Stage{
+		// built by the current werf version
+		{ID: "4", ParentID: "3", Signature: "s4", ImageName: "backend", Labels: map[string]string{"werf-stage-image-name": "backend"}},
+		// built by older werf versions
+		{ID: "3", ParentID: "2", Signature: "s3"},
+		{ID: "2", ParentID: "1", Signature: "s2"},
+		// base image stage with the published image name
+		{ID: "1", Signature: "s1", ImageName: "base"},
+		// not linked to anything
+		{ID: "5", Signature: "s5"},
+	}
+
+	deriveImageNames(stages)
+
+	var imageNames []string
+	for _, stage := range stages {
+		imageNames = append(imageNames, stage.ImageName)
+	}
+
+	if expected := []string{"backend", "backend", "backend", "base", ""}; !reflect.DeepEqual(imageNames, expected) {
+		t.Errorf("unexpected image names %v, expected %v", imageNames, expected)
+	}
+}
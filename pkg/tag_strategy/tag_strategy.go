@@ -7,4 +7,7 @@ const (
 	GitTag    TagStrategy = "git-tag"
 	GitBranch TagStrategy = "git-branch"
 	GitCommit TagStrategy = "git-commit"
+
+	// StagesSignature tags each image by the signature of its last stage, so identical content has the same tag
+	StagesSignature TagStrategy = "stages-signature"
 )
@@ -0,0 +1,72 @@
+package true_git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const devCommitDate = "2000-01-01T00:00:00+0000"
+
+// CreateDevCommit creates a commit on top of the parent commit with tracked and untracked but not ignored changes of the work tree.
+// Repo index and refs are not changed. The same work tree state always gives the same commit.
+func CreateDevCommit(repoDir, workTreeDir, parentCommit string) (string, error) {
+	indexFile, err := ioutil.TempFile("", "werf-dev-index-")
+	if err != nil {
+		return "", fmt.Errorf("unable to create temporary index file: %s", err)
+	}
+	indexFilePath := indexFile.Name()
+	indexFile.Close()
+	defer os.Remove(indexFilePath)
+
+	// reuse stat info of the repo index to avoid rehashing of all work tree files
+	if data, err := ioutil.ReadFile(filepath.Join(repoDir, "index")); err == nil {
+		if err := ioutil.WriteFile(indexFilePath, data, 0644); err != nil {
+			return "", fmt.Errorf("unable to write %s: %s", indexFilePath, err)
+		}
+	} else if os.IsNotExist(err) {
+		os.Remove(indexFilePath)
+		if _, err := runDevCommitGit(repoDir, workTreeDir, indexFilePath, "read-tree", parentCommit); err != nil {
+			return "", err
+		}
+	} else {
+		return "", fmt.Errorf("unable to read repo index: %s", err)
+	}
+
+	if _, err := runDevCommitGit(repoDir, workTreeDir, indexFilePath, "add", "--all"); err != nil {
+		return "", err
+	}
+
+	tree, err := runDevCommitGit(repoDir, workTreeDir, indexFilePath, "write-tree")
+	if err != nil {
+		return "", err
+	}
+
+	return runDevCommitGit(repoDir, workTreeDir, indexFilePath, "commit-tree", tree, "-p", parentCommit, "-m", "werf dev")
+}
+
+func runDevCommitGit(repoDir, workTreeDir, indexFilePath string, args ...string) (string, error) {
+	gitArgs := append([]string{"-c", "core.autocrlf=false", "--git-dir", repoDir, "--work-tree", workTreeDir}, args...)
+
+	cmd := exec.Command("git", gitArgs...)
+	cmd.Dir = workTreeDir
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("GIT_INDEX_FILE=%s", indexFilePath),
+		"GIT_AUTHOR_NAME=werf",
+		"GIT_AUTHOR_EMAIL=werf@flant.com",
+		fmt.Sprintf("GIT_AUTHOR_DATE=%s", devCommitDate),
+		"GIT_COMMITTER_NAME=werf",
+		"GIT_COMMITTER_EMAIL=werf@flant.com",
+		fmt.Sprintf("GIT_COMMITTER_DATE=%s", devCommitDate),
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("'git %s' failed: %s:\n%s", strings.Join(args, " "), err, output)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
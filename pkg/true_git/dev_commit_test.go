@@ -0,0 +1,74 @@
+package true_git
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dev commit", func() {
+	var workTreeDir string
+	var headCommit string
+
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = workTreeDir
+		output, err := cmd.CombinedOutput()
+		Ω(err).ShouldNot(HaveOccurred(), string(output))
+		return string(output)
+	}
+
+	writeFile := func(path, data string) {
+		Ω(ioutil.WriteFile(filepath.Join(workTreeDir, path), []byte(data), 0644)).Should(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		workTreeDir, err = ioutil.TempDir("", "werf-dev-commit-test-")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		git("init", "-q")
+		writeFile(".gitignore", "ignored\n")
+		writeFile("tracked", "1")
+		git("add", ".")
+		git("commit", "-q", "-m", "initial")
+		headCommit = git("rev-parse", "HEAD")[:40]
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(workTreeDir)
+	})
+
+	It("should contain tracked and untracked but not ignored changes", func() {
+		writeFile("tracked", "2")
+		writeFile("untracked", "3")
+		writeFile("ignored", "4")
+
+		commit, err := CreateDevCommit(filepath.Join(workTreeDir, ".git"), workTreeDir, headCommit)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(git("rev-parse", commit+"^")[:40]).Should(Equal(headCommit))
+		Ω(git("show", commit+":tracked")).Should(Equal("2"))
+		Ω(git("show", commit+":untracked")).Should(Equal("3"))
+		Ω(git("ls-tree", "--name-only", commit)).ShouldNot(ContainSubstring("ignored\n"))
+
+		Ω(git("status", "--porcelain")).Should(ContainSubstring("?? untracked"))
+		Ω(git("rev-parse", "HEAD")[:40]).Should(Equal(headCommit))
+	})
+
+	It("should be the same for the same work tree", func() {
+		writeFile("tracked", "2")
+
+		commit1, err := CreateDevCommit(filepath.Join(workTreeDir, ".git"), workTreeDir, headCommit)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		commit2, err := CreateDevCommit(filepath.Join(workTreeDir, ".git"), workTreeDir, headCommit)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(commit1).Should(Equal(commit2))
+	})
+})
@@ -0,0 +1,22 @@
+package true_git
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// IsAncestor checks that the ancestor commit is reachable from the descendant commit with 'git merge-base --is-ancestor'
+func IsAncestor(repoDir, ancestorCommit, descendantCommit string) (bool, error) {
+	cmd := exec.Command("git", "--git-dir", repoDir, "merge-base", "--is-ancestor", ancestorCommit, descendantCommit)
+
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return true, nil
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+
+	return false, fmt.Errorf("'git merge-base --is-ancestor' failed: %s:\n%s", err, output)
+}
@@ -0,0 +1,27 @@
+package secretvalues
+
+import "io"
+
+type maskingWriter struct {
+	writer           io.Writer
+	secretValuesFunc func() []string
+}
+
+// NewMaskingWriter returns the writer which masks the secret values in the written data.
+// The data is masked chunk by chunk, so the value split between two writes is not masked
+func NewMaskingWriter(w io.Writer, secretValuesFunc func() []string) io.Writer {
+	return &maskingWriter{writer: w, secretValuesFunc: secretValuesFunc}
+}
+
+func (w *maskingWriter) Write(p []byte) (int, error) {
+	secretValues := w.secretValuesFunc()
+	if len(secretValues) == 0 {
+		return w.writer.Write(p)
+	}
+
+	if _, err := w.writer.Write([]byte(MaskSecretValuesInString(secretValues, string(p)))); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,28 @@
+package secretvalues
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMaskingWriter(t *testing.T) {
+	var secretValues []string
+	buf := &bytes.Buffer{}
+	w := NewMaskingWriter(buf, func() []string { return secretValues })
+
+	_, _ = w.Write([]byte("token=s3cr3t\n"))
+
+	secretValues = []string{"s3cr3t"}
+	n, err := w.Write([]byte("token=s3cr3t\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != len("token=s3cr3t\n") {
+		t.Errorf("unexpected written bytes count %d", n)
+	}
+
+	if buf.String() != "token=s3cr3t\ntoken=***\n" {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+}